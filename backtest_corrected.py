@@ -9,13 +9,24 @@ CORRECTED TIMING:
       5 min before close  ≈ secs_left 594
       10 min before close ≈ secs_left 894
 
-Usage: python3 backtest_corrected.py [--mins-before-close=5] [--threshold=68] data/*.jsonl
+LATENCY:
+  --data-latency and --submit-latency delay the fill past the decision tick
+  (see btcdata/latency.py for the distributions). The side is still chosen on
+  the decision tick, but the entry price comes from the first tick after the
+  delay; orders that would land after market close are counted as missed.
+
+Usage: python3 backtest_corrected.py [--mins-before-close=5] [--threshold=68]
+           [--data-latency=MS] [--submit-latency=MS] [--seed=N] data/*.jsonl
 """
 
 import json
+import random
 import sys
 from collections import defaultdict
 
+from btcdata.latency import NONE, Latency, delayed_tick
+from btcdata.ticks import iter_markets
+
 SETTLEMENT_DELAY = 294  # secs_left when market closes
 
 def calculate_no_ask(yes_bid):
@@ -25,6 +36,9 @@ def main():
     # Parse args
     mins_before = 5
     threshold = 68
+    data_latency = NONE
+    submit_latency = NONE
+    seed = 0
     files = []
 
     for arg in sys.argv[1:]:
@@ -32,14 +46,22 @@ def main():
             mins_before = int(arg.split("=")[1])
         elif arg.startswith("--threshold="):
             threshold = int(arg.split("=")[1])
+        elif arg.startswith("--data-latency="):
+            data_latency = Latency.parse(arg.split("=", 1)[1])
+        elif arg.startswith("--submit-latency="):
+            submit_latency = Latency.parse(arg.split("=", 1)[1])
+        elif arg.startswith("--seed="):
+            seed = int(arg.split("=")[1])
         else:
             files.append(arg)
 
     if not files:
-        print("Usage: python3 backtest_corrected.py [--mins-before-close=5] [--threshold=68] data/*.jsonl")
+        print("Usage: python3 backtest_corrected.py [--mins-before-close=5] [--threshold=68] "
+              "[--data-latency=MS] [--submit-latency=MS] [--seed=N] data/*.jsonl")
         sys.exit(1)
 
     target_secs_left = SETTLEMENT_DELAY + (mins_before * 60)
+    rng = random.Random(seed)
 
     # Group ticks by market ticker
     markets = defaultdict(list)
@@ -51,7 +73,7 @@ def main():
                 if tick.get("type") != "tick":
                     continue
 
-                for market in tick.get("markets", []):
+                for market in iter_markets(tick):
                     ticker = market["ticker"]
                     strike = market.get("strike", 0)
                     if strike == 0:
@@ -71,6 +93,7 @@ def main():
     trades = []
     skipped_no_entry = 0
     skipped_no_settlement = 0
+    missed_fill = 0
 
     for ticker, ticks in markets.items():
         ticks.sort(key=lambda t: t["ts"])
@@ -78,7 +101,7 @@ def main():
         # Find the first tick at or below target secs_left
         # ONLY use ticks before market close (secs_left > SETTLEMENT_DELAY)
         entry_tick = None
-        for tick in ticks:
+        for i, tick in enumerate(ticks):
             if tick["secs_left"] <= target_secs_left and tick["secs_left"] > SETTLEMENT_DELAY:
                 entry_tick = tick
                 break
@@ -92,13 +115,21 @@ def main():
 
         if yes_ask >= threshold:
             side = "YES"
-            entry_price = yes_ask
         elif no_ask >= threshold:
             side = "NO"
-            entry_price = no_ask
         else:
             continue  # No trade
 
+        # The order hits the book after the data and submission delays
+        fill_tick, delay_ms = delayed_tick(ticks, i, data_latency, submit_latency, rng)
+        if fill_tick is None or fill_tick["secs_left"] <= SETTLEMENT_DELAY:
+            missed_fill += 1
+            continue
+        if side == "YES":
+            entry_price = fill_tick["yes_ask"]
+        else:
+            entry_price = calculate_no_ask(fill_tick["yes_bid"])
+
         # Find settlement
         settlement_result = ""
         for tick in reversed(ticks):
@@ -133,6 +164,8 @@ def main():
             "ticker": ticker,
             "side": side,
             "entry_price": entry_price,
+            "slippage": entry_price - (yes_ask if side == "YES" else no_ask),
+            "delay_ms": delay_ms,
             "winner": winner,
             "pnl": pnl,
             "settlement_source": "kalshi" if settlement_result else "brti",
@@ -141,6 +174,8 @@ def main():
     if not trades:
         print(f"\nNo trades (no markets with >={threshold}c at {mins_before}min before close)")
         print(f"Skipped (no entry tick): {skipped_no_entry}")
+        if missed_fill:
+            print(f"Missed (filled after close): {missed_fill}")
         return
 
     total_pnl = sum(t["pnl"] for t in trades)
@@ -155,6 +190,13 @@ def main():
     print(f"Total P&L: {total_pnl:.2f}c (${total_pnl/100:.2f})")
     print(f"Avg P&L per trade: {total_pnl/len(trades):.2f}c")
 
+    if data_latency or submit_latency:
+        slippage = sum(t["slippage"] for t in trades)
+        delay = sum(t["delay_ms"] for t in trades)
+        print(f"Latency: data {data_latency}, submit {submit_latency} (seed {seed})")
+        print(f"  Avg delay: {delay/len(trades):.0f}ms, avg slippage: {slippage/len(trades):+.2f}c")
+        print(f"  Missed (filled after close): {missed_fill}")
+
     if skipped_no_settlement > 0:
         print(f"Note: {skipped_no_settlement} used BRTI fallback")
 
@@ -165,7 +207,8 @@ def main():
     print("Individual trades:")
     for t in trades:
         m = "+" if t["settlement_source"] == "kalshi" else "~"
-        print(f"  {m} {t['ticker']}: {t['side']} @{t['entry_price']}c -> {t['winner']} wins -> P&L: {t['pnl']:+.0f}c")
+        slip = f" ({t['slippage']:+d}c slip)" if t["slippage"] else ""
+        print(f"  {m} {t['ticker']}: {t['side']} @{t['entry_price']}c{slip} -> {t['winner']} wins -> P&L: {t['pnl']:+.0f}c")
 
 if __name__ == "__main__":
     main()
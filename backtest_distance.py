@@ -27,6 +27,8 @@ import json
 import sys
 from collections import defaultdict
 
+from btcdata.ticks import iter_markets
+
 # ── Constants ──────────────────────────────────────────────────────────
 MARKET_CLOSE_OFFSET = 294  # seconds before settlement when market closes
 
@@ -100,7 +102,7 @@ def load_data(filepaths):
                 if brti == 0:
                     continue
 
-                for market in tick.get('markets', []):
+                for market in iter_markets(tick):
                     ticker = market['ticker']
                     strike = market.get('strike', 0)
                     if strike == 0:
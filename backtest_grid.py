@@ -29,6 +29,8 @@ import sys
 import math
 from collections import defaultdict
 
+from btcdata.ticks import iter_markets
+
 
 def main():
     if len(sys.argv) < 2:
@@ -59,7 +61,7 @@ def main():
                 ts = tick['ts']
                 brti = tick['brti']
 
-                for market in tick.get('markets', []):
+                for market in iter_markets(tick):
                     ticker = market['ticker']
                     strike = market.get('strike', 0)
                     if strike == 0:
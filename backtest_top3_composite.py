@@ -18,6 +18,8 @@ import json
 import sys
 from collections import defaultdict
 
+from btcdata.ticks import iter_markets
+
 
 SETTLEMENT_DELAY = 294  # secs_left when market closes
 
@@ -58,7 +60,7 @@ def load_data(files):
                 bitstamp = tick.get("bitstamp", 0)
                 binance = tick.get("binance", 0)
 
-                for market in tick.get("markets", []):
+                for market in iter_markets(tick):
                     ticker = market["ticker"]
                     strike = market.get("strike", 0)
                     if strike == 0:
@@ -1 +1,12 @@
-from .loader import load_day, load_days, load_all, available_dates
+from .ticks import iter_markets
+
+_LOADER_NAMES = {"load_day", "load_days", "load_all", "available_dates"}
+
+
+def __getattr__(name):
+    # The loaders need pandas; import them on first use so scripts that only
+    # want iter_markets run without it.
+    if name in _LOADER_NAMES:
+        from . import loader
+        return getattr(loader, name)
+    raise AttributeError(f"module {__name__!r} has no attribute {name!r}")
@@ -0,0 +1,110 @@
+"""Latency models for backtests.
+
+A live strategy never trades on the tick it decides on: the tick reaches it
+some milliseconds after the collector wrote it (data latency), and its order
+reaches Kalshi some milliseconds after that (submission latency). On a
+15-minute binary the book can move a lot in that gap, so zero-latency
+backtests fill at prices nobody could have had. ``delayed_tick`` finds the
+tick an order would actually have hit.
+
+Latencies are given as strings so scripts can take them straight from the
+command line:
+
+    250              fixed 250 ms
+    100-400          uniform between 100 and 400 ms
+    lognormal:200:0.5
+                     lognormal with a 200 ms median and sigma 0.5
+    exp:150          exponential with a 150 ms mean
+"""
+
+import math
+import random
+from datetime import datetime, timezone
+
+
+class Latency:
+    """A latency distribution in milliseconds."""
+
+    def __init__(self, kind, a=0.0, b=0.0):
+        self.kind = kind
+        self.a = a
+        self.b = b
+
+    @classmethod
+    def parse(cls, spec):
+        """Parse one of the forms in the module docstring."""
+        spec = spec.strip()
+        try:
+            if spec.startswith("lognormal:"):
+                _, median, sigma = spec.split(":")
+                return cls("lognormal", float(median), float(sigma))
+            if spec.startswith("exp:"):
+                return cls("exp", float(spec[len("exp:"):]))
+            if "-" in spec:
+                lo, hi = spec.split("-")
+                lo, hi = float(lo), float(hi)
+                if lo > hi:
+                    raise ValueError
+                return cls("uniform", lo, hi)
+            return cls("fixed", float(spec))
+        except ValueError:
+            raise ValueError(f"bad latency {spec!r}: want MS, LO-HI, "
+                             "lognormal:MEDIAN:SIGMA or exp:MEAN") from None
+
+    def sample(self, rng=random):
+        """Draw one latency in milliseconds."""
+        if self.kind == "uniform":
+            return rng.uniform(self.a, self.b)
+        if self.kind == "lognormal":
+            return rng.lognormvariate(math.log(self.a), self.b) if self.a > 0 else 0.0
+        if self.kind == "exp":
+            return rng.expovariate(1 / self.a) if self.a > 0 else 0.0
+        return self.a
+
+    def __bool__(self):
+        return self.a > 0 or self.b > 0
+
+    def __str__(self):
+        if self.kind == "uniform":
+            return f"{self.a:g}-{self.b:g}ms"
+        if self.kind == "lognormal":
+            return f"lognormal(median {self.a:g}ms, sigma {self.b:g})"
+        if self.kind == "exp":
+            return f"exp(mean {self.a:g}ms)"
+        return f"{self.a:g}ms"
+
+
+NONE = Latency("fixed")
+
+
+def parse_ts(ts):
+    """Parse a record's RFC 3339 ``ts`` (nanosecond precision) as UTC."""
+    if ts.endswith("Z"):
+        ts = ts[:-1] + "+00:00"
+    head, sep, frac = ts.partition(".")
+    if sep:
+        # datetime takes at most microseconds; drop the extra digits.
+        i = 0
+        while i < len(frac) and frac[i].isdigit():
+            i += 1
+        head = f"{head}.{frac[:i][:6].ljust(6, '0')}{frac[i:]}"
+    return datetime.fromisoformat(head).astimezone(timezone.utc)
+
+
+def delayed_tick(ticks, i, data=NONE, submit=NONE, rng=random):
+    """Return the tick an order decided on ``ticks[i]`` would fill against.
+
+    ``ticks`` is one market's ticks in time order, each with a ``ts``. The
+    order reaches the book ``data`` plus ``submit`` milliseconds after the
+    decision tick was recorded, and fills against the first tick at or after
+    that moment. Returns ``(tick, delay_ms)``, with ``tick`` None when the
+    market has no tick that late.
+    """
+    delay = data.sample(rng) + submit.sample(rng)
+    if delay <= 0:
+        return ticks[i], 0.0
+    due = parse_ts(ticks[i]["ts"]).timestamp() + delay / 1000
+    for tick in ticks[i + 1:]:
+        if parse_ts(tick["ts"]).timestamp() >= due:
+            return tick, delay
+    return None, delay
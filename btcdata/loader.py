@@ -11,6 +11,8 @@ from pathlib import Path
 
 import pandas as pd
 
+from .ticks import apply_delta, iter_markets, restore_books
+
 DATA_DIR = Path(__file__).resolve().parent.parent / "data"
 PARQUET_DIR = DATA_DIR / "parquet"
 PREFIX = "kxbtc15m"
@@ -73,8 +75,14 @@ def load_all(prefer_parquet: bool = True) -> pd.DataFrame:
 
 
 def _load_jsonl(path: Path, compressed: bool) -> pd.DataFrame:
-    """Parse a JSONL (or .jsonl.gz) file into a flattened DataFrame."""
+    """Parse a JSONL (or .jsonl.gz) file into a flattened DataFrame.
+
+    Delta-encoded files are expanded to one row per market per tick, as
+    pkg/ticks reads them.
+    """
     rows = []
+    prev_markets = {}
+    keyframe_seen = False
     opener = gzip.open if compressed else open
     with opener(path, "rt") as f:
         for line in f:
@@ -82,7 +90,15 @@ def _load_jsonl(path: Path, compressed: bool) -> pd.DataFrame:
             if not line:
                 continue
             tick = json.loads(line)
-            if tick.get("type") != "tick":
+            kind = tick.get("type")
+            if kind == "delta":
+                if not keyframe_seen:
+                    raise ValueError(f"{path}: delta at {tick.get('ts')} before any keyframe")
+                tick = apply_delta(tick, prev_markets)
+            elif kind == "tick":
+                keyframe_seen = True
+                restore_books(tick, prev_markets)
+            else:
                 continue
             base = {
                 "ts": tick["ts"],
@@ -90,8 +106,12 @@ def _load_jsonl(path: Path, compressed: bool) -> pd.DataFrame:
                 "coinbase": tick.get("coinbase", 0.0),
                 "kraken": tick.get("kraken", 0.0),
                 "bitstamp": tick.get("bitstamp", 0.0),
+                "source": tick.get("source", ""),
             }
-            for mkt in tick.get("markets", []):
+            window = tick.get("window") or {}
+            for k in ("open", "high", "low", "twap"):
+                base[f"window_{k}"] = window.get(k, 0.0)
+            for mkt in iter_markets(tick):
                 row = {
                     **base,
                     "ticker": mkt.get("ticker", ""),
@@ -101,11 +121,20 @@ def _load_jsonl(path: Path, compressed: bool) -> pd.DataFrame:
                     "volume": mkt.get("volume", 0),
                     "open_interest": mkt.get("open_interest", 0),
                     "strike": mkt.get("strike", 0.0),
+                    "strike_type": mkt.get("strike_type", ""),
+                    "strike_floor": mkt.get("strike_floor", 0.0),
+                    "strike_cap": mkt.get("strike_cap", 0.0),
                     "secs_left": mkt.get("secs_left", 0),
                     "status": mkt.get("status", ""),
                     "result": mkt.get("result", ""),
+                    "settlement_value": mkt.get("settlement_value", 0.0),
                     "yes_book": json.dumps(mkt["yes_book"]) if "yes_book" in mkt else "",
                     "no_book": json.dumps(mkt["no_book"]) if "no_book" in mkt else "",
+                    "book_stale": mkt.get("book_stale", False),
+                    "anomaly": mkt.get("anomaly", ""),
+                    "ticker_ts": mkt.get("ticker_ts", ""),
+                    "book_ts": mkt.get("book_ts", ""),
+                    "fresh": mkt.get("fresh", False),
                 }
                 rows.append(row)
 
@@ -0,0 +1,71 @@
+"""Dependency-free helpers for raw tick records."""
+
+
+def iter_markets(tick):
+    """Yield every market dict in a tick record.
+
+    Schema v6+ ticks group markets into ``events`` (one per 15-minute
+    window, each with a strike-ordered ladder); older ticks have a flat
+    ``markets`` list. The yielded dicts are the record's own, so callers
+    may patch them in place.
+    """
+    yield from tick.get("markets", [])
+    for event in tick.get("events", []):
+        yield from event.get("markets", [])
+
+
+def restore_books(tick, prev):
+    """Fill in books the collector left out of ``unchanged`` markets (v12+).
+
+    ``prev`` maps ticker to the market dict seen in the previous tick of the
+    same file; it is updated in place, so pass the same dict for every tick
+    in order and start a fresh one per file.
+    """
+    cur = {}
+    for mkt in iter_markets(tick):
+        if mkt.pop("unchanged", False) and mkt["ticker"] in prev:
+            old = prev[mkt["ticker"]]
+            for key in ("yes_book", "no_book", "book"):
+                if key in old:
+                    mkt[key] = old[key]
+        cur[mkt["ticker"]] = mkt
+    prev.clear()
+    prev.update(cur)
+
+
+def apply_delta(delta, prev):
+    """Expand a ``delta`` record (``--delta``) into a full tick.
+
+    This mirrors pkg/ticks' DeltaDecoder. ``prev`` is the same per-file
+    dict restore_books keeps, holding the markets of the last keyframe or
+    expanded delta; it is updated in place. Deltas carry no events, so the
+    returned tick lists its markets flat under ``markets``; book features
+    (``book``) are dropped rather than recomputed.
+    """
+    for ticker in delta.get("removed", []):
+        prev.pop(ticker, None)
+    for change in delta.get("markets", []):
+        mkt = dict(prev.get(change["ticker"], {}))
+        for key, value in change.items():
+            if key in ("yes_book", "no_book"):
+                mkt[key] = _apply_levels(mkt.get(key, []), value)
+            else:
+                mkt[key] = value
+        mkt.pop("book", None)
+        prev[change["ticker"]] = mkt
+
+    tick = {k: v for k, v in delta.items() if k not in ("markets", "removed")}
+    tick["type"] = "tick"
+    tick["markets"] = [dict(m) for m in prev.values()]
+    return tick
+
+
+def _apply_levels(book, changes):
+    """Merge ``[price, qty]`` changes into a price-sorted book; qty 0 removes."""
+    levels = {price: qty for price, qty in book}
+    for price, qty in changes:
+        if qty > 0:
+            levels[price] = qty
+        else:
+            levels.pop(price, None)
+    return [[price, levels[price]] for price in sorted(levels)]
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	if len(os.Args) == 2 && os.Args[1] == "keygen" {
+		fmt.Println(ticks.NewKey())
+		return
+	}
+	if len(os.Args) < 3 {
+		usage()
+		os.Exit(1)
+	}
+
+	cmd := os.Args[1]
+	paths := expand(os.Args[2:])
+
+	switch cmd {
+	case "manifest":
+		runManifest(paths)
+	case "verify":
+		runVerify(paths)
+	case "index":
+		runIndex(paths)
+	case "encrypt":
+		runCrypt(paths, true)
+	case "decrypt":
+		runCrypt(paths, false)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: archive <command> <paths...>
+
+Commands:
+  manifest <archives...>   Write (or rewrite) manifests for compressed archives
+  verify <paths...>        Check archives against their manifests; accepts
+                           manifest files or the archives themselves
+  index <archives...>      Recompress archives into seekable blocks and write
+                           their index (refreshes existing manifests)
+  encrypt <archives...>    Encrypt archives with the key in ARCHIVE_KEY or
+                           ARCHIVE_KEY_FILE, replacing each with <name>.enc
+  decrypt <archives...>    Decrypt .enc archives, replacing each with the
+                           plain archive
+  keygen                   Print a new random archive key`)
+}
+
+func expand(patterns []string) []string {
+	var out []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			slog.Warn("bad pattern", "pattern", p, "err", err)
+			continue
+		}
+		out = append(out, matches...)
+	}
+	return out
+}
+
+func runManifest(paths []string) {
+	failed := 0
+	for _, p := range paths {
+		m, err := ticks.WriteManifest(p)
+		if err != nil {
+			slog.Error("manifest failed", "path", p, "err", err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s  %d records  %d markets  %s\n", ticks.ManifestPath(p), m.Records, len(m.Markets), m.SHA256[:12])
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runIndex(paths []string) {
+	failed := 0
+	for _, p := range paths {
+		idx, err := ticks.ReindexFile(p)
+		if err != nil {
+			slog.Error("index failed", "path", p, "err", err)
+			failed++
+			continue
+		}
+		if _, err := os.Stat(ticks.ManifestPath(p)); err == nil {
+			if _, err := ticks.WriteManifest(p); err != nil {
+				slog.Error("manifest refresh failed", "path", p, "err", err)
+				failed++
+			}
+		}
+		fmt.Printf("%s  %d blocks  %d markets\n", ticks.IndexPath(p), len(idx.Blocks), len(idx.Tickers))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runVerify(paths []string) {
+	ok, bad := 0, 0
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		mp := p
+		if !strings.HasSuffix(p, ".manifest.json") {
+			mp = ticks.ManifestPath(p)
+		}
+		if seen[mp] {
+			continue
+		}
+		seen[mp] = true
+
+		if err := ticks.VerifyManifest(mp); err != nil {
+			fmt.Printf("FAIL  %s: %v\n", mp, err)
+			bad++
+			continue
+		}
+		fmt.Printf("OK    %s\n", mp)
+		ok++
+	}
+	fmt.Printf("\n%d ok, %d failed\n", ok, bad)
+	if bad > 0 {
+		os.Exit(1)
+	}
+}
+
+// runCrypt encrypts or decrypts archives in place. Index offsets hold
+// either way; existing manifests are refreshed for the new file.
+func runCrypt(paths []string, encrypt bool) {
+	key, err := ticks.ArchiveKey()
+	if err == nil && key == nil {
+		err = ticks.ErrNoKey
+	}
+	if err != nil {
+		slog.Error("archive key", "err", err)
+		os.Exit(1)
+	}
+	failed := 0
+	for _, p := range paths {
+		if strings.HasSuffix(p, ticks.EncExt) == encrypt {
+			continue
+		}
+		dst := strings.TrimSuffix(p, ticks.EncExt)
+		if encrypt {
+			dst = p + ticks.EncExt
+			err = ticks.EncryptFile(p, dst, key)
+		} else {
+			err = ticks.DecryptFile(p, dst, key)
+		}
+		if err != nil {
+			slog.Error("failed", "path", p, "err", err)
+			failed++
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			slog.Warn("remove original", "path", p, "err", err)
+		}
+		if _, err := os.Stat(ticks.ManifestPath(dst)); err == nil {
+			if _, err := ticks.WriteManifest(dst); err != nil {
+				slog.Error("manifest refresh failed", "path", dst, "err", err)
+				failed++
+			}
+		}
+		fmt.Println(dst)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
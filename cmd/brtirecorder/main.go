@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/collector"
+	"github.com/gw/btc15m-data/internal/feed"
+	"github.com/gw/btc15m-data/pkg/brti"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// brtirecorder runs only the exchange feeds and writes a 1s BRTI proxy plus
+// per-feed prices. It needs no Kalshi credentials, so it can run anywhere.
+func main() {
+	output := flag.String("output", "./data", "output directory for JSONL files")
+	region := flag.String("region", "", "region label added to the file prefix (brti-<region>-YYYY-MM-DD.jsonl)")
+	rotate := flag.String("rotate", "daily", "output file rotation: daily or hourly")
+	compress := flag.String("compress", "gzip", "codec for rotated files: gzip or zstd")
+	debug := flag.Bool("debug", false, "enable debug logging")
+	flag.Parse()
+
+	logLevel := slog.LevelInfo
+	if *debug {
+		logLevel = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
+	prefix := "brti"
+	if *region != "" {
+		prefix += "-" + *region
+	}
+
+	slog.Info("brti recorder starting", "output", *output, "prefix", prefix)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		slog.Info("received signal, shutting down", "signal", sig)
+		cancel()
+	}()
+
+	feeds := []brti.ExchangeFeed{feed.NewCoinbaseFeed(), feed.NewKrakenFeed(), feed.NewBitstampFeed()}
+	proxy, err := brti.New(feeds, brti.Options{})
+	if err != nil {
+		slog.Error("brti proxy init failed", "err", err)
+		os.Exit(1)
+	}
+	for _, f := range feeds {
+		f := f
+		go func() {
+			if err := f.Run(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("feed error", "feed", f.Name(), "err", err)
+			}
+		}()
+	}
+
+	writer, err := collector.NewWriterOptions(*output, prefix, collector.WriterOptions{
+		Rotation:      collector.Rotation(*rotate),
+		Compression:   collector.Compression(*compress),
+		BufferSize:    64 << 10,
+		FlushInterval: time.Second,
+		SyncInterval:  5 * time.Second,
+		Journal:       true,
+	})
+	if err != nil {
+		slog.Error("writer init failed", "err", err)
+		os.Exit(1)
+	}
+	defer writer.Close()
+	writer.CompressStale()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var count int64
+	heartbeat := time.NewTicker(60 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("brti recorder stopped", "ticks", count)
+			return
+		case <-heartbeat.C:
+			slog.Info("heartbeat", "ticks", count, "brti", fmt.Sprintf("$%.2f", proxy.Price()))
+		case now := <-ticker.C:
+			rec := ticks.TickRecord{
+				Type:          "tick",
+				SchemaVersion: ticks.CurrentSchemaVersion,
+				Ts:            now.UTC().Format(time.RFC3339Nano),
+				BRTI:          proxy.Snapshot(),
+			}
+			for _, f := range feeds {
+				switch f.Name() {
+				case "coinbase":
+					rec.Coinbase = f.MidPrice()
+				case "kraken":
+					rec.Kraken = f.MidPrice()
+				case "bitstamp":
+					rec.Bitstamp = f.MidPrice()
+				}
+			}
+			if rec.BRTI <= 0 {
+				continue // no feed has connected yet
+			}
+			if err := writer.Write(rec); err != nil {
+				slog.Warn("write failed", "err", err)
+				continue
+			}
+			count++
+		}
+	}
+}
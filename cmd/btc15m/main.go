@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+
+	"github.com/gw/btc15m-data/internal/cli/collect"
+	"github.com/gw/btc15m-data/internal/cli/export"
+	"github.com/gw/btc15m-data/internal/cli/retrofit"
+	"github.com/gw/btc15m-data/internal/cli/tradelog"
+	"github.com/gw/btc15m-data/internal/config"
+)
+
+// commands maps each subcommand to its entry point, which gets the
+// arguments after the subcommand's name. The standalone binaries under
+// cmd/ call the same functions.
+var commands = map[string]struct {
+	run  func(args []string)
+	help string
+}{
+	"collect":  {collect.Main, "run the data collector (cmd/datacollector)"},
+	"retrofit": {retrofit.Main, "backfill settlement results into archives"},
+	"tradelog": {tradelog.Main, "trade history, PnL and order tools"},
+	"export":   {export.Main, "convert archives to CSV, Parquet or JSONL"},
+	"version":  {runVersion, "print the build's version and commit"},
+}
+
+func main() {
+	flag.Usage = usage
+	envFile := flag.String("env", "", "dotenv file every subcommand reads instead of .env")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(1)
+	}
+	if *envFile != "" {
+		if err := config.SetEnvFile(*envFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", flag.Arg(0))
+		usage()
+		os.Exit(1)
+	}
+	cmd.run(flag.Args()[1:])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: btc15m [--env FILE] <command> [flags]\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun btc15m <command> -h for a command's flags.")
+}
+
+func runVersion(args []string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		fmt.Println("btc15m (unknown build)")
+		return
+	}
+	version, commit, modified := info.Main.Version, "", ""
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = " (modified)"
+			}
+		}
+	}
+	fmt.Printf("btc15m %s %s%s %s\n", version, commit, modified, info.GoVersion)
+}
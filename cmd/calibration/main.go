@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Probability sources.
+const (
+	sourceMid   = "mid"   // market (yes_bid+yes_ask)/2
+	sourceModel = "model" // prob_yes of forecast records
+)
+
+var (
+	sources   = flag.String("source", "mid,model", "probabilities to score: mid, model or both")
+	bins      = flag.Int("bins", 10, "number of equal-width probability buckets")
+	every     = flag.Duration("every", time.Minute, "score each market at most once per interval, so one market's ticks don't swamp the table")
+	maxSpread = flag.Int("max-spread", 10, "ignore mids with a wider yes spread in cents (0 = any)")
+	jsonOut   = flag.Bool("json", false, "print the tables as JSON")
+)
+
+// sample is one probability given to a market before it settled.
+type sample struct {
+	ticker string
+	p      float64
+}
+
+// bucket is one row of a calibration table.
+type bucket struct {
+	Lo        float64 `json:"lo"`
+	Hi        float64 `json:"hi"`
+	N         int     `json:"n"`
+	Predicted float64 `json:"predicted"` // mean probability given
+	Realized  float64 `json:"realized"`  // fraction that settled YES
+	Brier     float64 `json:"brier"`
+}
+
+// table is the calibration of one source.
+type table struct {
+	Source    string   `json:"source"`
+	Samples   int      `json:"samples"`
+	Markets   int      `json:"markets"`
+	Brier     float64  `json:"brier"`
+	BaseRate  float64  `json:"base_rate"` // fraction of samples that settled YES
+	Unsettled int      `json:"unsettled"` // samples of markets with no recorded result
+	Buckets   []bucket `json:"buckets"`
+}
+
+// scorer gathers samples by source and the results to score them on.
+type scorer struct {
+	samples map[string][]sample
+	last    map[string]time.Time // source + ticker → time of the last sample
+	results map[string]string
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || *bins < 1 {
+		log.Fatal("Usage: calibration [--source mid,model] [--bins 10] [--every 1m] [--max-spread 10] [--json] <jsonl-file-paths...>")
+	}
+	want := make(map[string]bool)
+	for _, s := range strings.Split(*sources, ",") {
+		switch s = strings.TrimSpace(s); s {
+		case sourceMid, sourceModel:
+			want[s] = true
+		case "":
+		default:
+			log.Fatalf("unknown --source %q (want mid or model)", s)
+		}
+	}
+
+	var paths []string
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Fatalf("bad pattern %s: %v", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	c := &scorer{
+		samples: make(map[string][]sample),
+		last:    make(map[string]time.Time),
+		results: make(map[string]string),
+	}
+	for _, p := range paths {
+		if err := c.read(p, want); err != nil {
+			log.Fatalf("reading %s: %v", p, err)
+		}
+	}
+
+	var tables []table
+	for _, src := range []string{sourceMid, sourceModel} {
+		if want[src] {
+			tables = append(tables, c.table(src, *bins))
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(tables); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Println()
+		}
+		report(t)
+	}
+	log.Printf("Scored %d settled markets from %d files", len(c.results), len(paths))
+}
+
+// read adds one archive's mids, forecasts and results.
+func (c *scorer) read(path string, want map[string]bool) error {
+	r, err := ticks.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if want[sourceModel] {
+		r.Handle("forecast", func(raw json.RawMessage) error {
+			var f ticks.ForecastRecord
+			if err := json.Unmarshal(raw, &f); err != nil {
+				return err
+			}
+			ts, err := time.Parse(time.RFC3339Nano, f.Ts)
+			if err != nil {
+				return nil
+			}
+			for _, m := range f.Markets {
+				c.add(sourceModel, m.Ticker, ts, m.ProbYes)
+			}
+			return nil
+		})
+	}
+
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			continue
+		}
+		for _, m := range rec.AllMarkets() {
+			if m.Result != "" {
+				c.results[m.Ticker] = m.Result
+			}
+			if !want[sourceMid] || m.Status != "" && m.Status != "active" || m.SecsLeft <= 0 {
+				continue
+			}
+			if m.YesBid <= 0 || m.YesAsk <= 0 || m.YesAsk < m.YesBid ||
+				*maxSpread > 0 && m.YesAsk-m.YesBid > *maxSpread {
+				continue
+			}
+			c.add(sourceMid, m.Ticker, ts, float64(m.YesBid+m.YesAsk)/200)
+		}
+	}
+}
+
+// add records p for ticker unless the market was sampled less than
+// --every ago.
+func (c *scorer) add(src, ticker string, ts time.Time, p float64) {
+	key := src + " " + ticker
+	if last, ok := c.last[key]; ok && ts.Sub(last) < *every {
+		return
+	}
+	c.last[key] = ts
+	c.samples[src] = append(c.samples[src], sample{ticker: ticker, p: p})
+}
+
+// table bins src's samples of settled markets into n buckets.
+func (c *scorer) table(src string, n int) table {
+	t := table{Source: src, Buckets: make([]bucket, n)}
+	for i := range t.Buckets {
+		t.Buckets[i].Lo = float64(i) / float64(n)
+		t.Buckets[i].Hi = float64(i+1) / float64(n)
+	}
+	markets := make(map[string]bool)
+	var yes, brier float64
+	for _, s := range c.samples[src] {
+		var o float64
+		switch c.results[s.ticker] {
+		case "yes":
+			o = 1
+		case "no":
+		default:
+			t.Unsettled++
+			continue
+		}
+		b := &t.Buckets[min(int(s.p*float64(n)), n-1)]
+		b.N++
+		b.Predicted += s.p
+		b.Realized += o
+		b.Brier += (s.p - o) * (s.p - o)
+		markets[s.ticker] = true
+		yes += o
+		brier += (s.p - o) * (s.p - o)
+		t.Samples++
+	}
+	for i := range t.Buckets {
+		if b := &t.Buckets[i]; b.N > 0 {
+			b.Predicted /= float64(b.N)
+			b.Realized /= float64(b.N)
+			b.Brier /= float64(b.N)
+		}
+	}
+	t.Markets = len(markets)
+	if t.Samples > 0 {
+		t.BaseRate = yes / float64(t.Samples)
+		t.Brier = brier / float64(t.Samples)
+	}
+	return t
+}
+
+// report prints t as a reliability table.
+func report(t table) {
+	fmt.Printf("%s: %d samples from %d markets, Brier %.4f, base rate %.1f%%",
+		t.Source, t.Samples, t.Markets, t.Brier, 100*t.BaseRate)
+	if t.Unsettled > 0 {
+		fmt.Printf(" (%d unsettled samples skipped)", t.Unsettled)
+	}
+	fmt.Println()
+	fmt.Printf("  %-11s %8s %10s %9s %8s %7s\n", "bucket", "n", "predicted", "realized", "gap", "brier")
+	for _, b := range t.Buckets {
+		label := fmt.Sprintf("%.2f-%.2f", b.Lo, b.Hi)
+		if b.N == 0 {
+			fmt.Printf("  %-11s %8d\n", label, 0)
+			continue
+		}
+		fmt.Printf("  %-11s %8d %9.1f%% %8.1f%% %+7.1f %7.4f\n",
+			label, b.N, 100*b.Predicted, 100*b.Realized, 100*(b.Realized-b.Predicted), b.Brier)
+	}
+}
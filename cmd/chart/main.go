@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var (
+	dataDir = flag.String("data", "./data", "collector output directory")
+	prefix  = flag.String("prefix", "kxbtc15m", "file name prefix of the archives")
+	output  = flag.String("o", "", "output SVG file (default <ticker>.svg)")
+)
+
+// point is one tick of the charted market.
+type point struct {
+	t              time.Time
+	yesBid, yesAsk int
+	last           int
+	volume         int
+	brti           float64
+	strike         float64
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("Usage: chart [-data ./data] [-o out.svg] <market-ticker>")
+	}
+	ticker := flag.Arg(0)
+
+	paths := ticks.FindArchives(*dataDir, *prefix, ticker)
+	if len(paths) == 0 {
+		log.Fatalf("no archives for %s in %s", ticker, *dataDir)
+	}
+
+	var pts []point
+	for _, p := range paths {
+		err := ticks.ReadRange(p, ticks.Query{Ticker: ticker}, func(rec ticks.TickRecord) error {
+			ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+			if err != nil {
+				return nil
+			}
+			for _, m := range rec.AllMarkets() {
+				if m.Ticker == ticker {
+					pts = append(pts, point{ts, m.YesBid, m.YesAsk, m.LastPrice, m.Volume, rec.BRTI, m.Strike})
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("reading archives: %v", err)
+		}
+	}
+	if len(pts) < 2 {
+		log.Fatalf("%s appears in %d ticks across %d files; nothing to chart", ticker, len(pts), len(paths))
+	}
+	sort.Slice(pts, func(i, j int) bool { return pts[i].t.Before(pts[j].t) })
+
+	out := *output
+	if out == "" {
+		out = ticker + ".svg"
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		log.Fatalf("creating output: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	render(w, ticker, pts)
+	if err := w.Flush(); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	log.Printf("Charted %d ticks of %s to %s", len(pts), ticker, out)
+}
+
+const (
+	width   = 900
+	panelH  = 200
+	padL    = 70
+	padR    = 20
+	padT    = 40
+	gap     = 40
+	plotW   = width - padL - padR
+	height  = padT + 3*panelH + 2*gap + 30
+	colBid  = "#2a9d8f"
+	colAsk  = "#e76f51"
+	colLast = "#264653"
+	colBRTI = "#f4a261"
+)
+
+// panel maps values into one of the three stacked plots.
+type panel struct {
+	top      float64
+	lo, hi   float64
+	t0, span float64
+}
+
+func (p panel) x(t time.Time) float64 {
+	return padL + float64(plotW)*(float64(t.Unix())-p.t0)/p.span
+}
+
+func (p panel) y(v float64) float64 {
+	return p.top + panelH - panelH*(v-p.lo)/(p.hi-p.lo)
+}
+
+func render(w io.Writer, ticker string, pts []point) {
+	t0 := float64(pts[0].t.Unix())
+	span := math.Max(float64(pts[len(pts)-1].t.Unix())-t0, 1)
+	panelAt := func(i int, lo, hi float64) panel {
+		if hi <= lo {
+			lo, hi = lo-1, hi+1
+		}
+		return panel{top: float64(padT + i*(panelH+gap)), lo: lo, hi: hi, t0: t0, span: span}
+	}
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(w, `<text x="%d" y="20" font-size="14" font-weight="bold">%s</text>`+"\n", padL, html.EscapeString(ticker))
+	fmt.Fprintf(w, `<text x="%d" y="20" text-anchor="end">%s – %s UTC</text>`+"\n", width-padR,
+		pts[0].t.UTC().Format("2006-01-02 15:04:05"), pts[len(pts)-1].t.UTC().Format("15:04:05"))
+
+	// Yes bid/ask and last price, in cents.
+	p := panelAt(0, 0, 100)
+	frame(w, p, "yes price (¢)", pts)
+	line(w, p, pts, colBid, "", func(q point) (float64, bool) { return float64(q.yesBid), q.yesBid > 0 })
+	line(w, p, pts, colAsk, "", func(q point) (float64, bool) { return float64(q.yesAsk), q.yesAsk > 0 })
+	line(w, p, pts, colLast, "2,2", func(q point) (float64, bool) { return float64(q.last), q.last > 0 })
+	legend(w, p, []string{"bid", "ask", "last"}, []string{colBid, colAsk, colLast})
+
+	// BRTI against the strike.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, q := range pts {
+		for _, v := range []float64{q.brti, q.strike} {
+			if v > 0 {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 1
+	}
+	pad := math.Max((hi-lo)*0.05, 1)
+	p = panelAt(1, lo-pad, hi+pad)
+	frame(w, p, "BRTI vs strike ($)", pts)
+	line(w, p, pts, colBRTI, "", func(q point) (float64, bool) { return q.brti, q.brti > 0 })
+	line(w, p, pts, colLast, "6,3", func(q point) (float64, bool) { return q.strike, q.strike > 0 })
+	legend(w, p, []string{"BRTI", "strike"}, []string{colBRTI, colLast})
+
+	// Cumulative volume.
+	maxVol := 0
+	for _, q := range pts {
+		maxVol = max(maxVol, q.volume)
+	}
+	p = panelAt(2, 0, float64(maxVol))
+	frame(w, p, "volume (contracts)", pts)
+	line(w, p, pts, colBid, "", func(q point) (float64, bool) { return float64(q.volume), true })
+
+	fmt.Fprintln(w, "</svg>")
+}
+
+// frame draws a panel's border, y-axis labels, minute gridlines and title.
+func frame(w io.Writer, p panel, title string, pts []point) {
+	fmt.Fprintf(w, `<rect x="%d" y="%.1f" width="%d" height="%d" fill="none" stroke="#ccc"/>`+"\n", padL, p.top, plotW, panelH)
+	fmt.Fprintf(w, `<text x="%d" y="%.1f" font-weight="bold">%s</text>`+"\n", padL, p.top-6, title)
+	for i := 0; i <= 4; i++ {
+		v := p.lo + (p.hi-p.lo)*float64(i)/4
+		y := p.y(v)
+		fmt.Fprintf(w, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#eee"/>`+"\n", padL, padL+plotW, y, y)
+		fmt.Fprintf(w, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", padL-4, y+4, axisLabel(v))
+	}
+	first, last := pts[0].t, pts[len(pts)-1].t
+	step := time.Minute
+	for last.Sub(first)/step > 15 {
+		step *= 5
+	}
+	for t := first.Truncate(step).Add(step); !t.After(last); t = t.Add(step) {
+		x := p.x(t)
+		fmt.Fprintf(w, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" stroke="#eee"/>`+"\n", x, x, p.top, p.top+panelH)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x, p.top+panelH+14, t.UTC().Format("15:04"))
+	}
+}
+
+func axisLabel(v float64) string {
+	if math.Abs(v) >= 1000 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+// line draws a series as a polyline, breaking it wherever value reports
+// no data.
+func line(w io.Writer, p panel, pts []point, color, dash string, value func(point) (float64, bool)) {
+	var seg []string
+	emit := func() {
+		if len(seg) > 1 {
+			fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="1.5" stroke-dasharray="%s" points="%s"/>`+"\n",
+				color, dash, strings.Join(seg, " "))
+		}
+		seg = seg[:0]
+	}
+	for _, q := range pts {
+		v, ok := value(q)
+		if !ok {
+			emit()
+			continue
+		}
+		seg = append(seg, fmt.Sprintf("%.1f,%.1f", p.x(q.t), p.y(v)))
+	}
+	emit()
+}
+
+func legend(w io.Writer, p panel, names, colors []string) {
+	x := padL + plotW - 70*len(names)
+	for i, n := range names {
+		fmt.Fprintf(w, `<rect x="%d" y="%.1f" width="10" height="3" fill="%s"/>`+"\n", x, p.top-10, colors[i])
+		fmt.Fprintf(w, `<text x="%d" y="%.1f">%s</text>`+"\n", x+14, p.top-6, n)
+		x += 70
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/gw/btc15m-data/internal/cli/export"
+)
+
+func main() {
+	export.Main(os.Args[1:])
+}
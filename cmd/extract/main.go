@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var (
+	ticker  = flag.String("ticker", "", "market ticker, or event ticker for every market in that window")
+	dataDir = flag.String("data-dir", "./data", "collector output directory")
+	prefix  = flag.String("prefix", "kxbtc15m", "file name prefix of the archives")
+	output  = flag.String("o", "", "output file, .jsonl or .csv (default JSONL on stdout)")
+	format  = flag.String("format", "", "jsonl or csv (default from the -o extension)")
+)
+
+var csvHeader = []string{
+	"ts", "brti", "coinbase", "kraken", "bitstamp", "ticker", "yes_bid", "yes_ask",
+	"last_price", "volume", "open_interest", "strike", "secs_left", "status", "result",
+	"yes_book", "no_book",
+}
+
+func main() {
+	flag.Parse()
+	if *ticker == "" {
+		log.Fatal("Usage: extract --ticker KXBTC15M-26FEB091900[-00] [--data-dir ./data] [-o out.jsonl|out.csv]")
+	}
+
+	outFormat := *format
+	if outFormat == "" {
+		outFormat = "jsonl"
+		if strings.EqualFold(filepath.Ext(*output), ".csv") {
+			outFormat = "csv"
+		}
+	}
+	if outFormat != "jsonl" && outFormat != "csv" {
+		log.Fatalf("unknown format %q (want jsonl or csv)", outFormat)
+	}
+
+	paths := ticks.FindArchives(*dataDir, *prefix, *ticker)
+	if len(paths) == 0 {
+		log.Fatalf("no archives for %s in %s", *ticker, *dataDir)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("creating output: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	var emit func(ticks.TickRecord) error
+	switch outFormat {
+	case "jsonl":
+		enc := json.NewEncoder(bw)
+		emit = func(rec ticks.TickRecord) error { return enc.Encode(rec) }
+	case "csv":
+		cw := csv.NewWriter(bw)
+		defer cw.Flush()
+		cw.Write(csvHeader)
+		emit = func(rec ticks.TickRecord) error {
+			for _, m := range rec.AllMarkets() {
+				cw.Write(csvRow(rec, m))
+			}
+			return cw.Error()
+		}
+	}
+
+	n := 0
+	// An event ticker never appears in the index, so only market tickers
+	// narrow the blocks read.
+	q := ticks.Query{Ticker: *ticker}
+	if strings.Count(*ticker, "-") < 2 {
+		q.Ticker = ""
+	}
+	for _, p := range paths {
+		err := ticks.ReadRange(p, q, func(rec ticks.TickRecord) error {
+			events := matching(rec, *ticker)
+			if len(events) == 0 {
+				return nil
+			}
+			rec.Markets, rec.Events = nil, events
+			n++
+			return emit(rec)
+		})
+		if err != nil {
+			log.Fatalf("extracting: %v", err)
+		}
+	}
+	log.Printf("Extracted %d ticks of %s from %d files", n, *ticker, len(paths))
+}
+
+// matching returns the events of rec narrowed to want, which is either a
+// market ticker or an event ticker.
+func matching(rec ticks.TickRecord, want string) []ticks.EventSnap {
+	var out []ticks.EventSnap
+	for _, ev := range rec.Events {
+		if ev.Event == want {
+			out = append(out, ev)
+			continue
+		}
+		for _, m := range ev.Markets {
+			if m.Ticker == want {
+				ev.Markets = []ticks.MarketSnap{m}
+				out = append(out, ev)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func csvRow(rec ticks.TickRecord, m ticks.MarketSnap) []string {
+	return []string{
+		rec.Ts, ftoa(rec.BRTI), ftoa(rec.Coinbase), ftoa(rec.Kraken), ftoa(rec.Bitstamp),
+		m.Ticker, strconv.Itoa(m.YesBid), strconv.Itoa(m.YesAsk), strconv.Itoa(m.LastPrice),
+		strconv.Itoa(m.Volume), strconv.Itoa(m.OpenInt), ftoa(m.Strike), strconv.Itoa(m.SecsLeft),
+		m.Status, m.Result, levels(m.YesBook), levels(m.NoBook),
+	}
+}
+
+func levels(l [][2]int) string {
+	if len(l) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+func ftoa(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
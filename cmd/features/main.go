@@ -0,0 +1,537 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var (
+	output    = flag.String("o", "", "output file (.csv or .parquet); with --test-from, -train and -test are added before the extension")
+	format    = flag.String("format", "", "csv or parquet (default: from output extension)")
+	features  = flag.String("features", strings.Join(featureNames, ","), "comma-separated features to compute")
+	windows   = flag.String("windows", "10s,60s,300s", "lookbacks for momentum and mid_momentum")
+	volWindow = flag.Duration("vol-window", 5*time.Minute, "lookback for vol")
+	testFrom  = flag.String("test-from", "", "split by date: rows before this UTC date go to the train file, the rest to the test file")
+	tickers   = flag.String("tickers", "", "comma-separated ticker prefixes to keep (default all)")
+	labels    = flag.Bool("labels", false, "add label_* columns: settlement result and values, and the yes mid's max favorable/adverse excursion")
+)
+
+// featureNames lists the features in output order.
+var featureNames = []string{
+	"secs_left", "spread", "mid", "imbalance", "microprice", "depth",
+	"distance", "momentum", "mid_momentum", "vol",
+}
+
+// row is what a column is computed from: one market in one tick, with the
+// history leading up to it.
+type row struct {
+	ts   time.Time
+	rec  *ticks.TickRecord
+	m    *ticks.MarketSnap
+	brti *history
+	mid  *history // this market's mids
+	vol  float64  // realized BRTI vol over --vol-window, 0 when unknown
+
+	labels *ticks.Labeler // nil without --labels
+}
+
+// column is one output column. value reports false when the feature is
+// undefined for the row, which is written as null.
+type column struct {
+	name  string
+	value func(*row) (float64, bool)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || *output == "" {
+		log.Fatal("Usage: features -o out.csv|out.parquet [--features=..] [--windows=10s,60s] [--vol-window=5m] [--test-from=YYYY-MM-DD] <jsonl-file-paths...>")
+	}
+
+	outFormat := *format
+	if outFormat == "" {
+		outFormat = strings.TrimPrefix(filepath.Ext(*output), ".")
+	}
+	if outFormat != "csv" && outFormat != "parquet" {
+		log.Fatalf("unsupported format %q (want csv or parquet)", outFormat)
+	}
+
+	lookbacks, err := parseWindows(*windows)
+	if err != nil {
+		log.Fatalf("--windows: %v", err)
+	}
+	cols, err := buildColumns(strings.Split(*features, ","), lookbacks)
+	if err != nil {
+		log.Fatalf("--features: %v", err)
+	}
+	if *labels {
+		cols = append(cols, labelColumns...)
+	}
+
+	var split time.Time
+	if *testFrom != "" {
+		if split, err = time.Parse("2006-01-02", *testFrom); err != nil {
+			log.Fatalf("--test-from: %v", err)
+		}
+	}
+
+	var prefixes []string
+	if *tickers != "" {
+		prefixes = strings.Split(*tickers, ",")
+	}
+
+	var paths []string
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Fatalf("bad pattern %s: %v", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		log.Fatal("no input files matched")
+	}
+
+	open := func(path string) (sink, error) {
+		if outFormat == "csv" {
+			return newCSVSink(path, cols)
+		}
+		return newParquetSink(path, cols)
+	}
+	var train, test sink
+	if split.IsZero() {
+		if train, err = open(*output); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if train, err = open(splitPath(*output, "train")); err != nil {
+			log.Fatal(err)
+		}
+		if test, err = open(splitPath(*output, "test")); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Labels depend on the rest of each market's life, so they take a pass
+	// of their own before the features.
+	var labeler *ticks.Labeler
+	if *labels {
+		labeler = ticks.NewLabeler()
+		err := ticks.ReadFiles(paths, func(rec ticks.TickRecord) error {
+			if ts, err := time.Parse(time.RFC3339Nano, rec.Ts); err == nil {
+				labeler.Observe(ts, &rec)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("reading archives: %v", err)
+		}
+	}
+
+	maxLookback := *volWindow
+	for _, d := range lookbacks {
+		maxLookback = max(maxLookback, d)
+	}
+	brti := &history{keep: maxLookback}
+	mids := make(map[string]*history)
+	counts := make(map[sink]int)
+
+	err = ticks.ReadFiles(paths, func(rec ticks.TickRecord) error {
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			return nil
+		}
+		if rec.BRTI > 0 {
+			brti.add(ts, rec.BRTI)
+		}
+		r := row{ts: ts, rec: &rec, brti: brti, vol: ticks.RealizedVol(brti.since(ts.Add(-*volWindow))), labels: labeler}
+
+		out := train
+		if !split.IsZero() && !ts.Before(split) {
+			out = test
+		}
+		markets := rec.AllMarkets()
+		for i := range markets {
+			m := &markets[i]
+			if !keepTicker(prefixes, m.Ticker) {
+				continue
+			}
+			h := mids[m.Ticker]
+			if h == nil {
+				h = &history{keep: maxLookback}
+				mids[m.Ticker] = h
+			}
+			if m.YesBid > 0 && m.YesAsk > 0 {
+				h.add(ts, float64(m.YesBid+m.YesAsk)/2)
+			}
+			if m.Status != "" && m.Status != "active" || m.SecsLeft <= 0 {
+				continue
+			}
+			r.m, r.mid = m, h
+			if err := out.write(&r); err != nil {
+				return err
+			}
+			counts[out]++
+		}
+		for t, h := range mids {
+			if h.stale(ts) {
+				delete(mids, t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("reading archives: %v", err)
+	}
+
+	for _, s := range []sink{train, test} {
+		if s == nil {
+			continue
+		}
+		if err := s.close(); err != nil {
+			log.Fatalf("writing %s: %v", s.path(), err)
+		}
+		log.Printf("Wrote %d rows to %s", counts[s], s.path())
+	}
+}
+
+// buildColumns expands feature names into columns, after the ts, ticker
+// and strike every row has.
+func buildColumns(names []string, lookbacks []time.Duration) ([]column, error) {
+	cols := []column{{name: "strike", value: func(r *row) (float64, bool) { return r.m.Strike, r.m.Strike > 0 }}}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "secs_left":
+			cols = append(cols, column{name, func(r *row) (float64, bool) { return float64(r.m.SecsLeft), true }})
+		case "spread":
+			cols = append(cols, column{name, func(r *row) (float64, bool) {
+				return float64(r.m.YesAsk - r.m.YesBid), r.m.YesBid > 0 && r.m.YesAsk > 0
+			}})
+		case "mid":
+			cols = append(cols, column{name, func(r *row) (float64, bool) {
+				return float64(r.m.YesBid+r.m.YesAsk) / 2, r.m.YesBid > 0 && r.m.YesAsk > 0
+			}})
+		case "imbalance":
+			cols = append(cols, column{name, func(r *row) (float64, bool) {
+				if r.m.Book == nil {
+					return 0, false
+				}
+				return r.m.Book.Imbalance, true
+			}})
+		case "microprice":
+			cols = append(cols, column{name, func(r *row) (float64, bool) {
+				if r.m.Book == nil {
+					return 0, false
+				}
+				return r.m.Book.Microprice, true
+			}})
+		case "depth":
+			cols = append(cols,
+				column{"yes_depth5", func(r *row) (float64, bool) {
+					if r.m.Book == nil {
+						return 0, false
+					}
+					return float64(r.m.Book.YesDepth5), true
+				}},
+				column{"no_depth5", func(r *row) (float64, bool) {
+					if r.m.Book == nil {
+						return 0, false
+					}
+					return float64(r.m.Book.NoDepth5), true
+				}})
+		case "distance":
+			cols = append(cols, column{name, func(r *row) (float64, bool) {
+				return (r.rec.BRTI - r.m.Strike) / r.m.Strike, r.rec.BRTI > 0 && r.m.Strike > 0
+			}})
+		case "momentum":
+			for _, d := range lookbacks {
+				cols = append(cols, column{"momentum_" + durationLabel(d), func(r *row) (float64, bool) {
+					then, ok := r.brti.at(r.ts.Add(-d))
+					if !ok || r.rec.BRTI <= 0 {
+						return 0, false
+					}
+					return math.Log(r.rec.BRTI / then), true
+				}})
+			}
+		case "mid_momentum":
+			for _, d := range lookbacks {
+				cols = append(cols, column{"mid_momentum_" + durationLabel(d), func(r *row) (float64, bool) {
+					now, ok := r.mid.at(r.ts)
+					then, ok2 := r.mid.at(r.ts.Add(-d))
+					return now - then, ok && ok2
+				}})
+			}
+		case "vol":
+			cols = append(cols, column{name, func(r *row) (float64, bool) { return r.vol, r.vol > 0 }})
+		default:
+			return nil, fmt.Errorf("unknown feature %q (want one of %s)", name, strings.Join(featureNames, ", "))
+		}
+	}
+	return cols, nil
+}
+
+// labelColumns are the --labels columns. They look ahead, so they must
+// never be used as features.
+var labelColumns = []column{
+	{"label_result", func(r *row) (float64, bool) {
+		l, _ := r.labels.Market(r.m.Ticker)
+		switch l.Result {
+		case "yes":
+			return 1, true
+		case "no":
+			return 0, true
+		}
+		return 0, false
+	}},
+	{"label_settlement_value", func(r *row) (float64, bool) {
+		l, _ := r.labels.Market(r.m.Ticker)
+		return l.SettlementValue, l.SettlementValue > 0
+	}},
+	{"label_settle_brti", func(r *row) (float64, bool) {
+		l, _ := r.labels.Market(r.m.Ticker)
+		return l.SettleBRTI, l.SettleBRTI > 0
+	}},
+	{"label_expiry_brti", func(r *row) (float64, bool) {
+		l, _ := r.labels.Market(r.m.Ticker)
+		return l.ExpiryBRTI, l.ExpiryBRTI > 0
+	}},
+	{"label_mfe", func(r *row) (float64, bool) {
+		x, ok := r.labels.Excursion(r.m.Ticker, r.ts)
+		return x.Favorable, ok
+	}},
+	{"label_mae", func(r *row) (float64, bool) {
+		x, ok := r.labels.Excursion(r.m.Ticker, r.ts)
+		return x.Adverse, ok
+	}},
+}
+
+// history is a trailing series of samples, trimmed to keep.
+type history struct {
+	keep    time.Duration
+	samples []ticks.PriceSample
+}
+
+func (h *history) add(ts time.Time, v float64) {
+	h.samples = append(h.samples, ticks.PriceSample{Time: ts, Price: v})
+	cutoff := ts.Add(-h.keep - time.Minute) // slack so at(ts-keep) finds a sample
+	n := 0
+	for n < len(h.samples)-1 && h.samples[n+1].Time.Before(cutoff) {
+		n++
+	}
+	if n > 0 {
+		h.samples = append(h.samples[:0], h.samples[n:]...)
+	}
+}
+
+// at returns the last value at or before t, or false if the history
+// doesn't reach back that far.
+func (h *history) at(t time.Time) (float64, bool) {
+	i := sort.Search(len(h.samples), func(i int) bool { return h.samples[i].Time.After(t) })
+	if i == 0 {
+		return 0, false
+	}
+	return h.samples[i-1].Price, true
+}
+
+// since returns the samples after t.
+func (h *history) since(t time.Time) []ticks.PriceSample {
+	i := sort.Search(len(h.samples), func(i int) bool { return h.samples[i].Time.After(t) })
+	return h.samples[i:]
+}
+
+// stale reports whether h has seen nothing within its lookback of now.
+func (h *history) stale(now time.Time) bool {
+	return len(h.samples) == 0 || now.Sub(h.samples[len(h.samples)-1].Time) > h.keep
+}
+
+// sink writes feature rows to one file.
+type sink interface {
+	write(*row) error
+	close() error
+	path() string
+}
+
+type csvSink struct {
+	name string
+	f    *os.File
+	cw   *csv.Writer
+	cols []column
+	buf  []string
+}
+
+func newCSVSink(path string, cols []column) (*csvSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &csvSink{name: path, f: f, cw: csv.NewWriter(f), cols: cols}
+	header := []string{"ts", "ticker"}
+	for _, c := range cols {
+		header = append(header, c.name)
+	}
+	return s, s.cw.Write(header)
+}
+
+func (s *csvSink) write(r *row) error {
+	s.buf = append(s.buf[:0], r.ts.Format(time.RFC3339Nano), r.m.Ticker)
+	for _, c := range s.cols {
+		v, ok := c.value(r)
+		if !ok {
+			s.buf = append(s.buf, "")
+			continue
+		}
+		s.buf = append(s.buf, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return s.cw.Write(s.buf)
+}
+
+func (s *csvSink) close() error {
+	s.cw.Flush()
+	err := s.cw.Error()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *csvSink) path() string { return s.name }
+
+// parquetSink writes rows against a schema built from the columns; every
+// feature column is an optional double.
+type parquetSink struct {
+	name  string
+	f     *os.File
+	w     *parquet.Writer
+	cols  []column
+	index []int // column position in the schema, which orders fields by name
+	ts    int
+	tick  int
+	rows  []parquet.Row
+}
+
+func newParquetSink(path string, cols []column) (*parquetSink, error) {
+	group := parquet.Group{
+		"ts":     parquet.Timestamp(parquet.Microsecond),
+		"ticker": parquet.String(),
+	}
+	for _, c := range cols {
+		group[c.name] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	}
+	schema := parquet.NewSchema("features", group)
+	pos := make(map[string]int)
+	for i, f := range schema.Fields() {
+		pos[f.Name()] = i
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &parquetSink{name: path, f: f, w: parquet.NewWriter(f, schema), cols: cols,
+		ts: pos["ts"], tick: pos["ticker"]}
+	for _, c := range cols {
+		s.index = append(s.index, pos[c.name])
+	}
+	return s, nil
+}
+
+func (s *parquetSink) write(r *row) error {
+	out := make(parquet.Row, len(s.cols)+2)
+	out[s.ts] = parquet.Int64Value(r.ts.UnixMicro()).Level(0, 0, s.ts)
+	out[s.tick] = parquet.ByteArrayValue([]byte(r.m.Ticker)).Level(0, 0, s.tick)
+	for i, c := range s.cols {
+		if v, ok := c.value(r); ok {
+			out[s.index[i]] = parquet.DoubleValue(v).Level(0, 1, s.index[i])
+		} else {
+			out[s.index[i]] = parquet.NullValue().Level(0, 0, s.index[i])
+		}
+	}
+	s.rows = append(s.rows, out)
+	if len(s.rows) < 1024 {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *parquetSink) flush() error {
+	_, err := s.w.WriteRows(s.rows)
+	s.rows = s.rows[:0]
+	return err
+}
+
+func (s *parquetSink) close() error {
+	err := s.flush()
+	if cerr := s.w.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *parquetSink) path() string { return s.name }
+
+// splitPath inserts -part before path's extension.
+func splitPath(path, part string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + part + ext
+}
+
+func parseWindows(spec string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil || d < time.Second || d%time.Second != 0 {
+			return nil, fmt.Errorf("window %q must be a whole number of seconds", f)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// durationLabel renders d as column names want it: 10s, 5m, 1h.
+func durationLabel(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return strconv.Itoa(int(d/time.Second)) + "s"
+}
+
+func keepTicker(prefixes []string, t string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(t, strings.TrimSpace(p)) {
+			return true
+		}
+	}
+	return false
+}
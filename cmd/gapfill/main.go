@@ -0,0 +1,399 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/feed"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// sourceGapfill marks the ticks this tool reconstructs, in
+// TickRecord.Source; their feed prices are marked sourceOHLC in FeedSources.
+const (
+	sourceGapfill = "gapfill"
+	sourceOHLC    = "ohlc"
+)
+
+var (
+	output   = flag.String("o", "", "output file for the reconstructed records (.jsonl or .jsonl.gz)")
+	minGap   = flag.Duration("min-gap", time.Minute, "treat a stretch this long without ticks as a gap")
+	seriesF  = flag.String("series", "", "comma-separated series to reconstruct markets for (default: those in the archives)")
+	feedsF   = flag.String("feeds", "coinbase,kraken,bitstamp", "exchanges to take minute bars from")
+	noKalshi = flag.Bool("no-kalshi", false, "reconstruct spot prices only; needs no Kalshi credentials")
+	rate     = flag.Duration("rate", 200*time.Millisecond, "wait between Kalshi requests")
+	dryRun   = flag.Bool("dry-run", false, "list the gaps without fetching anything")
+)
+
+// gap is a stretch between two ticks.
+type gap struct {
+	From, To time.Time
+}
+
+// market is one Kalshi market with its minute bars, keyed by bar end.
+type market struct {
+	kalshi.Market
+	open, close, expiry time.Time
+	bars                map[int64]kalshi.Candlestick
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || (*output == "" && !*dryRun) {
+		log.Fatal("Usage: gapfill [--min-gap 1m] [--no-kalshi] [--dry-run] -o filled.jsonl[.gz] <archive paths or globs...>")
+	}
+
+	var paths []string
+	for _, p := range flag.Args() {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			log.Fatalf("bad pattern %s: %v", p, err)
+		}
+		paths = append(paths, matches...)
+	}
+	times, seen, err := scan(paths)
+	if err != nil {
+		log.Fatalf("scanning archives: %v", err)
+	}
+	gaps := findGaps(times, *minGap)
+	var total time.Duration
+	for _, g := range gaps {
+		total += g.To.Sub(g.From)
+		log.Printf("gap %s → %s (%s)", g.From.Format(time.RFC3339), g.To.Format(time.RFC3339), g.To.Sub(g.From).Round(time.Second))
+	}
+	log.Printf("Found %d gaps totalling %s in %d ticks across %d files", len(gaps), total.Round(time.Second), len(times), len(paths))
+	if *dryRun || len(gaps) == 0 {
+		return
+	}
+
+	series := seen
+	if *seriesF != "" {
+		series = strings.Split(strings.ToUpper(*seriesF), ",")
+	}
+	var client kalshi.API
+	if !*noKalshi {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("Loading config: %v", err)
+		}
+		if client, err = kalshi.NewClient(cfg); err != nil {
+			log.Fatalf("Creating Kalshi client: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	history := feed.NewHistory(nil)
+	var recs []ticks.TickRecord
+	for _, g := range gaps {
+		spot := fetchSpot(ctx, history, strings.Split(*feedsF, ","), g)
+		var markets []*market
+		if client != nil {
+			markets = fetchMarkets(ctx, client, series, g)
+		}
+		recs = append(recs, reconstruct(g, spot, markets)...)
+	}
+
+	if err := write(*output, recs); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d reconstructed ticks -> %s", len(recs), *output)
+}
+
+// scan returns the sorted times of every tick in paths and the series of
+// the markets they hold.
+func scan(paths []string) ([]time.Time, []string, error) {
+	var times []time.Time
+	series := make(map[string]bool)
+	for _, p := range paths {
+		err := ticks.ReadFile(p, func(rec ticks.TickRecord) error {
+			ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+			if err != nil {
+				return nil
+			}
+			times = append(times, ts)
+			for _, m := range rec.AllMarkets() {
+				series[ticks.SeriesOf(m.Ticker)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	out := make([]string, 0, len(series))
+	for s := range series {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return times, out, nil
+}
+
+// findGaps returns the stretches longer than min between consecutive
+// times.
+func findGaps(times []time.Time, min time.Duration) []gap {
+	var out []gap
+	for i := 1; i < len(times); i++ {
+		if times[i].Sub(times[i-1]) > min {
+			out = append(out, gap{times[i-1], times[i]})
+		}
+	}
+	return out
+}
+
+// minutes returns the minute boundaries strictly inside g.
+func (g gap) minutes() []time.Time {
+	var out []time.Time
+	for t := g.From.Truncate(time.Minute).Add(time.Minute); t.Before(g.To); t = t.Add(time.Minute) {
+		if t.After(g.From) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// fetchSpot returns each exchange's minute closes over g, keyed by the end
+// of the minute. An exchange that fails is logged and left out.
+func fetchSpot(ctx context.Context, h *feed.History, feeds []string, g gap) map[string]map[int64]float64 {
+	out := make(map[string]map[int64]float64)
+	for _, name := range feeds {
+		name = strings.TrimSpace(name)
+		bars, err := h.Bars(ctx, name, g.From, g.To)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			continue
+		}
+		closes := make(map[int64]float64, len(bars))
+		for _, b := range bars {
+			closes[b.Start.Add(time.Minute).Unix()] = b.Close
+		}
+		out[name] = closes
+		log.Printf("%s: %d minute bars", name, len(bars))
+	}
+	return out
+}
+
+// fetchMarkets returns the markets of every series whose window overlaps
+// g, with their Kalshi minute bars.
+func fetchMarkets(ctx context.Context, client kalshi.API, series []string, g gap) []*market {
+	var out []*market
+	for _, s := range series {
+		for close := g.From.Truncate(15 * time.Minute).Add(15 * time.Minute); close.Add(-15 * time.Minute).Before(g.To); close = close.Add(15 * time.Minute) {
+			event := eventTicker(s, close)
+			var ms []kalshi.Market
+			err := call(func() (err error) {
+				ms, err = client.GetEventMarkets(ctx, event)
+				return err
+			})
+			if err != nil {
+				log.Printf("%s: %v", event, err)
+				continue
+			}
+			for _, m := range ms {
+				mk := &market{Market: m, bars: make(map[int64]kalshi.Candlestick)}
+				var err1, err2 error
+				mk.open, err1 = time.Parse(time.RFC3339, m.OpenTime)
+				mk.close, err2 = time.Parse(time.RFC3339, m.CloseTime)
+				if err1 != nil || err2 != nil {
+					continue
+				}
+				mk.expiry, _ = m.ExpirationParsed()
+				var bars []kalshi.Candlestick
+				err := call(func() (err error) {
+					bars, err = client.GetMarketCandlesticks(ctx, s, m.Ticker, mk.open, mk.close, time.Minute)
+					return err
+				})
+				if err != nil {
+					log.Printf("%s: %v", m.Ticker, err)
+					continue
+				}
+				for _, b := range bars {
+					mk.bars[b.EndPeriodTs] = b
+				}
+				out = append(out, mk)
+			}
+			log.Printf("%s: %d markets", event, len(ms))
+		}
+	}
+	return out
+}
+
+// call paces Kalshi requests, retrying once when rate limited or during
+// maintenance.
+func call(fn func() error) error {
+	time.Sleep(*rate)
+	err := fn()
+	if errors.Is(err, kalshi.ErrRateLimited) || errors.Is(err, kalshi.ErrMaintenance) {
+		wait := kalshi.RetryAfter(err)
+		if wait == 0 {
+			wait = 10 * time.Second
+		}
+		log.Printf("%v, retrying in %s", err, wait)
+		time.Sleep(wait)
+		err = fn()
+	}
+	return err
+}
+
+// eventTicker is the ticker of series' event closing at close, e.g.
+// KXBTC15M-26FEB101415.
+func eventTicker(series string, close time.Time) string {
+	et, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		et = time.UTC
+	}
+	return series + "-" + strings.ToUpper(close.In(et).Format("06Jan021504"))
+}
+
+// reconstruct builds one tick per minute inside g from the bars: exchange
+// closes, their median as BRTI, and each live market's quotes and last
+// trade at the end of the minute. Markets carry no books.
+func reconstruct(g gap, spot map[string]map[int64]float64, markets []*market) []ticks.TickRecord {
+	var out []ticks.TickRecord
+	for _, t := range g.minutes() {
+		rec := ticks.TickRecord{
+			Type:          "tick",
+			SchemaVersion: ticks.CurrentSchemaVersion,
+			Ts:            t.UTC().Format(time.RFC3339Nano),
+			Source:        sourceGapfill,
+		}
+		var prices []float64
+		for name, closes := range spot {
+			p, ok := closes[t.Unix()]
+			if !ok || p <= 0 {
+				continue
+			}
+			switch name {
+			case "coinbase":
+				rec.Coinbase = p
+			case "kraken":
+				rec.Kraken = p
+			case "bitstamp":
+				rec.Bitstamp = p
+			}
+			if rec.FeedSources == nil {
+				rec.FeedSources = make(map[string]string)
+			}
+			rec.FeedSources[name] = sourceOHLC
+			prices = append(prices, p)
+		}
+		rec.BRTI = median(prices)
+
+		var snaps []ticks.MarketSnap
+		expiries := make(map[string]time.Time)
+		for _, m := range markets {
+			if !t.After(m.open) || t.After(m.close) {
+				continue
+			}
+			snap, ok := m.snapAt(t)
+			if !ok {
+				continue
+			}
+			if !m.expiry.IsZero() {
+				expiries[ticks.EventOf(m.Ticker)] = m.expiry
+			}
+			snaps = append(snaps, snap)
+		}
+		rec.Events = ticks.GroupEvents(snaps, t, expiries)
+		if rec.BRTI == 0 && len(snaps) == 0 {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+// snapAt is the market as of t, from its latest bar ending at or before t.
+// Volume is the total over the bars up to t. ok is false before its first
+// bar.
+func (m *market) snapAt(t time.Time) (ticks.MarketSnap, bool) {
+	var last kalshi.Candlestick
+	volume := 0
+	for end, b := range m.bars {
+		if end > t.Unix() {
+			continue
+		}
+		volume += b.Volume
+		if end > last.EndPeriodTs {
+			last = b
+		}
+	}
+	if last.EndPeriodTs == 0 {
+		return ticks.MarketSnap{}, false
+	}
+	kind, floor, cap := m.StrikeRange()
+	snap := ticks.MarketSnap{
+		Ticker:      m.Ticker,
+		YesBid:      last.YesBid.Close,
+		YesAsk:      last.YesAsk.Close,
+		Volume:      volume,
+		OpenInt:     last.OpenInterest,
+		Strike:      m.StrikePrice(),
+		SecsLeft:    max(0, int(m.expiry.Sub(t).Seconds())),
+		Status:      "active",
+		StrikeType:  kind,
+		StrikeFloor: floor,
+		StrikeCap:   cap,
+	}
+	if last.Price.Close != nil {
+		snap.LastPrice = *last.Price.Close
+	} else if last.Price.Previous != nil {
+		snap.LastPrice = *last.Price.Previous
+	}
+	if !t.Before(m.close) {
+		snap.Status = "closed"
+	}
+	return snap, true
+}
+
+func median(prices []float64) float64 {
+	if len(prices) == 0 {
+		return 0
+	}
+	sort.Float64s(prices)
+	n := len(prices)
+	if n%2 == 0 {
+		return (prices[n/2-1] + prices[n/2]) / 2
+	}
+	return prices[n/2]
+}
+
+// write writes recs to path, gzipped if it ends in .gz.
+func write(path string, recs []ticks.TickRecord) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating output: %w", err)
+	}
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	enc := json.NewEncoder(w)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("closing gzip: %w", err)
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var output = flag.String("o", "", "merged output file (.jsonl or .jsonl.gz)")
+
+// item is one record read from an input: a tick, or any other record
+// (divergence, gap, status, ...) kept as read.
+type item struct {
+	t    time.Time
+	tick *ticks.TickRecord // nil for other records
+	typ  string
+	raw  json.RawMessage
+}
+
+// cursor walks one input file one second at a time.
+type cursor struct {
+	path  string
+	r     *ticks.Reader
+	queue []item // read but not yet merged, in file order
+	eof   bool
+}
+
+func newCursor(path string, r *ticks.Reader) (*cursor, error) {
+	c := &cursor{path: path, r: r}
+	r.HandleOther(func(typ string, raw json.RawMessage) error {
+		var env struct {
+			Ts string `json:"ts"`
+		}
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return err
+		}
+		ts, err := time.Parse(time.RFC3339Nano, env.Ts)
+		if err != nil {
+			return nil
+		}
+		c.queue = append(c.queue, item{t: ts, typ: typ, raw: raw})
+		return nil
+	})
+	return c, c.fill()
+}
+
+// fill reads until the cursor holds a record or its input ends. Records
+// other than ticks are queued by the reader as it passes them.
+func (c *cursor) fill() error {
+	for len(c.queue) == 0 && !c.eof {
+		rec, err := c.r.Next()
+		if err == io.EOF {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.path, err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			continue
+		}
+		c.queue = append(c.queue, item{t: ts, tick: &rec})
+	}
+	return nil
+}
+
+func (c *cursor) done() bool { return len(c.queue) == 0 }
+
+func (c *cursor) sec() int64 { return c.queue[0].t.Unix() }
+
+func (c *cursor) pop() (item, error) {
+	it := c.queue[0]
+	c.queue = c.queue[1:]
+	return it, c.fill()
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() < 1 || *output == "" {
+		log.Fatal("Usage: merge -o merged.jsonl[.gz] <jsonl-file-paths...>")
+	}
+
+	var cursors []*cursor
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Error expanding pattern %s: %v", pattern, err)
+			continue
+		}
+		for _, p := range matches {
+			r, err := ticks.Open(p)
+			if err != nil {
+				log.Fatalf("opening %s: %v", p, err)
+			}
+			defer r.Close()
+			c, err := newCursor(p, r)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cursors = append(cursors, c)
+		}
+	}
+	if len(cursors) == 0 {
+		log.Fatal("no input files matched")
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("creating output: %v", err)
+	}
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(*output, ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	st, err := merge(cursors, w)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			log.Fatalf("closing gzip: %v", err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("closing output: %v", err)
+	}
+	log.Printf("Merged %d inputs into %d seconds and %d other records (%d duplicates dropped, %d feed prices filled) -> %s",
+		len(cursors), st.seconds, st.others, st.dupes, st.filled, *output)
+}
+
+type mergeStats struct {
+	seconds int // ticks written, one per second
+	others  int // other records written
+	dupes   int // ticks and other records dropped as duplicates
+	filled  int // exchange prices filled from another input's tick
+}
+
+// merge writes the records of cursors to w in timestamp order. Each second
+// gets one tick, reconciled from every input's; other records are passed
+// through, with copies of the same record from several inputs written
+// once.
+func merge(cursors []*cursor, w io.Writer) (mergeStats, error) {
+	var st mergeStats
+	enc := json.NewEncoder(w)
+	for {
+		// Find the earliest second across all inputs.
+		var sec int64
+		found := false
+		for _, c := range cursors {
+			if !c.done() && (!found || c.sec() < sec) {
+				sec, found = c.sec(), true
+			}
+		}
+		if !found {
+			return st, nil
+		}
+
+		// Gather every record for that second from every input.
+		var candidates []ticks.TickRecord
+		var tickTime time.Time
+		var out []item
+		seen := make(map[string]bool)
+		for _, c := range cursors {
+			for !c.done() && c.sec() == sec {
+				it, err := c.pop()
+				if err != nil {
+					return st, err
+				}
+				if it.tick != nil {
+					if len(candidates) == 0 {
+						tickTime = it.t
+					}
+					candidates = append(candidates, *it.tick)
+					continue
+				}
+				key := it.typ + " " + it.t.Format(time.RFC3339Nano) + " " + compact(it.raw)
+				if seen[key] {
+					st.dupes++
+					continue
+				}
+				seen[key] = true
+				out = append(out, it)
+				st.others++
+			}
+		}
+
+		if len(candidates) > 0 {
+			best, n := reconcile(candidates)
+			st.filled += n
+			st.dupes += len(candidates) - 1
+			out = append(out, item{t: tickTime, tick: &best})
+			st.seconds++
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].t.Before(out[j].t) })
+		for _, it := range out {
+			var err error
+			if it.tick != nil {
+				err = enc.Encode(it.tick)
+			} else {
+				_, err = w.Write(append(it.raw, '\n'))
+			}
+			if err != nil {
+				return st, fmt.Errorf("writing output: %w", err)
+			}
+		}
+	}
+}
+
+// compact is raw without insignificant whitespace, so copies of a record
+// written by different collectors compare equal.
+func compact(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
+
+// reconcile picks the candidate with the richest market data and fills any
+// exchange prices it is missing from the others. Returns the fill count.
+func reconcile(cands []ticks.TickRecord) (ticks.TickRecord, int) {
+	best := cands[0]
+	bestScore := richness(best)
+	for _, c := range cands[1:] {
+		if s := richness(c); richer(s, bestScore) {
+			best, bestScore = c, s
+		}
+	}
+
+	filled := 0
+	fill := func(dst *float64, get func(ticks.TickRecord) float64) {
+		if *dst > 0 {
+			return
+		}
+		for _, c := range cands {
+			if v := get(c); v > 0 {
+				*dst = v
+				filled++
+				return
+			}
+		}
+	}
+	fill(&best.BRTI, func(r ticks.TickRecord) float64 { return r.BRTI })
+	fill(&best.Coinbase, func(r ticks.TickRecord) float64 { return r.Coinbase })
+	fill(&best.Kraken, func(r ticks.TickRecord) float64 { return r.Kraken })
+	fill(&best.Bitstamp, func(r ticks.TickRecord) float64 { return r.Bitstamp })
+	return best, filled
+}
+
+func richer(a, b [4]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// richness ranks a record by market count, then markets with depth, then
+// total book levels, then populated exchange prices.
+func richness(r ticks.TickRecord) [4]int {
+	var s [4]int
+	markets := r.AllMarkets()
+	s[0] = len(markets)
+	for _, m := range markets {
+		if len(m.YesBook) > 0 || len(m.NoBook) > 0 {
+			s[1]++
+		}
+		s[2] += len(m.YesBook) + len(m.NoBook)
+	}
+	for _, p := range []float64{r.Coinbase, r.Kraken, r.Bitstamp} {
+		if p > 0 {
+			s[3]++
+		}
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+func input(t *testing.T, name string, lines ...string) *cursor {
+	t.Helper()
+	c, err := newCursor(name, ticks.NewReader(strings.NewReader(strings.Join(lines, "\n")+"\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMergePassesOtherRecords(t *testing.T) {
+	gap := `{"type":"gap","ts":"2025-01-01T00:00:00.5Z","from":"2024-12-31T23:50:00Z","seconds":600,"reason":"restart","clean":false}`
+	a := input(t, "a",
+		`{"type":"tick","ts":"2025-01-01T00:00:00Z","brti":97000,"coinbase":97001,"kraken":0,"bitstamp":97002}`,
+		gap,
+		`{"type":"divergence","ts":"2025-01-01T00:00:01.2Z","state":"start","feed_a":"coinbase","feed_b":"kraken","diff":60}`,
+		`{"type":"tick","ts":"2025-01-01T00:00:01Z","brti":97010,"coinbase":97011,"kraken":97012,"bitstamp":97013}`,
+		`{"type":"shutdown","ts":"2025-01-01T00:00:03Z","reason":"signal","ticks":2}`,
+	)
+	b := input(t, "b",
+		`{"type":"tick","ts":"2025-01-01T00:00:00Z","brti":97000,"coinbase":97001,"kraken":97003,"bitstamp":0}`,
+		// The same gap record, spaced differently by another writer.
+		`{"type": "gap", "ts": "2025-01-01T00:00:00.5Z", "from": "2024-12-31T23:50:00Z", "seconds": 600, "reason": "restart", "clean": false}`,
+		`{"type":"tick","ts":"2025-01-01T00:00:02Z","brti":97020,"coinbase":97021,"kraken":97022,"bitstamp":97023}`,
+		`{"type":"status","ts":"2025-01-01T00:00:02.5Z","clock_skew_ms":3.5,"clock_rtt_ms":40}`,
+	)
+
+	var out bytes.Buffer
+	st, err := merge([]*cursor{a, b}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.seconds != 3 || st.others != 4 || st.dupes != 2 || st.filled != 1 {
+		t.Errorf("stats = %+v, want 3 seconds, 4 others, 2 dupes, 1 filled", st)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var env struct {
+			Type string `json:"type"`
+			Ts   string `json:"ts"`
+		}
+		if err := json.Unmarshal([]byte(line), &env); err != nil {
+			t.Fatalf("bad output line %q: %v", line, err)
+		}
+		got = append(got, env.Type+"@"+env.Ts)
+	}
+	want := []string{
+		"tick@2025-01-01T00:00:00Z",
+		"gap@2025-01-01T00:00:00.5Z",
+		"tick@2025-01-01T00:00:01Z",
+		"divergence@2025-01-01T00:00:01.2Z",
+		"tick@2025-01-01T00:00:02Z",
+		"status@2025-01-01T00:00:02.5Z",
+		"shutdown@2025-01-01T00:00:03Z",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("merged records:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out.String(), gap+"\n") {
+		t.Errorf("gap record not passed through as read:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `"kraken":97003`) {
+		t.Errorf("missing kraken price not filled from the other input:\n%s", out.String())
+	}
+}
+
+func TestMergeKeepsDifferingRecords(t *testing.T) {
+	a := input(t, "a", `{"type":"divergence","ts":"2025-01-01T00:00:00Z","state":"start","diff":60}`)
+	b := input(t, "b", `{"type":"divergence","ts":"2025-01-01T00:00:00Z","state":"start","diff":61}`)
+	var out bytes.Buffer
+	st, err := merge([]*cursor{a, b}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.others != 2 || st.dupes != 0 || st.seconds != 0 {
+		t.Errorf("stats = %+v, want both records kept", st)
+	}
+}
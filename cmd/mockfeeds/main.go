@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/feed/feedtest"
+)
+
+// mockfeeds serves fake Coinbase, Kraken and Bitstamp feeds quoting a
+// random-walk BTC price, optionally misbehaving, and prints the FEED_URLS
+// that points the collector at them.
+func main() {
+	price := flag.Float64("price", 100000, "starting BTC price")
+	vol := flag.Float64("vol", 10, "price standard deviation per second, in dollars")
+	interval := flag.Duration("interval", 250*time.Millisecond, "time between quotes")
+	chaosFlag := flag.String("chaos", "", "fault rates per quote, e.g. disconnect=0.01,stall=0.005,stall_for=30s,garbage=0.02")
+	only := flag.String("exchanges", strings.Join(feedtest.Exchanges, ","), "exchanges to serve")
+	seed := flag.Int64("seed", 0, "seed for prices and faults (0 = time-based)")
+	flag.Parse()
+
+	chaos, err := feedtest.ParseChaos(*chaosFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	var servers []*feedtest.Server
+	var urls []string
+	for i, name := range strings.Split(*only, ",") {
+		s, err := feedtest.NewServer(strings.TrimSpace(name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer s.Close()
+		s.SetChaos(chaos, *seed+int64(i))
+		servers = append(servers, s)
+		urls = append(urls, s.Exchange()+"="+s.URL())
+	}
+	fmt.Printf("FEED_URLS=%s\n", strings.Join(urls, ","))
+	slog.Info("mock feeds serving", "exchanges", len(servers), "price", *price, "chaos", *chaosFlag, "seed", *seed)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	report := time.NewTicker(time.Minute)
+	defer report.Stop()
+	step := *vol * math.Sqrt(interval.Seconds())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-report.C:
+			for _, s := range servers {
+				f := s.Faults()
+				slog.Info("mock feed", "exchange", s.Exchange(), "connects", s.Connects(),
+					"subscribed", s.Subscribed(), "disconnects", f.Disconnects, "stalls", f.Stalls, "garbage", f.Garbage)
+			}
+		case <-ticker.C:
+			*price += rng.NormFloat64() * step
+			for _, s := range servers {
+				// Venues disagree by a few dollars around the walk.
+				mid := *price + rng.NormFloat64()*2
+				s.Quote(mid-0.5, mid+0.5)
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// settlementDelay is how long after trading closes that secs_left reaches
+// zero (see tasks/lessons.md). Used to find the close when status is missing.
+const settlementDelay = 294
+
+// maxGap caps the weight one tick gets in the time-weighted spread, so a
+// collection outage doesn't let a single stale quote dominate.
+const maxGap = 5 * time.Second
+
+// final60 is the rule behind the final60_avg_brti column, kept for every
+// series so the column means the same thing whatever the series settles on.
+var final60 = ticks.SettlementRule{Window: time.Minute, Method: ticks.SettleMean}
+
+var (
+	output      = flag.String("o", "", "output CSV file (default stdout)")
+	jsonOut     = flag.Bool("json", false, "write a JSON array of market summaries instead of CSV")
+	settleRules = flag.String("settle", "", "override settlement rules, e.g. KXBTCD=60s:mean,KXBTC=5m:twap (methods: mean, twap, last)")
+)
+
+// marketStats accumulates one market's summary across all ticks.
+type marketStats struct {
+	Ticker    string
+	Strike    float64
+	FirstSeen time.Time
+	OpenBRTI  float64
+	CloseTime time.Time
+	CloseBRTI float64
+	Result    string
+	Value     float64 // Kalshi's settlement value, when recorded
+	MaxYes    int
+	MinYes    int
+	Volume    int
+
+	rule        ticks.SettlementRule
+	closeWindow []ticks.PriceSample // trailing BRTI while active, long enough for rule and final60
+
+	spreadWeighted float64 // Σ spread·dt (seconds)
+	spreadTime     float64 // Σ dt
+	lastQuoteTime  time.Time
+	lastSpread     int
+	haveQuote      bool
+}
+
+func main() {
+	flag.Parse()
+	if err := parseSettleRules(*settleRules); err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.NArg() == 0 {
+		log.Fatal("Usage: stats [-o out.csv] [--json] <jsonl-file-paths...>")
+	}
+
+	var paths []string
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Error expanding pattern %s: %v", pattern, err)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	markets := make(map[string]*marketStats)
+	err := ticks.ReadFiles(paths, func(rec ticks.TickRecord) error {
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			return nil
+		}
+		for _, m := range rec.AllMarkets() {
+			s, ok := markets[m.Ticker]
+			if !ok {
+				s = &marketStats{Ticker: m.Ticker, FirstSeen: ts, OpenBRTI: rec.BRTI, MinYes: -1,
+					rule: ticks.SettlementRuleFor(m.Ticker)}
+				markets[m.Ticker] = s
+			}
+			s.observe(ts, rec.BRTI, m)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("reading archives: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("creating output: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	write := writeCSV
+	if *jsonOut {
+		write = writeJSON
+	}
+	if err := write(w, summaries(markets)); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	log.Printf("Summarized %d markets from %d files", len(markets), len(paths))
+}
+
+// parseSettleRules registers the rules in a --settle spec.
+func parseSettleRules(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		series, rule, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		window, method, ok2 := strings.Cut(rule, ":")
+		if !ok || !ok2 {
+			return fmt.Errorf("bad --settle entry %q (want SERIES=WINDOW:METHOD)", entry)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return fmt.Errorf("bad --settle window %q: %w", window, err)
+		}
+		if err := ticks.SetSettlementRule(series, ticks.SettlementRule{Window: d, Method: method}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// active reports whether m is still open for trading.
+func active(m ticks.MarketSnap) bool {
+	if m.Status != "" {
+		return m.Status == "active"
+	}
+	return m.SecsLeft > settlementDelay
+}
+
+func (s *marketStats) observe(ts time.Time, brti float64, m ticks.MarketSnap) {
+	if m.Strike > 0 {
+		s.Strike = m.Strike
+	}
+	if m.Result != "" {
+		s.Result = m.Result
+	}
+	if m.SettlementValue != 0 {
+		s.Value = m.SettlementValue
+	}
+	if m.Volume > s.Volume {
+		s.Volume = m.Volume
+	}
+
+	if !active(m) {
+		s.closeQuote(ts)
+		return
+	}
+
+	s.CloseTime = ts
+	s.CloseBRTI = brti
+	if brti > 0 {
+		s.closeWindow = append(s.closeWindow, ticks.PriceSample{Time: ts, Price: brti})
+		cutoff := ts.Add(-max(s.rule.Window, final60.Window))
+		for len(s.closeWindow) > 0 && !s.closeWindow[0].Time.After(cutoff) {
+			s.closeWindow = s.closeWindow[1:]
+		}
+	}
+
+	if m.LastPrice > 0 {
+		if m.LastPrice > s.MaxYes {
+			s.MaxYes = m.LastPrice
+		}
+		if s.MinYes < 0 || m.LastPrice < s.MinYes {
+			s.MinYes = m.LastPrice
+		}
+	}
+
+	s.closeQuote(ts)
+	if m.YesBid > 0 && m.YesAsk > 0 {
+		s.lastQuoteTime = ts
+		s.lastSpread = m.YesAsk - m.YesBid
+		s.haveQuote = true
+	}
+}
+
+// closeQuote credits the previous quote's spread for the time it was live.
+func (s *marketStats) closeQuote(ts time.Time) {
+	if !s.haveQuote {
+		return
+	}
+	dt := ts.Sub(s.lastQuoteTime)
+	if dt > maxGap {
+		dt = maxGap
+	}
+	s.spreadWeighted += float64(s.lastSpread) * dt.Seconds()
+	s.spreadTime += dt.Seconds()
+	s.haveQuote = false
+}
+
+func (s *marketStats) final60Avg() float64 {
+	return final60.Settle(s.closeWindow, s.CloseTime)
+}
+
+// settleBRTI is the index value the market settles on under its series' rule.
+func (s *marketStats) settleBRTI() float64 {
+	return s.rule.Settle(s.closeWindow, s.CloseTime)
+}
+
+func (s *marketStats) twSpread() float64 {
+	if s.spreadTime == 0 {
+		return 0
+	}
+	return s.spreadWeighted / s.spreadTime
+}
+
+// summary is one output row. The JSON keys are the CSV column names.
+type summary struct {
+	Ticker    string  `json:"ticker"`
+	Strike    float64 `json:"strike"`
+	FirstSeen string  `json:"first_seen"`
+	OpenBRTI  float64 `json:"open_brti"`
+	CloseTime string  `json:"close_time"`
+	CloseBRTI float64 `json:"close_brti"`
+	Final60   float64 `json:"final60_avg_brti"`
+	Settle    float64 `json:"settle_brti"`
+	Result    string  `json:"result"`
+	Value     float64 `json:"settlement_value"`
+	MaxYes    int     `json:"max_yes_price"`
+	MinYes    int     `json:"min_yes_price"`
+	Volume    int     `json:"volume"`
+	TWSpread  float64 `json:"tw_spread"`
+}
+
+// summaries returns the output rows in ticker order.
+func summaries(markets map[string]*marketStats) []summary {
+	tickers := make([]string, 0, len(markets))
+	for t := range markets {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+
+	rows := make([]summary, 0, len(tickers))
+	for _, t := range tickers {
+		s := markets[t]
+		closeTime := ""
+		if !s.CloseTime.IsZero() {
+			closeTime = s.CloseTime.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, summary{
+			Ticker:    s.Ticker,
+			Strike:    s.Strike,
+			FirstSeen: s.FirstSeen.UTC().Format(time.RFC3339),
+			OpenBRTI:  s.OpenBRTI,
+			CloseTime: closeTime,
+			CloseBRTI: s.CloseBRTI,
+			Final60:   s.final60Avg(),
+			Settle:    s.settleBRTI(),
+			Result:    s.Result,
+			Value:     s.Value,
+			MaxYes:    s.MaxYes,
+			MinYes:    max(s.MinYes, 0),
+			Volume:    s.Volume,
+			TWSpread:  s.twSpread(),
+		})
+	}
+	return rows
+}
+
+func writeCSV(w io.Writer, rows []summary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"ticker", "strike", "first_seen", "open_brti", "close_time", "close_brti",
+		"final60_avg_brti", "settle_brti", "result", "settlement_value", "max_yes_price", "min_yes_price",
+		"volume", "tw_spread",
+	})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Ticker,
+			ftoa(r.Strike),
+			r.FirstSeen,
+			ftoa(r.OpenBRTI),
+			r.CloseTime,
+			ftoa(r.CloseBRTI),
+			strconv.FormatFloat(r.Final60, 'f', 2, 64),
+			strconv.FormatFloat(r.Settle, 'f', 2, 64),
+			r.Result,
+			ftoa(r.Value),
+			strconv.Itoa(r.MaxYes),
+			strconv.Itoa(r.MinYes),
+			strconv.Itoa(r.Volume),
+			strconv.FormatFloat(r.TWSpread, 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, rows []summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func ftoa(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var (
+	rate     = flag.Duration("rate", time.Second, "wait between Kalshi requests")
+	jsonOut  = flag.Bool("json", false, "print every check as JSON instead of a report of mismatches")
+	showSame = flag.Bool("all", false, "also list markets whose recorded result matches")
+)
+
+// recorded is what the archives say about one market's settlement.
+type recorded struct {
+	Results []string  `json:"results"` // distinct results seen, in order
+	Value   float64   `json:"settlement_value,omitempty"`
+	Status  string    `json:"status"`  // last status seen
+	LastTs  time.Time `json:"last_ts"` // last tick carrying a result
+	File    string    `json:"file"`    // archive of that tick
+}
+
+// check compares one market's recorded result with Kalshi's.
+type check struct {
+	Ticker       string   `json:"ticker"`
+	Recorded     recorded `json:"recorded"`
+	KalshiResult string   `json:"kalshi_result"`
+	KalshiStatus string   `json:"kalshi_status"`
+	KalshiValue  float64  `json:"kalshi_settlement_value,omitempty"`
+	Match        bool     `json:"match"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Usage: validate-settlements [--rate 1s] [--all] [--json] <archive paths or globs...>")
+	}
+
+	var paths []string
+	for _, p := range flag.Args() {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			log.Fatalf("bad pattern %s: %v", p, err)
+		}
+		paths = append(paths, matches...)
+	}
+
+	markets, err := scan(paths)
+	if err != nil {
+		log.Fatalf("scanning archives: %v", err)
+	}
+	log.Printf("Found %d settled markets in %d files", len(markets), len(paths))
+	if len(markets) == 0 {
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Loading config: %v", err)
+	}
+	client, err := kalshi.NewClient(cfg)
+	if err != nil {
+		log.Fatalf("Creating Kalshi client: %v", err)
+	}
+
+	tickers := make([]string, 0, len(markets))
+	for t := range markets {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+
+	ctx := context.Background()
+	checks := make([]check, 0, len(tickers))
+	for i, t := range tickers {
+		if i > 0 {
+			time.Sleep(*rate)
+		}
+		checks = append(checks, validate(ctx, client, t, markets[t]))
+	}
+
+	mismatches, failed := 0, 0
+	for _, c := range checks {
+		switch {
+		case c.Error != "":
+			failed++
+		case !c.Match:
+			mismatches++
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(checks); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		report(checks)
+	}
+	if mismatches > 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// scan collects the recorded result of every market that has one.
+func scan(paths []string) (map[string]*recorded, error) {
+	markets := make(map[string]*recorded)
+	for _, p := range paths {
+		err := ticks.ReadFile(p, func(rec ticks.TickRecord) error {
+			ts, _ := time.Parse(time.RFC3339Nano, rec.Ts)
+			for _, m := range rec.AllMarkets() {
+				if m.Result == "" {
+					continue
+				}
+				r := markets[m.Ticker]
+				if r == nil {
+					r = &recorded{}
+					markets[m.Ticker] = r
+				}
+				if !slices.Contains(r.Results, m.Result) {
+					r.Results = append(r.Results, m.Result)
+				}
+				if m.SettlementValue != 0 {
+					r.Value = m.SettlementValue
+				}
+				if !ts.Before(r.LastTs) {
+					r.Status, r.LastTs, r.File = m.Status, ts, p
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return markets, nil
+}
+
+// validate fetches ticker from Kalshi, retrying once when rate limited or
+// during maintenance. A recorded market matches only if every result the
+// archives hold for it agrees with Kalshi's, as does its settlement value
+// when one was recorded.
+func validate(ctx context.Context, client kalshi.API, ticker string, r *recorded) check {
+	c := check{Ticker: ticker, Recorded: *r}
+	market, err := client.GetMarket(ctx, ticker)
+	if errors.Is(err, kalshi.ErrRateLimited) || errors.Is(err, kalshi.ErrMaintenance) {
+		wait := kalshi.RetryAfter(err)
+		if wait == 0 {
+			wait = 10 * time.Second
+		}
+		log.Printf("%s: %v, retrying in %s", ticker, err, wait)
+		time.Sleep(wait)
+		market, err = client.GetMarket(ctx, ticker)
+	}
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.KalshiResult, c.KalshiStatus = market.Result, market.Status
+	c.KalshiValue, _ = market.SettlementValue()
+	c.Match = len(r.Results) == 1 && r.Results[0] == market.Result &&
+		(r.Value == 0 || r.Value == c.KalshiValue)
+	return c
+}
+
+func report(checks []check) {
+	mismatches, failed := 0, 0
+	for _, c := range checks {
+		got := strings.Join(c.Recorded.Results, ",")
+		switch {
+		case c.Error != "":
+			failed++
+			fmt.Printf("ERROR     %-32s recorded=%-6s %s\n", c.Ticker, got, c.Error)
+		case !c.Match:
+			mismatches++
+			fmt.Printf("MISMATCH  %-32s recorded=%-6s kalshi=%-6s status=%s",
+				c.Ticker, got, c.KalshiResult, c.KalshiStatus)
+			if c.Recorded.Value != c.KalshiValue {
+				fmt.Printf(" value=%.2f kalshi_value=%.2f", c.Recorded.Value, c.KalshiValue)
+			}
+			fmt.Printf("  (%s)\n", filepath.Base(c.Recorded.File))
+		case *showSame:
+			fmt.Printf("ok        %-32s result=%s\n", c.Ticker, c.KalshiResult)
+		}
+	}
+	fmt.Printf("\n%d markets checked: %d match, %d mismatched, %d failed\n",
+		len(checks), len(checks)-mismatches-failed, mismatches, failed)
+}
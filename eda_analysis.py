@@ -26,6 +26,8 @@ import math
 from collections import defaultdict
 from datetime import datetime, timezone
 
+from btcdata.ticks import iter_markets
+
 SETTLEMENT_DELAY = 294  # secs_left when market closes
 
 # Time windows: minutes before close
@@ -53,7 +55,7 @@ def load_data(files):
                 tick_count += 1
                 ts = tick["ts"]
                 brti = tick.get("brti", 0)
-                for market in tick.get("markets", []):
+                for market in iter_markets(tick):
                     ticker = market["ticker"]
                     markets[ticker].append({
                         "ts": ts,
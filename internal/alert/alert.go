@@ -0,0 +1,95 @@
+// Package alert delivers operator notifications for notable events, to a
+// generic JSON webhook, Pushover or Discord, routed by severity.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Alert is a single notification.
+type Alert struct {
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	Severity Severity  `json:"severity,omitempty"` // "" is Info
+}
+
+// Severity is how urgently an alert needs a human: Info for things worth
+// knowing, Warning for things worth a look, Critical for data being lost.
+type Severity string
+
+const (
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+	Critical Severity = "critical"
+)
+
+// ParseSeverity reads "info", "warning" or "critical", case-insensitively;
+// "" is Info.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(strings.TrimSpace(s))); sev {
+	case "":
+		return Info, nil
+	case Info, Warning, Critical:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown alert severity %q (want info, warning or critical)", s)
+}
+
+// AtLeast reports whether s is as severe as min or more.
+func (s Severity) AtLeast(min Severity) bool {
+	return s.rank() >= min.rank()
+}
+
+func (s Severity) rank() int {
+	switch s {
+	case Warning:
+		return 1
+	case Critical:
+		return 2
+	}
+	return 0
+}
+
+// Notifier sends alerts somewhere a human will see them.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Webhook POSTs alerts as JSON to a URL.
+type Webhook struct {
+	url  string
+	http *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Discord posts alerts to a Discord channel through one of its webhooks,
+// as an embed colored by severity.
+type Discord struct {
+	url  string
+	http *http.Client
+}
+
+// NewDiscord returns a notifier posting to the channel webhook url, as
+// shown under the channel's Integrations settings.
+func NewDiscord(url string) *Discord {
+	return &Discord{url: url, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Color       int    `json:"color"`
+	Timestamp   string `json:"timestamp,omitempty"`
+}
+
+func (d *Discord) Notify(ctx context.Context, a Alert) error {
+	embed := discordEmbed{
+		Title:       truncate(a.Title, 256),
+		Description: truncate(a.Message, 4096),
+		Color:       discordColor(a.Severity),
+	}
+	if !a.Time.IsZero() {
+		embed.Timestamp = a.Time.UTC().Format(time.RFC3339)
+	}
+	body, err := json.Marshal(map[string]any{"embeds": []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func discordColor(s Severity) int {
+	switch s {
+	case Warning:
+		return 0xf1c40f
+	case Critical:
+		return 0xe74c3c
+	}
+	return 0x3498db
+}
@@ -0,0 +1,82 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pushoverURL is the Pushover message API.
+const pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends alerts as Pushover push notifications. Info alerts are
+// sent quietly, warnings normally, and critical alerts at high priority,
+// which bypasses the user's quiet hours.
+type Pushover struct {
+	url   string
+	token string // application API token
+	user  string // user or group key
+	http  *http.Client
+}
+
+// NewPushover returns a notifier sending to user with the application
+// token.
+func NewPushover(token, user string) *Pushover {
+	return &Pushover{url: pushoverURL, token: token, user: user, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *Pushover) Notify(ctx context.Context, a Alert) error {
+	form := url.Values{
+		"token":    {p.token},
+		"user":     {p.user},
+		"title":    {truncate(a.Title, 250)},
+		"message":  {truncate(a.Message, 1024)},
+		"priority": {strconv.Itoa(pushoverPriority(a.Severity))},
+	}
+	if form.Get("message") == "" {
+		form.Set("message", form.Get("title")) // Pushover requires one
+	}
+	if !a.Time.IsZero() {
+		form.Set("timestamp", strconv.FormatInt(a.Time.Unix(), 10))
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushover request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushover returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func pushoverPriority(s Severity) int {
+	switch s {
+	case Warning:
+		return 0
+	case Critical:
+		return 1
+	}
+	return -1
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,42 @@
+package alert
+
+import (
+	"context"
+	"errors"
+)
+
+// Router sends each alert to every notifier whose minimum severity it
+// meets, e.g. everything to Discord but only critical alerts to a phone.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	n   Notifier
+	min Severity
+}
+
+// Add sends alerts of severity min and above to n.
+func (r *Router) Add(n Notifier, min Severity) {
+	r.routes = append(r.routes, route{n: n, min: min})
+}
+
+// Len returns the number of notifiers added.
+func (r *Router) Len() int {
+	return len(r.routes)
+}
+
+// Notify sends a to the notifiers it is routed to. One failing doesn't stop
+// the others; their errors are joined.
+func (r *Router) Notify(ctx context.Context, a Alert) error {
+	var errs []error
+	for _, rt := range r.routes {
+		if !a.Severity.AtLeast(rt.min) {
+			continue
+		}
+		if err := rt.n.Notify(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
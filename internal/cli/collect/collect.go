@@ -0,0 +1,676 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/collector"
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/feed"
+	"github.com/gw/btc15m-data/internal/framelog"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/brti"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Main runs the collector with args, the command line after its name.
+func Main(args []string) {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	output := fs.String("output", "", "output directory for JSONL files")
+	series := fs.String("series", "", "series ticker to collect (default KXBTC15M)")
+	debug := fs.Bool("debug", false, "enable debug logging")
+	rotate := fs.String("rotate", "daily", "output file rotation: daily or hourly")
+	maxFileMB := fs.Int("max-file-mb", 0, "also rotate when a file reaches this size in MB (0 = no limit)")
+	layout := fs.String("layout", "flat", "file layout under the output directory: flat or monthly (YYYY/MM/)")
+	sessionNames := fs.Bool("session-names", false, "name files <series>-<env>-<period> instead of kxbtc15m-<period>")
+	compress := fs.String("compress", "gzip", "codec for rotated files: gzip or zstd")
+	bufferKB := fs.Int("buffer-kb", 64, "write buffer size in KB (0 = unbuffered)")
+	flushEvery := fs.Duration("flush", time.Second, "how often buffered records are flushed to the file")
+	fsyncEvery := fs.Duration("fsync", 5*time.Second, "how often the output file is fsynced (0 = never)")
+	fsyncRecords := fs.Int("fsync-records", 0, "also fsync after this many records (0 = off)")
+	journal := fs.Bool("journal", true, "journal buffered records so a crash cannot lose them")
+	deadLetterDir := fs.String("dead-letter-dir", "", "where records the writer fails to write go, ideally on another disk (default <output>/dead-letter)")
+	deadLetterAfter := fs.Int("dead-letter-after", collector.DefaultDeadLetterAfter, "alert after this many write or compression failures in a row")
+	onRotate := fs.String("on-rotate", "", "run this sh command after each rotated file is compressed, with the archive path as $1")
+	onRotateTimeout := fs.Duration("on-rotate-timeout", collector.DefaultHookTimeout, "kill an --on-rotate command after this long")
+	fastJSON := fs.Bool("fast-json", false, "encode ticks with the hand-written encoder instead of encoding/json")
+	candles := fs.String("candles", "", "also write OHLCV candles at these intervals, e.g. 1m,5m (empty = off)")
+	candleFormat := fs.String("candle-format", "jsonl", "candle output format: jsonl or csv")
+	influxMarkets := fs.Bool("influx-markets", false, "also push per-market metrics to INFLUX_URL (one series per ticker)")
+	dedupeBooks := fs.Bool("dedupe-books", false, "omit books of markets unchanged since the previous tick (not with --delta)")
+	delta := fs.Int("delta", 0, "delta-encode ticks, writing a full keyframe every N seconds (0 = off)")
+	divergenceUSD := fs.Float64("divergence-usd", 50, "record feed divergence above this many USD (0 = off)")
+	divergenceFor := fs.Duration("divergence-for", 10*time.Second, "how long a divergence must last before it is recorded")
+	forecast := fs.Duration("forecast", 0, "write a settlement forecast for each open window this often (0 = off)")
+	forecastPaths := fs.Int("forecast-paths", 2000, "Monte Carlo paths per forecast (0 = closed form)")
+	forecastDrift := fs.Float64("forecast-drift", 0, "annualized BRTI drift assumed by forecasts")
+	expiryCandles := fs.Duration("expiry-candles", collector.DefaultExpiryCandlesDelay, "this long after each tracked market closes, record its 1-minute candlesticks from Kalshi (0 = off)")
+	exposure := fs.Bool("exposure", false, "record the account's positions (Kalshi WS market_positions) and resting orders in each tracked market with every tick")
+	exposureEvery := fs.Duration("exposure-every", collector.DefaultExposureEvery, "with --exposure, how often resting orders are polled")
+	exchangeStatus := fs.Duration("exchange-status", 30*time.Second, "how often to poll Kalshi's exchange status for halts and maintenance (0 = off)")
+	clockCheck := fs.Duration("clock-check", 5*time.Minute, "how often to compare the local clock with Kalshi's and write a status record (0 = off)")
+	maxSkew := fs.Duration("max-skew", collector.DefaultMaxSkew, "warn when the local clock is off from Kalshi's by more than this")
+	bookStaleAfter := fs.Duration("book-stale-after", 0, "flag active markets with no Kalshi WS update for this long (0 = off)")
+	restFallback := fs.Duration("rest-fallback", collector.DefaultRESTFallbackEvery, "while the Kalshi WS is down, fetch markets over REST this often (0 = off)")
+	restMaxFailures := fs.Int("rest-max-failures", collector.DefaultRESTMaxFailures, "pause the REST fallback for a minute after this many consecutive failures")
+	feedRESTAfter := fs.Duration("feed-rest-after", feed.DefaultRESTAfter, "poll an exchange's REST ticker once its WS feed has been silent this long (0 = off)")
+	feedRESTEvery := fs.Duration("feed-rest-every", feed.DefaultRESTEvery, "how often to poll an exchange's REST ticker while its WS is down")
+	brtiMethod := fs.String("brti-method", string(brti.Median), "how feed prices combine into the BRTI proxy: median or weighted")
+	brtiWeights := fs.String("brti-weights", "", "with --brti-method weighted, per-feed weights, e.g. coinbase=2,kraken=1,bitstamp=1 (missing = 1)")
+	brtiQuorum := fs.Int("brti-quorum", 1, "fresh feeds needed for a new BRTI price; with fewer the last one is kept")
+	brtiMaxDev := fs.Float64("brti-max-deviation", 0, "treat a feed further than this fraction from the median as an outlier, e.g. 0.002 (0 = off; needs 3 feeds)")
+	brtiOutliers := fs.String("brti-outliers", string(brti.OutlierDrop), "what to do with outliers: drop or clamp")
+	feedLatency := fs.Duration("feed-latency", collector.DefaultFeedLatencyEvery, "write each exchange feed's latency distribution this often (0 = off)")
+	maxSubs := fs.Int("max-subscriptions", 0, "subscribe the Kalshi WS to at most this many markets, nearest expiry first (0 = no limit)")
+	maxWindows := fs.Int("max-windows", 0, "subscribe the Kalshi WS to at most this many open windows, nearest expiry first (0 = all)")
+	bandPct := fs.Float64("strike-band-pct", 0, "only subscribe to and record markets whose strike is within this percentage of BRTI (0 = off)")
+	bandUSD := fs.Float64("strike-band-usd", 0, "only subscribe to and record markets whose strike is within this many dollars of BRTI (0 = off)")
+	ladderEvery := fs.Duration("ladder-every", collector.DefaultLadderEvery, "with a strike band, record the full ladder from REST this often (0 = off)")
+	allowlistSrc := fs.String("allowlist", "", "only collect the markets, events or series listed in this file or http(s) URL, re-read periodically (empty = all)")
+	allowlistEvery := fs.Duration("allowlist-every", collector.DefaultAllowlistEvery, "how often the --allowlist is re-read")
+	discoverSeries := fs.String("discover-series", "", "also collect 15-minute series matching these globs as Kalshi launches them, e.g. KXBTC*,KXETH* (alerts on each new one)")
+	discoverEvery := fs.Duration("discover-every", collector.DefaultSeriesEvery, "with --discover-series, how often to list Kalshi's series")
+	cacheTTL := fs.Duration("cache-ttl", kalshi.DefaultCacheTTL, "how long closed markets stay in the Kalshi WS cache after discovery drops them")
+	suppressBadBooks := fs.Bool("suppress-bad-books", false, "leave crossed or stale markets out of ticks instead of flagging them")
+	control := fs.String("control", "", "serve pause/resume/rotate/flush/reload/resubscribe commands over HTTP on this address, e.g. 127.0.0.1:7070")
+	leaderLease := fs.Duration("leader-lease", 0, "share the output directory with standby collectors: write only while holding a lease that expires after this long unrenewed (0 = off)")
+	encrypt := fs.Bool("encrypt", false, "encrypt rotated files with the key in ARCHIVE_KEY or ARCHIVE_KEY_FILE")
+	drainTimeout := fs.Duration("drain-timeout", collector.DefaultDrainTimeout, "on shutdown, give up on fetching results and finishing compression after this long")
+	shutdownSettle := fs.Duration("shutdown-settle", collector.DefaultSettleLookback, "on shutdown, record the results of markets that expired within this long (0 = off)")
+	captureMB := fs.Int("capture-frames-mb", 0, "keep the last N MB of raw Kalshi and exchange WS frames in memory, dumped on feed errors and POST /dump-frames (0 = off)")
+	captureDir := fs.String("capture-dir", "", "where captured frames are dumped (default <output>/frames)")
+	schemaDrift := fs.Bool("schema-drift", true, "warn when Kalshi REST or WS payloads gain, lose or retype fields")
+	schemaBaseline := fs.String("schema-baseline", "", "where the expected Kalshi payload shapes are kept between runs (default <output>/kalshi-schema.json)")
+	audit := fs.Bool("audit", false, "record every Kalshi REST call to kalshi-audit-*.jsonl")
+	fs.Parse(args)
+	if *dedupeBooks && *delta > 0 {
+		fmt.Fprintln(os.Stderr, "--dedupe-books and --delta cannot be combined; delta records already leave out unchanged books")
+		os.Exit(2)
+	}
+
+	// Logging; the level follows LOG_LEVEL (and reloads) unless --debug.
+	var logLevel slog.LevelVar
+	if *debug {
+		logLevel.Set(slog.LevelDebug)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel})))
+
+	// Load config
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("config error", "err", err)
+		os.Exit(1)
+	}
+
+	// CLI overrides
+	if !*debug {
+		logLevel.Set(cfg.LogLevel)
+	}
+	if *output != "" {
+		cfg.OutputDir = *output
+	}
+	if *series != "" {
+		cfg.SeriesTicker = *series
+	}
+
+	slog.Info("data collector starting",
+		"env", cfg.KalshiEnv,
+		"series", cfg.SeriesTicker,
+		"output", cfg.OutputDir,
+	)
+
+	var encryptKey []byte
+	if *encrypt {
+		key, err := ticks.ArchiveKey()
+		if err == nil && key == nil {
+			err = ticks.ErrNoKey
+		}
+		if err != nil {
+			slog.Error("--encrypt", "err", err)
+			os.Exit(1)
+		}
+		encryptKey = key
+		slog.Info("rotated files will be encrypted")
+	}
+
+	// Init Kalshi client
+	client, err := kalshi.NewClient(cfg)
+	if err != nil {
+		slog.Error("kalshi client init failed", "err", err)
+		os.Exit(1)
+	}
+
+	// Optional REST call audit log (daily-rotated alongside tick files)
+	if *audit {
+		auditWriter, err := collector.NewWriterOptions(cfg.OutputDir, "kalshi-audit", collector.WriterOptions{EncryptKey: encryptKey})
+		if err != nil {
+			slog.Error("audit writer init failed", "err", err)
+			os.Exit(1)
+		}
+		defer auditWriter.Close()
+		auditWriter.CompressStale()
+
+		client.SetAuditSink(kalshi.AuditFunc(func(r kalshi.AuditRecord) {
+			if err := auditWriter.Write(r); err != nil {
+				slog.Warn("audit write failed", "err", err)
+			}
+		}))
+		slog.Info("kalshi API audit log enabled")
+	}
+
+	// Schema drift canary on REST responses and WS messages
+	var drift *kalshi.DriftDetector
+	if *schemaDrift {
+		if *schemaBaseline == "" {
+			*schemaBaseline = filepath.Join(cfg.OutputDir, "kalshi-schema.json")
+		}
+		drift, err = kalshi.NewDriftDetector(*schemaBaseline, kalshi.DefaultDriftEvery)
+		if err != nil {
+			slog.Error("schema drift init failed", "err", err)
+			os.Exit(1)
+		}
+		client.SetDriftDetector(drift)
+	}
+
+	// Context with graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	var stopReason atomic.Value // string, set before cancel
+	go func() {
+		sig := <-sigCh
+		slog.Info("received signal, shutting down", "signal", sig)
+		stopReason.Store("signal: " + sig.String())
+		cancel()
+	}()
+
+	// Verify auth with a balance check (retry with backoff for maintenance windows)
+	const maxAuthAttempts = 5
+	var bal *kalshi.Balance
+	for attempt := 1; attempt <= maxAuthAttempts; attempt++ {
+		bal, err = client.GetBalance(ctx)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, kalshi.ErrUnauthorized) {
+			slog.Error("auth check rejected — check KALSHI_API_KEY_ID and key file", "err", err)
+			os.Exit(1)
+		}
+		if attempt == maxAuthAttempts {
+			slog.Error("auth check failed after retries — giving up", "err", err, "attempts", attempt)
+			os.Exit(1)
+		}
+		backoff := time.Duration(attempt*attempt) * 15 * time.Second // 15s, 60s, 135s, 240s
+		if ra := kalshi.RetryAfter(err); ra > backoff {
+			backoff = ra
+		}
+		slog.Warn("auth check failed, retrying", "err", err, "attempt", attempt, "backoff", backoff,
+			"maintenance", errors.Is(err, kalshi.ErrMaintenance))
+		select {
+		case <-ctx.Done():
+			slog.Error("shutdown during auth retry")
+			os.Exit(1)
+		case <-time.After(backoff):
+		}
+	}
+	slog.Info("authenticated", "balance", fmt.Sprintf("$%.2f", float64(bal.Balance)/100.0))
+
+	// Raw frame capture, shared by the Kalshi and exchange feeds
+	var frames *framelog.Ring
+	if *captureMB > 0 {
+		if *captureDir == "" {
+			*captureDir = filepath.Join(cfg.OutputDir, "frames")
+		}
+		frames = framelog.New(*captureMB<<20, *captureDir)
+		slog.Info("frame capture enabled", "mb", *captureMB, "dir", *captureDir)
+	}
+
+	// Init Kalshi WebSocket feed
+	kalshiWS := kalshi.NewKalshiFeed(cfg, client.PrivateKey())
+	kalshiWS.SetCacheTTL(*cacheTTL)
+	kalshiWS.SetFrameLog(frames)
+	kalshiWS.SetDriftDetector(drift)
+	if *exposure {
+		kalshiWS.EnablePositions()
+	}
+	go func() {
+		if err := kalshiWS.Run(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("kalshi ws error", "err", err)
+		}
+	}()
+
+	// Init and start price feeds
+	running := newFeedSet(ctx, cfg.FeedURLs, *feedRESTAfter, *feedRESTEvery, frames)
+	feeds, err := running.apply(cfg.Feeds)
+	if err != nil {
+		slog.Error("feed init failed", "err", err)
+		os.Exit(1)
+	}
+	weights, err := brti.ParseWeights(*brtiWeights)
+	if err != nil {
+		slog.Error("bad --brti-weights", "err", err)
+		os.Exit(1)
+	}
+	proxy, err := brti.New(feeds, brti.Options{
+		Aggregation:  brti.Aggregation(*brtiMethod),
+		Weights:      weights,
+		Quorum:       *brtiQuorum,
+		MaxDeviation: *brtiMaxDev,
+		Outliers:     brti.OutlierPolicy(*brtiOutliers),
+	})
+	if err != nil {
+		slog.Error("brti proxy init failed", "err", err)
+		os.Exit(1)
+	}
+
+	// Wait briefly for at least one feed to connect
+	slog.Info("waiting for price feeds...")
+	waitForFeeds(ctx, feeds)
+
+	// Wait briefly for Kalshi WS (non-blocking — REST fallback works without it)
+	slog.Info("waiting for kalshi ws...")
+	waitForWS(ctx, kalshiWS)
+
+	price := proxy.Snapshot()
+	if price > 0 {
+		slog.Info("initial BRTI proxy", "price", fmt.Sprintf("$%.2f", price))
+	} else {
+		slog.Warn("no price feeds connected yet — collector will wait for data")
+	}
+
+	// Print feed status
+	for _, h := range proxy.FeedStatus() {
+		status := "connected"
+		if h.Stale {
+			status = "stale/disconnected"
+		}
+		slog.Info("feed status", "name", h.Name, "price", fmt.Sprintf("$%.2f", h.Price), "status", status)
+	}
+
+	// Create writer
+	prefix, env := "kxbtc15m", ""
+	if *sessionNames {
+		prefix, env = strings.ToLower(cfg.SeriesTicker), cfg.KalshiEnv
+	}
+
+	// A standby keeps its feeds and WS hot but leaves the shared files
+	// alone until it holds the lease; its writer then replays the old
+	// leader's journal and compresses its files like any restart would.
+	var leaseLost atomic.Bool
+	if *leaderLease > 0 {
+		lease := collector.NewLease(cfg.OutputDir, prefix, *leaderLease)
+		if err := lease.Acquire(ctx); err != nil {
+			slog.Info("stopped while standing by")
+			return
+		}
+		defer lease.Release()
+		go func() {
+			if err := lease.Hold(ctx); err != nil {
+				slog.Error("stopping: another collector is writing", "err", err)
+				leaseLost.Store(true)
+				cancel()
+			}
+		}()
+	}
+
+	writer, err := collector.NewWriterOptions(cfg.OutputDir, prefix, collector.WriterOptions{
+		Rotation:      collector.Rotation(*rotate),
+		MaxBytes:      int64(*maxFileMB) << 20,
+		Compression:   collector.Compression(*compress),
+		Layout:        collector.Layout(*layout),
+		Env:           env,
+		BufferSize:    *bufferKB << 10,
+		FlushInterval: *flushEvery,
+		SyncInterval:  *fsyncEvery,
+		SyncEvery:     *fsyncRecords,
+		Journal:       *journal,
+		FastJSON:      *fastJSON,
+		EncryptKey:    encryptKey,
+	})
+	if err != nil {
+		slog.Error("writer init failed", "err", err)
+		os.Exit(1)
+	}
+	defer writer.Close()
+
+	notifier := cfg.Notifier()
+	if *deadLetterDir == "" {
+		*deadLetterDir = filepath.Join(cfg.OutputDir, "dead-letter")
+	}
+	deadPrefix := prefix
+	if env != "" {
+		deadPrefix += "-" + env
+	}
+	deadLetter, err := collector.NewDeadLetter(*deadLetterDir, deadPrefix, *deadLetterAfter, notifier)
+	if err != nil {
+		slog.Error("dead letter init failed", "err", err)
+		os.Exit(1)
+	}
+	defer deadLetter.Close()
+	writer.SetDeadLetter(deadLetter)
+	if *onRotate != "" {
+		writer.AddRotateHook(collector.ExecHook(*onRotate, *onRotateTimeout))
+		slog.Info("rotate hook enabled", "command", *onRotate)
+	}
+
+	if *delta > 0 {
+		every := time.Duration(*delta) * time.Second
+		writer.SetEncoder(collector.NewDeltaEncoder(every))
+		slog.Info("delta encoding enabled", "keyframe_every", every)
+	} else if *dedupeBooks {
+		writer.SetEncoder(collector.NewBookDeduper())
+		slog.Info("book deduplication enabled")
+	}
+
+	// Compress any stale JSONL files from previous periods
+	writer.CompressStale()
+
+	// Create and run collector
+	c := collector.New(client, kalshiWS, proxy, feeds, writer, cfg.SeriesTicker)
+	if *divergenceUSD > 0 {
+		c.EnableDivergence(*divergenceUSD, *divergenceFor, notifier)
+	}
+	c.EnableBookCheck(*bookStaleAfter, *suppressBadBooks)
+	c.SetDriftDetector(drift)
+	c.SetRESTFallback(*restFallback, *restMaxFailures)
+	c.SetSubscriptionPolicy(collector.SubscriptionPolicy{
+		MaxMarkets: *maxSubs,
+		MaxWindows: *maxWindows,
+		BandPct:    *bandPct,
+		BandUSD:    *bandUSD,
+	})
+	c.SetLadderSnapshots(*ladderEvery)
+	var allowlist *collector.Allowlist
+	if *allowlistSrc != "" {
+		allowlist = collector.NewAllowlist(*allowlistSrc, *allowlistEvery)
+		if err := allowlist.Reload(ctx); err != nil {
+			slog.Error("bad --allowlist", "err", err)
+			os.Exit(1)
+		}
+		c.SetAllowlist(allowlist)
+		slog.Info("allowlist enabled", "source", *allowlistSrc, "series", allowlist.Series())
+	}
+	if *discoverSeries != "" {
+		patterns, err := collector.ParseSeriesPatterns(*discoverSeries)
+		if err != nil {
+			slog.Error("bad --discover-series", "err", err)
+			os.Exit(1)
+		}
+		c.EnableSeriesDiscovery(collector.SeriesDiscovery{Patterns: patterns, Every: *discoverEvery}, notifier)
+		slog.Info("series discovery enabled", "patterns", patterns, "every", *discoverEvery)
+	}
+	if *feedLatency > 0 {
+		c.EnableFeedLatency(*feedLatency)
+	}
+	if *clockCheck > 0 {
+		c.EnableClockCheck(*clockCheck, *maxSkew)
+	}
+	if *exchangeStatus > 0 {
+		c.EnableExchangeStatus(*exchangeStatus)
+	}
+	if *expiryCandles > 0 {
+		c.EnableExpiryCandles(*expiryCandles)
+	}
+	if *exposure {
+		c.EnableExposure(*exposureEvery)
+		slog.Info("exposure recording enabled", "orders_every", *exposureEvery)
+	}
+	if *candles != "" {
+		intervals, err := collector.ParseCandleIntervals(*candles)
+		if err != nil {
+			slog.Error("bad --candles", "err", err)
+			os.Exit(1)
+		}
+		sink, err := newCandleSink(*candleFormat, filepath.Join(cfg.OutputDir, "candles"), prefix,
+			collector.Compression(*compress), encryptKey)
+		if err != nil {
+			slog.Error("candle sink init failed", "err", err)
+			os.Exit(1)
+		}
+		defer sink.Close()
+		c.EnableCandles(intervals, sink)
+		slog.Info("candles enabled", "intervals", *candles, "format", *candleFormat)
+	}
+	if *forecast > 0 {
+		model := ticks.ForecastModel{Paths: *forecastPaths, Drift: *forecastDrift}
+		c.EnableForecast(collector.NewForecaster(model, *forecast))
+		slog.Info("forecasts enabled", "every", *forecast, "method", model.Method())
+	}
+	c.SetInterval(cfg.TickInterval)
+	if cfg.InfluxURL != "" {
+		sink := collector.NewInfluxSink(cfg.InfluxURL, cfg.InfluxToken, *influxMarkets)
+		go sink.Run(ctx, 5*time.Second)
+		c.EnableInflux(sink)
+		slog.Info("influx metrics enabled", "markets", *influxMarkets)
+	}
+
+	// Reload tunables from .env on SIGHUP or POST /reload. Feeds that stay
+	// in the set, and the Kalshi WS, keep their connections.
+	var reloadMu sync.Mutex
+	reload := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		if allowlist != nil {
+			if err := allowlist.Reload(ctx); err != nil {
+				return err
+			}
+		}
+		fresh, err := config.Reload()
+		if err != nil {
+			return err
+		}
+		if *series != "" {
+			fresh.SeriesTicker = *series
+		}
+		feeds, err := running.apply(fresh.Feeds)
+		if err != nil {
+			return err
+		}
+		proxy.SetFeeds(feeds)
+		c.SetFeeds(feeds)
+
+		n := fresh.Notifier()
+		c.Reconfigure(fresh.SeriesTicker, n)
+		deadLetter.SetNotifier(n)
+		c.SetInterval(fresh.TickInterval)
+		if !*debug {
+			logLevel.Set(fresh.LogLevel)
+		}
+		slog.Info("config reloaded", "series", fresh.SeriesTicker, "feeds", fresh.Feeds,
+			"interval", fresh.TickInterval, "log_level", logLevel.Level(), "alerts", n != nil)
+		return nil
+	}
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				if err := reload(); err != nil {
+					slog.Error("config reload failed", "err", err)
+				}
+			}
+		}
+	}()
+
+	if *control != "" {
+		srv := collector.NewControlServer(*control, c, reload)
+		srv.SetFrameLog(frames)
+		go func() {
+			if err := srv.Run(ctx); err != nil {
+				slog.Error("control server failed", "err", err)
+			}
+		}()
+	}
+	if err := c.Run(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("collector error", "err", err)
+		// os.Exit skips defers; make sure buffered records hit disk.
+		if err := writer.Close(); err != nil {
+			slog.Error("writer close failed", "err", err)
+		}
+		os.Exit(1)
+	}
+	if leaseLost.Load() {
+		// Exit non-zero so a supervisor restarts this collector as a standby.
+		writer.Close()
+		os.Exit(1)
+	}
+
+	reason, _ := stopReason.Load().(string)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancelDrain()
+	if err := c.Drain(drainCtx, reason, *shutdownSettle); err != nil {
+		slog.Warn("shutdown drain incomplete", "err", err)
+	}
+
+	slog.Info("collector stopped")
+}
+
+// feedSet runs the exchange feeds named in the config. apply starts and
+// stops individual feeds, so those that stay in the set keep their
+// connections across a reload.
+type feedSet struct {
+	ctx       context.Context
+	urls      map[string]string // endpoint overrides, from FEED_URLS
+	restAfter time.Duration     // REST fallback after this long without WS data; 0 = off
+	restEvery time.Duration
+	frames    *framelog.Ring // raw frame capture; nil when off
+	running   map[string]runningFeed
+}
+
+type runningFeed struct {
+	feed   brti.ExchangeFeed
+	cancel context.CancelFunc
+}
+
+func newFeedSet(ctx context.Context, urls map[string]string, restAfter, restEvery time.Duration, frames *framelog.Ring) *feedSet {
+	return &feedSet{ctx: ctx, urls: urls, restAfter: restAfter, restEvery: restEvery, frames: frames, running: make(map[string]runningFeed)}
+}
+
+// apply makes names the running set and returns its feeds in that order.
+func (s *feedSet) apply(names []string) ([]brti.ExchangeFeed, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no feeds configured")
+	}
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		if _, ok := s.running[n]; !ok {
+			if _, err := feed.NewByName(n); err != nil {
+				return nil, err
+			}
+		}
+		want[n] = true
+	}
+
+	for name, r := range s.running {
+		if !want[name] {
+			r.cancel()
+			delete(s.running, name)
+			slog.Info("feed stopped", "feed", name)
+		}
+	}
+
+	out := make([]brti.ExchangeFeed, 0, len(names))
+	for _, n := range names {
+		r, ok := s.running[n]
+		if !ok {
+			f, _ := feed.NewByNameURL(n, s.urls[n])
+			if fl, ok := f.(interface{ SetFrameLog(*framelog.Ring) }); ok {
+				fl.SetFrameLog(s.frames)
+			}
+			if s.restAfter > 0 {
+				rf, err := feed.WithRESTFallback(f, s.restAfter, s.restEvery)
+				if err != nil {
+					return nil, err
+				}
+				f = rf
+			}
+			ctx, cancel := context.WithCancel(s.ctx)
+			r = runningFeed{feed: f, cancel: cancel}
+			s.running[n] = r
+			go func() {
+				if err := f.Run(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("feed error", "feed", f.Name(), "err", err)
+				}
+			}()
+		}
+		out = append(out, r.feed)
+	}
+	return out, nil
+}
+
+// newCandleSink opens the candle output under dir: rotating, compressed
+// (and, with a key, encrypted) JSONL like the tick files, or plain daily CSV.
+func newCandleSink(format, dir, prefix string, compress collector.Compression, key []byte) (collector.CandleSink, error) {
+	switch format {
+	case "jsonl":
+		w, err := collector.NewWriterOptions(dir, prefix, collector.WriterOptions{Compression: compress, EncryptKey: key})
+		if err != nil {
+			return nil, err
+		}
+		w.CompressStale()
+		return collector.NewJSONLCandleSink(w), nil
+	case "csv":
+		return collector.NewCSVCandleSink(dir, prefix)
+	}
+	return nil, fmt.Errorf("unknown candle format %q (want jsonl or csv)", format)
+}
+
+func waitForWS(ctx context.Context, ws *kalshi.KalshiFeed) {
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			slog.Warn("kalshi ws timed out, using REST fallback")
+			return
+		case <-tick.C:
+			if ws.IsConnected() {
+				slog.Info("kalshi ws ready")
+				return
+			}
+		}
+	}
+}
+
+func waitForFeeds(ctx context.Context, feeds []brti.ExchangeFeed) {
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			slog.Warn("timed out waiting for feeds")
+			return
+		case <-tick.C:
+			for _, f := range feeds {
+				if !f.IsStale() {
+					slog.Info("feed connected", "feed", f.Name())
+					return
+				}
+			}
+		}
+	}
+}
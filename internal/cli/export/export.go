@@ -0,0 +1,529 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+var (
+	flags = flag.NewFlagSet("export", flag.ExitOnError)
+
+	output  = flags.String("o", "", "output file (.csv, .parquet, .jsonl or .jsonl.gz)")
+	format  = flags.String("format", "", "csv, parquet or jsonl (default: from output extension)")
+	layout  = flags.String("layout", "long", "long: one row per market per tick; wide: one row per tick with the front market (csv and parquet)")
+	from    = flags.String("from", "", "only ticks at or after this time (RFC3339 or YYYY-MM-DD)")
+	to      = flags.String("to", "", "only ticks before this time (RFC3339 or YYYY-MM-DD)")
+	tickers = flags.String("tickers", "", "comma-separated ticker prefixes to keep (default all)")
+
+	public        = flags.Bool("public", false, "publishable output: market data only, normalized timestamps and prices, and a manifest next to the output")
+	tsPrecision   = flags.Duration("ts-precision", time.Second, "with --public, truncate timestamps to this, dropping later ticks in the same interval")
+	priceDecimals = flags.Int("price-decimals", 2, "with --public, round USD prices to this many decimals")
+)
+
+// longRow is one market in one tick. Columns match btcdata/loader.py.
+type longRow struct {
+	Ts           time.Time `parquet:"ts,timestamp(microsecond)"`
+	BRTI         float64   `parquet:"brti"`
+	Coinbase     float64   `parquet:"coinbase"`
+	Kraken       float64   `parquet:"kraken"`
+	Bitstamp     float64   `parquet:"bitstamp"`
+	Ticker       string    `parquet:"ticker"`
+	YesBid       int32     `parquet:"yes_bid"`
+	YesAsk       int32     `parquet:"yes_ask"`
+	LastPrice    int32     `parquet:"last_price"`
+	Volume       int32     `parquet:"volume"`
+	OpenInterest int32     `parquet:"open_interest"`
+	Strike       float64   `parquet:"strike"`
+	StrikeType   string    `parquet:"strike_type"`
+	StrikeFloor  float64   `parquet:"strike_floor"`
+	StrikeCap    float64   `parquet:"strike_cap"`
+	SecsLeft     int32     `parquet:"secs_left"`
+	Status       string    `parquet:"status"`
+	Result       string    `parquet:"result"`
+	Settlement   float64   `parquet:"settlement_value"`
+	YesBook      string    `parquet:"yes_book"` // JSON [[price, qty], ...]
+	NoBook       string    `parquet:"no_book"`
+	YesDepth5    int32     `parquet:"yes_depth5"`
+	NoDepth5     int32     `parquet:"no_depth5"`
+	Imbalance    float64   `parquet:"imbalance"`
+	Microprice   float64   `parquet:"microprice"`
+}
+
+var longHeader = []string{
+	"ts", "brti", "coinbase", "kraken", "bitstamp", "ticker", "yes_bid", "yes_ask",
+	"last_price", "volume", "open_interest", "strike", "strike_type", "strike_floor", "strike_cap",
+	"secs_left", "status", "result", "settlement_value", "yes_book", "no_book", "yes_depth5",
+	"no_depth5", "imbalance", "microprice",
+}
+
+func (r longRow) csvRecord() []string {
+	return []string{
+		r.Ts.Format(time.RFC3339Nano), ftoa(r.BRTI), ftoa(r.Coinbase), ftoa(r.Kraken), ftoa(r.Bitstamp),
+		r.Ticker, itoa(r.YesBid), itoa(r.YesAsk), itoa(r.LastPrice), itoa(r.Volume),
+		itoa(r.OpenInterest), ftoa(r.Strike), r.StrikeType, ftoa(r.StrikeFloor), ftoa(r.StrikeCap),
+		itoa(r.SecsLeft), r.Status, r.Result, ftoa(r.Settlement), r.YesBook, r.NoBook,
+		itoa(r.YesDepth5), itoa(r.NoDepth5), ftoa(r.Imbalance), ftoa(r.Microprice),
+	}
+}
+
+// wideRow is one tick with the front market (nearest unexpired) flattened in.
+type wideRow struct {
+	Ts        time.Time `parquet:"ts,timestamp(microsecond)"`
+	BRTI      float64   `parquet:"brti"`
+	Coinbase  float64   `parquet:"coinbase"`
+	Kraken    float64   `parquet:"kraken"`
+	Bitstamp  float64   `parquet:"bitstamp"`
+	Markets   int32     `parquet:"markets"`
+	Ticker    string    `parquet:"ticker"`
+	YesBid    int32     `parquet:"yes_bid"`
+	YesAsk    int32     `parquet:"yes_ask"`
+	LastPrice int32     `parquet:"last_price"`
+	Volume    int32     `parquet:"volume"`
+	Strike    float64   `parquet:"strike"`
+	SecsLeft  int32     `parquet:"secs_left"`
+	Status    string    `parquet:"status"`
+}
+
+var wideHeader = []string{
+	"ts", "brti", "coinbase", "kraken", "bitstamp", "markets", "ticker", "yes_bid",
+	"yes_ask", "last_price", "volume", "strike", "secs_left", "status",
+}
+
+func (r wideRow) csvRecord() []string {
+	return []string{
+		r.Ts.Format(time.RFC3339Nano), ftoa(r.BRTI), ftoa(r.Coinbase), ftoa(r.Kraken), ftoa(r.Bitstamp),
+		itoa(r.Markets), r.Ticker, itoa(r.YesBid), itoa(r.YesAsk), itoa(r.LastPrice),
+		itoa(r.Volume), ftoa(r.Strike), itoa(r.SecsLeft), r.Status,
+	}
+}
+
+// Main runs the export command with args, the command line after its name.
+func Main(args []string) {
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || *output == "" {
+		log.Fatal("Usage: export -o out.csv|out.parquet|out.jsonl [--layout=long|wide] [--from=..] [--to=..] [--tickers=..] [--public] <jsonl-file-paths...>")
+	}
+
+	outFormat := *format
+	if outFormat == "" {
+		outFormat = strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(*output, ".gz")), ".")
+	}
+	if outFormat != "csv" && outFormat != "parquet" && outFormat != "jsonl" {
+		log.Fatalf("unsupported format %q (want csv, parquet or jsonl)", outFormat)
+	}
+	if *public && *tsPrecision <= 0 {
+		log.Fatal("--ts-precision must be positive")
+	}
+
+	fromT, err := parseBound(*from)
+	if err != nil {
+		log.Fatalf("--from: %v", err)
+	}
+	toT, err := parseBound(*to)
+	if err != nil {
+		log.Fatalf("--to: %v", err)
+	}
+	var prefixes []string
+	if *tickers != "" {
+		prefixes = strings.Split(*tickers, ",")
+	}
+
+	paths := expandPaths(flags.Args())
+	if len(paths) == 0 {
+		log.Fatal("no input files matched")
+	}
+
+	f := filter{from: fromT, to: toT, prefixes: prefixes}
+	var man *ticks.Manifest
+	if *public {
+		man = &ticks.Manifest{Markets: make(map[string]int)}
+	}
+	var n int
+	switch {
+	case outFormat == "jsonl":
+		n, err = exportJSONL(paths, f, man)
+	case *layout == "long":
+		n, err = export(paths, outFormat, longHeader, f, man, longRows)
+	case *layout == "wide":
+		n, err = export(paths, outFormat, wideHeader, f, man, wideRows)
+	default:
+		log.Fatalf("unknown layout %q", *layout)
+	}
+	if err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+	log.Printf("Wrote %d rows from %d files to %s", n, len(paths), *output)
+
+	if man != nil {
+		if err := saveManifest(man, n); err != nil {
+			log.Fatalf("writing manifest: %v", err)
+		}
+		log.Printf("Wrote manifest %s", ticks.ManifestPath(*output))
+	}
+}
+
+type filter struct {
+	from, to time.Time
+	prefixes []string
+}
+
+func (f filter) keepTime(ts time.Time) bool {
+	if !f.from.IsZero() && ts.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !ts.Before(f.to) {
+		return false
+	}
+	return true
+}
+
+func (f filter) keepTicker(t string) bool {
+	if len(f.prefixes) == 0 {
+		return true
+	}
+	for _, p := range f.prefixes {
+		if strings.HasPrefix(t, strings.TrimSpace(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+type row interface {
+	csvRecord() []string
+}
+
+func export[T row](paths []string, outFormat string, header []string, f filter, man *ticks.Manifest, rows func(ticks.TickRecord, time.Time, filter) []T) (int, error) {
+	out, err := os.Create(*output)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var write func([]T) error
+	var finish func() error
+	if outFormat == "csv" {
+		cw := csv.NewWriter(out)
+		if err := cw.Write(header); err != nil {
+			return 0, err
+		}
+		write = func(rs []T) error {
+			for _, r := range rs {
+				if err := cw.Write(r.csvRecord()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		pw := parquet.NewGenericWriter[T](out)
+		write = func(rs []T) error {
+			_, err := pw.Write(rs)
+			return err
+		}
+		finish = pw.Close
+	}
+
+	n := 0
+	err = readTicks(paths, f, man, func(rec ticks.TickRecord, ts time.Time) error {
+		rs := rows(rec, ts, f)
+		n += len(rs)
+		return write(rs)
+	})
+	if err != nil {
+		return n, err
+	}
+	if err := finish(); err != nil {
+		return n, err
+	}
+	return n, out.Close()
+}
+
+// exportJSONL writes whole tick records, narrowed to the kept tickers,
+// gzipped if the output name ends in .gz.
+func exportJSONL(paths []string, f filter, man *ticks.Manifest) (int, error) {
+	out, err := os.Create(*output)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(*output, ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	bw := bufio.NewWriter(w)
+
+	n := 0
+	err = readTicks(paths, f, man, func(rec ticks.TickRecord, ts time.Time) error {
+		if len(f.prefixes) > 0 {
+			rec.Markets = keepMarkets(rec.Markets, f)
+			var events []ticks.EventSnap
+			for _, ev := range rec.Events {
+				if ev.Markets = keepMarkets(ev.Markets, f); len(ev.Markets) > 0 {
+					events = append(events, ev)
+				}
+			}
+			rec.Events = events
+			if len(rec.Markets) == 0 && len(rec.Events) == 0 {
+				return nil
+			}
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		n++
+		bw.Write(line)
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return n, err
+	}
+	if err := bw.Flush(); err != nil {
+		return n, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return n, err
+		}
+	}
+	return n, out.Close()
+}
+
+func keepMarkets(ms []ticks.MarketSnap, f filter) []ticks.MarketSnap {
+	var kept []ticks.MarketSnap
+	for _, m := range ms {
+		if f.keepTicker(m.Ticker) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// readTicks calls fn with each tick in the filter's time range. Under
+// --public the tick is first normalized (see publicize) and man, when not
+// nil, tallies what was exported.
+func readTicks(paths []string, f filter, man *ticks.Manifest, fn func(ticks.TickRecord, time.Time) error) error {
+	var last time.Time
+	return ticks.ReadFiles(paths, func(rec ticks.TickRecord) error {
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil || !f.keepTime(ts) {
+			return nil
+		}
+		if *public {
+			ts = ts.Truncate(*tsPrecision)
+			if !ts.After(last) {
+				return nil
+			}
+			last = ts
+			publicize(&rec, ts)
+		}
+		if man != nil {
+			man.Ticks++
+			if man.First == "" {
+				man.First = rec.Ts
+			}
+			man.Last = rec.Ts
+			for _, m := range rec.AllMarkets() {
+				if f.keepTicker(m.Ticker) {
+					man.Markets[m.Ticker]++
+				}
+			}
+		}
+		return fn(rec, ts)
+	})
+}
+
+// publicize keeps only what a tick says about the market: it drops the
+// collector's latency, receipt times and bookkeeping flags and the
+// account's exposure, and rounds timestamps and USD prices so records from
+// different collectors look alike. Markets are copied, as the Reader keeps
+// the originals to decode later records.
+func publicize(rec *ticks.TickRecord, ts time.Time) {
+	rec.Ts = ts.UTC().Format(time.RFC3339Nano)
+	rec.BRTI, rec.Coinbase = roundPrice(rec.BRTI), roundPrice(rec.Coinbase)
+	rec.Kraken, rec.Bitstamp = roundPrice(rec.Kraken), roundPrice(rec.Bitstamp)
+	rec.Binance = roundPrice(rec.Binance)
+	rec.Latency = nil
+	rec.Source, rec.FeedSources = "", nil
+	rec.Exposure = nil
+	if rec.Window != nil {
+		w := *rec.Window
+		w.Open, w.High, w.Low, w.TWAP = roundPrice(w.Open), roundPrice(w.High), roundPrice(w.Low), roundPrice(w.TWAP)
+		rec.Window = &w
+	}
+
+	clean := func(ms []ticks.MarketSnap) []ticks.MarketSnap {
+		ms = slices.Clone(ms)
+		for i := range ms {
+			m := &ms[i]
+			m.TickerTs, m.BookTs = "", ""
+			m.Fresh, m.Unchanged = false, false
+			m.SettlementValue = roundPrice(m.SettlementValue)
+		}
+		return ms
+	}
+	rec.Markets = clean(rec.Markets)
+	events := make([]ticks.EventSnap, len(rec.Events))
+	for i, ev := range rec.Events {
+		ev.Markets = clean(ev.Markets)
+		events[i] = ev
+	}
+	rec.Events = events
+}
+
+func roundPrice(p float64) float64 {
+	scale := math.Pow(10, float64(*priceDecimals))
+	return math.Round(p*scale) / scale
+}
+
+// saveManifest completes man with the output file's checksum and writes
+// it next to the output.
+func saveManifest(man *ticks.Manifest, rows int) error {
+	m, err := ticks.FileManifest(*output)
+	if err != nil {
+		return err
+	}
+	m.Records = rows
+	m.Ticks, m.First, m.Last, m.Markets = man.Ticks, man.First, man.Last, man.Markets
+	return m.Save(*output)
+}
+
+func longRows(rec ticks.TickRecord, ts time.Time, f filter) []longRow {
+	var rs []longRow
+	for _, m := range rec.AllMarkets() {
+		if !f.keepTicker(m.Ticker) {
+			continue
+		}
+		r := longRow{
+			Ts:           ts,
+			BRTI:         rec.BRTI,
+			Coinbase:     rec.Coinbase,
+			Kraken:       rec.Kraken,
+			Bitstamp:     rec.Bitstamp,
+			Ticker:       m.Ticker,
+			YesBid:       int32(m.YesBid),
+			YesAsk:       int32(m.YesAsk),
+			LastPrice:    int32(m.LastPrice),
+			Volume:       int32(m.Volume),
+			OpenInterest: int32(m.OpenInt),
+			Strike:       m.Strike,
+			StrikeType:   m.StrikeType,
+			StrikeFloor:  m.StrikeFloor,
+			StrikeCap:    m.StrikeCap,
+			SecsLeft:     int32(m.SecsLeft),
+			Status:       m.Status,
+			Result:       m.Result,
+			Settlement:   m.SettlementValue,
+			YesBook:      bookJSON(m.YesBook),
+			NoBook:       bookJSON(m.NoBook),
+		}
+		if b := m.Book; b != nil {
+			r.YesDepth5 = int32(b.YesDepth5)
+			r.NoDepth5 = int32(b.NoDepth5)
+			r.Imbalance = b.Imbalance
+			r.Microprice = b.Microprice
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func wideRows(rec ticks.TickRecord, ts time.Time, f filter) []wideRow {
+	r := wideRow{
+		Ts:       ts,
+		BRTI:     rec.BRTI,
+		Coinbase: rec.Coinbase,
+		Kraken:   rec.Kraken,
+		Bitstamp: rec.Bitstamp,
+	}
+
+	var front *ticks.MarketSnap
+	markets := rec.AllMarkets()
+	for i := range markets {
+		m := &markets[i]
+		if !f.keepTicker(m.Ticker) {
+			continue
+		}
+		r.Markets++
+		if m.SecsLeft > 0 && (front == nil || m.SecsLeft < front.SecsLeft) {
+			front = m
+		}
+	}
+	if len(f.prefixes) > 0 && r.Markets == 0 {
+		return nil
+	}
+	if front != nil {
+		r.Ticker = front.Ticker
+		r.YesBid = int32(front.YesBid)
+		r.YesAsk = int32(front.YesAsk)
+		r.LastPrice = int32(front.LastPrice)
+		r.Volume = int32(front.Volume)
+		r.Strike = front.Strike
+		r.SecsLeft = int32(front.SecsLeft)
+		r.Status = front.Status
+	}
+	return []wideRow{r}
+}
+
+// parseBound accepts RFC3339 or a bare UTC date.
+func parseBound(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func expandPaths(patterns []string) []string {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Error expanding pattern %s: %v", pattern, err)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func bookJSON(levels [][2]int) string {
+	if len(levels) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(levels)
+	return string(b)
+}
+
+func ftoa(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+func itoa(i int32) string { return strconv.Itoa(int(i)) }
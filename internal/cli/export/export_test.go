@@ -0,0 +1,85 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// exportPublic runs a --public JSONL export of lines and returns the
+// exported records as generic JSON objects.
+func exportPublic(t *testing.T, lines ...string) []map[string]any {
+	t.Helper()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"o": filepath.Join(dir, "out.jsonl.gz"), "public": "true"} {
+		if err := flags.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { flags.Set("public", "false") })
+
+	man := &ticks.Manifest{Markets: make(map[string]int)}
+	if _, err := exportJSONL([]string{in}, filter{}, man); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(*output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad output line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestPublicExportDropsAccountAndBookkeeping(t *testing.T) {
+	recs := exportPublic(t,
+		`{"type":"tick","schema_version":17,"ts":"2025-01-01T00:00:00.123Z","brti":97000.123,"coinbase":97001,"kraken":97002,"bitstamp":97003,`+
+			`"events":[{"event":"KXBTC15M-25JAN010015","expiry":"2025-01-01T00:15:00Z","markets":[{"ticker":"KXBTC15M-25JAN010015-T97000","yes_bid":40,"yes_ask":42,"last_price":41,"volume":10,"open_interest":5,"secs_left":900}]}],`+
+			`"source":"rest","feed_sources":{"kraken":"rest"},`+
+			`"exposure":{"KXBTC15M-25JAN010015-T97000":{"position":12,"cost":480,"realized_pnl":-35,"fees_paid":7,"resting_orders":2,"resting_yes":5}}}`,
+	)
+	if len(recs) != 1 {
+		t.Fatalf("exported %d records, want 1", len(recs))
+	}
+	rec := recs[0]
+	if _, ok := rec["exposure"]; ok {
+		t.Errorf("public export kept the account's exposure: %v", rec["exposure"])
+	}
+	for _, key := range []string{"source", "feed_sources"} {
+		if _, ok := rec[key]; ok {
+			t.Errorf("public export kept collector bookkeeping %s: %v", key, rec[key])
+		}
+	}
+	if rec["ts"] != "2025-01-01T00:00:00Z" || rec["brti"] != 97000.12 {
+		t.Errorf("ts, brti = %v, %v; want normalized", rec["ts"], rec["brti"])
+	}
+	if events, _ := rec["events"].([]any); len(events) != 1 {
+		t.Errorf("events = %v, want the market data kept", rec["events"])
+	}
+}
@@ -0,0 +1,341 @@
+package retrofit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// record is one line of a tick file. Only ticks are decoded, and only they
+// are re-encoded; divergence, gap and every other record type is written
+// back exactly as read.
+type record struct {
+	tick *ticks.TickRecord // nil for records other than ticks
+	raw  []byte
+}
+
+type MarketTracker struct {
+	Ticker      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	MinSecsLeft int
+	Expiry      time.Time
+	NeedsFetch  bool
+}
+
+var (
+	flags = flag.NewFlagSet("retrofit", flag.ExitOnError)
+
+	dryRun          = flags.Bool("dry-run", false, "Preview changes without writing")
+	settlementDelay = flags.Int("delay", 5, "Minutes to wait after expiry before fetching settlement")
+)
+
+// Main runs the retrofit command with args, the command line after its name.
+func Main(args []string) {
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		log.Fatal("Usage: retrofit [--dry-run] [--delay=5] <jsonl-file-paths...>")
+	}
+
+	// Load config for Kalshi client
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Loading config: %v", err)
+	}
+
+	client, err := kalshi.NewClient(cfg)
+	if err != nil {
+		log.Fatalf("Creating Kalshi client: %v", err)
+	}
+
+	// Process each file
+	for _, pattern := range flags.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Error expanding pattern %s: %v", pattern, err)
+			continue
+		}
+
+		for _, filePath := range matches {
+			if err := processFile(client, filePath); err != nil {
+				log.Printf("Error processing %s: %v", filePath, err)
+			}
+		}
+	}
+}
+
+func processFile(client kalshi.API, filePath string) error {
+	log.Printf("Scanning %s...", filePath)
+
+	// Step 1: Scan file and build market tracker + record list
+	records, markets, err := scanFile(filePath)
+	if err != nil {
+		return fmt.Errorf("scanning file: %w", err)
+	}
+
+	log.Printf("  Found %d records, %d unique markets", len(records), len(markets))
+
+	// Step 2: Identify expired markets needing settlement
+	now := time.Now()
+	delay := time.Duration(*settlementDelay) * time.Minute
+	var needsFetch []string
+
+	for ticker, tracker := range markets {
+		// Check if market has settlement info already
+		hasSettlement := false
+		for _, rec := range records {
+			if rec.tick == nil {
+				continue
+			}
+			for _, snap := range rec.tick.AllMarkets() {
+				if snap.Ticker == ticker && snap.Status != "" {
+					hasSettlement = true
+					break
+				}
+			}
+			if hasSettlement {
+				break
+			}
+		}
+
+		if hasSettlement {
+			continue
+		}
+
+		// Check if expired + delay has passed
+		if now.After(tracker.Expiry.Add(delay)) {
+			needsFetch = append(needsFetch, ticker)
+		}
+	}
+
+	if len(needsFetch) == 0 {
+		log.Printf("  No markets need settlement data")
+		return nil
+	}
+
+	sort.Strings(needsFetch)
+	log.Printf("  Identified %d expired markets needing settlement", len(needsFetch))
+
+	if *dryRun {
+		log.Printf("  [DRY RUN] Would fetch: %v", needsFetch)
+		return nil
+	}
+
+	// Step 3: Fetch settlements from Kalshi API
+	settlements := make(map[string]*kalshi.Market)
+	ctx := context.Background()
+
+	log.Printf("Fetching settlements from Kalshi API...")
+	for i, ticker := range needsFetch {
+		log.Printf("  [%d/%d] %s...", i+1, len(needsFetch), ticker)
+
+		market, err := client.GetMarket(ctx, ticker)
+		if errors.Is(err, kalshi.ErrRateLimited) || errors.Is(err, kalshi.ErrMaintenance) {
+			wait := kalshi.RetryAfter(err)
+			if wait == 0 {
+				wait = 10 * time.Second
+			}
+			log.Printf("    %v, retrying in %s", err, wait)
+			time.Sleep(wait)
+			market, err = client.GetMarket(ctx, ticker)
+		}
+		if errors.Is(err, kalshi.ErrNotFound) {
+			log.Printf("    not found, skipping")
+			continue
+		}
+		if err != nil {
+			log.Printf("    ERROR: %v", err)
+			continue
+		}
+
+		settlements[ticker] = market
+		log.Printf("    status=%s, result=%s, value=%s", market.Status, market.Result, market.ExpirationValue)
+
+		// Rate limit: 1 request per second
+		if i < len(needsFetch)-1 {
+			time.Sleep(1 * time.Second)
+		}
+	}
+
+	if len(settlements) == 0 {
+		log.Printf("  No settlements fetched")
+		return nil
+	}
+
+	// Step 4: Update records in memory
+	log.Printf("Updating records...")
+	updatedCount := applySettlements(records, settlements)
+
+	log.Printf("  Updated %d market snapshots across %d settlements", updatedCount, len(settlements))
+
+	// Step 5: Write file with backup
+	backupPath := filePath + ".pre-retrofit.jsonl"
+	if err := copyFile(filePath, backupPath); err != nil {
+		return fmt.Errorf("creating backup: %w", err)
+	}
+	log.Printf("  Backup: %s", backupPath)
+
+	if err := writeRecords(filePath, records); err != nil {
+		return fmt.Errorf("writing updated file: %w", err)
+	}
+
+	log.Printf("Done! Retrofitted %d markets in %s", len(settlements), filePath)
+	return nil
+}
+
+// applySettlements writes the status and result of each settled market into
+// its snapshots in the ticks of records, returning how many it updated.
+func applySettlements(records []record, settlements map[string]*kalshi.Market) int {
+	updated := 0
+	for _, rec := range records {
+		if rec.tick == nil {
+			continue
+		}
+		rec.tick.EachMarket(func(snap *ticks.MarketSnap) {
+			if settlement, ok := settlements[snap.Ticker]; ok {
+				snap.Status = settlement.Status
+				snap.Result = settlement.Result
+				snap.SettlementValue, _ = settlement.SettlementValue()
+				updated++
+			}
+		})
+	}
+	return updated
+}
+
+func scanFile(filePath string) ([]record, map[string]*MarketTracker, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	markets := make(map[string]*MarketTracker)
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var env struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(line), &env); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		switch env.Type {
+		case "tick":
+		case "delta":
+			return nil, nil, fmt.Errorf("line %d: delta-encoded files are not supported", lineNum)
+		default:
+			records = append(records, record{raw: []byte(line)})
+			continue
+		}
+
+		rec := new(ticks.TickRecord)
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		records = append(records, record{tick: rec})
+
+		// Parse timestamp
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			// Try RFC3339 without nano
+			ts, err = time.Parse(time.RFC3339, rec.Ts)
+			if err != nil {
+				continue
+			}
+		}
+
+		// Track markets
+		for _, snap := range rec.AllMarkets() {
+			tracker, exists := markets[snap.Ticker]
+			if !exists {
+				tracker = &MarketTracker{
+					Ticker:      snap.Ticker,
+					FirstSeen:   ts,
+					LastSeen:    ts,
+					MinSecsLeft: snap.SecsLeft,
+				}
+				markets[snap.Ticker] = tracker
+			}
+
+			if ts.After(tracker.LastSeen) {
+				tracker.LastSeen = ts
+				tracker.MinSecsLeft = snap.SecsLeft
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Calculate expiry times
+	for _, tracker := range markets {
+		tracker.Expiry = tracker.LastSeen.Add(time.Duration(tracker.MinSecsLeft) * time.Second)
+	}
+
+	return records, markets, nil
+}
+
+func writeRecords(filePath string, records []record) error {
+	tmpPath := filePath + ".tmp"
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	for _, rec := range records {
+		var err error
+		if rec.tick != nil {
+			err = encoder.Encode(rec.tick)
+		} else {
+			_, err = f.Write(append(rec.raw, '\n'))
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Atomic rename
+	return os.Rename(tmpPath, filePath)
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
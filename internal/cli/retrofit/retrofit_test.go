@@ -0,0 +1,115 @@
+package retrofit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+func tickLine(t *testing.T, ts string) string {
+	t.Helper()
+	rec := ticks.TickRecord{
+		Type: "tick", SchemaVersion: ticks.CurrentSchemaVersion, Ts: ts, BRTI: 97000.5,
+		Events: []ticks.EventSnap{{Markets: []ticks.MarketSnap{{Ticker: "KXBTC15M-T1", YesBid: 40, YesAsk: 42, SecsLeft: 60}}}},
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// Records other than ticks must survive a retrofit byte for byte, with
+// fields retrofit knows nothing about.
+var otherLines = []string{
+	`{"type":"divergence","ts":"2025-01-01T00:00:01Z","state":"start","feed_a":"coinbase","feed_b":"kraken","price_a":97010.5,"price_b":96950,"diff":60.5,"max_diff":60.5,"since":"2025-01-01T00:00:00Z","duration_secs":30}`,
+	`{"type":"gap","ts":"2025-01-01T00:00:02Z","from":"2024-12-31T23:50:00Z","seconds":600,"reason":"restart","clean":false}`,
+	`{"type":"ladder","ts":"2025-01-01T00:00:03Z","brti":97000,"events":[{"markets":[{"ticker":"KXBTC15M-T1","yes_bid":1,"yes_ask":2,"last_price":0,"volume":0,"open_interest":0,"secs_left":0}]}]}`,
+	`{"type":"future_kind","ts":"2025-01-01T00:00:04Z","nested":{"a":[1,2,3]},"spacing" :  "kept"}`,
+}
+
+func writeFile(t *testing.T, lines []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ticks.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRoundTripKeepsOtherRecords(t *testing.T) {
+	lines := []string{tickLine(t, "2025-01-01T00:00:00Z")}
+	lines = append(lines, otherLines...)
+	lines = append(lines, tickLine(t, "2025-01-01T00:00:05Z"))
+	path := writeFile(t, lines)
+	want, _ := os.ReadFile(path)
+
+	records, markets, err := scanFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(lines) {
+		t.Fatalf("scanned %d records, want %d", len(records), len(lines))
+	}
+	if len(markets) != 1 {
+		t.Fatalf("tracked %d markets, want 1 (from ticks only)", len(markets))
+	}
+	if err := writeRecords(path, records); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("round trip changed the file\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSettlementsOnlyTouchTicks(t *testing.T) {
+	lines := append([]string{tickLine(t, "2025-01-01T00:00:00Z")}, otherLines...)
+	path := writeFile(t, lines)
+
+	records, _, err := scanFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := applySettlements(records, map[string]*kalshi.Market{
+		"KXBTC15M-T1": {Ticker: "KXBTC15M-T1", Status: "finalized", Result: "yes", ExpirationValue: "97,001.25"},
+	})
+	if n != 1 {
+		t.Fatalf("updated %d snapshots, want 1", n)
+	}
+	if err := writeRecords(path, records); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(got) != len(lines) {
+		t.Fatalf("wrote %d lines, want %d", len(got), len(lines))
+	}
+	var tick ticks.TickRecord
+	if err := json.Unmarshal([]byte(got[0]), &tick); err != nil {
+		t.Fatal(err)
+	}
+	m := tick.AllMarkets()[0]
+	if m.Status != "finalized" || m.Result != "yes" || m.SettlementValue != 97001.25 {
+		t.Errorf("tick market = %+v, want settled", m)
+	}
+	for i, line := range otherLines {
+		if got[i+1] != line {
+			t.Errorf("line %d changed\n got: %s\nwant: %s", i+2, got[i+1], line)
+		}
+	}
+}
+
+func TestRejectsDeltaFiles(t *testing.T) {
+	path := writeFile(t, []string{tickLine(t, "2025-01-01T00:00:00Z"), `{"type":"delta","ts":"2025-01-01T00:00:01Z"}`})
+	if _, _, err := scanFile(path); err == nil || !strings.Contains(err.Error(), "delta") {
+		t.Fatalf("scanFile = %v, want delta error", err)
+	}
+}
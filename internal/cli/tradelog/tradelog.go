@@ -0,0 +1,1287 @@
+package tradelog
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/internal/tradelog"
+	"github.com/gw/btc15m-data/internal/tradelog/web"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Main runs the tradelog command with args, the command line after its name.
+func Main(args []string) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	fs := flag.NewFlagSet("tradelog", flag.ExitOnError)
+	fs.StringVar(&dbDSN, "db", "", "trade database: SQLite path or postgres:// URL (default $TRADELOG_DSN or data/tradelog.db)")
+	envFile := fs.String("env", "", "dotenv file to read instead of .env")
+	fs.BoolVar(&jsonOut, "json", false, "print results as JSON instead of tables")
+	fs.StringVar(&account, "account", "", "only this Kalshi account (default: every account in $KALSHI_ACCOUNTS)")
+	fs.Usage = usage
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(1)
+	}
+	if *envFile != "" {
+		if err := config.SetEnvFile(*envFile); err != nil {
+			slog.Error("config error", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+
+	switch cmd {
+	case "sync":
+		runSync()
+	case "migrate":
+		runMigrate(args)
+	case "import-csv":
+		runImportCSV(args)
+	case "pnl":
+		runPnL(args)
+	case "pnl-entry":
+		runPnLByEntry()
+	case "positions":
+		runPositions(args, false)
+	case "open":
+		runPositions(args, true)
+	case "trades":
+		runTrades(args)
+	case "reconcile":
+		runReconcile(args)
+	case "execquality":
+		runExecQuality(args)
+	case "orders":
+		runOrders(args)
+	case "flatten":
+		runFlatten(args)
+	case "watch":
+		runWatch(args)
+	case "taxreport":
+		runTaxReport(args)
+	case "backup":
+		runBackup(args)
+	case "restore":
+		runRestore(args)
+	case "query":
+		runQuery(args)
+	case "serve":
+		runServe(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: tradelog [global flags] <command> [flags]
+
+Global flags:
+  --db DSN      SQLite path or postgres:// URL (default $TRADELOG_DSN or data/tradelog.db)
+  --env FILE    dotenv file to read instead of .env
+  --json        print results as JSON instead of tables (one object
+                per refresh for watch; taxreport writes JSON instead of CSV)
+  --account A   only account A: sync and report just its rows. Without it,
+                sync and watch cover every account in KALSHI_ACCOUNTS and
+                reports combine them; commands that trade or import need it
+                when more than one account is configured
+
+Commands:
+  sync          Fetch all data from Kalshi API for each account, alerting
+                the configured ALERT_* destinations on newly settled positions
+  migrate       Apply pending schema migrations (--dry-run to list them)
+  import-csv F  Import a Kalshi fills or settlements CSV export
+  pnl           Show daily PnL table
+                  --tz Z          reporting time zone (default $TRADELOG_TZ or UTC)
+                  --session-hour H  local hour each day starts (default $TRADELOG_SESSION_HOUR or 0)
+                  --by-account    one table per account instead of all combined
+  pnl-entry     Show PnL by time-to-close at entry (0-1m, 1-3m, 3-5m, 5-15m)
+  positions     Show all positions with settlement status
+                  --since T       first fill at or after T (YYYY-MM-DD or RFC 3339)
+                  --until T       first fill before T (a date includes that day)
+                  --tz, --session-hour  zone and day start for date bounds, as for pnl
+  open          Show open (unsettled) positions only (same flags as positions)
+  trades [N]    Show last N fills (default 50)
+                  --since T, --until T  as for positions, on fill time
+                  --tz Z          time zone for fill times and dates
+  taxreport     Per-settlement realized gain/loss CSV for one year
+                  --year Y        calendar year, UTC (default last year)
+                  --out FILE      write CSV here instead of stdout
+  watch         Live dashboard: PnL, open positions at market, recent fills
+                (alerts on settlements like sync)
+                  --sync D        sync interval (default 1m)
+                  --refresh D     mark/redraw interval (default 5s)
+                  --tz, --session-hour  as for pnl
+  serve         Web dashboard: daily PnL chart, open positions, recent trades
+                (basic auth with TRADELOG_WEB_AUTH=user:password)
+                  --addr A        listen address (default 127.0.0.1:8080;
+                                  :8080 to reach it from a phone)
+                  --refresh D     how often an open page reloads (default 30s, 0 = never)
+                  --sync D        also sync from Kalshi this often (default 0 = never)
+                  --tz, --session-hour  as for pnl
+  reconcile     Match fills against collector data and flag anomalies
+                  --data-dir DIR  collector archive directory (default ./data)
+                  --prefix P      archive file prefix (default kxbtc15m)
+                  --tz Z          time zone for fill times
+  execquality   Slippage and markouts vs the recorded book, maker vs taker
+                  (same flags as reconcile)
+  orders open [--ticker=T]        List resting orders on Kalshi
+  orders cancel <order_id>        Cancel one resting order
+  orders cancel-all [--ticker=T]  Cancel every resting order (optionally one market)
+  orders context <order_id>       Show the market as it was when the order was placed
+  flatten       Watch positions and act before each market closes, recording
+                each order's context (see orders context)
+                  --before D      window before close_time (default 60s)
+                  --action A      sell | settle (default settle)
+                  --interval D    poll interval (default 5s)
+                  --dry-run       log decisions without sending orders
+                  --data-dir DIR  collector archives for order context (default ./data)
+                  --prefix P      archive file prefix (default kxbtc15m)
+  backup        Snapshot the SQLite database into a backups directory
+                  --dir DIR       backups directory (default backups/ next to the db)
+                  --keep N        backups to keep, oldest deleted first (default 14, 0 = all)
+                  --every D       keep running, backing up this often (default: once)
+                  --encrypt       encrypt with ARCHIVE_KEY or ARCHIVE_KEY_FILE
+  restore F     Replace the database with backup F (or the newest in --dir with
+                --latest), saving the current one as <db>.pre-restore; stop
+                every process using the database first
+  query SQL     Run one read-only statement (SELECT, WITH, VALUES or EXPLAIN)
+                and print the rows; --account doesn't apply, filter on the
+                account column instead
+                  --schema        list tables and views with their columns
+                                  (also shown when no SQL is given)`)
+}
+
+// Global flags, set in main before the command runs.
+var (
+	dbDSN   string
+	jsonOut bool
+	account string
+)
+
+// newSettlementNotifier returns a notifier for settlements the next sync
+// brings in, or nil when no alert destination is configured.
+func newSettlementNotifier(ctx context.Context, store *tradelog.Store) *tradelog.SettlementNotifier {
+	cfg, err := config.LoadAccount(accounts()[0])
+	if err != nil {
+		return nil
+	}
+	notifier := cfg.Notifier()
+	if notifier == nil {
+		return nil
+	}
+	n, err := tradelog.NewSettlementNotifier(ctx, store, notifier)
+	if err != nil {
+		slog.Warn("settlement alerts disabled", "err", err)
+		return nil
+	}
+	return n
+}
+
+// checkSettlements announces newly synced settlements; n may be nil.
+func checkSettlements(ctx context.Context, n *tradelog.SettlementNotifier) {
+	if n == nil {
+		return
+	}
+	if sent, err := n.Check(ctx); err != nil {
+		slog.Warn("checking settlements", "err", err)
+	} else if sent > 0 {
+		slog.Info("sent settlement alerts", "count", sent)
+	}
+}
+
+// storeDSN is --db if given, else the configured trade database.
+func storeDSN() string {
+	if dbDSN != "" {
+		return dbDSN
+	}
+	return config.TradelogDSN()
+}
+
+// openStore opens the trade database, limited to --account if given.
+func openStore() *tradelog.Store {
+	store, err := tradelog.Open(storeDSN())
+	if err != nil {
+		slog.Error("opening db", "err", err)
+		os.Exit(1)
+	}
+	if account != "" {
+		return store.ForAccount(account)
+	}
+	return store
+}
+
+// accounts returns --account, or every configured account.
+func accounts() []string {
+	if account != "" {
+		return []string{account}
+	}
+	return config.Accounts()
+}
+
+// singleAccount returns the one account a command that trades or writes
+// acts on: --account, or the only configured account. Guessing between
+// several could cancel orders in the wrong one, so that exits instead.
+func singleAccount() string {
+	names := accounts()
+	if len(names) > 1 {
+		slog.Error("several accounts configured; pick one with --account", "accounts", strings.Join(names, ","))
+		os.Exit(1)
+	}
+	return names[0]
+}
+
+// accountClient is the Kalshi client of one account.
+type accountClient struct {
+	name   string
+	client *kalshi.Client
+}
+
+// newClients returns a client for each account sync covers.
+func newClients() []accountClient {
+	var clients []accountClient
+	for _, name := range accounts() {
+		clients = append(clients, accountClient{name, newAccountClient(name)})
+	}
+	return clients
+}
+
+// syncAccounts syncs each client's account into store.
+func syncAccounts(ctx context.Context, clients []accountClient, store *tradelog.Store) error {
+	for _, c := range clients {
+		if err := tradelog.Sync(ctx, c.client, store.ForAccount(c.name)); err != nil {
+			return fmt.Errorf("account %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// multiAccount reports whether rows belong to more than one account, in
+// which case tables show an Account column.
+func multiAccount[T any](rows []T, accountOf func(T) string) bool {
+	for _, r := range rows {
+		if accountOf(r) != accountOf(rows[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportingFlags adds --tz and --session-hour to fs, defaulting to
+// TRADELOG_TZ and TRADELOG_SESSION_HOUR. Call the returned func after
+// fs.Parse to get the settings.
+func reportingFlags(fs *flag.FlagSet) func() tradelog.Reporting {
+	tz, hour, err := config.TradelogReporting()
+	if err != nil {
+		slog.Error("config error", "err", err)
+		os.Exit(1)
+	}
+	tzFlag := fs.String("tz", tz, "reporting time zone, e.g. America/New_York")
+	hourFlag := fs.Int("session-hour", hour, "local hour (0-23) at which each reporting day starts")
+	return func() tradelog.Reporting {
+		rep, err := tradelog.NewReporting(*tzFlag, *hourFlag)
+		if err != nil {
+			slog.Error("reporting settings", "err", err)
+			os.Exit(1)
+		}
+		return rep
+	}
+}
+
+// rangeFlags adds --since and --until to fs. Call the returned func after
+// fs.Parse to get the bounds, zero when not given.
+func rangeFlags(fs *flag.FlagSet) func(rep tradelog.Reporting) (since, until time.Time) {
+	sinceFlag := fs.String("since", "", "from this time (YYYY-MM-DD or RFC 3339)")
+	untilFlag := fs.String("until", "", "before this time; a date includes that whole day")
+	return func(rep tradelog.Reporting) (since, until time.Time) {
+		var err error
+		if since, err = parseBound(*sinceFlag, rep, false); err != nil {
+			slog.Error("bad --since", "err", err)
+			os.Exit(1)
+		}
+		if until, err = parseBound(*untilFlag, rep, true); err != nil {
+			slog.Error("bad --until", "err", err)
+			os.Exit(1)
+		}
+		return since, until
+	}
+}
+
+// parseBound parses a --since or --until value. A bare date is the start
+// of that reporting day, or for an upper bound the start of the next one.
+func parseBound(s string, rep tradelog.Reporting, upper bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := rep.DayStart(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not YYYY-MM-DD or RFC 3339", s)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printJSON writes v to stdout for --json.
+func printJSON(v any) {
+	if err := writeJSON(os.Stdout, v); err != nil {
+		slog.Error("writing json", "err", err)
+		os.Exit(1)
+	}
+}
+
+// nonNil lets an empty result print as [] rather than null.
+func nonNil[T any](rows []T) []T {
+	if rows == nil {
+		return []T{}
+	}
+	return rows
+}
+
+// newClient returns the client of the account singleAccount picks.
+func newClient() *kalshi.Client {
+	return newAccountClient(singleAccount())
+}
+
+func newAccountClient(name string) *kalshi.Client {
+	cfg, err := config.LoadAccount(name)
+	if err != nil {
+		slog.Error("config error", "err", err)
+		os.Exit(1)
+	}
+
+	client, err := kalshi.NewClient(cfg)
+	if err != nil {
+		slog.Error("kalshi client init", "err", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	fs.Parse(args)
+
+	applied, err := tradelog.Migrate(storeDSN(), *dryRun)
+	if err != nil {
+		slog.Error("migration failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(applied))
+		return
+	}
+	if len(applied) == 0 {
+		fmt.Println("Schema is up to date.")
+		return
+	}
+	verb := "Applied"
+	if *dryRun {
+		verb = "Pending"
+	}
+	for _, m := range applied {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func runImportCSV(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: tradelog import-csv <file> [file...]")
+		os.Exit(1)
+	}
+
+	store := openStore().ForAccount(singleAccount())
+	defer store.Close()
+
+	type fileResult struct {
+		File string `json:"file"`
+		tradelog.ImportResult
+	}
+	results := []fileResult{}
+	for _, path := range args {
+		res, err := tradelog.ImportCSV(context.Background(), store, path)
+		if err != nil {
+			slog.Error("import failed", "file", path, "err", err)
+			os.Exit(1)
+		}
+		results = append(results, fileResult{path, res})
+		if !jsonOut {
+			fmt.Printf("%s: %d %s rows, %d imported, %d already present\n",
+				path, res.Rows, res.Kind, res.Inserted, res.Skipped)
+		}
+	}
+	if jsonOut {
+		printJSON(results)
+	}
+}
+
+func runSync() {
+	clients := newClients()
+
+	store := openStore()
+	defer store.Close()
+
+	ctx := context.Background()
+	notifier := newSettlementNotifier(ctx, store)
+	if err := syncAccounts(ctx, clients, store); err != nil {
+		slog.Error("sync failed", "err", err)
+		os.Exit(1)
+	}
+	checkSettlements(ctx, notifier)
+
+	fmt.Println("Sync complete.")
+}
+
+func runPnL(args []string) {
+	fs := flag.NewFlagSet("pnl", flag.ExitOnError)
+	reporting := reportingFlags(fs)
+	byAccount := fs.Bool("by-account", false, "one table per account instead of all combined")
+	fs.Parse(args)
+	rep := reporting()
+
+	store := openStore()
+	defer store.Close()
+
+	daily := tradelog.DailyPnLIn
+	if *byAccount {
+		daily = tradelog.AccountDailyPnLIn
+	}
+	rows, err := daily(context.Background(), store, rep)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(rows))
+		return
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No PnL data. Run 'tradelog sync' first.")
+		return
+	}
+
+	fmt.Printf("Days in %s\n", rep)
+	for len(rows) > 0 {
+		n := 1
+		for n < len(rows) && rows[n].Account == rows[0].Account {
+			n++
+		}
+		printPnL(rows[:n])
+		rows = rows[n:]
+	}
+}
+
+// printPnL prints the days of one account, or of all combined, with a
+// total.
+func printPnL(rows []tradelog.DailyPnL) {
+	fmt.Println()
+	if rows[0].Account != "" {
+		fmt.Printf("Account %s\n", rows[0].Account)
+	}
+	fmt.Printf("%-12s %10s %10s %10s %6s\n", "Date", "Revenue", "Cost", "Net PnL", "Trades")
+	fmt.Println("--------------------------------------------------------------")
+	var totalRev, totalCost, totalPnL, totalTrades int
+	for _, r := range rows {
+		fmt.Printf("%-12s %10s %10s %10s %6d\n",
+			r.Date,
+			cents(r.Revenue),
+			cents(r.Cost),
+			cents(r.NetPnL),
+			r.Trades,
+		)
+		totalRev += r.Revenue
+		totalCost += r.Cost
+		totalPnL += r.NetPnL
+		totalTrades += r.Trades
+	}
+	fmt.Println("--------------------------------------------------------------")
+	fmt.Printf("%-12s %10s %10s %10s %6d\n", "TOTAL", cents(totalRev), cents(totalCost), cents(totalPnL), totalTrades)
+}
+
+func runPnLByEntry() {
+	store := openStore()
+	defer store.Close()
+
+	rows, err := tradelog.PnLByEntryBucket(context.Background(), store)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(rows))
+		return
+	}
+
+	fmt.Printf("%-8s %9s %6s %10s %10s %8s\n", "Entry", "Positions", "Win%", "Cost", "Net PnL", "ROI")
+	fmt.Println("------------------------------------------------------")
+	var total tradelog.EntryBucketPnL
+	for _, r := range rows {
+		fmt.Printf("%-8s %9d %6s %10s %10s %8s\n",
+			r.Bucket, r.Positions, pct(r.Wins, r.Positions), cents(r.Cost), cents(r.NetPnL), pct(r.NetPnL, r.Cost))
+		total.Positions += r.Positions
+		total.Wins += r.Wins
+		total.Cost += r.Cost
+		total.NetPnL += r.NetPnL
+	}
+	fmt.Println("------------------------------------------------------")
+	fmt.Printf("%-8s %9d %6s %10s %10s %8s\n",
+		"TOTAL", total.Positions, pct(total.Wins, total.Positions), cents(total.Cost), cents(total.NetPnL), pct(total.NetPnL, total.Cost))
+	if total.Positions == 0 {
+		fmt.Println("\nNo settled positions with market times. Run 'tradelog sync' first.")
+	}
+}
+
+func runTaxReport(args []string) {
+	fs := flag.NewFlagSet("taxreport", flag.ExitOnError)
+	year := fs.Int("year", time.Now().UTC().Year()-1, "calendar year (UTC)")
+	out := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	store := openStore()
+	defer store.Close()
+
+	lots, err := tradelog.TaxReport(context.Background(), store, *year)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			slog.Error("creating output", "err", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if jsonOut {
+		err = writeJSON(w, nonNil(lots))
+	} else {
+		err = tradelog.WriteTaxCSV(w, lots)
+	}
+	if err != nil {
+		slog.Error("writing report", "err", err)
+		os.Exit(1)
+	}
+	if *out != "" {
+		fmt.Printf("Wrote %d settlements for %d to %s\n", len(lots), *year, *out)
+	}
+}
+
+func runPositions(args []string, openOnly bool) {
+	name := "positions"
+	if openOnly {
+		name = "open"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	reporting := reportingFlags(fs)
+	bounds := rangeFlags(fs)
+	fs.Parse(args)
+	since, until := bounds(reporting())
+
+	store := openStore()
+	defer store.Close()
+
+	rows, err := store.PositionsOpenedBetween(context.Background(), openOnly, since, until)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(rows))
+		return
+	}
+
+	if len(rows) == 0 {
+		if openOnly {
+			fmt.Println("No open positions.")
+		} else {
+			fmt.Println("No positions. Run 'tradelog sync' first.")
+		}
+		return
+	}
+
+	showAccount := multiAccount(rows, func(p tradelog.Position) string { return p.Account })
+	if showAccount {
+		fmt.Printf("%-12s ", "Account")
+	}
+	fmt.Printf("%-35s %5s %5s %10s %10s %8s %10s\n",
+		"Ticker", "Yes", "No", "YesCost", "NoCost", "Result", "Revenue")
+	fmt.Println("---------------------------------------------------------------------------------------------------")
+	for _, p := range rows {
+		if showAccount {
+			fmt.Printf("%-12s ", p.Account)
+		}
+		fmt.Printf("%-35s %5d %5d %10s %10s %8s %10s\n",
+			p.Ticker,
+			p.YesContracts,
+			p.NoContracts,
+			cents(p.YesCost),
+			cents(p.NoCost),
+			p.MarketResult,
+			cents(p.Revenue),
+		)
+	}
+}
+
+func runTrades(args []string) {
+	fs := flag.NewFlagSet("trades", flag.ExitOnError)
+	reporting := reportingFlags(fs)
+	bounds := rangeFlags(fs)
+	fs.Parse(args)
+	rep := reporting()
+	since, until := bounds(rep)
+
+	limit := 50
+	if n, err := strconv.Atoi(fs.Arg(0)); err == nil {
+		limit = n
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	fills, err := store.TradesBetween(context.Background(), since, until, limit)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(fills))
+		return
+	}
+
+	if len(fills) == 0 {
+		fmt.Println("No trades. Run 'tradelog sync' first.")
+		return
+	}
+
+	showAccount := multiAccount(fills, func(f tradelog.Fill) string { return f.Account })
+	if showAccount {
+		fmt.Printf("%-12s ", "Account")
+	}
+	fmt.Printf("%-20s %-35s %5s %5s %5s %5s %5s\n",
+		"Time", "Ticker", "Side", "Act", "Price", "Qty", "Taker")
+	fmt.Println("---------------------------------------------------------------------------------------------------")
+	for _, f := range fills {
+		if showAccount {
+			fmt.Printf("%-12s ", f.Account)
+		}
+		price := f.YesPrice
+		if f.Side == "no" {
+			price = f.NoPrice
+		}
+		taker := " "
+		if f.IsTaker {
+			taker = "Y"
+		}
+		fmt.Printf("%-20s %-35s %5s %5s %5d %5d %5s\n",
+			rep.In(f.CreatedTime).Format("2006-01-02 15:04:05"),
+			f.Ticker,
+			f.Side,
+			f.Action,
+			price,
+			f.Count,
+			taker,
+		)
+	}
+}
+
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "./data", "collector archive directory")
+	prefix := fs.String("prefix", "kxbtc15m", "archive file prefix")
+	reporting := reportingFlags(fs)
+	fs.Parse(args)
+	rep := reporting()
+
+	store := openStore()
+	defer store.Close()
+
+	results, err := tradelog.Reconcile(context.Background(), store, *dataDir, *prefix)
+	if err != nil {
+		slog.Error("reconcile failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(results))
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No trades. Run 'tradelog sync' first.")
+		return
+	}
+
+	var anomalies int
+	for _, r := range results {
+		if r.Context.Anomaly == "" {
+			continue
+		}
+		if anomalies == 0 {
+			fmt.Printf("%-20s %-35s %5s %5s %5s %5s %6s  %s\n",
+				"Time", "Ticker", "Side", "Price", "Bid", "Ask", "Lag", "Anomaly")
+			fmt.Println("---------------------------------------------------------------------------------------------------")
+		}
+		anomalies++
+		c := r.Context
+		lag := "-"
+		if !c.SnapshotTime.IsZero() {
+			lag = fmt.Sprintf("%.1fs", c.SnapshotLag)
+		}
+		fmt.Printf("%-20s %-35s %5s %5d %5d %5d %6s  %s\n",
+			rep.In(r.Fill.CreatedTime).Format("2006-01-02 15:04:05"),
+			r.Fill.Ticker,
+			r.Fill.Side,
+			r.Fill.YesPrice,
+			c.YesBid,
+			c.YesAsk,
+			lag,
+			c.Anomaly,
+		)
+	}
+	if anomalies > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("Reconciled %d fills, %d with anomalies.\n", len(results), anomalies)
+}
+
+func runExecQuality(args []string) {
+	fs := flag.NewFlagSet("execquality", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "./data", "collector archive directory")
+	prefix := fs.String("prefix", "kxbtc15m", "archive file prefix")
+	fs.Parse(args)
+
+	store := openStore()
+	defer store.Close()
+
+	ctx := context.Background()
+	n, err := tradelog.ExecQuality(ctx, store, *dataDir, *prefix)
+	if err != nil {
+		slog.Error("execquality failed", "err", err)
+		os.Exit(1)
+	}
+
+	rows, err := store.ExecSummary(ctx)
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(struct {
+			Measured int                    `json:"measured"`
+			Summary  []tradelog.ExecSummary `json:"summary"`
+		}{n, nonNil(rows)})
+		return
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No fills with matching collector data. Run 'tradelog sync' first.")
+		return
+	}
+
+	fmt.Printf("Measured %d fills (cents vs YES mid; + slippage = paid more, - markout = adverse).\n\n", n)
+	fmt.Printf("%-6s %6s %7s %9s %9s %9s %8s %7s\n",
+		"Role", "Fills", "Qty", "Slippage", "Mark10s", "Mark60s", "Adverse", "Queue")
+	fmt.Println("--------------------------------------------------------------------")
+	for _, r := range rows {
+		role, queue := "maker", fmt.Sprintf("%.0f", r.AvgQueueAhead)
+		if r.IsTaker {
+			role, queue = "taker", "-"
+		}
+		fmt.Printf("%-6s %6d %7d %9.2f %9.2f %9.2f %7.0f%% %7s\n",
+			role,
+			r.Fills,
+			r.Contracts,
+			r.AvgSlippage,
+			r.AvgMarkout10s,
+			r.AvgMarkout60s,
+			r.AdverseShare*100,
+			queue,
+		)
+	}
+}
+
+func runOrders(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "open":
+		fs := flag.NewFlagSet("orders open", flag.ExitOnError)
+		ticker := fs.String("ticker", "", "only orders in this market")
+		fs.Parse(args[1:])
+		runOpenOrders(*ticker)
+	case "cancel":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: tradelog orders cancel <order_id>")
+			os.Exit(1)
+		}
+		runCancelOrder(args[1])
+	case "cancel-all":
+		fs := flag.NewFlagSet("orders cancel-all", flag.ExitOnError)
+		ticker := fs.String("ticker", "", "only orders in this market")
+		fs.Parse(args[1:])
+		runCancelAll(*ticker)
+	case "context":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: tradelog orders context <order_id>")
+			os.Exit(1)
+		}
+		runOrderContext(args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown orders command: %s\n", args[0])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runOpenOrders(ticker string) {
+	client := newClient()
+
+	orders, err := client.GetOpenOrders(context.Background(), ticker)
+	if err != nil {
+		slog.Error("fetching orders", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		printJSON(nonNil(orders))
+		return
+	}
+	if len(orders) == 0 {
+		fmt.Println("No resting orders.")
+		return
+	}
+
+	fmt.Printf("%-38s %-35s %5s %5s %5s %5s %-20s\n",
+		"Order", "Ticker", "Side", "Act", "Price", "Rem", "Created")
+	fmt.Println("-----------------------------------------------------------------------------------------------------------------------")
+	for _, o := range orders {
+		price := o.YesPrice
+		if o.Side == "no" {
+			price = o.NoPrice
+		}
+		fmt.Printf("%-38s %-35s %5s %5s %5d %5d %-20s\n",
+			o.OrderID,
+			o.Ticker,
+			o.Side,
+			o.Action,
+			price,
+			o.RemainingQuantity,
+			o.CreatedTime,
+		)
+	}
+}
+
+func runCancelOrder(orderID string) {
+	client := newClient()
+
+	o, err := client.CancelOrder(context.Background(), orderID)
+	if err != nil {
+		slog.Error("cancel failed", "order_id", orderID, "err", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Canceled %s (%s), status %s.\n", o.OrderID, o.Ticker, o.Status)
+}
+
+func runCancelAll(ticker string) {
+	client := newClient()
+	ctx := context.Background()
+
+	orders, err := client.GetOpenOrders(ctx, ticker)
+	if err != nil {
+		slog.Error("fetching orders", "err", err)
+		os.Exit(1)
+	}
+
+	var failed int
+	for _, o := range orders {
+		if _, err := client.CancelOrder(ctx, o.OrderID); err != nil {
+			slog.Error("cancel failed", "order_id", o.OrderID, "ticker", o.Ticker, "err", err)
+			failed++
+			continue
+		}
+		fmt.Printf("Canceled %s (%s)\n", o.OrderID, o.Ticker)
+	}
+
+	fmt.Printf("Canceled %d/%d resting orders.\n", len(orders)-failed, len(orders))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runOrderContext(orderID string) {
+	store := openStore()
+	defer store.Close()
+
+	oc, err := store.OrderContext(context.Background(), orderID)
+	if err != nil {
+		slog.Error("order context", "err", err)
+		os.Exit(1)
+	}
+	if jsonOut {
+		printJSON(oc)
+		return
+	}
+
+	fmt.Printf("Order %s (%s), placed %s\n", oc.OrderID, oc.Ticker, oc.PlacedTime.UTC().Format(time.RFC3339))
+	if oc.Source == "" {
+		fmt.Println("No market snapshot was found.")
+	} else {
+		fmt.Printf("Snapshot  %s from %s (%.1fs before)\n", oc.SnapshotTime.UTC().Format(time.RFC3339),
+			oc.Source, oc.PlacedTime.Sub(oc.SnapshotTime).Seconds())
+		fmt.Printf("Market    bid %d / ask %d, last %d, strike %.2f, %ds left, %s\n",
+			oc.YesBid, oc.YesAsk, oc.LastPrice, oc.Strike, oc.SecsLeft, oc.Status)
+		if len(oc.YesBook) > 0 || len(oc.NoBook) > 0 {
+			fmt.Printf("Book      yes %v\n          no  %v\n", oc.YesBook, oc.NoBook)
+		}
+	}
+	fmt.Printf("BRTI      $%.2f, realized vol %.1f%% (%s window)\n", oc.BRTI, 100*oc.Vol, tradelog.VolWindow)
+}
+
+func runFlatten(args []string) {
+	fs := flag.NewFlagSet("flatten", flag.ExitOnError)
+	before := fs.Duration("before", 60*time.Second, "act this long before close_time")
+	action := fs.String("action", string(tradelog.FlattenSettle), "sell | settle")
+	interval := fs.Duration("interval", 5*time.Second, "poll interval")
+	dryRun := fs.Bool("dry-run", false, "log decisions without sending orders")
+	dataDir := fs.String("data-dir", "./data", "collector archive directory, for order context")
+	prefix := fs.String("prefix", "kxbtc15m", "archive file prefix")
+	fs.Parse(args)
+
+	act := tradelog.FlattenAction(*action)
+	if act != tradelog.FlattenSell && act != tradelog.FlattenSettle {
+		fmt.Fprintf(os.Stderr, "unknown --action %q (want sell or settle)\n", *action)
+		os.Exit(1)
+	}
+
+	client := newClient()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	f := tradelog.NewFlattener(client, tradelog.FlattenConfig{
+		Before:   *before,
+		Action:   act,
+		Interval: *interval,
+		DryRun:   *dryRun,
+	})
+	if !*dryRun {
+		store := openStore().ForAccount(singleAccount())
+		defer store.Close()
+		f.SetOrderPlacer(tradelog.NewOrderPlacer(client, store, *dataDir, *prefix))
+	}
+	if err := f.Run(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("flattener stopped", "err", err)
+		os.Exit(1)
+	}
+}
+
+func pct(n, d int) string {
+	if d == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(d))
+}
+
+func cents(c int) string {
+	sign := ""
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, c/100, c%100)
+}
+
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("dir", "", "backups directory (default backups/ next to the database)")
+	keep := fs.Int("keep", 14, "number of backups to keep (0 = all)")
+	every := fs.Duration("every", 0, "keep running and back up this often (0 = once)")
+	encrypt := fs.Bool("encrypt", false, "encrypt backups with ARCHIVE_KEY or ARCHIVE_KEY_FILE")
+	fs.Parse(args)
+
+	dsn := storeDSN()
+	if *dir == "" {
+		*dir = tradelog.DefaultBackupDir(dsn)
+	}
+	var key []byte
+	if *encrypt {
+		key = archiveKey()
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	for {
+		path, err := store.Backup(ctx, *dir, *keep, key)
+		switch {
+		case err != nil && *every == 0:
+			slog.Error("backup failed", "err", err)
+			os.Exit(1)
+		case err != nil:
+			slog.Error("backup failed", "err", err)
+		default:
+			slog.Info("backup written", "path", path)
+		}
+		if *every == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*every):
+		}
+	}
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("dir", "", "backups directory for --latest (default backups/ next to the database)")
+	latest := fs.Bool("latest", false, "restore the newest backup in --dir")
+	fs.Parse(args)
+
+	dsn := storeDSN()
+	var backup string
+	switch {
+	case *latest:
+		if *dir == "" {
+			*dir = tradelog.DefaultBackupDir(dsn)
+		}
+		backups, err := tradelog.Backups(*dir)
+		if err != nil || len(backups) == 0 {
+			slog.Error("no backups found", "dir", *dir, "err", err)
+			os.Exit(1)
+		}
+		backup = backups[len(backups)-1]
+	case fs.NArg() == 1:
+		backup = fs.Arg(0)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: tradelog restore <backup> | --latest [--dir DIR]")
+		os.Exit(1)
+	}
+
+	var key []byte
+	if strings.HasSuffix(backup, ticks.EncExt) {
+		key = archiveKey()
+	}
+	if err := tradelog.Restore(context.Background(), dsn, backup, key); err != nil {
+		slog.Error("restore failed", "err", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %s from %s\n", dsn, backup)
+}
+
+// archiveKey returns the configured archive key or exits.
+func archiveKey() []byte {
+	key, err := ticks.ArchiveKey()
+	if err == nil && key == nil {
+		err = ticks.ErrNoKey
+	}
+	if err != nil {
+		slog.Error("archive key", "err", err)
+		os.Exit(1)
+	}
+	return key
+}
+
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	schema := fs.Bool("schema", false, "list tables and views with their columns")
+	fs.Parse(args)
+
+	store := openStore()
+	defer store.Close()
+	ctx := context.Background()
+
+	if *schema || fs.NArg() == 0 {
+		tables, err := store.Schema(ctx)
+		if err != nil {
+			slog.Error("reading schema", "err", err)
+			os.Exit(1)
+		}
+		if jsonOut {
+			printJSON(nonNil(tables))
+			return
+		}
+		for i, t := range tables {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s %s", t.Kind, t.Name)
+			if t.About != "" {
+				fmt.Printf(" — %s", t.About)
+			}
+			fmt.Println()
+			for _, c := range t.Columns {
+				fmt.Printf("  %-20s %s\n", c.Name, c.Type)
+			}
+		}
+		if fs.NArg() == 0 && !*schema {
+			fmt.Println("\nUsage: tradelog query \"SELECT ...\"")
+		}
+		return
+	}
+
+	res, err := store.Query(ctx, strings.Join(fs.Args(), " "))
+	if err != nil {
+		slog.Error("query failed", "err", err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		rows := make([]map[string]any, 0, len(res.Rows))
+		for _, r := range res.Rows {
+			row := make(map[string]any, len(r))
+			for i, v := range r {
+				row[res.Columns[i]] = v
+			}
+			rows = append(rows, row)
+		}
+		printJSON(rows)
+		return
+	}
+
+	cells := make([][]string, len(res.Rows))
+	widths := make([]int, len(res.Columns))
+	for i, c := range res.Columns {
+		widths[i] = len(c)
+	}
+	for i, r := range res.Rows {
+		cells[i] = make([]string, len(r))
+		for j, v := range r {
+			cells[i][j] = cell(v)
+			widths[j] = max(widths[j], len(cells[i][j]))
+		}
+	}
+	printRow := func(vals []string) {
+		line := ""
+		for i, v := range vals {
+			if i > 0 {
+				line += "  "
+			}
+			line += fmt.Sprintf("%-*s", widths[i], v)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	printRow(res.Columns)
+	dashes := make([]string, len(widths))
+	for i, w := range widths {
+		dashes[i] = strings.Repeat("-", w)
+	}
+	printRow(dashes)
+	for _, r := range cells {
+		printRow(r)
+	}
+	if len(res.Rows) == 1 {
+		fmt.Println("(1 row)")
+	} else {
+		fmt.Printf("(%d rows)\n", len(res.Rows))
+	}
+}
+
+// cell renders a value from a query result for a table.
+func cell(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return t.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address; :8080 reaches it from other devices")
+	refresh := fs.Duration("refresh", 30*time.Second, "how often an open page reloads its sections (0 = never)")
+	syncEvery := fs.Duration("sync", 0, "also sync from Kalshi this often (0 = never)")
+	reporting := reportingFlags(fs)
+	fs.Parse(args)
+	rep := reporting()
+
+	store := openStore()
+	defer store.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	srv := web.New(*addr, store, rep)
+	srv.SetRefresh(*refresh)
+	if user, pass := config.TradelogWebAuth(); user != "" || pass != "" {
+		srv.SetAuth(user, pass)
+	} else if host, _, _ := net.SplitHostPort(*addr); host != "localhost" && !net.ParseIP(host).IsLoopback() {
+		slog.Warn("dashboard has no authentication; set TRADELOG_WEB_AUTH", "addr", *addr)
+	}
+
+	if *syncEvery > 0 {
+		clients := newClients()
+		notifier := newSettlementNotifier(ctx, store)
+		go func() {
+			ticker := time.NewTicker(*syncEvery)
+			defer ticker.Stop()
+			for {
+				if err := syncAccounts(ctx, clients, store); err != nil {
+					slog.Warn("sync failed", "err", err)
+				} else {
+					checkSettlements(ctx, notifier)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	if err := srv.Run(ctx); err != nil {
+		slog.Error("serve failed", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,255 @@
+package tradelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/internal/tradelog"
+)
+
+const (
+	clearScreen  = "\033[H\033[2J"
+	watchPnLDays = 7
+	watchFills   = 10
+)
+
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	syncEvery := fs.Duration("sync", time.Minute, "how often to sync from Kalshi")
+	refresh := fs.Duration("refresh", 5*time.Second, "how often to refresh marks and redraw")
+	reporting := reportingFlags(fs)
+	fs.Parse(args)
+	rep := reporting()
+
+	clients := newClients()
+	// Any account's client can read the markets positions are marked at.
+	client := clients[0].client
+	store := openStore()
+	defer store.Close()
+
+	// Logging would scribble over the dashboard; errors are shown inline.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	notifier := newSettlementNotifier(ctx, store)
+	var lastSync time.Time
+	var syncErr error
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+
+	for {
+		if time.Since(lastSync) >= *syncEvery {
+			syncErr = syncAccounts(ctx, clients, store)
+			lastSync = time.Now()
+			if syncErr == nil {
+				checkSettlements(ctx, notifier)
+			}
+		}
+
+		if jsonOut {
+			writeWatchJSON(ctx, os.Stdout, client, store, rep, lastSync, syncErr)
+		} else {
+			var buf bytes.Buffer
+			buf.WriteString(clearScreen)
+			renderWatch(ctx, &buf, client, store, rep, lastSync, syncErr)
+			os.Stdout.Write(buf.Bytes())
+		}
+
+		select {
+		case <-ctx.Done():
+			if !jsonOut {
+				fmt.Println()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func renderWatch(ctx context.Context, w io.Writer, client kalshi.API, store *tradelog.Store, rep tradelog.Reporting, lastSync time.Time, syncErr error) {
+	now := time.Now()
+	fmt.Fprintf(w, "tradelog watch  %s  (last sync %s ago)\n", rep.In(now).Format("2006-01-02 15:04:05"), now.Sub(lastSync).Round(time.Second))
+	if syncErr != nil {
+		fmt.Fprintf(w, "SYNC ERROR: %v\n", syncErr)
+	}
+
+	fmt.Fprintln(w)
+	renderWatchPnL(ctx, w, store, rep)
+	fmt.Fprintln(w)
+	renderWatchPositions(ctx, w, client, store)
+	fmt.Fprintln(w)
+	renderWatchFills(ctx, w, store, rep)
+	fmt.Fprintln(w, "\nCtrl-C to exit.")
+}
+
+func renderWatchPnL(ctx context.Context, w io.Writer, store *tradelog.Store, rep tradelog.Reporting) {
+	rows, err := tradelog.DailyPnLIn(ctx, store, rep)
+	if err != nil {
+		fmt.Fprintf(w, "PnL query failed: %v\n", err)
+		return
+	}
+
+	var total int
+	for _, r := range rows {
+		total += r.NetPnL
+	}
+	if len(rows) > watchPnLDays {
+		rows = rows[len(rows)-watchPnLDays:]
+	}
+
+	fmt.Fprintf(w, "DAILY PnL (all-time %s, days in %s)\n", cents(total), rep)
+	fmt.Fprintf(w, "%-12s %10s %10s %10s %6s\n", "Date", "Revenue", "Cost", "Net PnL", "Trades")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%-12s %10s %10s %10s %6d\n", r.Date, cents(r.Revenue), cents(r.Cost), cents(r.NetPnL), r.Trades)
+	}
+}
+
+// renderWatchPositions marks open positions at the current bid, i.e. what
+// they would fetch if sold now.
+func renderWatchPositions(ctx context.Context, w io.Writer, client kalshi.API, store *tradelog.Store) {
+	rows, err := store.OpenPositions(ctx)
+	if err != nil {
+		fmt.Fprintf(w, "Positions query failed: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(w, "OPEN POSITIONS")
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+
+	fmt.Fprintf(w, "%-35s %5s %5s %6s %10s %10s %10s\n", "Ticker", "Yes", "No", "Bid", "Cost", "Mark", "Unreal")
+	showAccount := multiAccount(rows, func(p tradelog.Position) string { return p.Account })
+	var totalCost, totalMark int
+	for _, p := range rows {
+		label := p.Ticker
+		if showAccount {
+			label = p.Account + ":" + p.Ticker
+		}
+		cost := p.YesCost + p.NoCost
+		m, mark, err := markPosition(ctx, client, p)
+		if err != nil {
+			fmt.Fprintf(w, "%-35s %5d %5d %6s %10s %10s %10s\n", label, p.YesContracts, p.NoContracts, "?", cents(cost), "-", "-")
+			continue
+		}
+		bid := ""
+		if p.YesContracts > 0 {
+			bid = fmt.Sprintf("Y%d", m.YesBid)
+		}
+		if p.NoContracts > 0 {
+			bid = fmt.Sprintf("N%d", m.NoBid)
+		}
+		totalCost += cost
+		totalMark += mark
+		fmt.Fprintf(w, "%-35s %5d %5d %6s %10s %10s %10s\n",
+			label, p.YesContracts, p.NoContracts, bid, cents(cost), cents(mark), cents(mark-cost))
+	}
+	fmt.Fprintf(w, "%-35s %5s %5s %6s %10s %10s %10s\n", "TOTAL", "", "", "", cents(totalCost), cents(totalMark), cents(totalMark-totalCost))
+}
+
+// markPosition fetches p's market and values the position at its bids.
+func markPosition(ctx context.Context, client kalshi.API, p tradelog.Position) (*kalshi.Market, int, error) {
+	m, err := client.GetMarket(ctx, p.Ticker)
+	if err != nil {
+		return nil, 0, err
+	}
+	return m, p.YesContracts*m.YesBid + p.NoContracts*m.NoBid, nil
+}
+
+func renderWatchFills(ctx context.Context, w io.Writer, store *tradelog.Store, rep tradelog.Reporting) {
+	fills, err := store.RecentTrades(ctx, watchFills)
+	if err != nil {
+		fmt.Fprintf(w, "Fills query failed: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(w, "RECENT FILLS")
+	if len(fills) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	fmt.Fprintf(w, "%-20s %-35s %5s %5s %5s %5s\n", "Time", "Ticker", "Side", "Act", "Price", "Qty")
+	for _, f := range fills {
+		price := f.YesPrice
+		if f.Side == "no" {
+			price = f.NoPrice
+		}
+		fmt.Fprintf(w, "%-20s %-35s %5s %5s %5d %5d\n",
+			rep.In(f.CreatedTime).Format("2006-01-02 15:04:05"), f.Ticker, f.Side, f.Action, price, f.Count)
+	}
+}
+
+// watchFrame is one dashboard refresh under --json, written as a line.
+type watchFrame struct {
+	Time      time.Time           `json:"time"`
+	LastSync  time.Time           `json:"last_sync"`
+	Errors    []string            `json:"errors,omitempty"`
+	DailyPnL  []tradelog.DailyPnL `json:"daily_pnl"`
+	Positions []markedPosition    `json:"open_positions"`
+	Fills     []tradelog.Fill     `json:"recent_fills"`
+}
+
+// markedPosition is an open position valued at the current bids. Marked
+// is false when the market could not be fetched.
+type markedPosition struct {
+	tradelog.Position
+	Marked bool `json:"marked"`
+	YesBid int  `json:"yes_bid"`
+	NoBid  int  `json:"no_bid"`
+	Mark   int  `json:"mark"`
+}
+
+// writeWatchJSON is renderWatch for --json: the same sections as one JSON
+// object, with query failures listed under errors.
+func writeWatchJSON(ctx context.Context, w io.Writer, client kalshi.API, store *tradelog.Store, rep tradelog.Reporting, lastSync time.Time, syncErr error) {
+	frame := watchFrame{Time: time.Now(), LastSync: lastSync}
+	fail := func(what string, err error) {
+		frame.Errors = append(frame.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+	if syncErr != nil {
+		fail("sync", syncErr)
+	}
+
+	pnl, err := tradelog.DailyPnLIn(ctx, store, rep)
+	if err != nil {
+		fail("pnl", err)
+	}
+	if len(pnl) > watchPnLDays {
+		pnl = pnl[len(pnl)-watchPnLDays:]
+	}
+	frame.DailyPnL = nonNil(pnl)
+
+	rows, err := store.OpenPositions(ctx)
+	if err != nil {
+		fail("positions", err)
+	}
+	frame.Positions = []markedPosition{}
+	for _, p := range rows {
+		mp := markedPosition{Position: p}
+		if m, mark, err := markPosition(ctx, client, p); err == nil {
+			mp.Marked, mp.YesBid, mp.NoBid, mp.Mark = true, m.YesBid, m.NoBid, mark
+		}
+		frame.Positions = append(frame.Positions, mp)
+	}
+
+	fills, err := store.RecentTrades(ctx, watchFills)
+	if err != nil {
+		fail("fills", err)
+	}
+	frame.Fills = nonNil(fills)
+
+	json.NewEncoder(w).Encode(frame)
+}
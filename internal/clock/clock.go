@@ -0,0 +1,225 @@
+// Package clock abstracts the time source of the collector, the writer's
+// rotation and the BRTI proxy, so tests and replays can run them in
+// simulated time instead of waiting on the wall clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the subset of package time the collector uses.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker is a *time.Ticker behind an interface.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+// Or returns c, or Real when c is nil, so a zero-valued option means the
+// wall clock.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
+func (t realTicker) Stop()                 { t.t.Stop() }
+
+// Sim is a Clock that only moves when told to. Advance fires the tickers
+// and timers that fall due in time order, each at its own due time, so a
+// day of one-second ticks takes as long as the receivers need to handle
+// 86,400 of them.
+//
+// Unlike the wall clock, Sim never drops a tick: Advance hands each one
+// over and waits for it to be received (or the ticker stopped) before
+// moving on, so a loop driven by a Sim ticker sees every tick, in order,
+// and has handled tick N before it receives N+1. Advance returns once the
+// last tick has been received, which may be before it has been handled.
+// Loops must Stop their tickers when they exit, as they should with
+// time.Ticker anyway.
+type Sim struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*simTimer
+	added  chan struct{} // closed and replaced when a timer is added
+}
+
+type simTimer struct {
+	sim     *Sim
+	c       chan time.Time
+	next    time.Time
+	period  time.Duration // 0 for one-shot After timers
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewSim returns a Sim reading start.
+func NewSim(start time.Time) *Sim {
+	return &Sim{now: start, added: make(chan struct{})}
+}
+
+func (s *Sim) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+func (s *Sim) After(d time.Duration) <-chan time.Time {
+	t := s.add(d, 0)
+	return t.c
+}
+
+func (s *Sim) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return s.add(d, d)
+}
+
+func (s *Sim) add(d, period time.Duration) *simTimer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &simTimer{sim: s, c: make(chan time.Time, 1), next: s.now.Add(d), period: period, stopped: make(chan struct{})}
+	s.timers = append(s.timers, t)
+	close(s.added)
+	s.added = make(chan struct{})
+	return t
+}
+
+func (t *simTimer) C() <-chan time.Time { return t.c }
+
+func (t *simTimer) Reset(d time.Duration) {
+	t.sim.mu.Lock()
+	defer t.sim.mu.Unlock()
+	t.period = d
+	t.next = t.sim.now.Add(d)
+}
+
+func (t *simTimer) Stop() {
+	t.once.Do(func() { close(t.stopped) })
+	t.sim.remove(t)
+}
+
+func (s *Sim) remove(t *simTimer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(t)
+}
+
+// Advance moves the clock forward by d, firing everything that falls due
+// on the way.
+func (s *Sim) Advance(d time.Duration) {
+	s.AdvanceTo(s.Now().Add(d))
+}
+
+// AdvanceTo moves the clock forward to t, firing everything that falls due
+// on the way. It never moves the clock backwards.
+func (s *Sim) AdvanceTo(t time.Time) {
+	for {
+		s.mu.Lock()
+		next := s.dueLocked(t)
+		if next == nil {
+			if t.After(s.now) {
+				s.now = t
+			}
+			s.mu.Unlock()
+			return
+		}
+		if next.next.After(s.now) {
+			s.now = next.next
+		}
+		fired := s.now
+		if next.period > 0 {
+			next.next = next.next.Add(next.period)
+		} else {
+			s.removeLocked(next)
+		}
+		s.mu.Unlock()
+
+		select {
+		case next.c <- fired:
+		case <-next.stopped:
+		}
+		if next.period > 0 {
+			// Wait for the receiver to take it, so ticks are handled one
+			// at a time and none is dropped.
+			s.drain(next)
+		}
+	}
+}
+
+// drain waits until t's buffered tick has been received or t is stopped.
+func (s *Sim) drain(t *simTimer) {
+	for len(t.c) > 0 {
+		select {
+		case <-t.stopped:
+			return
+		case <-time.After(50 * time.Microsecond):
+		}
+	}
+}
+
+// dueLocked returns the timer due soonest at or before t. Ties go to the
+// one created first. Caller must hold s.mu.
+func (s *Sim) dueLocked(t time.Time) *simTimer {
+	var due *simTimer
+	for _, x := range s.timers {
+		if !x.next.After(t) && (due == nil || x.next.Before(due.next)) {
+			due = x
+		}
+	}
+	return due
+}
+
+func (s *Sim) removeLocked(t *simTimer) {
+	for i, x := range s.timers {
+		if x == t {
+			s.timers = append(s.timers[:i], s.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Waiters returns how many tickers and pending After timers there are.
+func (s *Sim) Waiters() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.timers)
+}
+
+// BlockUntil waits until at least n tickers and timers are registered,
+// typically so a test knows the loops it started are waiting before it
+// advances the clock.
+func (s *Sim) BlockUntil(n int) {
+	for {
+		s.mu.Lock()
+		ok := len(s.timers) >= n
+		added := s.added
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		<-added
+	}
+}
@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultAllowlistEvery is how often an allowlist is re-read.
+const DefaultAllowlistEvery = 30 * time.Second
+
+// maxAllowlistBytes caps how much of an allowlist source is read.
+const maxAllowlistBytes = 1 << 20
+
+// Allowlist restricts collection to an explicit set of markets, for
+// targeted capture of a few markets instead of whole series. Its source is
+// a file or an http(s) URL holding either a JSON array of strings or one
+// entry per line (commas also separate; # starts a comment). An entry is a
+// market ticker, an event ticker (every strike of one window) or a series
+// ticker. Series named by entries are collected even if they aren't the
+// configured one.
+//
+// The source is re-read periodically, so edits take effect without a
+// restart: markets leave the ticks on the next tick and new ones arrive
+// with the next discovery. A read that fails keeps the previous list; an
+// empty list records no markets, only the spot prices.
+type Allowlist struct {
+	source string
+	every  time.Duration
+	client *http.Client
+
+	mu      sync.RWMutex
+	entries map[string]bool
+}
+
+// NewAllowlist returns an allowlist read from source every every (0 means
+// DefaultAllowlistEvery). It is empty until the first Reload.
+func NewAllowlist(source string, every time.Duration) *Allowlist {
+	if every <= 0 {
+		every = DefaultAllowlistEvery
+	}
+	return &Allowlist{
+		source:  source,
+		every:   every,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		entries: make(map[string]bool),
+	}
+}
+
+// ParseAllowlist reads allowlist entries from data: a JSON array of
+// strings, or entries separated by newlines or commas with # comments.
+// Entries are upper-cased.
+func ParseAllowlist(data []byte) ([]string, error) {
+	var raw []string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("allowlist: %w", err)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			line, _, _ = strings.Cut(line, "#")
+			raw = append(raw, strings.Split(line, ",")...)
+		}
+	}
+	var out []string
+	for _, e := range raw {
+		if e = strings.ToUpper(strings.TrimSpace(e)); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Reload re-reads the source, replacing the list if the read succeeds.
+func (a *Allowlist) Reload(ctx context.Context) error {
+	data, err := a.read(ctx)
+	if err != nil {
+		return err
+	}
+	entries, err := ParseAllowlist(data)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		set[e] = true
+	}
+
+	a.mu.Lock()
+	changed := !maps.Equal(set, a.entries)
+	a.entries = set
+	a.mu.Unlock()
+	if changed {
+		slog.Info("allowlist loaded", "source", a.source, "entries", len(set))
+	}
+	return nil
+}
+
+func (a *Allowlist) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(a.source, "http://") && !strings.HasPrefix(a.source, "https://") {
+		data, err := os.ReadFile(a.source)
+		if err != nil {
+			return nil, fmt.Errorf("reading allowlist: %w", err)
+		}
+		return data, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("allowlist request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching allowlist: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching allowlist: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAllowlistBytes))
+	if err != nil {
+		return nil, fmt.Errorf("fetching allowlist: %w", err)
+	}
+	return data, nil
+}
+
+// Allows reports whether ticker, a market ticker, is on the list itself or
+// through its event or series.
+func (a *Allowlist) Allows(ticker string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.entries[ticker] || a.entries[ticks.EventOf(ticker)] || a.entries[ticks.SeriesOf(ticker)]
+}
+
+// Series returns the series the entries belong to, sorted.
+func (a *Allowlist) Series() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	set := make(map[string]bool)
+	for e := range a.entries {
+		set[ticks.SeriesOf(e)] = true
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// SetAllowlist limits collection to the markets a allows and keeps it
+// reloaded while running. Load it once before Run, so the first discovery
+// has the list. Call before Run.
+func (c *Collector) SetAllowlist(a *Allowlist) {
+	c.allowlist = a
+}
+
+// allowed reports whether ticker may be collected.
+func (c *Collector) allowed(ticker string) bool {
+	return c.allowlist == nil || c.allowlist.Allows(ticker)
+}
+
+// allowlistLoop re-reads the allowlist until ctx is done.
+func (c *Collector) allowlistLoop(ctx context.Context) {
+	ticker := c.clock.NewTicker(c.allowlist.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := c.allowlist.Reload(ctx); err != nil {
+				slog.Warn("allowlist: reload failed, keeping the previous list", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+)
+
+// Book anomalies recorded in ticks.MarketSnap.Anomaly.
+const (
+	BookCrossed = "crossed" // best YES bid at or above best YES ask
+	BookStale   = "stale"   // active market with no WS update for too long
+)
+
+// BookChecker flags WS snapshots whose books can't be trusted. Crossed
+// books mostly come from reconnect races, where deltas for the old
+// subscription land on the new snapshot.
+type BookChecker struct {
+	staleAfter time.Duration // 0 disables the staleness check
+	suppress   bool          // drop flagged markets instead of recording them
+}
+
+func NewBookChecker(staleAfter time.Duration, suppress bool) *BookChecker {
+	return &BookChecker{staleAfter: staleAfter, suppress: suppress}
+}
+
+// Check returns the anomaly for ms at now, or "" if the book looks sane.
+func (b *BookChecker) Check(ms kalshi.MarketSnapshot, now time.Time) string {
+	if crossed(ms.YesBook, ms.NoBook) {
+		return BookCrossed
+	}
+	if b.staleAfter > 0 && ms.Status == "active" && !ms.LastUpdate.IsZero() &&
+		now.Sub(ms.LastUpdate) > b.staleAfter {
+		return BookStale
+	}
+	return ""
+}
+
+// crossed reports whether price-ascending YES and NO bid levels imply a
+// YES bid at or above the YES ask (100 minus the best NO bid).
+func crossed(yes, no [][2]int) bool {
+	if len(yes) == 0 || len(no) == 0 {
+		return false
+	}
+	bid := yes[len(yes)-1][0]
+	ask := 100 - no[len(no)-1][0]
+	return bid >= ask
+}
@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// BRTISymbol is the candle symbol for the BRTI proxy.
+const BRTISymbol = "BRTI"
+
+// CandleAggregator rolls ticks up into OHLCV candles at one or more
+// intervals. Bars are aligned to UTC multiples of their interval and are
+// emitted once a tick lands in the next bar. The bar in progress at
+// shutdown is dropped; the first bar after startup is written with
+// whatever it saw, and its Ticks count shows how much that was.
+type CandleAggregator struct {
+	series []*candleSeries
+}
+
+type candleSeries struct {
+	interval time.Duration
+	label    string
+	start    time.Time
+	bars     map[string]*ticks.CandleRecord
+	volume   map[string]int // last cumulative volume per market
+}
+
+// NewCandleAggregator builds candles at each of intervals.
+func NewCandleAggregator(intervals []time.Duration) *CandleAggregator {
+	a := &CandleAggregator{}
+	for _, iv := range intervals {
+		a.series = append(a.series, &candleSeries{
+			interval: iv,
+			label:    intervalLabel(iv),
+			bars:     make(map[string]*ticks.CandleRecord),
+			volume:   make(map[string]int),
+		})
+	}
+	return a
+}
+
+// ParseCandleIntervals parses a comma-separated interval list like "1m,5m".
+func ParseCandleIntervals(spec string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil || d < time.Second || d%time.Second != 0 {
+			return nil, fmt.Errorf("candle interval %q must be a whole number of seconds", f)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// intervalLabel renders d the way it is usually written for candles:
+// 1m, 5m, 1h rather than 1m0s.
+func intervalLabel(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return strconv.Itoa(int(d/time.Second)) + "s"
+}
+
+// Observe adds one tick taken at now and returns the candles it completed,
+// ordered by interval and then symbol.
+func (a *CandleAggregator) Observe(now time.Time, rec *ticks.TickRecord) []ticks.CandleRecord {
+	var done []ticks.CandleRecord
+	for _, s := range a.series {
+		if start := now.UTC().Truncate(s.interval); !start.Equal(s.start) {
+			done = append(done, s.drain()...)
+			s.start = start
+		}
+		if rec.BRTI > 0 {
+			s.bar(BRTISymbol).add(rec.BRTI, 0)
+		}
+		for _, m := range rec.AllMarkets() {
+			prev, seen := s.volume[m.Ticker]
+			s.volume[m.Ticker] = m.Volume
+			traded := 0
+			if seen && m.Volume > prev {
+				traded = m.Volume - prev
+			}
+			if m.LastPrice > 0 {
+				s.bar(m.Ticker).add(float64(m.LastPrice), traded)
+			} else if b, ok := s.bars[m.Ticker]; ok {
+				b.Volume += traded
+			}
+		}
+	}
+	return done
+}
+
+func (s *candleSeries) bar(symbol string) *candle {
+	b, ok := s.bars[symbol]
+	if !ok {
+		b = &ticks.CandleRecord{
+			Type:     "candle",
+			Interval: s.label,
+			Start:    s.start.Format(time.RFC3339),
+			Symbol:   symbol,
+		}
+		s.bars[symbol] = b
+	}
+	return (*candle)(b)
+}
+
+// drain returns the finished bars sorted by symbol and forgets volume for
+// markets that did not trade in them, so churned markets don't accumulate.
+func (s *candleSeries) drain() []ticks.CandleRecord {
+	out := make([]ticks.CandleRecord, 0, len(s.bars))
+	for _, b := range s.bars {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	for t := range s.volume {
+		if _, ok := s.bars[t]; !ok {
+			delete(s.volume, t)
+		}
+	}
+	clear(s.bars)
+	return out
+}
+
+type candle ticks.CandleRecord
+
+func (c *candle) add(price float64, volume int) {
+	if c.Ticks == 0 {
+		c.Open, c.High, c.Low = price, price, price
+	}
+	c.High = max(c.High, price)
+	c.Low = min(c.Low, price)
+	c.Close = price
+	c.Volume += volume
+	c.Ticks++
+}
+
+// CandleSink receives completed candles.
+type CandleSink interface {
+	WriteCandle(ticks.CandleRecord) error
+	Close() error
+}
+
+// NewJSONLCandleSink writes candles as JSONL through w, so they get the
+// same rotation and compression as ticks.
+func NewJSONLCandleSink(w *Writer) CandleSink {
+	return jsonlCandleSink{w}
+}
+
+type jsonlCandleSink struct{ w *Writer }
+
+func (s jsonlCandleSink) WriteCandle(c ticks.CandleRecord) error { return s.w.Write(c) }
+func (s jsonlCandleSink) Close() error                           { return s.w.Close() }
+
+var candleCSVHeader = []string{"start", "interval", "symbol", "open", "high", "low", "close", "volume", "ticks"}
+
+// CSVCandleSink writes candles to one CSV file per UTC day,
+// <dir>/<prefix>-YYYY-MM-DD.csv, appending to a file from an earlier run.
+type CSVCandleSink struct {
+	dir, prefix string
+	day         string
+	f           *os.File
+	cw          *csv.Writer
+}
+
+func NewCSVCandleSink(dir, prefix string) (*CSVCandleSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create candle dir: %w", err)
+	}
+	return &CSVCandleSink{dir: dir, prefix: prefix}, nil
+}
+
+// WriteCandle appends c to the file for the day the bar started and
+// flushes it; candles arrive at most a few per second.
+func (s *CSVCandleSink) WriteCandle(c ticks.CandleRecord) error {
+	day := c.Start[:len("2006-01-02")]
+	if day != s.day {
+		if err := s.Close(); err != nil {
+			return err
+		}
+		path := filepath.Join(s.dir, fmt.Sprintf("%s-%s.csv", s.prefix, day))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("open candle file: %w", err)
+		}
+		s.f, s.cw, s.day = f, csv.NewWriter(f), day
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			s.cw.Write(candleCSVHeader)
+		}
+	}
+	s.cw.Write([]string{
+		c.Start, c.Interval, c.Symbol,
+		ftoa(c.Open), ftoa(c.High), ftoa(c.Low), ftoa(c.Close),
+		strconv.Itoa(c.Volume), strconv.Itoa(c.Ticks),
+	})
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+func (s *CSVCandleSink) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	s.cw.Flush()
+	err := s.cw.Error()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f, s.cw, s.day = nil, nil, ""
+	return err
+}
+
+func ftoa(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
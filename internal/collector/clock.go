@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultMaxSkew is the clock skew above which the collector warns. The
+// measurement itself is only good to about half a second.
+const DefaultMaxSkew = 2 * time.Second
+
+// EnableClockCheck compares the local clock with Kalshi's every interval,
+// writing a status record each time and warning when the two differ by
+// more than maxSkew.
+func (c *Collector) EnableClockCheck(interval, maxSkew time.Duration) {
+	c.clockEvery = interval
+	c.maxSkew = maxSkew
+}
+
+// ClockSkew returns the last measured Kalshi-minus-local clock skew, and
+// false if none has been measured yet.
+func (c *Collector) ClockSkew() (time.Duration, bool) {
+	return time.Duration(c.clockSkew.Load()), c.clockMeasured.Load()
+}
+
+func (c *Collector) clockLoop(ctx context.Context) {
+	ticker := c.clock.NewTicker(c.clockEvery)
+	defer ticker.Stop()
+
+	c.checkClock(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.checkClock(ctx)
+		}
+	}
+}
+
+func (c *Collector) checkClock(ctx context.Context) {
+	_, sample, err := c.client.GetExchangeStatus(ctx)
+	if err != nil {
+		slog.Debug("clock check failed", "err", err)
+		return
+	}
+	skew := sample.Skew()
+	c.clockSkew.Store(int64(skew))
+	c.clockMeasured.Store(true)
+
+	if skew.Abs() > c.maxSkew {
+		slog.Warn("local clock skewed from Kalshi; secs_left is off by this much",
+			"skew", skew.Round(time.Millisecond), "rtt", sample.RTT.Round(time.Millisecond), "max", c.maxSkew)
+	}
+	rec := ticks.StatusRecord{
+		Type:        "status",
+		Ts:          c.clock.Now().UTC().Format(time.RFC3339Nano),
+		ClockSkewMs: ms(skew),
+		ClockRTTMs:  ms(sample.RTT),
+	}
+	if err := c.writer.Write(rec); err != nil {
+		slog.Warn("status: write failed", "err", err)
+	}
+}
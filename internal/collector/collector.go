@@ -2,85 +2,245 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gw/btc15m-data/internal/alert"
+	"github.com/gw/btc15m-data/internal/clock"
 	"github.com/gw/btc15m-data/internal/feed"
 	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/brti"
+	"github.com/gw/btc15m-data/pkg/ticks"
 )
 
-// TickRecord is one per-second snapshot of all prices.
-type TickRecord struct {
-	Type     string       `json:"type"`
-	Ts       string       `json:"ts"`
-	BRTI     float64      `json:"brti"`
-	Coinbase float64      `json:"coinbase"`
-	Kraken   float64      `json:"kraken"`
-	Bitstamp float64      `json:"bitstamp"`
-	Markets  []MarketSnap `json:"markets,omitempty"`
-}
-
-// MarketSnap is a point-in-time snapshot of a Kalshi market.
-type MarketSnap struct {
-	Ticker    string   `json:"ticker"`
-	YesBid    int      `json:"yes_bid"`
-	YesAsk    int      `json:"yes_ask"`
-	LastPrice int      `json:"last_price"`
-	Volume    int      `json:"volume"`
-	OpenInt   int      `json:"open_interest"`
-	Strike    float64  `json:"strike,omitempty"`
-	SecsLeft  int      `json:"secs_left"`
-	Status    string   `json:"status,omitempty"`
-	Result    string   `json:"result,omitempty"`
-	YesBook   [][2]int `json:"yes_book,omitempty"`
-	NoBook    [][2]int `json:"no_book,omitempty"`
-}
-
 type Collector struct {
-	client   *kalshi.Client
+	client   kalshi.API
 	kalshiWS *kalshi.KalshiFeed
-	brti     *feed.BRTIProxy
-	feeds    []feed.ExchangeFeed
+	brti     *brti.Proxy
 	writer   *Writer
-	series   string
+	clock    clock.Clock
+
+	// series, notifier and feeds can change at runtime (see Reconfigure
+	// and SetFeeds).
+	cfgMu      sync.RWMutex
+	series     string
+	discovered []string       // series added by series discovery
+	notifier   alert.Notifier // nil when alerts are disabled
+	feeds      []brti.ExchangeFeed
+	interval   atomic.Int64 // tick interval in ns; see SetInterval
+
+	divergence *DivergenceDetector // nil when disabled
+	paused     atomic.Bool
+	bookCheck  *BookChecker // nil when disabled
+	badBooks   atomic.Int64
+	window     *WindowTracker    // tick goroutine only
+	candles    *CandleAggregator // nil when disabled
+	candleSink CandleSink
+	influx     *InfluxSink // nil when disabled
+	forecast   *Forecaster // nil when disabled
+	fallback   *restFallback
+	tickHooks  []func(ticks.TickRecord)
+
+	seriesFinder *seriesFinder // nil when series discovery is disabled
+	latency      *feedLatency  // nil when feed latency reporting is disabled
+
+	subPolicy   SubscriptionPolicy
+	allowlist   *Allowlist   // nil when every market is collected
+	droppedSubs atomic.Int64 // markets left out by subPolicy at the last discovery
+	ladderEvery time.Duration
+	lastLadder  time.Time // discovery goroutine only
+
+	expiryCandles *expiryCandles // nil when expiry candles are disabled
+	exposure      *exposureState // nil when exposure recording is disabled
+
+	drift         *kalshi.DriftDetector // nil when schema drift detection is disabled
+	exchange      *exchangeState        // nil when exchange status polling is disabled
+	clockEvery    time.Duration         // 0 when the clock check is disabled
+	maxSkew       time.Duration
+	clockSkew     atomic.Int64 // last measured skew in ns
+	clockMeasured atomic.Bool
+
+	lastTick    time.Time // start of the previous tick; tick goroutine only
+	lastWriteMs float64   // fire → write done for the previous tick; tick goroutine only
 
 	lastWriteMu   sync.Mutex
 	lastWriteTime time.Time
 	tickCount     int64
 }
 
-func New(client *kalshi.Client, kalshiWS *kalshi.KalshiFeed, brti *feed.BRTIProxy, feeds []feed.ExchangeFeed, writer *Writer, series string) *Collector {
-	return &Collector{
+func New(client kalshi.API, kalshiWS *kalshi.KalshiFeed, proxy *brti.Proxy, feeds []brti.ExchangeFeed, writer *Writer, series string) *Collector {
+	c := &Collector{
 		client:   client,
 		kalshiWS: kalshiWS,
-		brti:     brti,
+		brti:     proxy,
 		feeds:    feeds,
 		writer:   writer,
+		clock:    clock.Real,
 		series:   series,
+		window:   NewWindowTracker(WindowLength),
+		fallback: newRESTFallback(DefaultRESTFallbackEvery, DefaultRESTMaxFailures),
+	}
+	c.interval.Store(int64(time.Second))
+	return c
+}
+
+// EnableDivergence writes divergence records when two feeds differ by more
+// than threshold USD for at least minDuration. notifier may be nil.
+func (c *Collector) EnableDivergence(threshold float64, minDuration time.Duration, notifier alert.Notifier) {
+	c.divergence = NewDivergenceDetector(threshold, minDuration)
+	c.cfgMu.Lock()
+	c.notifier = notifier
+	c.cfgMu.Unlock()
+}
+
+// EnableCandles aggregates each tick into candles at the given intervals
+// and writes completed ones to sink.
+func (c *Collector) EnableCandles(intervals []time.Duration, sink CandleSink) {
+	c.candles = NewCandleAggregator(intervals)
+	c.candleSink = sink
+}
+
+// EnableInflux pushes each written tick's metrics to s.
+func (c *Collector) EnableInflux(s *InfluxSink) {
+	c.influx = s
+}
+
+// Reconfigure switches the collected series and the alert notifier
+// (nil disables alerts). Discovery picks up the new series on its next run.
+func (c *Collector) Reconfigure(series string, notifier alert.Notifier) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.series = series
+	c.notifier = notifier
+}
+
+// SetFeeds replaces the exchange feeds recorded in each tick.
+func (c *Collector) SetFeeds(feeds []brti.ExchangeFeed) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.feeds = feeds
+}
+
+func (c *Collector) currentFeeds() []brti.ExchangeFeed {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	return c.feeds
+}
+
+// SetClock makes the collector read time from clk instead of the wall
+// clock, e.g. a clock.Sim in tests and replays. Call it before Run.
+func (c *Collector) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// AddTickHook registers h to be called with every tick once it is written.
+// Hooks run on the tick goroutine, so they must not block, and must not
+// modify the record. Call it before Run.
+func (c *Collector) AddTickHook(h func(ticks.TickRecord)) {
+	c.tickHooks = append(c.tickHooks, h)
+}
+
+// SetInterval changes how often ticks are sampled, from the next tick on.
+func (c *Collector) SetInterval(d time.Duration) {
+	if d > 0 {
+		c.interval.Store(int64(d))
+	}
+}
+
+func (c *Collector) seriesTicker() string {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	return c.series
+}
+
+// Pause stops writing ticks until Resume. Feeds stay connected and the
+// watchdog is suspended.
+func (c *Collector) Pause() {
+	c.paused.Store(true)
+}
+
+func (c *Collector) Resume() {
+	// Restart the watchdog's clock so the pause doesn't count as a stall.
+	c.lastWriteMu.Lock()
+	if !c.lastWriteTime.IsZero() {
+		c.lastWriteTime = c.clock.Now()
 	}
+	c.lastWriteMu.Unlock()
+	c.paused.Store(false)
+}
+
+func (c *Collector) Paused() bool {
+	return c.paused.Load()
+}
+
+// SetDriftDetector reports d's schema drift counts in the heartbeat and
+// the control endpoint's status. d itself is attached to the Kalshi
+// clients.
+func (c *Collector) SetDriftDetector(d *kalshi.DriftDetector) {
+	c.drift = d
+}
+
+// EnableBookCheck flags crossed books and, when staleAfter > 0, active
+// markets without a WS update for that long. With suppress set, flagged
+// markets are left out of the tick instead of being recorded.
+func (c *Collector) EnableBookCheck(staleAfter time.Duration, suppress bool) {
+	c.bookCheck = NewBookChecker(staleAfter, suppress)
 }
 
 func (c *Collector) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	c.recordStartGap()
+
 	// Start watchdog
 	go c.watchdog(ctx, cancel)
 
 	// Start market discovery loop (REST for metadata + subscription management)
 	go c.discoveryLoop(ctx)
 
-	ticker := time.NewTicker(1 * time.Second)
+	if c.clockEvery > 0 {
+		go c.clockLoop(ctx)
+	}
+	if c.exchange != nil {
+		go c.exchangeLoop(ctx)
+	}
+	if c.seriesFinder != nil {
+		go c.seriesLoop(ctx)
+	}
+	if c.latency != nil {
+		go c.latencyLoop(ctx)
+	}
+	if c.allowlist != nil {
+		go c.allowlistLoop(ctx)
+	}
+	if c.expiryCandles != nil {
+		go c.expiryCandlesLoop(ctx)
+	}
+	if c.exposure != nil {
+		go c.exposureLoop(ctx)
+	}
+
+	interval := time.Duration(c.interval.Load())
+	ticker := c.clock.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
-			c.tick(ctx)
+		case fired := <-ticker.C():
+			c.tick(ctx, fired)
+			if d := time.Duration(c.interval.Load()); d != interval {
+				interval = d
+				ticker.Reset(d)
+				slog.Info("tick interval changed", "interval", d)
+			}
 		}
 	}
 }
@@ -95,14 +255,14 @@ func (c *Collector) discoveryLoop(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(interval):
+		case <-c.clock.After(interval):
 			c.discover(ctx)
 		}
 	}
 }
 
 func (c *Collector) discoveryInterval() time.Duration {
-	min := time.Now().Minute() % 15
+	min := c.clock.Now().Minute() % 15
 	if min <= 1 || min >= 14 {
 		return 5 * time.Second // Near market rotation
 	}
@@ -110,45 +270,86 @@ func (c *Collector) discoveryInterval() time.Duration {
 }
 
 func (c *Collector) discover(ctx context.Context) {
-	openMarkets, err := c.client.GetMarkets(ctx, c.series, "open")
-	if err != nil {
-		slog.Debug("discover: open market fetch failed", "err", err)
-	}
+	var allMarkets []kalshi.Market
+	for _, series := range c.allSeries() {
+		openMarkets, err := c.client.GetMarkets(ctx, series, "open")
+		if err != nil {
+			slog.Debug("discover: open market fetch failed", "series", series, "err", err)
+		}
 
-	closedMarkets, err := c.client.GetMarkets(ctx, c.series, "closed")
-	if err != nil {
-		slog.Debug("discover: closed market fetch failed", "err", err)
-	}
+		closedMarkets, err := c.client.GetMarkets(ctx, series, "closed")
+		if err != nil {
+			slog.Debug("discover: closed market fetch failed", "series", series, "err", err)
+		}
 
-	var allMarkets []kalshi.Market
-	allMarkets = append(allMarkets, openMarkets...)
-	allMarkets = append(allMarkets, closedMarkets...)
+		allMarkets = append(allMarkets, openMarkets...)
+		allMarkets = append(allMarkets, closedMarkets...)
+	}
+	c.recordLadder(allMarkets)
+	fetched := len(allMarkets) > 0
+	if c.allowlist != nil {
+		allMarkets = slices.DeleteFunc(allMarkets, func(m kalshi.Market) bool { return !c.allowlist.Allows(m.Ticker) })
+	}
 
-	if c.kalshiWS != nil && len(allMarkets) > 0 {
-		c.kalshiWS.UpdateMetadata(allMarkets)
+	tracked := allMarkets
+	if c.kalshiWS != nil && fetched {
+		// Markets the policy leaves out get no metadata either, so ticks
+		// don't record them with empty prices.
+		selected := c.selectSubscriptions(allMarkets)
+		c.kalshiWS.UpdateMetadata(selected)
 
-		tickers := make([]string, len(allMarkets))
-		for i, m := range allMarkets {
+		tickers := make([]string, len(selected))
+		for i, m := range selected {
 			tickers[i] = m.Ticker
 		}
 		c.kalshiWS.UpdateSubscriptions(tickers)
+		tracked = selected
+	}
+	if c.expiryCandles != nil {
+		c.expiryCandles.track(tracked)
+	}
+	if c.kalshiWS != nil {
+		if n := c.kalshiWS.Evict(c.clock.Now()); n > 0 {
+			slog.Debug("discover: evicted closed markets", "count", n)
+		}
 	}
 }
 
-func (c *Collector) tick(ctx context.Context) {
+// tick samples every source and writes one record. fired is when the
+// sampling timer went off, the baseline for the latency measurements.
+func (c *Collector) tick(ctx context.Context, fired time.Time) {
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("tick panic recovered", "panic", r)
 		}
 	}()
+	if c.paused.Load() {
+		return
+	}
 
-	now := time.Now()
+	now := c.clock.Now()
 	brti := c.brti.Snapshot()
 	c.brti.RecordSample()
 
 	// Snapshot individual feeds
 	var coinbase, kraken, bitstamp float64
-	for _, f := range c.feeds {
+	feeds := c.currentFeeds()
+	live := make(map[string]float64, len(feeds))
+	ages := make(map[string]float64, len(feeds))
+	var sources map[string]string
+	for _, f := range feeds {
+		if s, ok := f.(interface{ Source() string }); ok && s.Source() == feed.SourceREST {
+			if sources == nil {
+				sources = make(map[string]string)
+			}
+			sources[f.Name()] = feed.SourceREST
+		}
+		if p := f.MidPrice(); p > 0 && !f.IsStale() {
+			live[f.Name()] = p
+		}
+		if u := f.LastUpdate(); !u.IsZero() {
+			ages[f.Name()] = ms(now.Sub(u))
+		}
 		switch f.Name() {
 		case "coinbase":
 			coinbase = f.MidPrice()
@@ -160,69 +361,184 @@ func (c *Collector) tick(ctx context.Context) {
 	}
 
 	// Get Kalshi market data: WS when connected, REST fallback otherwise
-	var snaps []MarketSnap
+	var snaps []ticks.MarketSnap
+	var source string
+	expiries := make(map[string]time.Time)
+	closes := make(map[string]time.Time)
 	if c.kalshiWS != nil && c.kalshiWS.IsConnected() {
+		banded := c.subPolicy.banded() && brti > 0
+		primary := c.seriesTicker()
 		for _, ms := range c.kalshiWS.Snapshot() {
-			snaps = append(snaps, MarketSnap{
-				Ticker:    ms.Ticker,
-				YesBid:    ms.YesBid,
-				YesAsk:    ms.YesAsk,
-				LastPrice: ms.LastPrice,
-				Volume:    ms.Volume,
-				OpenInt:   ms.OpenInterest,
-				Strike:    ms.Strike,
-				SecsLeft:  ms.SecsLeft,
-				Status:    ms.Status,
-				Result:    ms.Result,
-				YesBook:   ms.YesBook,
-				NoBook:    ms.NoBook,
+			// Discovered series aren't priced off BRTI, so neither the
+			// band nor the forecasts apply to them.
+			if !c.allowed(ms.Ticker) {
+				continue // cached from before the allowlist dropped it
+			}
+			btc := ticks.SeriesOf(ms.Ticker) == primary
+			if banded && btc && !c.subPolicy.inBand(ms.Strike, brti) {
+				continue // cached from before the band moved away
+			}
+			if !ms.Expiry.IsZero() {
+				expiries[ticks.EventOf(ms.Ticker)] = ms.Expiry
+			}
+			if !ms.Close.IsZero() && btc {
+				closes[ticks.EventOf(ms.Ticker)] = ms.Close
+			}
+			var anomaly string
+			if c.bookCheck != nil {
+				if anomaly = c.bookCheck.Check(ms, now); anomaly != "" {
+					c.badBooks.Add(1)
+					slog.Debug("tick: bad book", "ticker", ms.Ticker, "anomaly", anomaly,
+						"suppressed", c.bookCheck.suppress)
+					if c.bookCheck.suppress {
+						continue
+					}
+				}
+			}
+			snaps = append(snaps, ticks.MarketSnap{
+				Ticker:      ms.Ticker,
+				YesBid:      ms.YesBid,
+				YesAsk:      ms.YesAsk,
+				LastPrice:   ms.LastPrice,
+				Volume:      ms.Volume,
+				OpenInt:     ms.OpenInterest,
+				Strike:      ms.Strike,
+				SecsLeft:    ms.SecsLeft,
+				Status:      ms.Status,
+				Result:      ms.Result,
+				YesBook:     ms.YesBook,
+				NoBook:      ms.NoBook,
+				BookStale:   ms.BookStale,
+				Anomaly:     anomaly,
+				TickerTs:    formatTs(ms.TickerUpdate),
+				BookTs:      formatTs(ms.BookUpdate),
+				Fresh:       ms.LastUpdate.After(c.lastTick),
+				StrikeType:  ms.StrikeType,
+				StrikeFloor: ms.StrikeFloor,
+				StrikeCap:   ms.StrikeCap,
+				Book:        ticks.ComputeBookFeatures(ms.YesBook, ms.NoBook),
+
+				SettlementValue: ms.Settlement,
 			})
 		}
-	} else {
-		snaps = c.restFallback(ctx)
+	} else if rest, ok := c.restFallback(ctx, now, expiries); ok {
+		snaps, source = rest, "rest"
 	}
-
-	rec := TickRecord{
-		Type:     "tick",
-		Ts:       now.UTC().Format(time.RFC3339Nano),
-		BRTI:     brti,
-		Coinbase: coinbase,
-		Kraken:   kraken,
-		Bitstamp: bitstamp,
-		Markets:  snaps,
+	c.lastTick = now
+
+	rec := ticks.TickRecord{
+		Type:          "tick",
+		SchemaVersion: ticks.CurrentSchemaVersion,
+		Ts:            now.UTC().Format(time.RFC3339Nano),
+		BRTI:          brti,
+		Coinbase:      coinbase,
+		Kraken:        kraken,
+		Bitstamp:      bitstamp,
+		Events:        ticks.GroupEvents(snaps, now, expiries),
+		Window:        c.window.Observe(now, brti),
+		Source:        source,
+		FeedSources:   sources,
+	}
+	if c.exposure != nil {
+		rec.Exposure = c.exposureFor(snaps)
+	}
+	rec.Latency = &ticks.TickLatency{
+		SnapshotMs:  ms(c.clock.Now().Sub(fired)),
+		PrevWriteMs: c.lastWriteMs,
+		FeedAgeMs:   ages,
 	}
 
-	if err := c.writer.Write(rec); err != nil {
+	err := c.writer.Write(rec)
+	c.lastWriteMs = ms(c.clock.Now().Sub(fired))
+	if err != nil {
 		slog.Warn("tick: write failed", "err", err)
 	} else {
 		c.lastWriteMu.Lock()
-		c.lastWriteTime = time.Now()
+		c.lastWriteTime = c.clock.Now()
 		c.tickCount++
 		c.lastWriteMu.Unlock()
 	}
+
+	if c.influx != nil && err == nil {
+		c.influx.Observe(now, &rec)
+	}
+	if err == nil {
+		for _, h := range c.tickHooks {
+			h(rec)
+		}
+	}
+
+	if c.candles != nil {
+		for _, cd := range c.candles.Observe(now, &rec) {
+			if err := c.candleSink.WriteCandle(cd); err != nil {
+				slog.Warn("tick: candle write failed", "err", err)
+			}
+		}
+	}
+
+	if c.forecast != nil {
+		for _, f := range c.forecast.Observe(now, &rec, closes) {
+			if err := c.writer.Write(f); err != nil {
+				slog.Warn("tick: forecast write failed", "err", err)
+			}
+		}
+	}
+
+	if c.divergence != nil {
+		for _, d := range c.divergence.Observe(now, live) {
+			c.reportDivergence(ctx, d)
+		}
+	}
+}
+
+func (c *Collector) reportDivergence(ctx context.Context, d ticks.DivergenceRecord) {
+	slog.Warn("feed divergence", "state", d.State, "feeds", d.FeedA+"/"+d.FeedB,
+		"diff", d.Diff, "max_diff", d.MaxDiff, "duration", d.DurationSecs)
+	if err := c.writer.Write(d); err != nil {
+		slog.Warn("divergence: write failed", "err", err)
+	}
+
+	c.cfgMu.RLock()
+	notifier := c.notifier
+	c.cfgMu.RUnlock()
+	if notifier == nil || d.State != "start" {
+		return
+	}
+	a := alert.Alert{
+		Title: fmt.Sprintf("Feed divergence: %s vs %s", d.FeedA, d.FeedB),
+		Message: fmt.Sprintf("%s $%.2f vs %s $%.2f (diff $%.2f) for %.0fs",
+			d.FeedA, d.PriceA, d.FeedB, d.PriceB, d.Diff, d.DurationSecs),
+		Time:     c.clock.Now(),
+		Severity: alert.Warning,
+	}
+	go func() {
+		if err := notifier.Notify(ctx, a); err != nil {
+			slog.Warn("divergence: alert failed", "err", err)
+		}
+	}()
 }
 
 // watchdog monitors data flow and cancels context if writes stall.
 // Also emits a periodic heartbeat log every 60s.
 func (c *Collector) watchdog(ctx context.Context, cancel context.CancelFunc) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := c.clock.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	heartbeatTicker := time.NewTicker(60 * time.Second)
+	heartbeatTicker := c.clock.NewTicker(60 * time.Second)
 	defer heartbeatTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-heartbeatTicker.C:
+		case <-heartbeatTicker.C():
 			c.lastWriteMu.Lock()
 			count := c.tickCount
 			lastWrite := c.lastWriteTime
 			c.lastWriteMu.Unlock()
 
 			var feedStatus []string
-			for _, f := range c.feeds {
+			for _, f := range c.currentFeeds() {
 				status := "ok"
 				if f.IsStale() {
 					status = "stale"
@@ -230,21 +546,37 @@ func (c *Collector) watchdog(ctx context.Context, cancel context.CancelFunc) {
 				feedStatus = append(feedStatus, f.Name()+":"+status)
 			}
 
+			ws := c.kalshiWS.Stats()
 			slog.Info("heartbeat",
 				"ticks", count,
-				"last_write_ago", time.Since(lastWrite).Round(time.Second).String(),
+				"last_write_ago", c.clock.Now().Sub(lastWrite).Round(time.Second).String(),
 				"feeds", strings.Join(feedStatus, " "),
-				"kalshi_ws", c.kalshiWS.IsConnected(),
+				"kalshi_ws", ws.Connected,
+				"ws_reconnects", ws.Reconnects,
+				"ws_subscribed", ws.SubscribedTickers,
+				"ws_cached", ws.CachedMarkets,
+				"ws_dropped", c.droppedSubs.Load(),
+				"ws_seq_gaps", ws.SeqGaps,
+				"bad_books", c.badBooks.Load(),
+				"unparsed_strikes", kalshi.UnparsedStrikes(),
+				"schema_drift", c.schemaDrift(),
+				"paused", c.paused.Load(),
 			)
-		case <-ticker.C:
+		case <-ticker.C():
 			c.lastWriteMu.Lock()
 			lastWrite := c.lastWriteTime
 			c.lastWriteMu.Unlock()
 
-			if lastWrite.IsZero() {
-				continue // hasn't started writing yet
+			if lastWrite.IsZero() || c.paused.Load() {
+				continue // hasn't started writing yet, or paused on request
+			}
+			now := c.clock.Now()
+			if now.Sub(lastWrite) > 90*time.Second && c.inMaintenance(now) {
+				slog.Warn("watchdog: no write for 90s during exchange maintenance, not restarting",
+					"last_write", lastWrite.Format(time.RFC3339))
+				continue
 			}
-			if time.Since(lastWrite) > 90*time.Second {
+			if now.Sub(lastWrite) > 90*time.Second {
 				slog.Error("watchdog: no successful write for 90s, triggering restart",
 					"last_write", lastWrite.Format(time.RFC3339),
 				)
@@ -255,42 +587,24 @@ func (c *Collector) watchdog(ctx context.Context, cancel context.CancelFunc) {
 	}
 }
 
-// restFallback fetches market data directly via REST (current behavior, no orderbook depth).
-func (c *Collector) restFallback(ctx context.Context) []MarketSnap {
-	openMarkets, err := c.client.GetMarkets(ctx, c.series, "open")
-	if err != nil {
-		slog.Debug("tick: open market fetch failed", "err", err)
-	}
-
-	closedMarkets, err := c.client.GetMarkets(ctx, c.series, "closed")
-	if err != nil {
-		slog.Debug("tick: closed market fetch failed", "err", err)
+// schemaDrift is the number of schema changes found across endpoints.
+func (c *Collector) schemaDrift() int64 {
+	var n int64
+	for _, v := range c.drift.Counts() {
+		n += v
 	}
+	return n
+}
 
-	var allMarkets []kalshi.Market
-	allMarkets = append(allMarkets, openMarkets...)
-	allMarkets = append(allMarkets, closedMarkets...)
-
-	var snaps []MarketSnap
-	for _, m := range allMarkets {
-		expiry, _ := m.ExpirationParsed()
-		secsLeft := int(time.Until(expiry).Seconds())
-		if secsLeft < 0 {
-			secsLeft = 0
-		}
+// ms converts d to fractional milliseconds, rounded to microseconds.
+func ms(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Millisecond)
+}
 
-		snaps = append(snaps, MarketSnap{
-			Ticker:    m.Ticker,
-			YesBid:    m.YesBid,
-			YesAsk:    m.YesAsk,
-			LastPrice: m.LastPrice,
-			Volume:    m.Volume,
-			OpenInt:   m.OpenInterest,
-			Strike:    m.StrikePrice(),
-			SecsLeft:  secsLeft,
-			Status:    m.Status,
-			Result:    m.Result,
-		})
-	}
-	return snaps
+// formatTs renders a WS receipt time for a tick, or "" if there was none.
+func formatTs(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
 }
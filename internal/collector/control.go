@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/framelog"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// ControlServer accepts operational commands over HTTP so a running
+// collector can be steered without restarting it:
+//
+//	POST /pause        stop writing ticks
+//	POST /resume       resume writing
+//	POST /rotate       close the current file and start a new part
+//	POST /flush        flush buffered records and fsync
+//	POST /reload       re-read configuration and apply what can change live
+//	POST /resubscribe  reconnect the Kalshi WS with fresh subscriptions
+//	POST /dump-frames  write the captured raw WS frames to disk (see SetFrameLog)
+//	GET  /status       pause state, Kalshi WS stats, clock skew and feed latency as JSON
+//
+// There is no authentication; bind it to a loopback address.
+type ControlServer struct {
+	c      *Collector
+	reload func() error
+	frames *framelog.Ring // nil when frame capture is off
+	srv    *http.Server
+}
+
+// ControlStatus is the body of GET /status.
+type ControlStatus struct {
+	Paused      bool              `json:"paused"`
+	Ticks       int64             `json:"ticks"`
+	WS          *kalshi.FeedStats `json:"kalshi_ws,omitempty"`
+	ClockSkewMs *float64          `json:"clock_skew_ms,omitempty"` // Kalshi minus local; absent until measured
+
+	FeedLatency map[string]ticks.LatencyStats `json:"feed_latency,omitempty"` // latest report; see EnableFeedLatency
+	SchemaDrift map[string]int64              `json:"schema_drift,omitempty"` // Kalshi payload changes found, by endpoint
+}
+
+// NewControlServer serves c's controls on addr. reload is called for
+// POST /reload; nil leaves the command unsupported.
+func NewControlServer(addr string, c *Collector, reload func() error) *ControlServer {
+	s := &ControlServer{c: c, reload: reload}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pause", s.command(func() error { c.Pause(); return nil }))
+	mux.HandleFunc("/resume", s.command(func() error { c.Resume(); return nil }))
+	mux.HandleFunc("/rotate", s.command(c.writer.Rotate))
+	mux.HandleFunc("/flush", s.command(c.writer.Sync))
+	mux.HandleFunc("/reload", s.command(s.doReload))
+	mux.HandleFunc("/resubscribe", s.command(s.doResubscribe))
+	mux.HandleFunc("/dump-frames", s.dumpFrames)
+	mux.HandleFunc("/status", s.status)
+	s.srv = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return s
+}
+
+// SetFrameLog makes POST /dump-frames dump r. Call it before Run.
+func (s *ControlServer) SetFrameLog(r *framelog.Ring) {
+	s.frames = r
+}
+
+// Run serves until ctx is cancelled.
+func (s *ControlServer) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("control server listening", "addr", s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("control server: %w", err)
+	}
+	return nil
+}
+
+func (s *ControlServer) command(fn func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := fn(); err != nil {
+			slog.Warn("control command failed", "path", r.URL.Path, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("control command", "path", r.URL.Path)
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+func (s *ControlServer) doReload() error {
+	if s.reload == nil {
+		return errors.New("reload not supported")
+	}
+	return s.reload()
+}
+
+func (s *ControlServer) doResubscribe() error {
+	if s.c.kalshiWS == nil {
+		return errors.New("kalshi ws not enabled")
+	}
+	s.c.kalshiWS.Resubscribe()
+	return nil
+}
+
+// dumpFrames answers with the path of the dump.
+func (s *ControlServer) dumpFrames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	path, err := s.frames.Dump("control")
+	if err != nil {
+		slog.Warn("control command failed", "path", r.URL.Path, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("control command", "path", r.URL.Path, "dump", path)
+	fmt.Fprintln(w, path)
+}
+
+func (s *ControlServer) status(w http.ResponseWriter, r *http.Request) {
+	s.c.lastWriteMu.Lock()
+	st := ControlStatus{Paused: s.c.Paused(), Ticks: s.c.tickCount}
+	s.c.lastWriteMu.Unlock()
+	if s.c.kalshiWS != nil {
+		ws := s.c.kalshiWS.Stats()
+		st.WS = &ws
+	}
+	if skew, ok := s.c.ClockSkew(); ok {
+		v := ms(skew)
+		st.ClockSkewMs = &v
+	}
+	st.FeedLatency = s.c.FeedLatency()
+	if counts := s.c.drift.Counts(); len(counts) > 0 {
+		st.SchemaDrift = counts
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
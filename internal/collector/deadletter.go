@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/alert"
+)
+
+// DefaultDeadLetterAfter is how many failures in a row raise an alert.
+const DefaultDeadLetterAfter = 3
+
+// DeadLetter takes the records a Writer failed to write, so a disk error
+// delays data instead of dropping it. Records go, exactly as they would
+// have been written, to daily prefix-2006-01-02.jsonl files in a directory
+// of their own (ideally on another disk); they are fsynced as written and
+// never rotated or compressed. Merge them back with the reader tools once
+// the disk is healthy.
+//
+// It also counts failures in a row per kind (writes, compressions) and
+// alerts once a streak reaches the threshold, again when the kind
+// recovers.
+type DeadLetter struct {
+	dir    string
+	prefix string
+	after  int
+
+	mu       sync.Mutex
+	notifier alert.Notifier // nil when alerts are disabled
+	file     *os.File
+	day      string
+	spilled  int64
+	streaks  map[string]int
+	alerted  map[string]bool
+}
+
+// NewDeadLetter returns a DeadLetter writing under dir that alerts after
+// after failures in a row. notifier may be nil.
+func NewDeadLetter(dir, prefix string, after int, notifier alert.Notifier) (*DeadLetter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating dead letter dir: %w", err)
+	}
+	if after <= 0 {
+		after = DefaultDeadLetterAfter
+	}
+	return &DeadLetter{
+		dir:      dir,
+		prefix:   prefix,
+		after:    after,
+		notifier: notifier,
+		streaks:  make(map[string]int),
+		alerted:  make(map[string]bool),
+	}, nil
+}
+
+// SetNotifier replaces the alert notifier, e.g. on a config reload.
+func (d *DeadLetter) SetNotifier(n alert.Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifier = n
+}
+
+// Spilled returns how many records have been written to the dead letter
+// files.
+func (d *DeadLetter) Spilled() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.spilled
+}
+
+// spill appends newline-terminated records, written at now, to the day's
+// file and syncs it.
+func (d *DeadLetter) spill(now time.Time, recs ...[]byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	day := now.UTC().Format("2006-01-02")
+	if d.file == nil || d.day != day {
+		if d.file != nil {
+			d.file.Close()
+		}
+		path := filepath.Join(d.dir, fmt.Sprintf("%s-%s.jsonl", d.prefix, day))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			d.file = nil
+			return fmt.Errorf("opening dead letter file: %w", err)
+		}
+		d.file, d.day = f, day
+	}
+	for _, rec := range recs {
+		if _, err := d.file.Write(rec); err != nil {
+			return fmt.Errorf("writing dead letter file: %w", err)
+		}
+	}
+	if err := d.file.Sync(); err != nil {
+		return fmt.Errorf("syncing dead letter file: %w", err)
+	}
+	d.spilled += int64(len(recs))
+	slog.Warn("records written to dead letter file", "records", len(recs), "path", d.file.Name(), "total", d.spilled)
+	return nil
+}
+
+// failed counts a failure of kind ("write" or "compress") and alerts when
+// the streak reaches the threshold.
+func (d *DeadLetter) failed(kind string, err error) {
+	d.mu.Lock()
+	d.streaks[kind]++
+	n := d.streaks[kind]
+	notify := n >= d.after && !d.alerted[kind]
+	if notify {
+		d.alerted[kind] = true
+	}
+	notifier, spilled := d.notifier, d.spilled
+	d.mu.Unlock()
+
+	if !notify {
+		return
+	}
+	slog.Error("writer failing repeatedly", "kind", kind, "failures", n, "err", err, "dead_letter", d.dir)
+	msg := fmt.Sprintf("%d %s failures in a row, last: %v. ", n, kind, err)
+	if kind == "compress" {
+		msg += "The files stay uncompressed until the next CompressStale."
+	} else {
+		msg += fmt.Sprintf("%d records in dead letter files under %s.", spilled, d.dir)
+	}
+	d.notify(notifier, alert.Alert{
+		Title:    fmt.Sprintf("%s: %s failing", d.prefix, kind),
+		Message:  msg,
+		Time:     time.Now(),
+		Severity: alert.Critical,
+	})
+}
+
+// recovered ends kind's failure streak, alerting if it had alerted.
+func (d *DeadLetter) recovered(kind string) {
+	d.mu.Lock()
+	n, alerted := d.streaks[kind], d.alerted[kind]
+	if n == 0 {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.streaks, kind)
+	delete(d.alerted, kind)
+	notifier, spilled := d.notifier, d.spilled
+	d.mu.Unlock()
+
+	slog.Info("writer recovered", "kind", kind, "failures", n)
+	if alerted {
+		d.notify(notifier, alert.Alert{
+			Title:    fmt.Sprintf("%s: %s recovered", d.prefix, kind),
+			Message:  fmt.Sprintf("%s succeeded after %d failures. %d records in dead letter files under %s.", kind, n, spilled, d.dir),
+			Time:     time.Now(),
+			Severity: alert.Info,
+		})
+	}
+}
+
+func (d *DeadLetter) notify(notifier alert.Notifier, a alert.Alert) {
+	if notifier == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := notifier.Notify(ctx, a); err != nil {
+			slog.Warn("dead letter: alert failed", "err", err)
+		}
+	}()
+}
+
+// Close closes the current dead letter file.
+func (d *DeadLetter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// BookDeduper is a Writer encoder that drops the orderbook of any market
+// whose book and prices are identical to the previous tick, marking it
+// "unchanged" instead; ticks.Reader restores the book. The first tick of
+// every file and of every minute is written in full, so a reader starting
+// at a file or an index block (see ticks.Index) never depends on earlier
+// data. Non-tick events pass through unchanged.
+type BookDeduper struct {
+	minute time.Time
+	prev   map[string]ticks.MarketSnap
+}
+
+func NewBookDeduper() *BookDeduper {
+	return &BookDeduper{}
+}
+
+func (d *BookDeduper) Encode(event any, newFile bool) any {
+	rec, ok := event.(ticks.TickRecord)
+	if !ok || len(rec.Events) == 0 {
+		return event
+	}
+
+	full := newFile || d.prev == nil
+	if ts, err := time.Parse(time.RFC3339Nano, rec.Ts); err == nil {
+		if m := ts.Truncate(time.Minute); !m.Equal(d.minute) {
+			d.minute = m
+			full = true
+		}
+	}
+
+	cur := make(map[string]ticks.MarketSnap, len(d.prev))
+	// Copy the slices so the caller's record keeps its books.
+	rec.Events = slices.Clone(rec.Events)
+	for i := range rec.Events {
+		ev := &rec.Events[i]
+		ev.Markets = slices.Clone(ev.Markets)
+		for j := range ev.Markets {
+			m := &ev.Markets[j]
+			cur[m.Ticker] = *m
+			if full {
+				continue
+			}
+			if old, ok := d.prev[m.Ticker]; ok && sameBookAndPrices(old, *m) && (len(m.YesBook) > 0 || len(m.NoBook) > 0) {
+				m.YesBook, m.NoBook, m.Book = nil, nil, nil
+				m.Unchanged = true
+			}
+		}
+	}
+	d.prev = cur
+	return rec
+}
+
+func sameBookAndPrices(a, b ticks.MarketSnap) bool {
+	return a.YesBid == b.YesBid && a.YesAsk == b.YesAsk && a.LastPrice == b.LastPrice &&
+		a.Volume == b.Volume && a.OpenInt == b.OpenInt &&
+		slices.Equal(a.YesBook, b.YesBook) && slices.Equal(a.NoBook, b.NoBook)
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DeltaEncoder is a Writer encoder that emits a full tick once keyframeEvery
+// has passed since the last, going by the ticks' timestamps (and at the
+// start of every file), and DeltaRecords in between (see ticks.DeltaRecord).
+// Keyframes stay the same time apart whatever the tick interval. Non-tick
+// events pass through unchanged.
+type DeltaEncoder struct {
+	keyframeEvery time.Duration
+	lastKey       time.Time // timestamp of the last keyframe
+	prev          map[string]ticks.MarketSnap
+}
+
+func NewDeltaEncoder(keyframeEvery time.Duration) *DeltaEncoder {
+	return &DeltaEncoder{keyframeEvery: keyframeEvery}
+}
+
+func (e *DeltaEncoder) Encode(event any, newFile bool) any {
+	rec, ok := event.(ticks.TickRecord)
+	if !ok {
+		return event
+	}
+
+	markets := rec.AllMarkets()
+	cur := make(map[string]ticks.MarketSnap, len(markets))
+	for _, m := range markets {
+		cur[m.Ticker] = m
+	}
+
+	// A tick stamped before the last keyframe (the clock stepped back) or
+	// without a readable timestamp starts over with a keyframe too.
+	ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+	if newFile || e.prev == nil || err != nil || ts.Before(e.lastKey) || ts.Sub(e.lastKey) >= e.keyframeEvery {
+		e.prev = cur
+		e.lastKey = ts
+		return rec
+	}
+
+	d := ticks.DeltaRecord{
+		Type:     "delta",
+		Ts:       rec.Ts,
+		BRTI:     rec.BRTI,
+		Coinbase: rec.Coinbase,
+		Kraken:   rec.Kraken,
+		Bitstamp: rec.Bitstamp,
+		Latency:  rec.Latency,
+		Window:   rec.Window,
+		Source:   rec.Source,
+
+		FeedSources: rec.FeedSources,
+		Exposure:    rec.Exposure,
+	}
+	for _, m := range markets {
+		old, seen := e.prev[m.Ticker]
+		if md, changed := diffMarket(old, m, seen); changed {
+			d.Markets = append(d.Markets, md)
+		}
+	}
+	for ticker := range e.prev {
+		if _, ok := cur[ticker]; !ok {
+			d.Removed = append(d.Removed, ticker)
+		}
+	}
+	sort.Strings(d.Removed)
+
+	e.prev = cur
+	return d
+}
+
+func diffMarket(old, cur ticks.MarketSnap, seen bool) (ticks.MarketDelta, bool) {
+	md := ticks.MarketDelta{Ticker: cur.Ticker}
+	changed := !seen
+	if !seen || old.YesBid != cur.YesBid {
+		md.YesBid, changed = ptr(cur.YesBid), true
+	}
+	if !seen || old.YesAsk != cur.YesAsk {
+		md.YesAsk, changed = ptr(cur.YesAsk), true
+	}
+	if !seen || old.LastPrice != cur.LastPrice {
+		md.LastPrice, changed = ptr(cur.LastPrice), true
+	}
+	if !seen || old.Volume != cur.Volume {
+		md.Volume, changed = ptr(cur.Volume), true
+	}
+	if !seen || old.OpenInt != cur.OpenInt {
+		md.OpenInt, changed = ptr(cur.OpenInt), true
+	}
+	if !seen || old.Strike != cur.Strike {
+		md.Strike, changed = ptr(cur.Strike), true
+	}
+	if !seen || old.SecsLeft != cur.SecsLeft {
+		md.SecsLeft, changed = ptr(cur.SecsLeft), true
+	}
+	if !seen || old.Status != cur.Status {
+		md.Status, changed = ptr(cur.Status), true
+	}
+	if !seen || old.Result != cur.Result {
+		md.Result, changed = ptr(cur.Result), true
+	}
+	if !seen || old.BookStale != cur.BookStale {
+		md.BookStale, changed = ptr(cur.BookStale), true
+	}
+	if !seen || old.Anomaly != cur.Anomaly {
+		md.Anomaly, changed = ptr(cur.Anomaly), true
+	}
+	if !seen || old.TickerTs != cur.TickerTs {
+		md.TickerTs, changed = ptr(cur.TickerTs), true
+	}
+	if !seen || old.BookTs != cur.BookTs {
+		md.BookTs, changed = ptr(cur.BookTs), true
+	}
+	if !seen || old.Fresh != cur.Fresh {
+		md.Fresh, changed = ptr(cur.Fresh), true
+	}
+	if old.StrikeType != cur.StrikeType {
+		md.StrikeType, changed = ptr(cur.StrikeType), true
+	}
+	if old.StrikeFloor != cur.StrikeFloor {
+		md.StrikeFloor, changed = ptr(cur.StrikeFloor), true
+	}
+	if old.StrikeCap != cur.StrikeCap {
+		md.StrikeCap, changed = ptr(cur.StrikeCap), true
+	}
+	if old.SettlementValue != cur.SettlementValue {
+		md.SettlementValue, changed = ptr(cur.SettlementValue), true
+	}
+	if lv := diffLevels(old.YesBook, cur.YesBook); len(lv) > 0 {
+		md.YesBook, changed = lv, true
+	}
+	if lv := diffLevels(old.NoBook, cur.NoBook); len(lv) > 0 {
+		md.NoBook, changed = lv, true
+	}
+	return md, changed
+}
+
+// diffLevels returns the levels that differ between two price-sorted books.
+// Levels present in prev but missing from cur are emitted with qty 0.
+func diffLevels(prev, cur [][2]int) [][2]int {
+	var out [][2]int
+	i, j := 0, 0
+	for i < len(prev) || j < len(cur) {
+		switch {
+		case j >= len(cur) || (i < len(prev) && prev[i][0] < cur[j][0]):
+			out = append(out, [2]int{prev[i][0], 0})
+			i++
+		case i >= len(prev) || cur[j][0] < prev[i][0]:
+			out = append(out, cur[j])
+			j++
+		default:
+			if prev[i][1] != cur[j][1] {
+				out = append(out, cur[j])
+			}
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DivergenceDetector watches every pair of feeds and reports episodes where
+// their prices differ by more than threshold for at least minDuration.
+type DivergenceDetector struct {
+	threshold   float64
+	minDuration time.Duration
+	pairs       map[[2]string]*divergenceState
+}
+
+type divergenceState struct {
+	since    time.Time
+	maxDiff  float64
+	reported bool
+}
+
+func NewDivergenceDetector(threshold float64, minDuration time.Duration) *DivergenceDetector {
+	return &DivergenceDetector{
+		threshold:   threshold,
+		minDuration: minDuration,
+		pairs:       make(map[[2]string]*divergenceState),
+	}
+}
+
+// Observe checks one sample of live feed prices and returns any episode
+// start/end records. Feeds missing from prices end their open episodes.
+func (d *DivergenceDetector) Observe(now time.Time, prices map[string]float64) []ticks.DivergenceRecord {
+	names := make([]string, 0, len(prices))
+	for n := range prices {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var out []ticks.DivergenceRecord
+	seen := make(map[[2]string]bool)
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			key := [2]string{names[i], names[j]}
+			seen[key] = true
+			a, b := prices[names[i]], prices[names[j]]
+			if rec, ok := d.observePair(now, key, a, b); ok {
+				out = append(out, rec)
+			}
+		}
+	}
+
+	// Feeds that went stale close their episodes.
+	for key, st := range d.pairs {
+		if seen[key] {
+			continue
+		}
+		if st.reported {
+			out = append(out, d.record(now, key, "end", 0, 0, st))
+		}
+		delete(d.pairs, key)
+	}
+	return out
+}
+
+func (d *DivergenceDetector) observePair(now time.Time, key [2]string, a, b float64) (ticks.DivergenceRecord, bool) {
+	diff := a - b
+	st := d.pairs[key]
+
+	if math.Abs(diff) <= d.threshold {
+		if st == nil {
+			return ticks.DivergenceRecord{}, false
+		}
+		delete(d.pairs, key)
+		if st.reported {
+			return d.record(now, key, "end", a, b, st), true
+		}
+		return ticks.DivergenceRecord{}, false
+	}
+
+	if st == nil {
+		st = &divergenceState{since: now}
+		d.pairs[key] = st
+	}
+	if abs := math.Abs(diff); abs > st.maxDiff {
+		st.maxDiff = abs
+	}
+	if !st.reported && now.Sub(st.since) >= d.minDuration {
+		st.reported = true
+		return d.record(now, key, "start", a, b, st), true
+	}
+	return ticks.DivergenceRecord{}, false
+}
+
+func (d *DivergenceDetector) record(now time.Time, key [2]string, state string, a, b float64, st *divergenceState) ticks.DivergenceRecord {
+	rec := ticks.DivergenceRecord{
+		Type:         "divergence",
+		Ts:           now.UTC().Format(time.RFC3339Nano),
+		State:        state,
+		FeedA:        key[0],
+		FeedB:        key[1],
+		PriceA:       a,
+		PriceB:       b,
+		MaxDiff:      st.maxDiff,
+		Since:        st.since.UTC().Format(time.RFC3339Nano),
+		DurationSecs: now.Sub(st.since).Seconds(),
+	}
+	if a > 0 && b > 0 {
+		rec.Diff = a - b
+	}
+	return rec
+}
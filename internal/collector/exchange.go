@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// scheduleEvery is how often the announced maintenance windows are
+// refetched; they are published well ahead.
+const scheduleEvery = 30 * time.Minute
+
+// exchangeState tracks Kalshi's exchange status and maintenance schedule
+// so ticks can be annotated and the watchdog can stand down.
+type exchangeState struct {
+	every time.Duration
+
+	mu       sync.Mutex
+	known    bool // a status has been fetched
+	status   kalshi.ExchangeStatus
+	windows  []kalshi.MaintenanceWindow
+	last     ticks.ExchangeStatusRecord // last record written, for change detection
+	lastSent bool
+}
+
+// EnableExchangeStatus polls Kalshi's exchange status every interval,
+// writing an exchange_status record whenever it changes, and suspends the
+// watchdog while the exchange is down or inside an announced maintenance
+// window.
+func (c *Collector) EnableExchangeStatus(interval time.Duration) {
+	c.exchange = &exchangeState{every: interval}
+}
+
+// inMaintenance reports whether the exchange is known to be down at now,
+// either from its status or from the announced schedule.
+func (c *Collector) inMaintenance(now time.Time) bool {
+	if c.exchange == nil {
+		return false
+	}
+	_, ok := c.exchange.maintenance(now)
+	return ok
+}
+
+// maintenance returns the end of the announced window containing now
+// (zero if the status alone says the exchange is down) and whether the
+// exchange is in maintenance at all.
+func (e *exchangeState) maintenance(now time.Time) (time.Time, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.windows {
+		if !now.Before(w.Start) && now.Before(w.End) {
+			return w.End, true
+		}
+	}
+	return time.Time{}, e.known && !e.status.ExchangeActive
+}
+
+func (c *Collector) exchangeLoop(ctx context.Context) {
+	ticker := c.clock.NewTicker(c.exchange.every)
+	defer ticker.Stop()
+
+	var lastSchedule time.Time
+	for {
+		if c.clock.Now().Sub(lastSchedule) >= scheduleEvery {
+			if sched, err := c.client.GetExchangeSchedule(ctx); err != nil {
+				slog.Debug("exchange schedule fetch failed", "err", err)
+			} else {
+				c.exchange.mu.Lock()
+				c.exchange.windows = sched.MaintenanceWindows
+				c.exchange.mu.Unlock()
+				lastSchedule = c.clock.Now()
+			}
+		}
+		c.checkExchange(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (c *Collector) checkExchange(ctx context.Context) {
+	e := c.exchange
+	status, _, err := c.client.GetExchangeStatus(ctx)
+	if err != nil {
+		slog.Debug("exchange status fetch failed", "err", err)
+	} else {
+		e.mu.Lock()
+		e.known, e.status = true, *status
+		e.mu.Unlock()
+	}
+
+	now := c.clock.Now()
+	end, down := e.maintenance(now)
+	e.mu.Lock()
+	rec := ticks.ExchangeStatusRecord{
+		Type:           "exchange_status",
+		ExchangeActive: e.status.ExchangeActive,
+		TradingActive:  e.status.TradingActive,
+		Maintenance:    down,
+	}
+	if !e.known {
+		// Unknown status: only the schedule speaks.
+		rec.ExchangeActive, rec.TradingActive = !down, !down
+	}
+	if !end.IsZero() {
+		rec.MaintenanceEnd = end.UTC().Format(time.RFC3339)
+	}
+	changed := !e.lastSent || rec != e.last
+	wasDown := e.last.Maintenance
+	e.last, e.lastSent = rec, true
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if wasDown && !down {
+		// Don't count the maintenance against the watchdog.
+		c.lastWriteMu.Lock()
+		if !c.lastWriteTime.IsZero() {
+			c.lastWriteTime = now
+		}
+		c.lastWriteMu.Unlock()
+	}
+	slog.Info("exchange status", "exchange_active", rec.ExchangeActive, "trading_active", rec.TradingActive,
+		"maintenance", rec.Maintenance, "maintenance_end", rec.MaintenanceEnd)
+	rec.Ts = now.UTC().Format(time.RFC3339Nano)
+	if err := c.writer.Write(rec); err != nil {
+		slog.Warn("exchange status: write failed", "err", err)
+	}
+}
@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultExpiryCandlesDelay is how long after a market closes its
+// candlesticks are fetched, giving Kalshi time to finish the last bar.
+const DefaultExpiryCandlesDelay = time.Minute
+
+const (
+	expiryCandlesEvery    = 15 * time.Second // how often due markets are looked for
+	expiryCandlesAttempts = 5                // fetches before a market is given up on
+	expiryCandlesKeep     = 24 * time.Hour   // how long fetched markets are remembered
+)
+
+// expiryCandles tracks the markets whose candlesticks are still to be
+// fetched. Discovery adds to it; the candles goroutine fetches.
+type expiryCandles struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingCandles
+	done    map[string]time.Time // fetched or given up on, by close time
+}
+
+type pendingCandles struct {
+	ticker      string
+	open, close time.Time
+	failures    int
+	retryAt     time.Time
+}
+
+// EnableExpiryCandles writes a candles record for every market the
+// collector tracks, with Kalshi's 1-minute candlesticks over the market's
+// life, fetched delay after it closes. Failed fetches are retried a few
+// times with growing pauses. A market that closed shortly before a restart
+// can be fetched by both runs. Call before Run.
+func (c *Collector) EnableExpiryCandles(delay time.Duration) {
+	c.expiryCandles = &expiryCandles{
+		delay:   delay,
+		pending: make(map[string]*pendingCandles),
+		done:    make(map[string]time.Time),
+	}
+}
+
+// track adds markets not seen before.
+func (e *expiryCandles) track(markets []kalshi.Market) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, m := range markets {
+		if _, ok := e.pending[m.Ticker]; ok {
+			continue
+		}
+		if _, ok := e.done[m.Ticker]; ok {
+			continue
+		}
+		open, err1 := time.Parse(time.RFC3339, m.OpenTime)
+		close, err2 := time.Parse(time.RFC3339, m.CloseTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		e.pending[m.Ticker] = &pendingCandles{ticker: m.Ticker, open: open, close: close}
+	}
+}
+
+// due returns the markets ready to fetch at now, earliest close first.
+func (e *expiryCandles) due(now time.Time) []pendingCandles {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []pendingCandles
+	for _, p := range e.pending {
+		if !now.Before(p.close.Add(e.delay)) && !now.Before(p.retryAt) {
+			out = append(out, *p)
+		}
+	}
+	slices.SortFunc(out, func(a, b pendingCandles) int { return a.close.Compare(b.close) })
+	return out
+}
+
+// finish moves ticker from pending to done.
+func (e *expiryCandles) finish(ticker string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p, ok := e.pending[ticker]; ok {
+		e.done[ticker] = p.close
+		delete(e.pending, ticker)
+	}
+}
+
+// failed counts a failed fetch of ticker, and reports whether it is given
+// up on.
+func (e *expiryCandles) failed(ticker string, now time.Time) (failures int, gaveUp bool) {
+	e.mu.Lock()
+	p, ok := e.pending[ticker]
+	if !ok {
+		e.mu.Unlock()
+		return 0, false
+	}
+	p.failures++
+	p.retryAt = now.Add(time.Duration(p.failures) * time.Minute)
+	failures = p.failures
+	e.mu.Unlock()
+	if failures >= expiryCandlesAttempts {
+		e.finish(ticker)
+		return failures, true
+	}
+	return failures, false
+}
+
+// prune forgets markets fetched long enough ago that discovery no longer
+// returns them.
+func (e *expiryCandles) prune(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ticker, close := range e.done {
+		if now.Sub(close) > expiryCandlesKeep {
+			delete(e.done, ticker)
+		}
+	}
+}
+
+// expiryCandlesLoop fetches due candlesticks until ctx is done.
+func (c *Collector) expiryCandlesLoop(ctx context.Context) {
+	ticker := c.clock.NewTicker(expiryCandlesEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.fetchExpiryCandles(ctx)
+		}
+	}
+}
+
+// fetchExpiryCandles fetches and writes the candlesticks of every due
+// market.
+func (c *Collector) fetchExpiryCandles(ctx context.Context) {
+	e := c.expiryCandles
+	for _, p := range e.due(c.clock.Now()) {
+		if ctx.Err() != nil {
+			return
+		}
+		bars, err := c.client.GetMarketCandlesticks(ctx, ticks.SeriesOf(p.ticker), p.ticker, p.open, p.close, time.Minute)
+		if err == nil && len(bars) == 0 {
+			err = errNoCandles
+		}
+		if err != nil {
+			n, gaveUp := e.failed(p.ticker, c.clock.Now())
+			if gaveUp {
+				slog.Warn("expiry candles: giving up", "ticker", p.ticker, "attempts", n, "err", err)
+			} else {
+				slog.Debug("expiry candles: fetch failed", "ticker", p.ticker, "attempt", n, "err", err)
+			}
+			continue
+		}
+		if err := c.writer.Write(candlesRecord(p, bars, c.clock.Now())); err != nil {
+			slog.Warn("expiry candles: write failed", "ticker", p.ticker, "err", err)
+		}
+		e.finish(p.ticker)
+	}
+	e.prune(c.clock.Now())
+}
+
+var errNoCandles = errors.New("no candlesticks yet")
+
+func candlesRecord(p pendingCandles, bars []kalshi.Candlestick, now time.Time) ticks.MarketCandlesRecord {
+	rec := ticks.MarketCandlesRecord{
+		Type:     "candles",
+		Ts:       now.UTC().Format(time.RFC3339Nano),
+		Ticker:   p.ticker,
+		Interval: "1m",
+		Open:     p.open.UTC().Format(time.RFC3339),
+		Close:    p.close.UTC().Format(time.RFC3339),
+		Candles:  make([]ticks.MarketCandle, len(bars)),
+	}
+	for i, b := range bars {
+		mc := ticks.MarketCandle{
+			End:     time.Unix(b.EndPeriodTs, 0).UTC().Format(time.RFC3339),
+			YesBid:  ticks.PriceOHLC(b.YesBid),
+			YesAsk:  ticks.PriceOHLC(b.YesAsk),
+			Volume:  b.Volume,
+			OpenInt: b.OpenInterest,
+		}
+		if pr := b.Price; pr.Open != nil && pr.High != nil && pr.Low != nil && pr.Close != nil {
+			mc.Price = &ticks.PriceOHLC{Open: *pr.Open, High: *pr.High, Low: *pr.Low, Close: *pr.Close}
+		}
+		rec.Candles[i] = mc
+	}
+	return rec
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultExposureEvery is how often resting orders are polled with
+// --exposure.
+const DefaultExposureEvery = 10 * time.Second
+
+// exposureState holds the account's resting orders from the last poll.
+// Positions live in the Kalshi WS feed, which tracks the market_positions
+// channel.
+type exposureState struct {
+	every time.Duration
+
+	mu      sync.Mutex
+	resting map[string]restingOrders // by ticker
+}
+
+type restingOrders struct {
+	orders, yes, no int
+}
+
+// EnableExposure adds the account's position and resting orders in each
+// tracked market to every tick. Positions come from the Kalshi WS, which
+// must have had EnablePositions called; they are seeded over REST when
+// the collector starts. Resting orders are polled over REST every
+// interval, so they can lag fills by that much.
+func (c *Collector) EnableExposure(interval time.Duration) {
+	c.exposure = &exposureState{every: interval}
+}
+
+func (c *Collector) exposureLoop(ctx context.Context) {
+	c.seedPositions(ctx)
+
+	ticker := c.clock.NewTicker(c.exposure.every)
+	defer ticker.Stop()
+	for {
+		c.pollResting(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// seedPositions loads the holdings the positions channel won't report
+// until they next change.
+func (c *Collector) seedPositions(ctx context.Context) {
+	if c.kalshiWS == nil {
+		return
+	}
+	var all []kalshi.MarketPosition
+	var cursor string
+	for {
+		ps, next, err := c.client.GetPositions(ctx, kalshi.PositionParams{Cursor: cursor})
+		if err != nil {
+			slog.Warn("exposure: position seed failed", "err", err)
+			return
+		}
+		all = append(all, ps...)
+		if next == "" || len(ps) == 0 {
+			break
+		}
+		cursor = next
+	}
+	c.kalshiWS.SeedPositions(all)
+	slog.Debug("exposure: positions seeded", "markets", len(all))
+}
+
+func (c *Collector) pollResting(ctx context.Context) {
+	orders, err := c.client.GetOpenOrders(ctx, "")
+	if err != nil {
+		slog.Debug("exposure: resting order poll failed", "err", err)
+		return // keep the last known orders
+	}
+	resting := make(map[string]restingOrders)
+	for _, o := range orders {
+		r := resting[o.Ticker]
+		r.orders++
+		if o.Side == "yes" {
+			r.yes += o.RemainingQuantity
+		} else {
+			r.no += o.RemainingQuantity
+		}
+		resting[o.Ticker] = r
+	}
+	c.exposure.mu.Lock()
+	c.exposure.resting = resting
+	c.exposure.mu.Unlock()
+}
+
+// exposureFor returns the account's stake in the markets of snaps, or nil
+// if it has none there.
+func (c *Collector) exposureFor(snaps []ticks.MarketSnap) map[string]ticks.MarketExposure {
+	var positions map[string]kalshi.Position
+	if c.kalshiWS != nil {
+		positions = c.kalshiWS.Positions()
+	}
+	c.exposure.mu.Lock()
+	defer c.exposure.mu.Unlock()
+
+	var out map[string]ticks.MarketExposure
+	for _, m := range snaps {
+		p, held := positions[m.Ticker]
+		r, rests := c.exposure.resting[m.Ticker]
+		if !held && !rests {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]ticks.MarketExposure)
+		}
+		out[m.Ticker] = ticks.MarketExposure{
+			Position:      p.Position,
+			Cost:          p.Cost,
+			RealizedPnL:   p.RealizedPnL,
+			FeesPaid:      p.FeesPaid,
+			RestingOrders: r.orders,
+			RestingYes:    r.yes,
+			RestingNo:     r.no,
+		}
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// REST fallback defaults. Each fetch is two /markets calls, so fetching on
+// every tick while the WS is down risks being rate limited.
+const (
+	DefaultRESTFallbackEvery = 5 * time.Second
+	DefaultRESTMaxFailures   = 5
+
+	// restBreakerCooldown is how long the fallback stops fetching once
+	// the circuit breaker has tripped.
+	restBreakerCooldown = time.Minute
+)
+
+// restFallback fetches markets over REST while the Kalshi WS is down, at
+// most once per interval, and shares each fetch between the ticks until
+// the next. After maxFailures consecutive failed fetches it stops for
+// restBreakerCooldown, then tries again. Tick goroutine only.
+type restFallback struct {
+	every       time.Duration // 0 disables the fallback
+	maxFailures int
+
+	attempted time.Time       // last fetch attempt
+	markets   []kalshi.Market // from the last fetch, nil if it failed
+	failures  int             // consecutive failed fetches
+	openUntil time.Time       // breaker open: no fetches before this
+}
+
+func newRESTFallback(every time.Duration, maxFailures int) *restFallback {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return &restFallback{every: every, maxFailures: maxFailures}
+}
+
+// SetRESTFallback sets how often markets are fetched over REST while the
+// WS is down (0 disables the fallback) and after how many consecutive
+// failures the fetches pause for a minute. Call before Run.
+func (c *Collector) SetRESTFallback(every time.Duration, maxFailures int) {
+	c.fallback = newRESTFallback(every, maxFailures)
+}
+
+// restFallback returns market snapshots from the REST fallback, fetching
+// if the last fetch is older than the fallback interval. Market expiries
+// are recorded into expiries by event. ok is false when there is nothing
+// to report: the fallback is disabled, its breaker is open or the last
+// fetch failed.
+func (c *Collector) restFallback(ctx context.Context, now time.Time, expiries map[string]time.Time) (snaps []ticks.MarketSnap, ok bool) {
+	f := c.fallback
+	if f.every <= 0 {
+		return nil, false
+	}
+
+	fresh := false
+	if now.Sub(f.attempted) >= f.every {
+		if now.Before(f.openUntil) {
+			return nil, false
+		}
+		f.attempted = now
+		f.markets, fresh = c.fetchFallback(ctx), true
+	}
+	if f.markets == nil {
+		return nil, false
+	}
+
+	spot := c.brti.Price()
+	primary := c.seriesTicker()
+	for _, m := range f.markets {
+		if !c.allowed(m.Ticker) {
+			continue
+		}
+		if c.subPolicy.banded() && spot > 0 && ticks.SeriesOf(m.Ticker) == primary &&
+			!c.subPolicy.inBand(m.StrikePrice(), spot) {
+			continue
+		}
+		snap, expiry := restSnap(m, now, fresh)
+		if !expiry.IsZero() {
+			expiries[ticks.EventOf(m.Ticker)] = expiry
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, true
+}
+
+// restSnap is m as a tick market, without books, and its expiry (zero if
+// unparseable).
+func restSnap(m kalshi.Market, now time.Time, fresh bool) (ticks.MarketSnap, time.Time) {
+	expiry, _ := m.ExpirationParsed()
+	secsLeft := int(expiry.Sub(now).Seconds())
+	if secsLeft < 0 {
+		secsLeft = 0
+	}
+	kind, floor, cap := m.StrikeRange()
+	settlement, _ := m.SettlementValue()
+	return ticks.MarketSnap{
+		Ticker:      m.Ticker,
+		YesBid:      m.YesBid,
+		YesAsk:      m.YesAsk,
+		LastPrice:   m.LastPrice,
+		Volume:      m.Volume,
+		OpenInt:     m.OpenInterest,
+		Strike:      m.StrikePrice(),
+		SecsLeft:    secsLeft,
+		Status:      m.Status,
+		Result:      m.Result,
+		Fresh:       fresh,
+		StrikeType:  kind,
+		StrikeFloor: floor,
+		StrikeCap:   cap,
+
+		SettlementValue: settlement,
+	}, expiry
+}
+
+// fetchFallback fetches open and closed markets of every collected
+// series, returning nil and counting a failure towards the breaker if any
+// call fails.
+func (c *Collector) fetchFallback(ctx context.Context) []kalshi.Market {
+	f := c.fallback
+	var markets []kalshi.Market
+	var err error
+	for _, series := range c.allSeries() {
+		var openMarkets, closedMarkets []kalshi.Market
+		if openMarkets, err = c.client.GetMarkets(ctx, series, "open"); err != nil {
+			break
+		}
+		if closedMarkets, err = c.client.GetMarkets(ctx, series, "closed"); err != nil {
+			break
+		}
+		markets = append(append(markets, openMarkets...), closedMarkets...)
+	}
+	if err == nil {
+		if f.failures >= f.maxFailures {
+			slog.Info("rest fallback: recovered, circuit closed")
+		}
+		f.failures = 0
+		return markets
+	}
+
+	f.failures++
+	slog.Debug("rest fallback: market fetch failed", "err", err, "failures", f.failures)
+	if f.failures >= f.maxFailures {
+		f.openUntil = c.clock.Now().Add(restBreakerCooldown)
+		slog.Warn("rest fallback: circuit open after repeated failures",
+			"failures", f.failures, "retry_in", restBreakerCooldown, "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// ForecastVolWindow is how much BRTI history the forecaster estimates
+// volatility from.
+const ForecastVolWindow = 15 * time.Minute
+
+// Forecaster writes a settlement forecast for each open event every so
+// often, from the BRTI it has seen over the last ForecastVolWindow. It
+// stays quiet until that history spans at least a minute, so a fresh start
+// doesn't forecast from a handful of returns.
+type Forecaster struct {
+	model   ticks.ForecastModel
+	every   time.Duration
+	last    time.Time
+	samples []ticks.PriceSample
+}
+
+// NewForecaster forecasts with model at most once per every.
+func NewForecaster(model ticks.ForecastModel, every time.Duration) *Forecaster {
+	return &Forecaster{model: model, every: every}
+}
+
+// EnableForecast writes forecast records alongside ticks; see Forecaster.
+func (c *Collector) EnableForecast(f *Forecaster) {
+	c.forecast = f
+}
+
+// Observe adds the tick's BRTI and, when a forecast is due, returns one
+// record per event whose close is known and still ahead. closes maps event
+// ticker to close time.
+func (f *Forecaster) Observe(now time.Time, rec *ticks.TickRecord, closes map[string]time.Time) []ticks.ForecastRecord {
+	if rec.BRTI > 0 {
+		f.samples = append(f.samples, ticks.PriceSample{Time: now, Price: rec.BRTI})
+	}
+	cutoff := now.Add(-ForecastVolWindow)
+	n := 0
+	for n < len(f.samples) && !f.samples[n].Time.After(cutoff) {
+		n++
+	}
+	f.samples = f.samples[n:]
+
+	if rec.BRTI <= 0 || now.Sub(f.last) < f.every || len(f.samples) < 2 ||
+		f.samples[len(f.samples)-1].Time.Sub(f.samples[0].Time) < time.Minute {
+		return nil
+	}
+	vol := ticks.RealizedVol(f.samples)
+	if vol <= 0 {
+		return nil
+	}
+	f.last = now
+
+	var out []ticks.ForecastRecord
+	for _, ev := range rec.Events {
+		close, ok := closes[ev.Event]
+		if !ok || !close.After(now) || len(ev.Markets) == 0 {
+			continue
+		}
+		start := time.Now()
+		out = append(out, ticks.ForecastRecord{
+			Type:     "forecast",
+			Ts:       rec.Ts,
+			Event:    ev.Event,
+			Close:    close.UTC().Format(time.RFC3339),
+			SecsLeft: close.Sub(now).Seconds(),
+			BRTI:     rec.BRTI,
+			Vol:      vol,
+			Drift:    f.model.Drift,
+			Method:   f.model.Method(),
+			Paths:    f.model.Paths,
+			Markets:  f.model.Forecast(now, close, rec.BRTI, vol, f.samples, ev.Markets),
+		})
+		slog.Debug("forecast", "event", ev.Event, "vol", vol, "took", time.Since(start))
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// GapRestart is the reason of the gap record written at startup.
+const GapRestart = "restart"
+
+// maxTailLine caps how far back lastRecord reads for the final record,
+// matching the journal's longest line.
+const maxTailLine = 16 << 20
+
+// recordStartGap writes a gap record covering the time since the last
+// record in the file the writer appends to, if there is one. Records of
+// an earlier period (yesterday, or the last hour with hourly rotation)
+// don't count: the new file starts the period afresh.
+func (c *Collector) recordStartGap() {
+	last, err := c.writer.lastRecord()
+	if err != nil {
+		slog.Warn("gap: reading last record failed", "err", err)
+		return
+	}
+	if last == nil {
+		return
+	}
+	from, err := time.Parse(time.RFC3339Nano, last.Ts)
+	now := c.clock.Now()
+	if err != nil || from.After(now) {
+		return
+	}
+	rec := ticks.GapRecord{
+		Type:    "gap",
+		Ts:      now.UTC().Format(time.RFC3339Nano),
+		From:    last.Ts,
+		Seconds: now.Sub(from).Round(time.Millisecond).Seconds(),
+		Reason:  GapRestart,
+		Clean:   last.Type == "shutdown",
+	}
+	if err := c.writer.Write(rec); err != nil {
+		slog.Error("gap: write failed", "err", err)
+		return
+	}
+	slog.Info("gap since last run", "from", last.Ts, "seconds", rec.Seconds, "clean", rec.Clean)
+}
+
+// tailRecord is the part of any record lastRecord needs.
+type tailRecord struct {
+	Type string `json:"type"`
+	Ts   string `json:"ts"`
+}
+
+// lastRecord returns the last complete record of the current period's
+// file, or nil if there is no such file or it holds no records. A torn
+// final line, left by a crash mid-write, is skipped.
+func (w *Writer) lastRecord() (*tailRecord, error) {
+	f, err := os.Open(w.currentPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	line, err := lastLine(f, st.Size())
+	if err != nil || line == nil {
+		return nil, err
+	}
+	var rec tailRecord
+	if err := json.Unmarshal(line, &rec); err != nil || rec.Ts == "" {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// lastLine returns the last newline-terminated line of the size bytes of
+// f, reading back from the end in growing windows.
+func lastLine(f *os.File, size int64) ([]byte, error) {
+	for n := int64(64 << 10); ; n *= 2 {
+		n = min(n, size, maxTailLine)
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, size-n); err != nil {
+			return nil, err
+		}
+		end := bytes.LastIndexByte(buf, '\n')
+		if end < 0 {
+			if n == size || n == maxTailLine {
+				return nil, nil
+			}
+			continue
+		}
+		start := bytes.LastIndexByte(buf[:end], '\n')
+		if start >= 0 || n == size {
+			return buf[start+1 : end], nil
+		}
+		if n == maxTailLine {
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// DefaultHookTimeout bounds one run of an ExecHook command.
+const DefaultHookTimeout = 10 * time.Minute
+
+// RotateHook is called with the path of each archive a Writer finishes: a
+// rotated file once it is compressed (and encrypted, if enabled) with its
+// index and manifest written. Hooks run one after another on the file's
+// compression goroutine, so WaitCompressions waits for them too. Files
+// left over from a crash are compressed, and hooked, on the next start,
+// so a hook can see a path more than once and should be idempotent.
+type RotateHook func(path string)
+
+// AddRotateHook registers h to run after every later compression.
+func (w *Writer) AddRotateHook(h RotateHook) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks = append(w.hooks, h)
+}
+
+// ExecHook returns a hook running command with sh -c, the archive path
+// passed as $1 and in ARCHIVE_PATH, e.g. `aws s3 cp "$1" s3://bucket/`.
+// A run is killed after timeout; failures and output are logged.
+func ExecHook(command string, timeout time.Duration) RotateHook {
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	return func(path string) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		start := time.Now()
+		cmd := exec.CommandContext(ctx, "sh", "-c", command, "sh", path)
+		cmd.Env = append(os.Environ(), "ARCHIVE_PATH="+path)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			slog.Error("rotate hook failed", "err", err, "path", path, "output", string(out))
+			return
+		}
+		slog.Info("rotate hook done", "path", path, "took", time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// maxInfluxPending caps the line-protocol bytes held while InfluxDB is
+// unreachable; beyond it new points are dropped.
+const maxInfluxPending = 4 << 20
+
+// InfluxSink pushes tick metrics to InfluxDB in line protocol so live
+// collection can be charted in Grafana. Each tick becomes
+//
+//	btc,source=brti price=70241.33 <ns>
+//	btc,source=coinbase price=70241.15 <ns>      (one per exchange)
+//	kalshi_market,event=…,ticker=… yes_bid=48i,yes_ask=51i,… <ns>
+//
+// Market points are optional since every market is its own series and
+// tickers churn every 15 minutes. Points are batched and POSTed by Run.
+type InfluxSink struct {
+	url     string // full write URL, e.g. http://host:8086/api/v2/write?org=o&bucket=b
+	token   string
+	markets bool
+	http    *http.Client
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+	dropped int64
+}
+
+// NewInfluxSink writes to url (an InfluxDB v1 /write or v2 /api/v2/write
+// endpoint, including its db/bucket query parameters). token is sent as
+// "Authorization: Token <token>" when set. markets adds a point per market.
+func NewInfluxSink(url, token string, markets bool) *InfluxSink {
+	return &InfluxSink{url: url, token: token, markets: markets,
+		http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Observe queues the points for one tick.
+func (s *InfluxSink) Observe(now time.Time, rec *ticks.TickRecord) {
+	var b []byte
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	point := func(measurement, tags, fields string) {
+		b = append(b, measurement...)
+		b = append(b, tags...)
+		b = append(b, ' ')
+		b = append(b, fields...)
+		b = append(b, ' ')
+		b = append(b, ts...)
+		b = append(b, '\n')
+	}
+	for _, p := range []struct {
+		source string
+		price  float64
+	}{{"brti", rec.BRTI}, {"coinbase", rec.Coinbase}, {"kraken", rec.Kraken}, {"bitstamp", rec.Bitstamp}} {
+		if p.price > 0 {
+			point("btc", ",source="+p.source, "price="+ftoa(p.price))
+		}
+	}
+	if s.markets {
+		for _, ev := range rec.Events {
+			for _, m := range ev.Markets {
+				fields := fmt.Sprintf("yes_bid=%di,yes_ask=%di,last_price=%di,volume=%di,open_interest=%di,secs_left=%di,strike=%s",
+					m.YesBid, m.YesAsk, m.LastPrice, m.Volume, m.OpenInt, m.SecsLeft, ftoa(m.Strike))
+				point("kalshi_market", ",event="+escapeTag(ev.Event)+",ticker="+escapeTag(m.Ticker), fields)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending.Len()+len(b) > maxInfluxPending {
+		s.dropped++
+		return
+	}
+	s.pending.Write(b)
+}
+
+// ObserveLatency queues one point per feed with its latency distribution:
+//
+//	feed_latency,feed=coinbase count=240i,mean_ms=41.2,p50_ms=38.9,… <ns>
+func (s *InfluxSink) ObserveLatency(now time.Time, feeds map[string]ticks.LatencyStats) {
+	var b []byte
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	for name, l := range feeds {
+		b = fmt.Appendf(b, "feed_latency,feed=%s count=%di,mean_ms=%s,p50_ms=%s,p90_ms=%s,p99_ms=%s,max_ms=%s %s\n",
+			escapeTag(name), l.Count, ftoa(l.MeanMs), ftoa(l.P50Ms), ftoa(l.P90Ms), ftoa(l.P99Ms), ftoa(l.MaxMs), ts)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending.Len()+len(b) > maxInfluxPending {
+		s.dropped++
+		return
+	}
+	s.pending.Write(b)
+}
+
+// escapeTag escapes the characters line protocol reserves in tag values.
+func escapeTag(v string) string {
+	if !strings.ContainsAny(v, ", =") {
+		return v
+	}
+	return strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`).Replace(v)
+}
+
+// Run POSTs queued points every interval until ctx is cancelled, then
+// makes a final attempt.
+func (s *InfluxSink) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.flush(flushCtx)
+			cancel()
+			return
+		case <-t.C:
+			s.flush(ctx)
+		}
+	}
+}
+
+func (s *InfluxSink) flush(ctx context.Context) {
+	s.mu.Lock()
+	body := bytes.Clone(s.pending.Bytes())
+	dropped := s.dropped
+	s.mu.Unlock()
+	if len(body) == 0 {
+		return
+	}
+	if retry, err := s.post(ctx, body); err != nil {
+		if retry {
+			slog.Warn("influx: write failed, will retry", "err", err, "pending_bytes", len(body), "dropped_ticks", dropped)
+			return
+		}
+		// InfluxDB rejected the batch itself; resending it won't help.
+		slog.Warn("influx: batch rejected, discarding", "err", err, "bytes", len(body))
+	}
+	s.mu.Lock()
+	s.pending.Next(len(body))
+	s.dropped = 0
+	s.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("influx: dropped ticks while unreachable", "count", dropped)
+	}
+}
+
+// post sends one batch. retry reports whether a failure is worth retrying.
+func (s *InfluxSink) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("influx request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("influx returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
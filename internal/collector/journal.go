@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// The journal is a small write-ahead log for records that are sitting in the
+// Writer's memory buffer. Each record is appended to the journal before it is
+// buffered, and the journal is truncated once the buffer has been flushed to
+// the data file. If the process dies with unflushed data, the next Writer for
+// the same dir/prefix replays the journal into the files the records were
+// destined for before writing anything new.
+//
+// Entries carry the target path and byte offset so replay is idempotent:
+// records already present in the target are skipped.
+
+type journalEntry struct {
+	Path string          `json:"path"`
+	Off  int64           `json:"off"`
+	Rec  json.RawMessage `json:"rec"`
+}
+
+func journalPath(dir, prefix string) string {
+	return filepath.Join(dir, "."+prefix+".wal")
+}
+
+// openJournal replays any leftover journal and returns it opened for appending.
+func openJournal(dir, prefix string) (*os.File, error) {
+	path := journalPath(dir, prefix)
+	if err := replayJournal(path); err != nil {
+		return nil, fmt.Errorf("replaying journal: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	return f, nil
+}
+
+func replayJournal(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	recovered, skipped := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Path == "" {
+			// Torn final line from a crash mid-append; nothing after it is valid.
+			slog.Warn("journal: ignoring unreadable entry", "path", path)
+			break
+		}
+		ok, err := replayEntry(e)
+		if err != nil {
+			return err
+		}
+		if ok {
+			recovered++
+		} else {
+			skipped++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if recovered > 0 || skipped > 0 {
+		slog.Info("journal replayed", "recovered", recovered, "already_written", skipped)
+	}
+	return nil
+}
+
+// replayEntry writes e.Rec at e.Off in e.Path unless it is already there.
+func replayEntry(e journalEntry) (bool, error) {
+	end := e.Off + int64(len(e.Rec)) + 1
+
+	st, err := os.Stat(e.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Compressed means the file was closed (and flushed) before the crash.
+		for _, ext := range []string{".gz", ".zst", ".gz" + ticks.EncExt, ".zst" + ticks.EncExt} {
+			if _, err := os.Stat(e.Path + ext); err == nil {
+				return false, nil
+			}
+		}
+	case err != nil:
+		return false, err
+	case st.Size() >= end:
+		return false, nil
+	case st.Size() > e.Off:
+		// Partial record at the tail; cut it back before rewriting.
+		if err := os.Truncate(e.Path, e.Off); err != nil {
+			return false, err
+		}
+	}
+
+	out, err := os.OpenFile(e.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	data := append(append([]byte{}, e.Rec...), '\n')
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return false, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return false, err
+	}
+	return true, out.Close()
+}
+
+// journalLocked appends one record to the journal. Caller holds w.mu.
+func (w *Writer) journalLocked(data []byte) error {
+	if w.journal == nil || w.buf == nil {
+		return nil // unbuffered writes go straight to the file
+	}
+	entry, err := json.Marshal(journalEntry{
+		Path: w.file.Name(),
+		Off:  w.size,
+		Rec:  data[:len(data)-1], // strip newline
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry: %w", err)
+	}
+	entry = append(entry, '\n')
+	if _, err := w.journal.Write(entry); err != nil {
+		return fmt.Errorf("journal write: %w", err)
+	}
+	w.journalDirty = true
+	return nil
+}
+
+// journalRecordsLocked returns the newline-terminated records the journal
+// holds: those written since the buffer was last flushed.
+func (w *Writer) journalRecordsLocked() [][]byte {
+	if w.journal == nil || !w.journalDirty {
+		return nil
+	}
+	data, err := os.ReadFile(w.journal.Name())
+	if err != nil {
+		slog.Warn("journal: read failed", "err", err)
+		return nil
+	}
+	var recs [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var e journalEntry
+		if json.Unmarshal(line, &e) != nil || e.Path == "" {
+			continue
+		}
+		recs = append(recs, append([]byte(e.Rec), '\n'))
+	}
+	return recs
+}
+
+// resetJournalLocked empties the journal after buffered data reached the file.
+func (w *Writer) resetJournalLocked() error {
+	if w.journal == nil || !w.journalDirty {
+		return nil
+	}
+	if err := w.journal.Truncate(0); err != nil {
+		return fmt.Errorf("journal truncate: %w", err)
+	}
+	w.journalDirty = false
+	return nil
+}
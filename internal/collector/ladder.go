@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultLadderEvery is how often a full ladder is recorded while a strike
+// band is in effect.
+const DefaultLadderEvery = time.Minute
+
+// SetLadderSnapshots writes a ladder record of every discovered market at
+// most this often (0 disables it). It only applies while the subscription
+// policy has a strike band; otherwise the ticks hold the full ladder
+// already. Call before Run.
+func (c *Collector) SetLadderSnapshots(every time.Duration) {
+	c.ladderEvery = every
+}
+
+// recordLadder writes markets as a ladder record if one is due. Discovery
+// goroutine only.
+func (c *Collector) recordLadder(markets []kalshi.Market) {
+	if c.ladderEvery <= 0 || !c.subPolicy.banded() || len(markets) == 0 {
+		return
+	}
+	now := c.clock.Now()
+	if now.Sub(c.lastLadder) < c.ladderEvery {
+		return
+	}
+	c.lastLadder = now
+
+	snaps := make([]ticks.MarketSnap, 0, len(markets))
+	expiries := make(map[string]time.Time)
+	for _, m := range markets {
+		snap, expiry := restSnap(m, now, true)
+		if !expiry.IsZero() {
+			expiries[ticks.EventOf(m.Ticker)] = expiry
+		}
+		snaps = append(snaps, snap)
+	}
+	rec := ticks.LadderRecord{
+		Type:   "ladder",
+		Ts:     now.UTC().Format(time.RFC3339Nano),
+		BRTI:   c.brti.Price(),
+		Events: ticks.GroupEvents(snaps, now, expiries),
+	}
+	if err := c.writer.Write(rec); err != nil {
+		slog.Warn("ladder: write failed", "err", err)
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/feed"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultFeedLatencyEvery is how often feed latency is reported.
+const DefaultFeedLatencyEvery = time.Minute
+
+// feedLatency holds the latest per-feed latency report.
+type feedLatency struct {
+	every time.Duration
+	mu    sync.Mutex
+	last  map[string]ticks.LatencyStats
+}
+
+// EnableFeedLatency writes a feed_latency record every interval with the
+// distribution of each exchange feed's latency over it, and pushes it to
+// InfluxDB when enabled. Call before Run.
+func (c *Collector) EnableFeedLatency(every time.Duration) {
+	c.latency = &feedLatency{every: every}
+}
+
+// FeedLatency returns the latest latency report per feed, or nil before
+// the first.
+func (c *Collector) FeedLatency() map[string]ticks.LatencyStats {
+	if c.latency == nil {
+		return nil
+	}
+	c.latency.mu.Lock()
+	defer c.latency.mu.Unlock()
+	return maps.Clone(c.latency.last)
+}
+
+func (c *Collector) latencyLoop(ctx context.Context) {
+	ticker := c.clock.NewTicker(c.latency.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.reportLatency()
+		}
+	}
+}
+
+func (c *Collector) reportLatency() {
+	stats := make(map[string]ticks.LatencyStats)
+	for _, f := range c.currentFeeds() {
+		if l, ok := f.(feed.LatencyReporter); ok {
+			if s := l.TakeLatency(); s.Count > 0 {
+				stats[f.Name()] = s
+				slog.Debug("feed latency", "feed", f.Name(), "count", s.Count,
+					"p50_ms", s.P50Ms, "p99_ms", s.P99Ms, "max_ms", s.MaxMs)
+			}
+		}
+	}
+	c.latency.mu.Lock()
+	c.latency.last = stats
+	c.latency.mu.Unlock()
+	if len(stats) == 0 {
+		return
+	}
+
+	now := c.clock.Now()
+	rec := ticks.FeedLatencyRecord{
+		Type:  "feed_latency",
+		Ts:    now.UTC().Format(time.RFC3339Nano),
+		Feeds: stats,
+	}
+	if err := c.writer.Write(rec); err != nil {
+		slog.Warn("feed latency: write failed", "err", err)
+	}
+	if c.influx != nil {
+		c.influx.ObserveLatency(now, stats)
+	}
+}
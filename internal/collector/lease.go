@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Lease elects one writer among collectors sharing an output directory
+// (NFS, SMB, or object storage mounted as a filesystem). The holder
+// rewrites <dir>/<prefix>.lease every TTL/3; a standby polls it and takes
+// over once it has gone unrenewed past its expiry. Takeover is a write to
+// a temporary file and a rename, then a re-read after a short settle to
+// catch two standbys racing. Expiry is compared across hosts, so their
+// clocks must agree to well within the TTL.
+type Lease struct {
+	path string
+	id   string // host/pid/tag
+	tag  string // random, names this holder's temporary file
+	ttl  time.Duration
+}
+
+// leaseFile is the content of the lease file.
+type leaseFile struct {
+	Holder  string    `json:"holder"`
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Since   time.Time `json:"since"`
+	Expires time.Time `json:"expires"`
+}
+
+// ErrLeaseLost is returned by Hold when another collector took the lease.
+var ErrLeaseLost = errors.New("lease lost")
+
+// NewLease returns a lease on dir/prefix.lease with a fresh holder ID.
+func NewLease(dir, prefix string, ttl time.Duration) *Lease {
+	host, _ := os.Hostname()
+	var b [4]byte
+	rand.Read(b[:])
+	tag := hex.EncodeToString(b[:])
+	return &Lease{
+		path: filepath.Join(dir, prefix+".lease"),
+		id:   fmt.Sprintf("%s/%d/%s", host, os.Getpid(), tag),
+		tag:  tag,
+		ttl:  ttl,
+	}
+}
+
+// Acquire blocks until this collector holds the lease or ctx is done.
+func (l *Lease) Acquire(ctx context.Context) error {
+	var since time.Time
+	waiting := false
+	for {
+		cur, err := l.read()
+		switch {
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			slog.Warn("lease: read failed", "path", l.path, "err", err)
+		case cur == nil || cur.Holder == l.id || time.Now().After(cur.Expires):
+			if cur != nil && cur.Holder != l.id {
+				slog.Warn("lease: taking over expired lease", "from", cur.Holder, "expired", cur.Expires)
+			}
+			if since.IsZero() {
+				since = time.Now()
+			}
+			if err := l.write(since); err != nil {
+				slog.Warn("lease: write failed", "path", l.path, "err", err)
+				break
+			}
+			if ok, err := l.confirm(ctx); err != nil {
+				return err
+			} else if ok {
+				slog.Info("lease: acquired, writing as leader", "path", l.path, "id", l.id)
+				return nil
+			}
+			since = time.Time{}
+		default:
+			if !waiting {
+				slog.Info("lease: held elsewhere, standing by", "holder", cur.Holder, "expires", cur.Expires)
+				waiting = true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.ttl / 3):
+		}
+	}
+}
+
+// confirm waits for a racing standby's rename to land, then checks the
+// lease is still ours.
+func (l *Lease) confirm(ctx context.Context) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(min(time.Second, l.ttl/10)):
+	}
+	cur, err := l.read()
+	if err != nil {
+		return false, nil
+	}
+	return cur.Holder == l.id, nil
+}
+
+// Hold renews the lease until ctx is done. It returns ErrLeaseLost if
+// another collector took the lease, or if renewals fail until it expires;
+// the caller must stop writing.
+func (l *Lease) Hold(ctx context.Context) error {
+	since := time.Now()
+	expires := since.Add(l.ttl)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		cur, err := l.read()
+		if err == nil && cur.Holder != l.id {
+			return fmt.Errorf("%w to %s", ErrLeaseLost, cur.Holder)
+		}
+		if err == nil {
+			since = cur.Since
+			err = l.write(since)
+		}
+		if err != nil {
+			slog.Warn("lease: renewal failed", "path", l.path, "err", err)
+			if time.Now().After(expires) {
+				return fmt.Errorf("%w: not renewed before expiry: %v", ErrLeaseLost, err)
+			}
+			continue
+		}
+		expires = time.Now().Add(l.ttl)
+	}
+}
+
+// Release deletes the lease file if it is still ours, so a standby takes
+// over without waiting for it to expire. Call it after the last write.
+func (l *Lease) Release() {
+	if cur, err := l.read(); err == nil && cur.Holder == l.id {
+		if err := os.Remove(l.path); err != nil {
+			slog.Warn("lease: release failed", "path", l.path, "err", err)
+		}
+	}
+}
+
+func (l *Lease) read() (*leaseFile, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	var lf leaseFile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", l.path, err)
+	}
+	return &lf, nil
+}
+
+// write replaces the lease file with one held by l, expiring a TTL from now.
+func (l *Lease) write(since time.Time) error {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(leaseFile{
+		Holder:  l.id,
+		Host:    host,
+		PID:     os.Getpid(),
+		Since:   since.UTC(),
+		Expires: time.Now().Add(l.ttl).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	tmp := l.path + "." + l.tag + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/alert"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// DefaultSeriesEvery is how often series discovery lists Kalshi's series.
+const DefaultSeriesEvery = 10 * time.Minute
+
+// SeriesDiscovery finds new 15-minute products as Kalshi launches them.
+// Every so often it lists the exchange's series, and any whose ticker
+// matches one of Patterns and whose open markets last WindowLength is
+// collected alongside the configured series from then on.
+//
+// The strike band and forecasts are measured against BRTI, so they only
+// apply to the configured series; discovered series are recorded in full.
+type SeriesDiscovery struct {
+	Patterns []string      // path.Match globs on series tickers, e.g. "KXETH*"
+	Every    time.Duration // default DefaultSeriesEvery
+}
+
+// ParseSeriesPatterns splits a comma-separated list of series globs, e.g.
+// "KXBTC*,KXETH*".
+func ParseSeriesPatterns(s string) ([]string, error) {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("series pattern %q: %w", p, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// seriesFinder is series discovery's state; discovery goroutine only.
+type seriesFinder struct {
+	SeriesDiscovery
+	rejected map[string]bool // matching series whose markets aren't 15-minute ones
+	listed   bool            // the first listing has been done
+}
+
+func (f *seriesFinder) matches(ticker string) bool {
+	for _, p := range f.Patterns {
+		if ok, _ := path.Match(p, ticker); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// EnableSeriesDiscovery turns on series discovery (see SeriesDiscovery),
+// alerting notifier about each series launched while running. notifier
+// may be nil. Call before Run.
+func (c *Collector) EnableSeriesDiscovery(d SeriesDiscovery, notifier alert.Notifier) {
+	if d.Every <= 0 {
+		d.Every = DefaultSeriesEvery
+	}
+	c.seriesFinder = &seriesFinder{SeriesDiscovery: d, rejected: make(map[string]bool)}
+	c.cfgMu.Lock()
+	c.notifier = notifier
+	c.cfgMu.Unlock()
+}
+
+// allSeries returns the configured series followed by the discovered ones
+// and those named by the allowlist.
+func (c *Collector) allSeries() []string {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	out := []string{c.series}
+	for _, s := range c.discovered {
+		if s != c.series {
+			out = append(out, s)
+		}
+	}
+	if c.allowlist != nil {
+		for _, s := range c.allowlist.Series() {
+			if !slices.Contains(out, s) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// seriesLoop runs series discovery until ctx is done.
+func (c *Collector) seriesLoop(ctx context.Context) {
+	c.findSeries(ctx)
+
+	ticker := c.clock.NewTicker(c.seriesFinder.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.findSeries(ctx)
+		}
+	}
+}
+
+// findSeries lists Kalshi's series and starts collecting matching
+// 15-minute ones not collected yet. A matching series with no open
+// markets is looked at again on the next listing, since Kalshi often
+// lists a series before its first market opens.
+func (c *Collector) findSeries(ctx context.Context) {
+	f := c.seriesFinder
+	list, err := c.client.GetSeriesList(ctx, "")
+	if err != nil {
+		slog.Warn("series discovery: listing failed", "err", err)
+		return
+	}
+	collected := c.allSeries()
+	for _, s := range list {
+		if !f.matches(s.Ticker) || f.rejected[s.Ticker] || slices.Contains(collected, s.Ticker) {
+			continue
+		}
+		markets, err := c.client.GetMarkets(ctx, s.Ticker, "open")
+		if err != nil {
+			slog.Debug("series discovery: market fetch failed", "series", s.Ticker, "err", err)
+			continue
+		}
+		fifteen, known := windowed(markets)
+		if !known {
+			continue
+		}
+		if !fifteen {
+			slog.Debug("series discovery: not a 15-minute series", "series", s.Ticker)
+			f.rejected[s.Ticker] = true
+			continue
+		}
+		c.addSeries(ctx, s, f.listed)
+	}
+	f.listed = true
+}
+
+// windowed reports whether markets trade for WindowLength each; known is
+// false when none has parseable open and close times.
+func windowed(markets []kalshi.Market) (fifteen, known bool) {
+	for _, m := range markets {
+		open, err1 := time.Parse(time.RFC3339, m.OpenTime)
+		close, err2 := time.Parse(time.RFC3339, m.CloseTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		return close.Sub(open) == WindowLength, true
+	}
+	return false, false
+}
+
+// addSeries starts collecting s from the next market discovery on. Series
+// found by the first listing were launched before the collector started,
+// so only later ones raise an alert.
+func (c *Collector) addSeries(ctx context.Context, s kalshi.Series, launched bool) {
+	c.cfgMu.Lock()
+	c.discovered = append(c.discovered, s.Ticker)
+	notifier := c.notifier
+	c.cfgMu.Unlock()
+
+	now := c.clock.Now()
+	slog.Info("series discovery: collecting series", "series", s.Ticker, "title", s.Title, "new", launched)
+	rec := ticks.SeriesRecord{
+		Type:     "series",
+		Ts:       now.UTC().Format(time.RFC3339Nano),
+		Series:   s.Ticker,
+		Title:    s.Title,
+		Category: s.Category,
+	}
+	if err := c.writer.Write(rec); err != nil {
+		slog.Warn("series discovery: write failed", "err", err)
+	}
+
+	if notifier == nil || !launched {
+		return
+	}
+	a := alert.Alert{
+		Title:    "New Kalshi series: " + s.Ticker,
+		Message:  fmt.Sprintf("%s (%s) launched; collecting its markets", s.Title, s.Ticker),
+		Time:     now,
+		Severity: alert.Info,
+	}
+	go func() {
+		if err := notifier.Notify(ctx, a); err != nil {
+			slog.Warn("series discovery: alert failed", "err", err)
+		}
+	}()
+}
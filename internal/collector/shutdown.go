@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Shutdown drain defaults: how long the drain may take in all, and how
+// far back markets count as recently expired.
+const (
+	DefaultDrainTimeout   = 30 * time.Second
+	DefaultSettleLookback = 15 * time.Minute
+)
+
+// Drain finishes a collector whose Run has returned. It fetches the
+// markets that expired within lookback (0 skips this), writes a shutdown
+// record with them, closes the writer and waits for compressions in
+// flight. ctx bounds the whole drain; whatever it cuts short is picked up
+// on the next start by the journal replay and CompressStale.
+func (c *Collector) Drain(ctx context.Context, reason string, lookback time.Duration) error {
+	now := c.clock.Now()
+	var events []ticks.EventSnap
+	if lookback > 0 {
+		events = c.recentlyExpired(ctx, now, lookback)
+	}
+
+	c.lastWriteMu.Lock()
+	count := c.tickCount
+	c.lastWriteMu.Unlock()
+	rec := ticks.ShutdownRecord{
+		Type:   "shutdown",
+		Ts:     now.UTC().Format(time.RFC3339Nano),
+		Reason: reason,
+		Ticks:  count,
+		Events: events,
+	}
+	if err := c.writer.Write(rec); err != nil {
+		return fmt.Errorf("writing shutdown record: %w", err)
+	}
+	if err := c.writer.Close(); err != nil {
+		return fmt.Errorf("closing writer: %w", err)
+	}
+	if err := c.writer.WaitCompressions(ctx); err != nil {
+		return fmt.Errorf("waiting for compression: %w", err)
+	}
+	slog.Info("drained", "ticks", count, "expired_markets", len(events), "took", c.clock.Now().Sub(now).Round(time.Millisecond))
+	return nil
+}
+
+// recentlyExpired returns the closed and settled markets of every
+// collected series that expired within lookback of now, grouped by event.
+func (c *Collector) recentlyExpired(ctx context.Context, now time.Time, lookback time.Duration) []ticks.EventSnap {
+	var snaps []ticks.MarketSnap
+	expiries := make(map[string]time.Time)
+	for _, series := range c.allSeries() {
+		for _, status := range []string{"closed", "settled"} {
+			markets, err := c.client.GetMarkets(ctx, series, status)
+			if err != nil {
+				slog.Warn("drain: market fetch failed", "series", series, "status", status, "err", err)
+				continue
+			}
+			for _, m := range markets {
+				if !expiredWithin(m, now, lookback) || !c.allowed(m.Ticker) {
+					continue
+				}
+				snap, expiry := restSnap(m, now, true)
+				expiries[ticks.EventOf(m.Ticker)] = expiry
+				snaps = append(snaps, snap)
+			}
+		}
+	}
+	if len(snaps) == 0 {
+		return nil
+	}
+	return ticks.GroupEvents(snaps, now, expiries)
+}
+
+func expiredWithin(m kalshi.Market, now time.Time, lookback time.Duration) bool {
+	expiry, err := m.ExpirationParsed()
+	return err == nil && !expiry.After(now) && now.Sub(expiry) <= lookback
+}
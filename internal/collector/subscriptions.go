@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"cmp"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// SubscriptionPolicy decides which discovered markets the Kalshi WS
+// subscribes to when there are more than it should carry. Windows are
+// ranked by time to expiry: the current one, then the next, and so on,
+// with closed windows (kept for their results) last. Far-future ladders
+// are dropped first.
+//
+// A strike band additionally leaves out markets whose strike is far from
+// the current BRTI. With both BandPct and BandUSD set the wider band
+// applies. Markets without a strike, and every market while BRTI is still
+// unknown, are kept.
+type SubscriptionPolicy struct {
+	MaxMarkets int     // 0 = no limit
+	MaxWindows int     // open windows to keep, nearest first; 0 = all
+	BandPct    float64 // keep strikes within this percentage of BRTI; 0 = no band
+	BandUSD    float64 // keep strikes within this many dollars of BRTI; 0 = no band
+
+	bandSeries string // when set, the band only applies to this series
+}
+
+// banded reports whether the policy has a strike band.
+func (p SubscriptionPolicy) banded() bool {
+	return p.BandPct > 0 || p.BandUSD > 0
+}
+
+// inBand reports whether strike lies within the band around spot. A
+// market without a strike (0) is always in it.
+func (p SubscriptionPolicy) inBand(strike, spot float64) bool {
+	if strike <= 0 {
+		return true
+	}
+	width := max(p.BandUSD, spot*p.BandPct/100)
+	return math.Abs(strike-spot) <= width
+}
+
+// SetSubscriptionPolicy limits which markets discovery subscribes to.
+// Call before Run.
+func (c *Collector) SetSubscriptionPolicy(p SubscriptionPolicy) {
+	c.subPolicy = p
+}
+
+// Select returns the markets to subscribe to, in priority order, given
+// the BRTI spot (0 if unknown). A window that only partly fits under
+// MaxMarkets keeps the strikes nearest the middle of its ladder, where the
+// price usually is.
+func (p SubscriptionPolicy) Select(markets []kalshi.Market, now time.Time, spot float64) []kalshi.Market {
+	if p.banded() && spot > 0 {
+		banded := make([]kalshi.Market, 0, len(markets))
+		for _, m := range markets {
+			if p.inBand(m.StrikePrice(), spot) || (p.bandSeries != "" && ticks.SeriesOf(m.Ticker) != p.bandSeries) {
+				banded = append(banded, m)
+			}
+		}
+		markets = banded
+	}
+	if p.MaxMarkets <= 0 && p.MaxWindows <= 0 {
+		return markets
+	}
+
+	type window struct {
+		expiry  time.Time
+		closed  bool
+		markets []kalshi.Market
+	}
+	byEvent := make(map[string]*window)
+	var windows []*window
+	for _, m := range markets {
+		event := m.EventTicker
+		if event == "" {
+			event = ticks.EventOf(m.Ticker)
+		}
+		w := byEvent[event]
+		if w == nil {
+			w = &window{}
+			byEvent[event] = w
+			windows = append(windows, w)
+		}
+		if expiry, err := m.ExpirationParsed(); err == nil && (w.expiry.IsZero() || expiry.Before(w.expiry)) {
+			w.expiry = expiry
+		}
+		w.markets = append(w.markets, m)
+	}
+	for _, w := range windows {
+		w.closed = !w.expiry.IsZero() && !w.expiry.After(now)
+	}
+
+	// Open windows soonest first, then closed ones most recent first.
+	slices.SortStableFunc(windows, func(a, b *window) int {
+		switch {
+		case a.closed != b.closed:
+			if a.closed {
+				return 1
+			}
+			return -1
+		case a.closed:
+			return b.expiry.Compare(a.expiry)
+		default:
+			return a.expiry.Compare(b.expiry)
+		}
+	})
+
+	var keep []kalshi.Market
+	open := 0
+	for _, w := range windows {
+		if !w.closed {
+			if p.MaxWindows > 0 && open >= p.MaxWindows {
+				continue
+			}
+			open++
+		}
+		room := len(w.markets)
+		if p.MaxMarkets > 0 {
+			room = min(room, p.MaxMarkets-len(keep))
+		}
+		if room <= 0 {
+			break
+		}
+		keep = append(keep, nearMiddle(w.markets, room)...)
+	}
+	return keep
+}
+
+// nearMiddle returns the n markets of a ladder whose strikes are closest to
+// the middle of it.
+func nearMiddle(ladder []kalshi.Market, n int) []kalshi.Market {
+	if n >= len(ladder) {
+		return ladder
+	}
+	sorted := slices.Clone(ladder)
+	slices.SortFunc(sorted, func(a, b kalshi.Market) int {
+		return cmp.Compare(a.StrikePrice(), b.StrikePrice())
+	})
+	mid := sorted[len(sorted)/2].StrikePrice()
+	slices.SortStableFunc(sorted, func(a, b kalshi.Market) int {
+		return cmp.Compare(math.Abs(a.StrikePrice()-mid), math.Abs(b.StrikePrice()-mid))
+	})
+	return sorted[:n]
+}
+
+// selectSubscriptions applies the subscription policy, logging when the
+// number of markets it leaves out changes.
+func (c *Collector) selectSubscriptions(markets []kalshi.Market) []kalshi.Market {
+	p := c.subPolicy
+	p.bandSeries = c.seriesTicker() // discovered series aren't priced off BRTI
+	keep := p.Select(markets, c.clock.Now(), c.brti.Price())
+	dropped := int64(len(markets) - len(keep))
+	if prev := c.droppedSubs.Swap(dropped); prev != dropped {
+		slog.Info("subscription policy", "markets", len(markets), "subscribed", len(keep), "dropped", dropped)
+	}
+	return keep
+}
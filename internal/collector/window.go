@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// WindowLength is the length of a Kalshi BTC market window.
+const WindowLength = 15 * time.Minute
+
+// WindowTracker keeps the open, high, low and running time-weighted
+// average of BRTI for the current window. Windows are aligned to UTC
+// multiples of their length and include their start: a sample taken at
+// exactly :15:00 opens the :15 window rather than closing the :00 one,
+// the same boundary Kalshi uses for the markets it settles.
+type WindowTracker struct {
+	length time.Duration
+
+	start     time.Time // zero until the first sample
+	open      float64
+	high, low float64
+	last      float64 // previous sample, held until the next one
+	lastAt    time.Time
+	weighted  float64 // Σ price × seconds held
+	span      float64 // seconds covered by weighted
+}
+
+// NewWindowTracker tracks windows of the given length (WindowLength for
+// the 15-minute series).
+func NewWindowTracker(length time.Duration) *WindowTracker {
+	return &WindowTracker{length: length}
+}
+
+// Observe records price at now and returns the current window's statistics.
+// Non-positive prices (no live feed) are ignored; the result is nil until
+// the window has a sample.
+func (w *WindowTracker) Observe(now time.Time, price float64) *ticks.WindowStats {
+	if start := now.UTC().Truncate(w.length); !start.Equal(w.start) {
+		w.start = start
+		w.open, w.high, w.low = 0, 0, 0
+		w.weighted, w.span = 0, 0
+		w.lastAt = time.Time{}
+	}
+	if price > 0 {
+		if w.lastAt.IsZero() {
+			w.open, w.high, w.low = price, price, price
+		} else {
+			dt := now.Sub(w.lastAt).Seconds()
+			w.weighted += w.last * dt
+			w.span += dt
+			w.high = max(w.high, price)
+			w.low = min(w.low, price)
+		}
+		w.last, w.lastAt = price, now
+	}
+	if w.lastAt.IsZero() {
+		return nil
+	}
+
+	twap := w.last
+	if w.span > 0 {
+		twap = w.weighted / w.span
+	}
+	return &ticks.WindowStats{
+		Start: w.start.Format(time.RFC3339),
+		Open:  w.open,
+		High:  w.high,
+		Low:   w.low,
+		TWAP:  twap,
+	}
+}
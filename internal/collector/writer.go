@@ -1,182 +1,796 @@
 package collector
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/gw/btc15m-data/internal/clock"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Rotation selects the time period covered by each output file.
+type Rotation string
+
+const (
+	RotateDaily  Rotation = "daily"  // prefix-2006-01-02.jsonl
+	RotateHourly Rotation = "hourly" // prefix-2006-01-02T15.jsonl
+)
+
+// Layout selects where files go under the output directory.
+type Layout string
+
+const (
+	LayoutFlat    Layout = "flat"    // dir/prefix-<period>.jsonl
+	LayoutMonthly Layout = "monthly" // dir/2006/01/prefix-<period>.jsonl
 )
 
-// Writer is a daily-rotating JSONL file writer.
+// Compression selects the codec applied to rotated files.
+type Compression string
+
+const (
+	CompressGzip Compression = "gzip" // .jsonl.gz
+	CompressZstd Compression = "zstd" // .jsonl.zst
+)
+
+// WriterOptions configures rotation, compression, buffering and durability.
+// The zero value is daily rotation with gzip and unbuffered writes, matching
+// NewWriter.
+type WriterOptions struct {
+	Rotation    Rotation
+	MaxBytes    int64 // also rotate once a file reaches this size (0 = no limit)
+	Compression Compression
+	Layout      Layout
+
+	// Env, when set, is appended to the prefix so files from different
+	// Kalshi environments never mix: prefix-<env>-<period>.jsonl.
+	Env string
+
+	// BufferSize enables an in-memory write buffer of this many bytes.
+	// Buffered data reaches the file every FlushInterval, when the buffer
+	// fills, on rotation, and on Close.
+	BufferSize    int
+	FlushInterval time.Duration // default 1s when buffered
+
+	// SyncInterval and SyncEvery fsync the file after that much time or that
+	// many records, whichever comes first (0 disables each trigger).
+	SyncInterval time.Duration
+	SyncEvery    int
+
+	// Journal records buffered data in a write-ahead log (see journal.go)
+	// so a crash never loses records that were not yet flushed.
+	Journal bool
+
+	// FastJSON encodes ticks with ticks.TickRecord.AppendJSON instead of
+	// encoding/json. The bytes are identical; it only saves CPU and garbage.
+	FastJSON bool
+
+	// EncryptKey, when set, encrypts rotated files after compression
+	// (.jsonl.gz.enc); see ticks.NewEncryptWriter. The file being written
+	// and the journal stay plaintext until rotation.
+	EncryptKey []byte
+
+	// Clock decides the period each write falls in and drives the flush
+	// and fsync loop; nil is the wall clock. See clock.Sim.
+	Clock clock.Clock
+}
+
+// Writer is a rotating JSONL file writer. Files rotate per UTC day by
+// default; size-based rotation adds numbered parts (prefix-<period>.N.jsonl).
 type Writer struct {
-	dir      string
-	prefix   string
-	mu       sync.Mutex
-	file     *os.File
-	fileDate string // "2006-01-02" of current file
+	dir     string
+	prefix  string
+	opts    WriterOptions
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer // nil when unbuffered
+	period  string        // period key of current file
+	part    int           // size-rotation part within the period
+	size    int64         // bytes in current file
+	rotated bool          // Rotate closed the file; the next one is part+1
+	encoder EventEncoder
+
+	// Reused encoding buffers; the bytes are copied out by every Write.
+	encBuf  []byte
+	jsonBuf bytes.Buffer
+	jsonEnc *json.Encoder
+
+	journal      *os.File // nil unless opts.Journal
+	journalDirty bool
+
+	unsynced  int       // records written since last fsync
+	lastSync  time.Time // time of last fsync
+	bgErr     error     // last background flush/sync error, reported by next Write
+	stop      chan struct{}
+	stopOnce  sync.Once
+	stoppedWg sync.WaitGroup
+
+	compressing sync.WaitGroup // background compressions; see WaitCompressions
+
+	dead  *DeadLetter // nil unless SetDeadLetter
+	hooks []RotateHook
+}
+
+// EventEncoder transforms events just before they are marshaled.
+// newFile is true for the first event written after a file is opened,
+// so stateful encodings can restart cleanly at rotation boundaries.
+type EventEncoder interface {
+	Encode(event any, newFile bool) any
 }
 
 func NewWriter(dir, prefix string) (*Writer, error) {
+	return NewWriterOptions(dir, prefix, WriterOptions{})
+}
+
+func NewWriterOptions(dir, prefix string, opts WriterOptions) (*Writer, error) {
+	if opts.Rotation == "" {
+		opts.Rotation = RotateDaily
+	}
+	if opts.Compression == "" {
+		opts.Compression = CompressGzip
+	}
+	if opts.Layout == "" {
+		opts.Layout = LayoutFlat
+	}
+	switch opts.Rotation {
+	case RotateDaily, RotateHourly:
+	default:
+		return nil, fmt.Errorf("unknown rotation %q", opts.Rotation)
+	}
+	switch opts.Layout {
+	case LayoutFlat, LayoutMonthly:
+	default:
+		return nil, fmt.Errorf("unknown layout %q", opts.Layout)
+	}
+	if opts.Env != "" {
+		prefix += "-" + opts.Env
+	}
+	if _, err := compressedExt(opts.Compression); err != nil {
+		return nil, err
+	}
+	if opts.EncryptKey != nil {
+		if _, err := ticks.NewEncryptWriter(io.Discard, opts.EncryptKey); err != nil {
+			return nil, fmt.Errorf("encrypt key: %w", err)
+		}
+	}
+
+	if opts.BufferSize > 0 && opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("creating output dir: %w", err)
 	}
-	return &Writer{dir: dir, prefix: prefix}, nil
+	opts.Clock = clock.Or(opts.Clock)
+	w := &Writer{dir: dir, prefix: prefix, opts: opts, lastSync: opts.Clock.Now()}
+
+	if opts.Journal {
+		j, err := openJournal(dir, prefix)
+		if err != nil {
+			return nil, err
+		}
+		w.journal = j
+	}
+
+	if interval := w.maintenanceInterval(); interval > 0 {
+		w.stop = make(chan struct{})
+		w.stoppedWg.Add(1)
+		go w.maintain(interval)
+	}
+	return w, nil
 }
 
-func (w *Writer) Write(event any) error {
-	data, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("marshaling event: %w", err)
+// maintenanceInterval is how often the background loop flushes/syncs.
+func (w *Writer) maintenanceInterval() time.Duration {
+	var d time.Duration
+	if w.opts.BufferSize > 0 {
+		d = w.opts.FlushInterval
+	}
+	if s := w.opts.SyncInterval; s > 0 && (d == 0 || s < d) {
+		d = s
 	}
-	data = append(data, '\n')
+	return d
+}
 
+// maintain periodically flushes the buffer and fsyncs when due.
+func (w *Writer) maintain(interval time.Duration) {
+	defer w.stoppedWg.Done()
+	ticker := w.opts.Clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C():
+			w.mu.Lock()
+			if err := w.flushLocked(); err != nil {
+				w.bgErr = err
+				slog.Error("writer flush failed", "err", err, "prefix", w.prefix)
+			} else if w.opts.SyncInterval > 0 && w.opts.Clock.Now().Sub(w.lastSync) >= w.opts.SyncInterval {
+				if err := w.syncLocked(); err != nil {
+					w.bgErr = err
+					slog.Error("writer fsync failed", "err", err, "prefix", w.prefix)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Flush writes any buffered data through to the file.
+func (w *Writer) Flush() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.flushLocked()
+}
 
-	if err := w.ensureFile(); err != nil {
+// Sync flushes buffered data and fsyncs the file.
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushLocked(); err != nil {
 		return err
 	}
+	return w.syncLocked()
+}
 
-	_, err = w.file.Write(data)
+// Rotate closes the current file now and compresses it in the background.
+// The next Write opens the following part of the same period.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	path := w.file.Name()
+	err := w.closeLocked()
+	w.rotated = true
+	w.compressAsync(path)
 	return err
 }
 
-func (w *Writer) ensureFile() error {
-	today := time.Now().UTC().Format("2006-01-02")
-	if w.file != nil && w.fileDate == today {
+func (w *Writer) flushLocked() error {
+	if w.buf == nil {
+		return nil
+	}
+	pending := w.buf.Buffered()
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if pending > 0 && w.dead != nil {
+		w.dead.recovered("write")
+	}
+	return w.resetJournalLocked()
+}
+
+func (w *Writer) syncLocked() error {
+	if w.file == nil || w.unsynced == 0 {
 		return nil
 	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.unsynced = 0
+	w.lastSync = w.opts.Clock.Now()
+	return nil
+}
+
+// SetEncoder installs an encoder applied to every subsequent Write.
+func (w *Writer) SetEncoder(e EventEncoder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.encoder = e
+}
+
+func (w *Writer) Write(event any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.bgErr; err != nil {
+		w.bgErr = nil
+		if w.dead == nil {
+			return fmt.Errorf("background flush: %w", err)
+		}
+		if err := w.failLocked(fmt.Errorf("background flush: %w", err), nil, false); err != nil {
+			slog.Error("writer: buffered records lost", "err", err, "prefix", w.prefix)
+		}
+	}
+
+	data, journaled, err := w.writeLocked(event)
+	if err != nil && data != nil && w.dead != nil {
+		return w.failLocked(err, data, journaled)
+	}
+	return err
+}
+
+// writeLocked encodes event and writes it. On failure it also returns the
+// encoded record, if encoding got that far, and whether the journal holds
+// it. Caller holds mu.
+func (w *Writer) writeLocked(event any) ([]byte, bool, error) {
+	opened, ferr := w.ensureFile()
+	if ferr != nil && w.dead == nil {
+		return nil, false, ferr
+	}
+
+	if w.encoder != nil {
+		event = w.encoder.Encode(event, opened)
+	}
+
+	data, err := w.marshalLocked(event)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshaling event: %w", err)
+	}
+	if ferr != nil {
+		return data, false, ferr
+	}
+
+	if err := w.journalLocked(data); err != nil {
+		return data, false, err
+	}
+	journaled := w.journal != nil && w.buf != nil
+
+	var n int
+	if w.buf != nil {
+		n, err = w.buf.Write(data)
+	} else {
+		n, err = w.file.Write(data)
+	}
+	w.size += int64(n)
+	if err != nil {
+		return data, journaled, err
+	}
+	if w.buf == nil && w.dead != nil {
+		w.dead.recovered("write")
+	}
+
+	w.unsynced++
+	if w.opts.SyncEvery > 0 && w.unsynced >= w.opts.SyncEvery {
+		if err := w.flushLocked(); err != nil {
+			return data, journaled, err
+		}
+		return nil, false, w.syncLocked()
+	}
+	return nil, false, nil
+}
+
+// marshalLocked encodes event as one newline-terminated line into a
+// buffer reused across calls. Caller holds mu.
+func (w *Writer) marshalLocked(event any) ([]byte, error) {
+	if rec, ok := event.(ticks.TickRecord); ok && w.opts.FastJSON {
+		data, err := rec.AppendJSON(w.encBuf[:0])
+		if err != nil {
+			return nil, err
+		}
+		w.encBuf = append(data, '\n')
+		return w.encBuf, nil
+	}
+
+	if w.jsonEnc == nil {
+		w.jsonEnc = json.NewEncoder(&w.jsonBuf)
+	}
+	w.jsonBuf.Reset()
+	if err := w.jsonEnc.Encode(event); err != nil {
+		return nil, err
+	}
+	return w.jsonBuf.Bytes(), nil
+}
+
+func (w *Writer) periodKey(t time.Time) string {
+	if w.opts.Rotation == RotateHourly {
+		return t.UTC().Format("2006-01-02T15")
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// periodDir is the directory holding period's files.
+func (w *Writer) periodDir(period string) string {
+	if w.opts.Layout == LayoutMonthly && len(period) >= 7 {
+		return filepath.Join(w.dir, period[:4], period[5:7])
+	}
+	return w.dir
+}
+
+func (w *Writer) path(period string, part int) string {
+	if part == 0 {
+		return filepath.Join(w.periodDir(period), fmt.Sprintf("%s-%s.jsonl", w.prefix, period))
+	}
+	return filepath.Join(w.periodDir(period), fmt.Sprintf("%s-%s.%d.jsonl", w.prefix, period, part))
+}
+
+// globs returns pattern matched against every directory files may be in.
+// Periods start with a digit, which keeps prefix "x" from matching the
+// files of prefix "x-prod".
+func (w *Writer) globs(pattern string) []string {
+	pattern = w.prefix + "-[0-9]" + pattern
+	matches, _ := filepath.Glob(filepath.Join(w.dir, pattern))
+	if w.opts.Layout == LayoutMonthly {
+		nested, _ := filepath.Glob(filepath.Join(w.dir, "[0-9]*", "[0-9]*", pattern))
+		matches = append(matches, nested...)
+	}
+	return matches
+}
+
+// resumePart returns the part to append to for period: the highest existing
+// part if it is still uncompressed, otherwise the one after it.
+func (w *Writer) resumePart(period string) int {
+	ext := w.opts.archiveExt()
+	base := w.prefix + "-" + period
+	matches, _ := filepath.Glob(filepath.Join(w.periodDir(period), base+".*"))
+
+	part, compressed := 0, false
+	for _, m := range matches {
+		rest := strings.TrimPrefix(filepath.Base(m), base)
+		n := 0
+		if !strings.HasPrefix(rest, ".jsonl") {
+			idx, tail, ok := strings.Cut(strings.TrimPrefix(rest, "."), ".")
+			v, err := strconv.Atoi(idx)
+			if !ok || err != nil || !strings.HasPrefix(tail, "jsonl") {
+				continue
+			}
+			n, rest = v, "."+tail
+		}
+		if n > part {
+			part, compressed = n, false
+		}
+		if n == part && rest == ".jsonl"+ext {
+			compressed = true
+		}
+	}
+	if compressed {
+		part++
+	}
+	return part
+}
+
+// ensureFile opens the current period's file if needed and reports whether it did.
+func (w *Writer) ensureFile() (bool, error) {
+	period := w.periodKey(w.opts.Clock.Now())
+	if w.file != nil && w.period == period && (w.opts.MaxBytes <= 0 || w.size < w.opts.MaxBytes) {
+		return false, nil
+	}
+
+	part := 0
+	if (w.file != nil || w.rotated) && w.period == period {
+		part = w.part + 1 // size limit reached or rotated on request
+	} else {
+		part = w.resumePart(period)
+	}
+	w.rotated = false
 
 	// Capture path before closing for background compression
 	var prevPath string
 	if w.file != nil {
 		prevPath = w.file.Name()
-		w.file.Close()
+		if err := w.closeLocked(); err != nil {
+			slog.Error("closing rotated file", "err", err, "path", prevPath)
+		}
 	}
 
-	path := filepath.Join(w.dir, fmt.Sprintf("%s-%s.jsonl", w.prefix, today))
+	path := w.path(period, part)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("creating output dir: %w", err)
+	}
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("opening output file: %w", err)
+		return false, fmt.Errorf("opening output file: %w", err)
+	}
+	var size int64
+	if st, err := f.Stat(); err == nil {
+		size = st.Size()
+	}
+	// A crash mid-write can leave a torn final line; end it so the next
+	// record starts on a line of its own.
+	if size > 0 && !endsWithNewline(path, size) {
+		if n, err := f.Write([]byte{'\n'}); err == nil {
+			size += int64(n)
+		}
 	}
 
 	w.file = f
-	w.fileDate = today
+	if w.opts.BufferSize > 0 {
+		w.buf = bufio.NewWriterSize(f, w.opts.BufferSize)
+	}
+	w.period = period
+	w.part = part
+	w.size = size
 
 	if prevPath != "" {
-		go compressFile(prevPath)
+		w.compressAsync(prevPath)
 	}
 
-	return nil
+	return true, nil
 }
 
-func (w *Writer) Close() error {
+func endsWithNewline(path string, size int64) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	var b [1]byte
+	if _, err := f.ReadAt(b[:], size-1); err != nil {
+		return true
+	}
+	return b[0] == '\n'
+}
+
+// SetDeadLetter makes failed writes go to d instead of being dropped, and
+// reports write and compression failures to it.
+func (w *Writer) SetDeadLetter(d *DeadLetter) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.dead = d
+}
+
+// failLocked saves what a failed write may have lost to the dead letter
+// file: data, unless the journal holds it, and when writes are buffered
+// the journal's records, which went with the buffer (records the buffer
+// got out before failing appear in both files). The file is then dropped
+// without flushing, so the next Write reopens it and starts a fresh
+// encoding. Caller holds mu; w.dead is set.
+func (w *Writer) failLocked(err error, data []byte, journaled bool) error {
+	w.dead.failed("write", err)
+
+	var recs [][]byte
+	if w.buf != nil {
+		recs = w.journalRecordsLocked()
+	}
+	if data != nil && !journaled {
+		recs = append(recs, data)
+	}
 	if w.file != nil {
-		return w.file.Close()
+		w.file.Close()
+		w.file, w.buf = nil, nil
+		w.unsynced = 0
+	}
+	if err := w.resetJournalLocked(); err != nil {
+		slog.Warn("dead letter: journal reset failed", "err", err)
+	}
+
+	if len(recs) == 0 {
+		return err
+	}
+	if serr := w.dead.spill(w.opts.Clock.Now(), recs...); serr != nil {
+		return fmt.Errorf("%w (dead letter: %v)", err, serr)
 	}
 	return nil
 }
 
-// compressFile gzips a JSONL file and removes the original.
-// Writes to .gz.tmp first, then renames atomically.
-func compressFile(srcPath string) {
-	dstPath := srcPath + ".gz"
+// currentPath is the file the writer is appending to, or would open next.
+func (w *Writer) currentPath() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		return w.file.Name()
+	}
+	period := w.periodKey(w.opts.Clock.Now())
+	return w.path(period, w.resumePart(period))
+}
+
+// Close stops background flushing and flushes, syncs and closes the file.
+func (w *Writer) Close() error {
+	if w.stop != nil {
+		w.stopOnce.Do(func() { close(w.stop) })
+		w.stoppedWg.Wait()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.closeLocked()
+	if w.journal != nil {
+		if jerr := w.journal.Close(); err == nil {
+			err = jerr
+		}
+		w.journal = nil
+	}
+	return err
+}
+
+// closeLocked flushes, fsyncs and closes the current file. Caller holds mu.
+func (w *Writer) closeLocked() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.flushLocked()
+	if serr := w.syncLocked(); err == nil {
+		err = serr
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	w.buf = nil
+	return err
+}
+
+// archiveExt is what compression, and encryption if enabled, add to a
+// rotated file's name.
+func (o WriterOptions) archiveExt() string {
+	ext, _ := compressedExt(o.Compression)
+	if o.EncryptKey != nil {
+		ext += ticks.EncExt
+	}
+	return ext
+}
+
+func compressedExt(c Compression) (string, error) {
+	switch c {
+	case CompressGzip:
+		return ".gz", nil
+	case CompressZstd:
+		return ".zst", nil
+	}
+	return "", fmt.Errorf("unknown compression %q", c)
+}
+
+// compressFile compresses a JSONL file into seekable blocks, encrypting
+// them if opts has a key, writes its index and manifest, and removes the
+// original. Writes to <dst>.tmp first, then renames atomically. It returns
+// the archive's path, or "" if srcPath was gone; an error means the
+// original is still in place.
+func compressFile(srcPath string, opts WriterOptions) (string, error) {
+	c := opts.Compression
+	if _, err := compressedExt(c); err != nil {
+		return "", err
+	}
+	dstPath := srcPath + opts.archiveExt()
 	tmpPath := dstPath + ".tmp"
 
-	// If .gz already exists, just clean up the original
+	// If the compressed file already exists, just clean up the original
 	if _, err := os.Stat(dstPath); err == nil {
 		if _, err := os.Stat(srcPath); err == nil {
-			slog.Info("gzip exists, removing original", "path", srcPath)
+			slog.Info("compressed file exists, removing original", "path", srcPath)
 			os.Remove(srcPath)
+			return dstPath, nil
 		}
-		return
+		return "", nil
 	}
 	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
-		return
+		return "", nil
 	}
 
-	slog.Info("compressing", "src", srcPath)
+	slog.Info("compressing", "src", srcPath, "codec", c)
 
 	src, err := os.Open(srcPath)
 	if err != nil {
-		slog.Error("compress: open source", "err", err, "path", srcPath)
-		return
+		return "", fmt.Errorf("open source: %w", err)
 	}
 	defer src.Close()
 
 	tmp, err := os.Create(tmpPath)
 	if err != nil {
-		slog.Error("compress: create tmp", "err", err, "path", tmpPath)
-		return
+		return "", fmt.Errorf("create tmp: %w", err)
 	}
 
-	gz, _ := gzip.NewWriterLevel(tmp, gzip.BestCompression)
-	if _, err := io.Copy(gz, src); err != nil {
-		gz.Close()
-		tmp.Close()
-		os.Remove(tmpPath)
-		slog.Error("compress: copy", "err", err, "path", srcPath)
-		return
+	var dst io.Writer = tmp
+	var enc *ticks.EncryptWriter
+	if opts.EncryptKey != nil {
+		if enc, err = ticks.NewEncryptWriter(tmp, opts.EncryptKey); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return "", fmt.Errorf("encrypt: %w", err)
+		}
+		dst = enc
+	}
+	idx, err := ticks.CompressIndexed(src, dst, string(c))
+	if err == nil && enc != nil {
+		err = enc.Close()
 	}
-	if err := gz.Close(); err != nil {
+	if err != nil {
 		tmp.Close()
 		os.Remove(tmpPath)
-		slog.Error("compress: gzip close", "err", err, "path", srcPath)
-		return
+		return "", fmt.Errorf("copy: %w", err)
 	}
 	if err := tmp.Close(); err != nil {
 		os.Remove(tmpPath)
-		slog.Error("compress: tmp close", "err", err, "path", srcPath)
-		return
+		return "", fmt.Errorf("tmp close: %w", err)
 	}
 
 	// Atomic rename
 	if err := os.Rename(tmpPath, dstPath); err != nil {
 		os.Remove(tmpPath)
-		slog.Error("compress: rename", "err", err, "path", srcPath)
-		return
+		return "", fmt.Errorf("rename: %w", err)
 	}
 
 	// Remove original
 	if err := os.Remove(srcPath); err != nil {
 		slog.Warn("compress: remove original", "err", err, "path", srcPath)
-		return
+		return dstPath, nil
+	}
+
+	slog.Info("compressed", "dst", dstPath, "blocks", len(idx.Blocks))
+
+	if err := ticks.WriteIndex(dstPath, idx); err != nil {
+		slog.Error("index failed", "err", err, "path", dstPath)
 	}
 
-	slog.Info("compressed", "dst", dstPath)
+	if m, err := ticks.WriteManifest(dstPath); err != nil {
+		slog.Error("manifest failed", "err", err, "path", dstPath)
+	} else {
+		slog.Info("manifest written", "path", ticks.ManifestPath(dstPath), "records", m.Records, "markets", len(m.Markets))
+	}
+	return dstPath, nil
 }
 
-// CompressStaleFiles compresses any JSONL files from previous days.
+// compressAsync compresses path in the background.
+func (w *Writer) compressAsync(path string) {
+	w.compressing.Add(1)
+	go func() {
+		defer w.compressing.Done()
+		archive, err := compressFile(path, w.opts)
+		if err != nil {
+			slog.Error("compress failed", "err", err, "path", path)
+		}
+		w.mu.Lock()
+		dead, hooks := w.dead, w.hooks
+		w.mu.Unlock()
+		if archive != "" {
+			for _, h := range hooks {
+				h(archive)
+			}
+		}
+		switch {
+		case dead == nil:
+		case err != nil:
+			dead.failed("compress", err)
+		default:
+			dead.recovered("compress")
+		}
+	}()
+}
+
+// WaitCompressions waits until the compressions started by rotation and
+// CompressStale have finished, or ctx is done. One cut short leaves a .tmp
+// file that the next CompressStale removes before starting over.
+func (w *Writer) WaitCompressions(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.compressing.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CompressStaleFiles compresses any JSONL files from previous days with gzip.
 // Call on startup to handle files left uncompressed after a crash.
 func CompressStaleFiles(dir, prefix string) {
-	today := time.Now().UTC().Format("2006-01-02")
-
-	// Clean up leftover .gz.tmp files
-	tmps, _ := filepath.Glob(filepath.Join(dir, prefix+"-*.jsonl.gz.tmp"))
-	for _, tmp := range tmps {
-		slog.Warn("removing stale tmp", "path", tmp)
-		os.Remove(tmp)
-	}
-
-	// Find JSONL files from previous days
-	pattern := filepath.Join(dir, prefix+"-*.jsonl")
-	files, _ := filepath.Glob(pattern)
-	for _, f := range files {
-		base := filepath.Base(f)
-		// Extract date from prefix-YYYY-MM-DD.jsonl
-		dateStr := strings.TrimPrefix(base, prefix+"-")
-		dateStr = strings.TrimSuffix(dateStr, ".jsonl")
-		if dateStr == today {
+	w := &Writer{dir: dir, prefix: prefix, opts: WriterOptions{Rotation: RotateDaily, Compression: CompressGzip, Clock: clock.Real}}
+	w.CompressStale()
+}
+
+// CompressStale compresses every uncompressed file for this writer's prefix
+// except the one it is (or would be) currently appending to.
+func (w *Writer) CompressStale() {
+	current := w.currentPath()
+
+	// Clean up leftover compression tmp files
+	for _, ext := range []string{".gz", ".zst", ".gz" + ticks.EncExt, ".zst" + ticks.EncExt} {
+		for _, tmp := range w.globs("*.jsonl" + ext + ".tmp") {
+			slog.Warn("removing stale tmp", "path", tmp)
+			os.Remove(tmp)
+		}
+	}
+
+	for _, f := range w.globs("*.jsonl") {
+		if f == current {
 			continue
 		}
-		go compressFile(f)
+		w.compressAsync(f)
 	}
 }
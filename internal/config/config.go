@@ -2,17 +2,54 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gw/btc15m-data/internal/alert"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	Account           string // Kalshi account the credentials belong to, see Accounts
 	KalshiAPIKeyID    string
 	KalshiPrivKeyPath string
 	KalshiEnv         string // "prod" or "demo"
 	OutputDir         string // default "./data"
 	SeriesTicker      string // default "KXBTC15M"
+	AlertWebhookURL   string // optional; alerts are POSTed here as JSON
+	InfluxURL         string // optional; InfluxDB write endpoint for live metrics
+	InfluxToken       string // optional; InfluxDB API token
+
+	// Optional REST cassettes (see kalshi.Recorder): record every exchange
+	// to KALSHI_HTTP_RECORD, or answer from KALSHI_HTTP_REPLAY offline.
+	KalshiHTTPRecord string
+	KalshiHTTPReplay string
+
+	// KalshiWSURL, from KALSHI_WS_URL, replaces the environment's WebSocket
+	// endpoint, e.g. with a kalshitest.WSServer.
+	KalshiWSURL string
+
+	// Optional alert destinations besides AlertWebhookURL, each with the
+	// least severity it receives (ALERT_*_SEVERITY, default info); see
+	// Notifier.
+	AlertPushoverToken    string // ALERT_PUSHOVER_TOKEN, the application token
+	AlertPushoverUser     string // ALERT_PUSHOVER_USER, the user or group key
+	AlertDiscordURL       string // ALERT_DISCORD_WEBHOOK_URL
+	AlertWebhookSeverity  alert.Severity
+	AlertPushoverSeverity alert.Severity
+	AlertDiscordSeverity  alert.Severity
+
+	// Tunables the collector re-applies on SIGHUP.
+	LogLevel     slog.Level    // LOG_LEVEL, default info
+	TickInterval time.Duration // TICK_INTERVAL, default 1s
+	Feeds        []string      // FEEDS, default coinbase,kraken,bitstamp
+
+	// FeedURLs, from FEED_URLS (name=url,...), replaces exchange feeds'
+	// WebSocket endpoints, e.g. with cmd/mockfeeds. Read at startup only.
+	FeedURLs map[string]string
 }
 
 func (c *Config) BaseURL() string {
@@ -23,33 +60,215 @@ func (c *Config) BaseURL() string {
 }
 
 func (c *Config) WSBaseURL() string {
+	if c.KalshiWSURL != "" {
+		return c.KalshiWSURL
+	}
 	if c.KalshiEnv == "prod" {
 		return "wss://api.elections.kalshi.com/trade-api/ws/v2"
 	}
 	return "wss://demo-api.kalshi.co/trade-api/ws/v2"
 }
 
+// envFile is the dotenv file Load, Reload and the Tradelog helpers read.
+var envFile = ".env"
+
+// SetEnvFile makes the package read path instead of .env. Unlike the
+// default file, path must exist.
+func SetEnvFile(path string) error {
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("env file: %w", err)
+	}
+	envFile = path
+	return nil
+}
+
 func Load() (*Config, error) {
-	_ = godotenv.Load()
+	_ = godotenv.Load(envFile)
+	return fromEnv()
+}
+
+// DefaultAccount is the Kalshi account configured by KALSHI_API_KEY_ID
+// and KALSHI_PRIV_KEY_PATH, and the only one when KALSHI_ACCOUNTS is unset.
+const DefaultAccount = "default"
 
+// Accounts returns the Kalshi account names listed in KALSHI_ACCOUNTS
+// (comma-separated), or just DefaultAccount.
+func Accounts() []string {
+	_ = godotenv.Load(envFile)
+	var names []string
+	for _, n := range strings.Split(os.Getenv("KALSHI_ACCOUNTS"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return []string{DefaultAccount}
+	}
+	return names
+}
+
+// LoadAccount is Load with the credentials of the named account, read
+// from KALSHI_<NAME>_API_KEY_ID, KALSHI_<NAME>_PRIV_KEY_PATH and
+// optionally KALSHI_<NAME>_ENV (default KALSHI_ENV). NAME is the account
+// name upper-cased, with anything but letters and digits replaced by '_'.
+// DefaultAccount uses the unprefixed variables, as Load does.
+func LoadAccount(name string) (*Config, error) {
+	_ = godotenv.Load(envFile)
+	return accountFromEnv(name)
+}
+
+func fromEnv() (*Config, error) {
+	return accountFromEnv(DefaultAccount)
+}
+
+// accountVars returns the variables holding an account's credentials.
+func accountVars(account string) (keyID, keyPath, env string) {
+	if account == DefaultAccount {
+		return "KALSHI_API_KEY_ID", "KALSHI_PRIV_KEY_PATH", "KALSHI_ENV"
+	}
+	prefix := "KALSHI_" + strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			return c
+		}
+		return '_'
+	}, account) + "_"
+	return prefix + "API_KEY_ID", prefix + "PRIV_KEY_PATH", prefix + "ENV"
+}
+
+// Notifier returns a notifier sending alerts to every configured
+// destination that takes their severity, or nil when none is configured.
+func (c *Config) Notifier() alert.Notifier {
+	var r alert.Router
+	if c.AlertWebhookURL != "" {
+		r.Add(alert.NewWebhook(c.AlertWebhookURL), c.AlertWebhookSeverity)
+	}
+	if c.AlertPushoverToken != "" {
+		r.Add(alert.NewPushover(c.AlertPushoverToken, c.AlertPushoverUser), c.AlertPushoverSeverity)
+	}
+	if c.AlertDiscordURL != "" {
+		r.Add(alert.NewDiscord(c.AlertDiscordURL), c.AlertDiscordSeverity)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	return &r
+}
+
+// Reload re-reads .env, letting its values override the ones already in
+// the environment, so a running process can pick up edits to the file.
+func Reload() (*Config, error) {
+	_ = godotenv.Overload(envFile)
+	return fromEnv()
+}
+
+func accountFromEnv(account string) (*Config, error) {
+	keyIDVar, keyPathVar, envVar := accountVars(account)
+	defaultKeyPath := "./kalshi_private_key.pem"
+	if account != DefaultAccount {
+		defaultKeyPath = ""
+	}
 	cfg := &Config{
-		KalshiAPIKeyID:    os.Getenv("KALSHI_API_KEY_ID"),
-		KalshiPrivKeyPath: getEnvDefault("KALSHI_PRIV_KEY_PATH", "./kalshi_private_key.pem"),
-		KalshiEnv:         getEnvDefault("KALSHI_ENV", "prod"),
+		Account:           account,
+		KalshiAPIKeyID:    os.Getenv(keyIDVar),
+		KalshiPrivKeyPath: getEnvDefault(keyPathVar, defaultKeyPath),
+		KalshiEnv:         getEnvDefault(envVar, getEnvDefault("KALSHI_ENV", "prod")),
 		OutputDir:         getEnvDefault("OUTPUT_DIR", "./data"),
 		SeriesTicker:      getEnvDefault("SERIES_TICKER", "KXBTC15M"),
+		AlertWebhookURL:   os.Getenv("ALERT_WEBHOOK_URL"),
+		InfluxURL:         os.Getenv("INFLUX_URL"),
+		InfluxToken:       os.Getenv("INFLUX_TOKEN"),
+		KalshiHTTPRecord:  os.Getenv("KALSHI_HTTP_RECORD"),
+		KalshiHTTPReplay:  os.Getenv("KALSHI_HTTP_REPLAY"),
+		KalshiWSURL:       os.Getenv("KALSHI_WS_URL"),
+	}
+
+	if err := cfg.LogLevel.UnmarshalText([]byte(getEnvDefault("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	interval, err := time.ParseDuration(getEnvDefault("TICK_INTERVAL", "1s"))
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("TICK_INTERVAL must be a positive duration, got %q", os.Getenv("TICK_INTERVAL"))
+	}
+	cfg.TickInterval = interval
+	for _, f := range strings.Split(getEnvDefault("FEEDS", "coinbase,kraken,bitstamp"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.Feeds = append(cfg.Feeds, f)
+		}
+	}
+
+	if v := os.Getenv("FEED_URLS"); v != "" {
+		cfg.FeedURLs = make(map[string]string)
+		for _, kv := range strings.Split(v, ",") {
+			name, url, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok || name == "" || url == "" {
+				return nil, fmt.Errorf("FEED_URLS entries must be name=url, got %q", kv)
+			}
+			cfg.FeedURLs[name] = url
+		}
+	}
+
+	cfg.AlertPushoverToken = os.Getenv("ALERT_PUSHOVER_TOKEN")
+	cfg.AlertPushoverUser = os.Getenv("ALERT_PUSHOVER_USER")
+	cfg.AlertDiscordURL = os.Getenv("ALERT_DISCORD_WEBHOOK_URL")
+	for v, sev := range map[string]*alert.Severity{
+		"ALERT_WEBHOOK_SEVERITY":  &cfg.AlertWebhookSeverity,
+		"ALERT_PUSHOVER_SEVERITY": &cfg.AlertPushoverSeverity,
+		"ALERT_DISCORD_SEVERITY":  &cfg.AlertDiscordSeverity,
+	} {
+		if *sev, err = alert.ParseSeverity(os.Getenv(v)); err != nil {
+			return nil, fmt.Errorf("%s: %w", v, err)
+		}
+	}
+	if (cfg.AlertPushoverToken == "") != (cfg.AlertPushoverUser == "") {
+		return nil, fmt.Errorf("ALERT_PUSHOVER_TOKEN and ALERT_PUSHOVER_USER must be set together")
 	}
 
 	if cfg.KalshiAPIKeyID == "" {
-		return nil, fmt.Errorf("KALSHI_API_KEY_ID is required")
+		return nil, fmt.Errorf("%s is required", keyIDVar)
+	}
+	if cfg.KalshiPrivKeyPath == "" {
+		return nil, fmt.Errorf("%s is required", keyPathVar)
 	}
 	if cfg.KalshiEnv != "prod" && cfg.KalshiEnv != "demo" {
-		return nil, fmt.Errorf("KALSHI_ENV must be 'prod' or 'demo', got %q", cfg.KalshiEnv)
+		return nil, fmt.Errorf("%s must be 'prod' or 'demo', got %q", envVar, cfg.KalshiEnv)
 	}
 
 	return cfg, nil
 }
 
+// TradelogDSN returns the trade database to use: a SQLite file path or a
+// postgres:// URL. It needs no Kalshi credentials, so read-only tradelog
+// commands can call it without Load.
+func TradelogDSN() string {
+	_ = godotenv.Load(envFile)
+	return getEnvDefault("TRADELOG_DSN", "data/tradelog.db")
+}
+
+// TradelogReporting returns the time zone and session start hour that
+// tradelog reports group days by: TRADELOG_TZ (default UTC) and
+// TRADELOG_SESSION_HOUR (default 0, i.e. midnight).
+func TradelogReporting() (tz string, sessionHour int, err error) {
+	_ = godotenv.Load(envFile)
+	tz = getEnvDefault("TRADELOG_TZ", "UTC")
+	if v := os.Getenv("TRADELOG_SESSION_HOUR"); v != "" {
+		if sessionHour, err = strconv.Atoi(v); err != nil {
+			return "", 0, fmt.Errorf("TRADELOG_SESSION_HOUR: %w", err)
+		}
+	}
+	return tz, sessionHour, nil
+}
+
+// TradelogWebAuth returns the user and password tradelog serve requires,
+// from TRADELOG_WEB_AUTH (user:password); both are "" when it is unset.
+func TradelogWebAuth() (user, pass string) {
+	_ = godotenv.Load(envFile)
+	user, pass, _ = strings.Cut(os.Getenv("TRADELOG_WEB_AUTH"), ":")
+	return user, pass
+}
+
 func getEnvDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
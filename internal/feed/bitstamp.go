@@ -3,20 +3,31 @@ package feed
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// BitstampFeed streams BTC-USD order book from Bitstamp WebSocket.
+// BitstampFeed keeps a local BTC-USD order book from Bitstamp's
+// diff_order_book WebSocket channel, seeded from a REST snapshot on each
+// connect. Diffs carry only the levels that changed, so this is far
+// lighter than the full order_book channel and sees every top-of-book
+// change rather than a sampled snapshot.
 type BitstampFeed struct {
 	baseFeed
+	http *http.Client
+	top  Quote // guarded by baseFeed.mu
 }
 
 func NewBitstampFeed() *BitstampFeed {
-	return &BitstampFeed{baseFeed: baseFeed{name: "bitstamp"}}
+	return &BitstampFeed{
+		baseFeed: baseFeed{name: "bitstamp"},
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
 }
 
 type bitstampSubscribe struct {
@@ -28,12 +39,23 @@ type bitstampSubData struct {
 	Channel string `json:"channel"`
 }
 
+// bitstampBookMsg is a REST order book snapshot or a WS diff. In a diff a
+// level with amount 0 is removed.
+type bitstampBookMsg struct {
+	Microtimestamp string     `json:"microtimestamp"`
+	Bids           [][]string `json:"bids"` // [[price, amount], ...]
+	Asks           [][]string `json:"asks"`
+}
+
 func (f *BitstampFeed) Run(ctx context.Context) error {
-	const wsURL = "wss://ws.bitstamp.net"
+	wsURL := f.endpoint("wss://ws.bitstamp.net")
 
 	for {
 		if err := f.connect(ctx, wsURL); err != nil {
 			slog.Warn("bitstamp ws disconnected", "err", err)
+			if ctx.Err() == nil {
+				f.frames.DumpOnError(f.name, err)
+			}
 		}
 
 		select {
@@ -55,13 +77,21 @@ func (f *BitstampFeed) connect(ctx context.Context, wsURL string) error {
 
 	sub := bitstampSubscribe{
 		Event: "bts:subscribe",
-		Data:  bitstampSubData{Channel: "order_book_btcusd"},
+		Data:  bitstampSubData{Channel: "diff_order_book_btcusd"},
 	}
 	if err := conn.WriteJSON(sub); err != nil {
 		return err
 	}
 	slog.Info("bitstamp subscribed")
 
+	// Diffs queue on the connection while the snapshot is fetched; those
+	// it already includes are skipped by timestamp.
+	book, err := f.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	f.publish(book)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -74,6 +104,8 @@ func (f *BitstampFeed) connect(ctx context.Context, wsURL string) error {
 		if err != nil {
 			return err
 		}
+		received := time.Now()
+		f.frames.Add(f.name, msg)
 
 		var envelope struct {
 			Event   string          `json:"event"`
@@ -89,26 +121,142 @@ func (f *BitstampFeed) connect(ctx context.Context, wsURL string) error {
 			slog.Debug("bitstamp event", "event", envelope.Event)
 			continue
 		}
+		if envelope.Event != "data" {
+			continue
+		}
 
-		var book struct {
-			Bids [][]string `json:"bids"` // [[price, amount], ...]
-			Asks [][]string `json:"asks"`
+		var diff bitstampBookMsg
+		if err := json.Unmarshal(envelope.Data, &diff); err != nil {
+			continue
 		}
-		if err := json.Unmarshal(envelope.Data, &book); err != nil {
+		us, err := strconv.ParseInt(diff.Microtimestamp, 10, 64)
+		if err != nil || us <= book.ts {
 			continue
 		}
+		book.apply(diff, us)
+		f.observeLatency(time.UnixMicro(us), received)
 
-		if len(book.Bids) == 0 || len(book.Asks) == 0 {
-			continue
+		if q := book.quote(); q.Bid > 0 && q.Ask > 0 && q.Bid >= q.Ask {
+			// A missed diff; there are no sequence numbers to catch it
+			// any sooner.
+			slog.Warn("bitstamp book crossed, resyncing", "bid", q.Bid, "ask", q.Ask)
+			if book, err = f.snapshot(ctx); err != nil {
+				return err
+			}
 		}
+		f.publish(book)
+	}
+}
 
-		bid, err1 := strconv.ParseFloat(book.Bids[0][0], 64)
-		ask, err2 := strconv.ParseFloat(book.Asks[0][0], 64)
-		if err1 != nil || err2 != nil {
-			continue
+// snapshot fetches the full order book over REST.
+func (f *BitstampFeed) snapshot(ctx context.Context) (*bitstampBook, error) {
+	base := f.restBase()
+	if base == "" {
+		base = restTickers["bitstamp"].base
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/v2/order_book/btcusd/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bitstamp order book: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bitstamp order book: %s", resp.Status)
+	}
+	var snap bitstampBookMsg
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("bitstamp order book: %w", err)
+	}
+	us, _ := strconv.ParseInt(snap.Microtimestamp, 10, 64)
+	book := newBitstampBook()
+	book.apply(snap, us)
+	return book, nil
+}
+
+// publish makes the book's top the feed's quote and mid price.
+func (f *BitstampFeed) publish(book *bitstampBook) {
+	q := book.quote()
+	if q.Bid <= 0 || q.Ask <= 0 {
+		return
+	}
+	q.Time = time.Now()
+	f.mu.Lock()
+	f.top = q
+	f.mu.Unlock()
+	f.setPrice((q.Bid + q.Ask) / 2)
+}
+
+// TopOfBook returns the best bid and ask with their sizes.
+func (f *BitstampFeed) TopOfBook() Quote {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.top
+}
+
+// bitstampBook is a price → amount order book. The best levels are cached
+// and recomputed only when the cached one is removed.
+type bitstampBook struct {
+	bids, asks       map[float64]float64
+	bestBid, bestAsk float64 // 0 = recompute
+	ts               int64   // microtimestamp of the last update applied
+}
+
+func newBitstampBook() *bitstampBook {
+	return &bitstampBook{bids: make(map[float64]float64), asks: make(map[float64]float64)}
+}
+
+func (b *bitstampBook) apply(m bitstampBookMsg, us int64) {
+	for _, l := range m.Bids {
+		if p, amt, ok := parseLevel(l); ok {
+			b.set(b.bids, &b.bestBid, p, amt, func(p, best float64) bool { return p > best })
+		}
+	}
+	for _, l := range m.Asks {
+		if p, amt, ok := parseLevel(l); ok {
+			b.set(b.asks, &b.bestAsk, p, amt, func(p, best float64) bool { return p < best })
 		}
+	}
+	b.ts = us
+}
+
+func (b *bitstampBook) set(side map[float64]float64, best *float64, p, amt float64, better func(p, best float64) bool) {
+	if amt <= 0 {
+		delete(side, p)
+		if p == *best {
+			*best = 0
+		}
+		return
+	}
+	side[p] = amt
+	if *best != 0 && better(p, *best) {
+		*best = p
+	}
+}
+
+func (b *bitstampBook) quote() Quote {
+	if b.bestBid == 0 {
+		for p := range b.bids {
+			b.bestBid = max(b.bestBid, p)
+		}
+	}
+	if b.bestAsk == 0 {
+		for p := range b.asks {
+			if b.bestAsk == 0 || p < b.bestAsk {
+				b.bestAsk = p
+			}
+		}
+	}
+	return Quote{Bid: b.bestBid, BidSize: b.bids[b.bestBid], Ask: b.bestAsk, AskSize: b.asks[b.bestAsk]}
+}
 
-		mid := (bid + ask) / 2
-		f.setPrice(mid)
+func parseLevel(l []string) (price, amount float64, ok bool) {
+	if len(l) < 2 {
+		return 0, 0, false
 	}
+	price, err1 := strconv.ParseFloat(l[0], 64)
+	amount, err2 := strconv.ParseFloat(l[1], 64)
+	return price, amount, err1 == nil && err2 == nil && price > 0
 }
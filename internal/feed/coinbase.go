@@ -30,14 +30,18 @@ type coinbaseTicker struct {
 	BestBid   string `json:"best_bid"`
 	BestAsk   string `json:"best_ask"`
 	ProductID string `json:"product_id"`
+	Time      string `json:"time"`
 }
 
 func (f *CoinbaseFeed) Run(ctx context.Context) error {
-	const wsURL = "wss://ws-feed.exchange.coinbase.com"
+	wsURL := f.endpoint("wss://ws-feed.exchange.coinbase.com")
 
 	for {
 		if err := f.connect(ctx, wsURL); err != nil {
 			slog.Warn("coinbase ws disconnected", "err", err)
+			if ctx.Err() == nil {
+				f.frames.DumpOnError(f.name, err)
+			}
 		}
 
 		select {
@@ -78,6 +82,8 @@ func (f *CoinbaseFeed) connect(ctx context.Context, wsURL string) error {
 		if err != nil {
 			return err
 		}
+		received := time.Now()
+		f.frames.Add(f.name, msg)
 
 		var ticker coinbaseTicker
 		if err := json.Unmarshal(msg, &ticker); err != nil {
@@ -96,5 +102,8 @@ func (f *CoinbaseFeed) connect(ctx context.Context, wsURL string) error {
 
 		mid := (bid + ask) / 2
 		f.setPrice(mid)
+		if sent, err := time.Parse(time.RFC3339Nano, ticker.Time); err == nil {
+			f.observeLatency(sent, received)
+		}
 	}
 }
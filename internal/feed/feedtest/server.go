@@ -0,0 +1,416 @@
+// Package feedtest serves fake Coinbase, Kraken and Bitstamp WebSocket
+// feeds, with optional fault injection, so the collector's exchange feeds
+// can run against a local endpoint.
+package feedtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Exchanges are the feeds a Server can imitate.
+var Exchanges = []string{"coinbase", "kraken", "bitstamp"}
+
+// Chaos sets how often a Server misbehaves. Each rate is a probability per
+// quote sent to a connection.
+type Chaos struct {
+	Disconnect float64       // drop the connection without a close frame
+	Stall      float64       // go silent on the connection for StallFor
+	StallFor   time.Duration // default 20s, past every feed's staleness limit
+	Garbage    float64       // send a malformed frame before the quote
+}
+
+// DefaultStall is how long a stall lasts when Chaos.StallFor is zero.
+const DefaultStall = 20 * time.Second
+
+// ParseChaos reads a Chaos from comma-separated key=value pairs, e.g.
+// "disconnect=0.01,stall=0.005,stall_for=30s,garbage=0.02".
+func ParseChaos(s string) (Chaos, error) {
+	var c Chaos
+	if s == "" {
+		return c, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return c, fmt.Errorf("chaos: %q is not key=value", kv)
+		}
+		if k == "stall_for" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return c, fmt.Errorf("chaos: stall_for must be a positive duration, got %q", v)
+			}
+			c.StallFor = d
+			continue
+		}
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return c, fmt.Errorf("chaos: %s must be a rate between 0 and 1, got %q", k, v)
+		}
+		switch k {
+		case "disconnect":
+			c.Disconnect = rate
+		case "stall":
+			c.Stall = rate
+		case "garbage":
+			c.Garbage = rate
+		default:
+			return c, fmt.Errorf("chaos: unknown key %q (want disconnect, stall, stall_for or garbage)", k)
+		}
+	}
+	return c, nil
+}
+
+// Server is one exchange's ticker feed. A connection receives quotes once
+// it has sent that exchange's subscribe message; Quote writes to every
+// subscribed connection before returning. Plain HTTP requests get the
+// last quote in the exchange's REST ticker format, for the feeds' REST
+// fallback; chaos leaves them alone.
+type Server struct {
+	exchange string
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	conns    map[*conn]bool
+	chaos    Chaos
+	rng      *rand.Rand
+	connects int
+	faults   Faults
+	bid, ask float64   // last quote, for REST and Bitstamp's diffs
+	quotedAt time.Time // when it was sent
+	restDown bool
+}
+
+// Faults counts the faults a Server has injected.
+type Faults struct {
+	Disconnects int
+	Stalls      int
+	Garbage     int
+}
+
+type conn struct {
+	ws         *websocket.Conn
+	subscribed bool
+	stallUntil time.Time
+}
+
+// NewServer starts a fake feed for exchange (see Exchanges) on a loopback
+// port. Close it when done.
+func NewServer(exchange string) (*Server, error) {
+	switch exchange {
+	case "coinbase", "kraken", "bitstamp":
+	default:
+		return nil, fmt.Errorf("unknown exchange %q", exchange)
+	}
+	s := &Server{
+		exchange: exchange,
+		conns:    make(map[*conn]bool),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s, nil
+}
+
+// Exchange returns the exchange the server imitates.
+func (s *Server) Exchange() string { return s.exchange }
+
+// URL is the ws:// address to dial.
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Close drops every connection and stops the server.
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
+
+// SetChaos changes the fault rates. A seed makes the faults repeatable.
+func (s *Server) SetChaos(c Chaos, seed int64) {
+	if c.StallFor == 0 {
+		c.StallFor = DefaultStall
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chaos = c
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+// Faults returns the faults injected so far.
+func (s *Server) Faults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults
+}
+
+// Connects returns how many connections have been accepted so far.
+func (s *Server) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+// Subscribed returns how many open connections have subscribed.
+func (s *Server) Subscribed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for c := range s.conns {
+		if c.subscribed {
+			n++
+		}
+	}
+	return n
+}
+
+// Disconnect drops every open connection without a close frame.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+}
+
+// SetRESTDown makes REST ticker requests fail with 503 while down is set.
+func (s *Server) SetRESTDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restDown = down
+}
+
+// Stall stops quotes to every open connection for d, leaving them open.
+func (s *Server) Stall(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := time.Now().Add(d)
+	for c := range s.conns {
+		c.stallUntil = until
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		s.serveREST(w, r)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.connects++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if reply, ok := s.subscribeReply(data); ok {
+			s.mu.Lock()
+			c.subscribed = true
+			s.write(c, reply)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// subscribeReply returns the exchange's acknowledgement if data is its
+// subscribe message for BTC-USD.
+func (s *Server) subscribeReply(data []byte) ([]byte, bool) {
+	var m struct {
+		Type       string   `json:"type"`
+		ProductIDs []string `json:"product_ids"`
+		Method     string   `json:"method"`
+		Params     struct {
+			Channel string   `json:"channel"`
+			Symbol  []string `json:"symbol"`
+		} `json:"params"`
+		Event string `json:"event"`
+		Data  struct {
+			Channel string `json:"channel"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, false
+	}
+	switch s.exchange {
+	case "coinbase":
+		if m.Type == "subscribe" && len(m.ProductIDs) > 0 {
+			return []byte(`{"type":"subscriptions","channels":[{"name":"ticker","product_ids":["BTC-USD"]}]}`), true
+		}
+	case "kraken":
+		if m.Method == "subscribe" && m.Params.Channel == "ticker" {
+			return []byte(`{"method":"subscribe","result":{"channel":"ticker","symbol":"BTC/USD"},"success":true}`), true
+		}
+	case "bitstamp":
+		if m.Event == "bts:subscribe" {
+			return []byte(`{"event":"bts:subscription_succeeded","channel":"` + m.Data.Channel + `","data":{}}`), true
+		}
+	}
+	return nil, false
+}
+
+// serveREST answers with the last quote in the exchange's REST ticker
+// format, or for Bitstamp's order_book path as a one-level book snapshot.
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	bid, ask, at, down := s.bid, s.ask, s.quotedAt, s.restDown
+	s.mu.Unlock()
+	if down || bid <= 0 {
+		http.Error(w, "no quote", http.StatusServiceUnavailable)
+		return
+	}
+	b := strconv.FormatFloat(bid, 'f', 2, 64)
+	a := strconv.FormatFloat(ask, 'f', 2, 64)
+	var m any
+	switch {
+	case s.exchange == "bitstamp" && strings.Contains(r.URL.Path, "order_book"):
+		m = map[string]any{
+			"timestamp":      strconv.FormatInt(at.Unix(), 10),
+			"microtimestamp": strconv.FormatInt(at.UnixMicro(), 10),
+			"bids":           [][]string{{b, "0.5"}},
+			"asks":           [][]string{{a, "0.5"}},
+		}
+	case s.exchange == "coinbase", s.exchange == "bitstamp":
+		m = map[string]string{"bid": b, "ask": a}
+	case s.exchange == "kraken":
+		m = map[string]any{
+			"error":  []string{},
+			"result": map[string]any{"XXBTZUSD": map[string][]string{"a": {a, "1", "1.000"}, "b": {b, "1", "1.000"}}},
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// quote formats a quote in the exchange's ticker message. Bitstamp's is
+// a diff against the previous quote. Caller must hold s.mu.
+func (s *Server) quote(bid, ask float64, now time.Time) []byte {
+	var m any
+	switch s.exchange {
+	case "coinbase":
+		m = map[string]any{
+			"type":       "ticker",
+			"product_id": "BTC-USD",
+			"price":      strconv.FormatFloat((bid+ask)/2, 'f', 2, 64),
+			"best_bid":   strconv.FormatFloat(bid, 'f', 2, 64),
+			"best_ask":   strconv.FormatFloat(ask, 'f', 2, 64),
+			"time":       now.UTC().Format(time.RFC3339Nano),
+		}
+	case "kraken":
+		m = map[string]any{
+			"channel": "ticker",
+			"type":    "update",
+			"data": []map[string]any{{
+				"symbol":    "BTC/USD",
+				"bid":       bid,
+				"ask":       ask,
+				"last":      (bid + ask) / 2,
+				"timestamp": now.UTC().Format(time.RFC3339Nano),
+			}},
+		}
+	case "bitstamp":
+		level := func(prev, p float64) [][]string {
+			out := [][]string{{strconv.FormatFloat(p, 'f', 2, 64), "0.5"}}
+			if prev > 0 && prev != p {
+				out = append([][]string{{strconv.FormatFloat(prev, 'f', 2, 64), "0"}}, out...)
+			}
+			return out
+		}
+		m = map[string]any{
+			"event":   "data",
+			"channel": "diff_order_book_btcusd",
+			"data": map[string]any{
+				"timestamp":      strconv.FormatInt(now.Unix(), 10),
+				"microtimestamp": strconv.FormatInt(now.UnixMicro(), 10),
+				"bids":           level(s.bid, bid),
+				"asks":           level(s.ask, ask),
+			},
+		}
+	}
+	data, _ := json.Marshal(m)
+	return data
+}
+
+// garbage are the malformed frames chaos sends: broken JSON, the right
+// shape with unparseable prices, and an empty book.
+var garbage = map[string][]string{
+	"coinbase": {
+		`{"type":"ticker","best_bid":`,
+		`{"type":"ticker","product_id":"BTC-USD","best_bid":"NaN?","best_ask":"x"}`,
+		`{"type":"error","message":"chaos"}`,
+	},
+	"kraken": {
+		`{"channel":"ticker","data":[`,
+		`{"channel":"ticker","type":"update","data":[{"symbol":"BTC/USD","bid":"x","ask":null}]}`,
+		`{"channel":"ticker","type":"update","data":[]}`,
+	},
+	"bitstamp": {
+		`{"event":"data","data":{"bids":[["`,
+		`{"event":"data","channel":"diff_order_book_btcusd","data":{"bids":[["abc","1"]],"asks":[["def","1"]]}}`,
+		`{"event":"data","channel":"diff_order_book_btcusd","data":{"bids":[],"asks":[]}}`,
+	},
+}
+
+// Quote sends a best bid and ask to every subscribed connection, applying
+// the chaos rates to each.
+func (s *Server) Quote(bid, ask float64) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.quote(bid, ask, now)
+	s.bid, s.ask, s.quotedAt = bid, ask, now
+	for c := range s.conns {
+		if !c.subscribed || now.Before(c.stallUntil) {
+			continue
+		}
+		switch {
+		case s.roll(s.chaos.Disconnect):
+			s.faults.Disconnects++
+			c.ws.Close()
+			continue
+		case s.roll(s.chaos.Stall):
+			s.faults.Stalls++
+			c.stallUntil = now.Add(s.chaos.StallFor)
+			continue
+		case s.roll(s.chaos.Garbage):
+			s.faults.Garbage++
+			frames := garbage[s.exchange]
+			s.write(c, []byte(frames[s.rng.Intn(len(frames))]))
+		}
+		s.write(c, msg)
+	}
+}
+
+// roll reports whether an event with probability p happens. Caller must
+// hold s.mu.
+func (s *Server) roll(p float64) bool {
+	return p > 0 && s.rng.Float64() < p
+}
+
+// write sends one text frame, dropping it if the connection is gone.
+// Caller must hold s.mu, which serializes writes.
+func (s *Server) write(c *conn, data []byte) {
+	c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.ws.WriteMessage(websocket.TextMessage, data)
+}
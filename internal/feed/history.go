@@ -0,0 +1,204 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Bar is one minute of an exchange's BTC-USD trading, from its public REST
+// OHLC endpoint. Start is the beginning of the minute.
+type Bar struct {
+	Start                  time.Time
+	Open, High, Low, Close float64
+	Volume                 float64
+}
+
+// History fetches minute bars from the exchanges' public REST OHLC
+// endpoints, for backfilling stretches the WebSocket feeds missed. Each
+// exchange keeps its own depth: Kraken only serves its last 720 minutes.
+type History struct {
+	client *http.Client
+	bases  map[string]string // REST host overrides, by feed name
+}
+
+// NewHistory returns a History. bases replaces exchanges' REST hosts, by
+// feed name, e.g. with a feedtest server; nil uses the exchanges' own.
+func NewHistory(bases map[string]string) *History {
+	return &History{client: &http.Client{Timeout: 30 * time.Second}, bases: bases}
+}
+
+// historyLimits is how many bars one request returns, by feed.
+var historyLimits = map[string]int{"coinbase": 300, "kraken": 720, "bitstamp": 1000}
+
+// Bars returns feed's minute bars starting in [start, end), oldest first.
+func (h *History) Bars(ctx context.Context, feed string, start, end time.Time) ([]Bar, error) {
+	limit, ok := historyLimits[feed]
+	if !ok {
+		return nil, fmt.Errorf("no REST history for feed %q", feed)
+	}
+	start, end = start.Truncate(time.Minute), end.Truncate(time.Minute)
+	var out []Bar
+	for from := start; from.Before(end); {
+		to := from.Add(time.Duration(limit) * time.Minute)
+		if to.After(end) {
+			to = end
+		}
+		bars, err := h.fetch(ctx, feed, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bars {
+			if !b.Start.Before(from) && b.Start.Before(to) {
+				out = append(out, b)
+			}
+		}
+		if feed == "kraken" {
+			break // one request covers everything Kraken still has
+		}
+		from = to
+	}
+	slices.SortFunc(out, func(a, b Bar) int { return a.Start.Compare(b.Start) })
+	return slices.CompactFunc(out, func(a, b Bar) bool { return a.Start.Equal(b.Start) }), nil
+}
+
+func (h *History) fetch(ctx context.Context, feed string, from, to time.Time) ([]Bar, error) {
+	base := restTickers[feed].base
+	if b := h.bases[feed]; b != "" {
+		base = b
+	}
+	var path string
+	params := url.Values{}
+	switch feed {
+	case "coinbase":
+		path = "/products/BTC-USD/candles"
+		params.Set("granularity", "60")
+		params.Set("start", from.UTC().Format(time.RFC3339))
+		params.Set("end", to.Add(-time.Minute).UTC().Format(time.RFC3339))
+	case "kraken":
+		path = "/0/public/OHLC"
+		params.Set("pair", "XBTUSD")
+		params.Set("interval", "1")
+		params.Set("since", strconv.FormatInt(from.Add(-time.Minute).Unix(), 10))
+	case "bitstamp":
+		path = "/api/v2/ohlc/btcusd/"
+		params.Set("step", "60")
+		params.Set("limit", strconv.Itoa(historyLimits[feed]))
+		params.Set("start", strconv.FormatInt(from.Unix(), 10))
+		params.Set("end", strconv.FormatInt(to.Add(-time.Minute).Unix(), 10))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding %s bars: %w", feed, err)
+	}
+	switch feed {
+	case "coinbase":
+		return parseCoinbaseBars(body)
+	case "kraken":
+		return parseKrakenBars(body)
+	default:
+		return parseBitstampBars(body)
+	}
+}
+
+// parseCoinbaseBars reads [[time, low, high, open, close, volume], ...],
+// newest first.
+func parseCoinbaseBars(body []byte) ([]Bar, error) {
+	var rows [][6]float64
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]Bar, len(rows))
+	for i, r := range rows {
+		out[i] = Bar{Start: time.Unix(int64(r[0]), 0).UTC(), Low: r[1], High: r[2], Open: r[3], Close: r[4], Volume: r[5]}
+	}
+	return out, nil
+}
+
+// parseKrakenBars reads {"error":[],"result":{"XXBTZUSD":[[time, "open",
+// "high", "low", "close", "vwap", "volume", count], ...],"last":...}}.
+func parseKrakenBars(body []byte) ([]Bar, error) {
+	var t struct {
+		Error  []string                   `json:"error"`
+		Result map[string]json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, err
+	}
+	if len(t.Error) > 0 {
+		return nil, fmt.Errorf("kraken: %v", t.Error)
+	}
+	for pair, raw := range t.Result {
+		if pair == "last" {
+			continue
+		}
+		var rows [][]any
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, err
+		}
+		out := make([]Bar, 0, len(rows))
+		for _, r := range rows {
+			if len(r) < 7 {
+				continue
+			}
+			ts, _ := r[0].(float64)
+			b := Bar{Start: time.Unix(int64(ts), 0).UTC()}
+			for j, dst := range []*float64{&b.Open, &b.High, &b.Low, &b.Close, nil, &b.Volume} {
+				if dst == nil {
+					continue
+				}
+				s, _ := r[j+1].(string)
+				*dst, _ = strconv.ParseFloat(s, 64)
+			}
+			out = append(out, b)
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+// parseBitstampBars reads {"data":{"ohlc":[{"timestamp":"...","open":"...",
+// ...}]}}, with every value a string.
+func parseBitstampBars(body []byte) ([]Bar, error) {
+	var t struct {
+		Data struct {
+			OHLC []map[string]string `json:"ohlc"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, err
+	}
+	out := make([]Bar, 0, len(t.Data.OHLC))
+	for _, r := range t.Data.OHLC {
+		ts, err := strconv.ParseInt(r["timestamp"], 10, 64)
+		if err != nil {
+			continue
+		}
+		b := Bar{Start: time.Unix(ts, 0).UTC()}
+		b.Open, _ = strconv.ParseFloat(r["open"], 64)
+		b.High, _ = strconv.ParseFloat(r["high"], 64)
+		b.Low, _ = strconv.ParseFloat(r["low"], 64)
+		b.Close, _ = strconv.ParseFloat(r["close"], 64)
+		b.Volume, _ = strconv.ParseFloat(r["volume"], 64)
+		out = append(out, b)
+	}
+	return out, nil
+}
@@ -29,11 +29,14 @@ type krakenSubParams struct {
 }
 
 func (f *KrakenFeed) Run(ctx context.Context) error {
-	const wsURL = "wss://ws.kraken.com/v2"
+	wsURL := f.endpoint("wss://ws.kraken.com/v2")
 
 	for {
 		if err := f.connect(ctx, wsURL); err != nil {
 			slog.Warn("kraken ws disconnected", "err", err)
+			if ctx.Err() == nil {
+				f.frames.DumpOnError(f.name, err)
+			}
 		}
 
 		select {
@@ -77,6 +80,8 @@ func (f *KrakenFeed) connect(ctx context.Context, wsURL string) error {
 		if err != nil {
 			return err
 		}
+		received := time.Now()
+		f.frames.Add(f.name, msg)
 
 		// Kraken v2 sends: {"channel":"ticker","type":"update","data":[{"symbol":"BTC/USD","bid":...,"ask":...}]}
 		var envelope struct {
@@ -92,8 +97,9 @@ func (f *KrakenFeed) connect(ctx context.Context, wsURL string) error {
 		}
 
 		var ticker struct {
-			Bid float64 `json:"bid"`
-			Ask float64 `json:"ask"`
+			Bid       float64 `json:"bid"`
+			Ask       float64 `json:"ask"`
+			Timestamp string  `json:"timestamp"` // RFC 3339, on newer v2 ticker messages
 		}
 		if err := json.Unmarshal(envelope.Data[0], &ticker); err != nil {
 			continue
@@ -107,5 +113,8 @@ func (f *KrakenFeed) connect(ctx context.Context, wsURL string) error {
 
 		mid := (bid + ask) / 2
 		f.setPrice(mid)
+		if sent, err := time.Parse(time.RFC3339Nano, ticker.Timestamp); err == nil {
+			f.observeLatency(sent, received)
+		}
 	}
 }
@@ -0,0 +1,70 @@
+package feed
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// maxLatencySamples bounds the latencies a feed keeps between reports;
+// past it the oldest are overwritten.
+const maxLatencySamples = 4096
+
+// LatencyReporter is implemented by feeds that measure network latency
+// from the exchange's timestamps on their updates.
+type LatencyReporter interface {
+	// TakeLatency returns the distribution since the previous call and
+	// starts a new one.
+	TakeLatency() ticks.LatencyStats
+}
+
+// observeLatency records an update the exchange stamped at sent and that
+// arrived at received. A zero sent (no timestamp) is ignored.
+func (b *baseFeed) observeLatency(sent, received time.Time) {
+	if sent.IsZero() {
+		return
+	}
+	ms := float64(received.Sub(sent)) / float64(time.Millisecond)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.latencies) < maxLatencySamples {
+		b.latencies = append(b.latencies, ms)
+	} else {
+		b.latencies[b.latencyIdx] = ms
+		b.latencyIdx = (b.latencyIdx + 1) % maxLatencySamples
+	}
+}
+
+func (b *baseFeed) TakeLatency() ticks.LatencyStats {
+	b.mu.Lock()
+	samples := b.latencies
+	b.latencies, b.latencyIdx = nil, 0
+	b.mu.Unlock()
+	return latencyStats(samples)
+}
+
+// latencyStats summarizes samples, sorting them in place. Values are
+// rounded to the microsecond.
+func latencyStats(samples []float64) ticks.LatencyStats {
+	n := len(samples)
+	if n == 0 {
+		return ticks.LatencyStats{}
+	}
+	slices.Sort(samples)
+	sum := 0.0
+	for _, v := range samples {
+		sum += v
+	}
+	us := func(ms float64) float64 { return math.Round(ms*1000) / 1000 }
+	pct := func(p float64) float64 { return us(samples[min(n-1, int(p*float64(n)))]) }
+	return ticks.LatencyStats{
+		Count:  n,
+		MeanMs: us(sum / float64(n)),
+		P50Ms:  pct(0.50),
+		P90Ms:  pct(0.90),
+		P99Ms:  pct(0.99),
+		MaxMs:  us(samples[n-1]),
+	}
+}
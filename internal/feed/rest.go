@@ -0,0 +1,293 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/brti"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Default REST fallback timings: how long a feed's WS may be silent before
+// its exchange's REST ticker is polled, and how often it is polled then.
+const (
+	DefaultRESTAfter = 15 * time.Second
+	DefaultRESTEvery = 5 * time.Second
+)
+
+// Price sources reported by RESTFallback.Source.
+const (
+	SourceWS   = "ws"
+	SourceREST = "rest"
+)
+
+// restTicker is an exchange's public REST ticker for BTC-USD.
+type restTicker struct {
+	base  string
+	path  string
+	parse func(body []byte) (bid, ask float64, err error)
+}
+
+var restTickers = map[string]restTicker{
+	"coinbase": {"https://api.exchange.coinbase.com", "/products/BTC-USD/ticker", parseCoinbaseREST},
+	"kraken":   {"https://api.kraken.com", "/0/public/Ticker?pair=XBTUSD", parseKrakenREST},
+	"bitstamp": {"https://www.bitstamp.net", "/api/v2/ticker/btcusd/", parseBitstampREST},
+}
+
+// RESTFallback keeps a feed priced while its WebSocket is down. Once the
+// WS has been silent for after, the exchange's public REST ticker is
+// polled every every until the WS recovers, so the BRTI proxy keeps its
+// quorum through a single feed's outage. Polled prices count as fresh for
+// two polling intervals.
+type RESTFallback struct {
+	brti.ExchangeFeed
+	after, every time.Duration
+	ticker       restTicker
+	client       *http.Client
+	started      time.Time
+
+	mu         sync.RWMutex
+	price      float64
+	lastUpdate time.Time
+	active     bool // polling; for logging the switch both ways
+}
+
+// WithRESTFallback wraps f, one of the feeds NewByNameURL returns. A feed
+// pointed at another endpoint polls that host's REST ticker, so a feedtest
+// server covers both.
+func WithRESTFallback(f brti.ExchangeFeed, after, every time.Duration) (*RESTFallback, error) {
+	t, ok := restTickers[f.Name()]
+	if !ok {
+		return nil, fmt.Errorf("no REST ticker for feed %q", f.Name())
+	}
+	if e, ok := f.(interface{ restBase() string }); ok {
+		if base := e.restBase(); base != "" {
+			t.base = base
+		}
+	}
+	return &RESTFallback{
+		ExchangeFeed: f,
+		after:        after,
+		every:        every,
+		ticker:       t,
+		client:       &http.Client{Timeout: every},
+	}, nil
+}
+
+// Run runs the WS feed and, alongside it, the REST poller.
+func (r *RESTFallback) Run(ctx context.Context) error {
+	r.started = time.Now()
+	go r.poll(ctx)
+	return r.ExchangeFeed.Run(ctx)
+}
+
+// wsDown reports whether the WS has been silent for longer than after.
+func (r *RESTFallback) wsDown() bool {
+	last := r.ExchangeFeed.LastUpdate()
+	if last.IsZero() {
+		last = r.started
+	}
+	return time.Since(last) > r.after
+}
+
+func (r *RESTFallback) poll(ctx context.Context) {
+	ticker := time.NewTicker(r.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		down := r.wsDown()
+		r.mu.Lock()
+		switched := down != r.active
+		r.active = down
+		r.mu.Unlock()
+		if switched {
+			if down {
+				slog.Warn("feed ws down, polling REST ticker", "feed", r.Name(), "after", r.after)
+			} else {
+				slog.Info("feed ws recovered, REST polling stopped", "feed", r.Name())
+			}
+		}
+		if !down {
+			continue
+		}
+
+		mid, err := r.fetch(ctx)
+		if err != nil {
+			slog.Debug("feed rest fallback failed", "feed", r.Name(), "err", err)
+			continue
+		}
+		r.mu.Lock()
+		r.price = mid
+		r.lastUpdate = time.Now()
+		r.mu.Unlock()
+	}
+}
+
+// fetch returns the mid of the exchange's REST ticker.
+func (r *RESTFallback) fetch(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.ticker.base+r.ticker.path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", r.ticker.path, resp.Status)
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("decoding ticker: %w", err)
+	}
+	bid, ask, err := r.ticker.parse(body)
+	if err != nil {
+		return 0, err
+	}
+	if bid <= 0 || ask <= 0 {
+		return 0, fmt.Errorf("bad quote %v/%v", bid, ask)
+	}
+	return (bid + ask) / 2, nil
+}
+
+// TopOfBook returns the WS feed's book, or a zero Quote if it keeps none.
+func (r *RESTFallback) TopOfBook() Quote {
+	if b, ok := r.ExchangeFeed.(BookFeed); ok {
+		return b.TopOfBook()
+	}
+	return Quote{}
+}
+
+// TakeLatency reports the WS feed's latency; REST polls aren't measured.
+func (r *RESTFallback) TakeLatency() ticks.LatencyStats {
+	if l, ok := r.ExchangeFeed.(LatencyReporter); ok {
+		return l.TakeLatency()
+	}
+	return ticks.LatencyStats{}
+}
+
+// restFresh reports whether a polled price is current while the WS is
+// down.
+func (r *RESTFallback) restFresh() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active && !r.lastUpdate.IsZero() && time.Since(r.lastUpdate) <= 2*r.every
+}
+
+// Source returns SourceREST while prices come from the REST ticker, and
+// SourceWS otherwise.
+func (r *RESTFallback) Source() string {
+	if r.ExchangeFeed.IsStale() && r.restFresh() {
+		return SourceREST
+	}
+	return SourceWS
+}
+
+func (r *RESTFallback) MidPrice() float64 {
+	if r.Source() == SourceREST {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.price
+	}
+	return r.ExchangeFeed.MidPrice()
+}
+
+func (r *RESTFallback) LastUpdate() time.Time {
+	if r.Source() == SourceREST {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.lastUpdate
+	}
+	return r.ExchangeFeed.LastUpdate()
+}
+
+func (r *RESTFallback) IsStale() bool {
+	return r.ExchangeFeed.IsStale() && !r.restFresh()
+}
+
+// restBase is the REST host matching an overridden WS endpoint: the same
+// host over http(s). Empty when the exchange's own endpoint is used.
+func (b *baseFeed) restBase() string {
+	if b.url == "" {
+		return ""
+	}
+	u := b.url
+	if rest, ok := strings.CutPrefix(u, "ws"); ok {
+		u = "http" + rest
+	}
+	if i := strings.Index(u, "://"); i >= 0 {
+		if j := strings.IndexByte(u[i+3:], '/'); j >= 0 {
+			u = u[:i+3+j]
+		}
+	}
+	return u
+}
+
+func parseCoinbaseREST(body []byte) (bid, ask float64, err error) {
+	var t struct {
+		Bid string `json:"bid"`
+		Ask string `json:"ask"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return 0, 0, err
+	}
+	return parseQuote(t.Bid, t.Ask)
+}
+
+// parseKrakenREST reads {"error":[],"result":{"XXBTZUSD":{"a":[price,...],"b":[price,...]}}}.
+func parseKrakenREST(body []byte) (bid, ask float64, err error) {
+	var t struct {
+		Error  []string `json:"error"`
+		Result map[string]struct {
+			A []string `json:"a"`
+			B []string `json:"b"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return 0, 0, err
+	}
+	if len(t.Error) > 0 {
+		return 0, 0, fmt.Errorf("kraken: %s", strings.Join(t.Error, "; "))
+	}
+	for _, pair := range t.Result {
+		if len(pair.A) == 0 || len(pair.B) == 0 {
+			break
+		}
+		return parseQuote(pair.B[0], pair.A[0])
+	}
+	return 0, 0, fmt.Errorf("kraken: no quote in ticker")
+}
+
+func parseBitstampREST(body []byte) (bid, ask float64, err error) {
+	var t struct {
+		Bid string `json:"bid"`
+		Ask string `json:"ask"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return 0, 0, err
+	}
+	return parseQuote(t.Bid, t.Ask)
+}
+
+func parseQuote(bidStr, askStr string) (bid, ask float64, err error) {
+	if bid, err = strconv.ParseFloat(bidStr, 64); err != nil {
+		return 0, 0, fmt.Errorf("bid %q: %w", bidStr, err)
+	}
+	if ask, err = strconv.ParseFloat(askStr, 64); err != nil {
+		return 0, 0, fmt.Errorf("ask %q: %w", askStr, err)
+	}
+	return bid, ask, nil
+}
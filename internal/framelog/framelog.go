@@ -0,0 +1,151 @@
+// Package framelog keeps the most recent raw WebSocket frames from the
+// Kalshi and exchange feeds in a bounded in-memory ring, and writes them
+// to disk when a feed fails or on request. It is meant for debugging
+// protocol changes without running debug logging all the time.
+package framelog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DumpEvery is the least time between two dumps triggered by errors, so
+// a feed stuck in a reconnect loop doesn't fill the disk. Dump itself is
+// not throttled.
+const DumpEvery = time.Minute
+
+type frame struct {
+	time   time.Time
+	source string
+	data   []byte
+}
+
+// Ring holds raw frames up to a total size, dropping the oldest first. A
+// nil *Ring is valid and records nothing, so feeds call it unconditionally.
+type Ring struct {
+	maxBytes int
+	dir      string
+
+	mu       sync.Mutex
+	frames   []frame
+	head     int // index of the oldest frame in frames
+	size     int // bytes held
+	lastDump time.Time
+}
+
+// New returns a Ring keeping up to maxBytes of frames and dumping them
+// into dir.
+func New(maxBytes int, dir string) *Ring {
+	return &Ring{maxBytes: maxBytes, dir: dir}
+}
+
+// Add records a frame received from source, e.g. "kalshi" or "coinbase".
+// data is kept, not copied; callers must not reuse it.
+func (r *Ring) Add(source string, data []byte) {
+	if r == nil || len(data) > r.maxBytes {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, frame{time: time.Now(), source: source, data: data})
+	r.size += len(data)
+	for r.size > r.maxBytes {
+		r.size -= len(r.frames[r.head].data)
+		r.frames[r.head] = frame{}
+		r.head++
+	}
+	// Compact once the dropped frames outnumber the live ones, so the
+	// backing array doesn't grow without bound.
+	if r.head > len(r.frames)/2 {
+		n := copy(r.frames, r.frames[r.head:])
+		clear(r.frames[n:])
+		r.frames = r.frames[:n]
+		r.head = 0
+	}
+}
+
+// Dump writes the frames held to a gzipped JSONL file in the ring's
+// directory, oldest first, and returns its path. Each line is
+// {"ts":...,"source":...,"frame":...}, with the frame inline when it is
+// JSON and as a string otherwise. The ring keeps its frames.
+func (r *Ring) Dump(reason string) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("frame capture not enabled")
+	}
+	r.mu.Lock()
+	frames := make([]frame, len(r.frames)-r.head)
+	copy(frames, r.frames[r.head:])
+	r.lastDump = time.Now()
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("frames-%s-%s.jsonl.gz", time.Now().UTC().Format("20060102T150405.000Z"), slug(reason))
+	path := filepath.Join(r.dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, fr := range frames {
+		line := struct {
+			Ts     string `json:"ts"`
+			Source string `json:"source"`
+			Frame  any    `json:"frame"`
+		}{Ts: fr.time.UTC().Format(time.RFC3339Nano), Source: fr.source, Frame: string(fr.data)}
+		if json.Valid(fr.data) {
+			line.Frame = json.RawMessage(fr.data)
+		}
+		if err := enc.Encode(line); err != nil {
+			f.Close()
+			return "", fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// DumpOnError dumps the ring after source failed with err, unless another
+// dump happened within DumpEvery.
+func (r *Ring) DumpOnError(source string, err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.mu.Lock()
+	recent := time.Since(r.lastDump) < DumpEvery
+	r.mu.Unlock()
+	if recent {
+		return
+	}
+	path, derr := r.Dump(source + "-error")
+	if derr != nil {
+		slog.Warn("frame dump failed", "source", source, "err", derr)
+		return
+	}
+	slog.Info("frames dumped", "source", source, "reason", err, "path", path)
+}
+
+// slug makes reason safe for a file name.
+func slug(reason string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+			return c
+		}
+		return '_'
+	}, reason)
+}
@@ -0,0 +1,32 @@
+package kalshi
+
+import (
+	"context"
+	"time"
+)
+
+// API is the Kalshi REST surface the collector, tradelog and retrofit use.
+// *Client implements it against the exchange; kalshitest.Fake implements
+// it in memory.
+type API interface {
+	GetMarkets(ctx context.Context, seriesTicker string, status string) ([]Market, error)
+	GetMarket(ctx context.Context, ticker string) (*Market, error)
+	GetEventMarkets(ctx context.Context, eventTicker string) ([]Market, error)
+	GetSeriesList(ctx context.Context, category string) ([]Series, error)
+	GetMarketCandlesticks(ctx context.Context, series, ticker string, start, end time.Time, period time.Duration) ([]Candlestick, error)
+	GetBalance(ctx context.Context) (*Balance, error)
+
+	GetOrders(ctx context.Context, p OrderParams) ([]Order, string, error)
+	GetOpenOrders(ctx context.Context, ticker string) ([]Order, error)
+	CreateOrder(ctx context.Context, o CreateOrderRequest) (*Order, error)
+	CancelOrder(ctx context.Context, orderID string) (*Order, error)
+
+	GetPositions(ctx context.Context, p PositionParams) ([]MarketPosition, string, error)
+	GetFills(ctx context.Context, p FillParams) ([]Fill, string, error)
+	GetSettlements(ctx context.Context, p SettlementParams) ([]Settlement, string, error)
+
+	GetExchangeStatus(ctx context.Context) (*ExchangeStatus, ClockSample, error)
+	GetExchangeSchedule(ctx context.Context) (*ExchangeSchedule, error)
+}
+
+var _ API = (*Client)(nil)
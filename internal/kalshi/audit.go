@@ -0,0 +1,50 @@
+package kalshi
+
+import "time"
+
+// AuditRecord describes one logical REST call, including any retries.
+type AuditRecord struct {
+	Type      string `json:"type"`
+	Ts        string `json:"ts"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"` // 0 if no response was received
+	LatencyMS int64  `json:"latency_ms"`
+	Retries   int    `json:"retries"`
+	Err       string `json:"err,omitempty"`
+}
+
+// AuditSink receives a record for every REST call made by a Client.
+// Implementations must be safe for concurrent use.
+type AuditSink interface {
+	RecordCall(AuditRecord)
+}
+
+// AuditFunc adapts a plain function to AuditSink.
+type AuditFunc func(AuditRecord)
+
+func (f AuditFunc) RecordCall(r AuditRecord) { f(r) }
+
+// SetAuditSink enables call auditing. Pass nil to disable.
+func (c *Client) SetAuditSink(s AuditSink) {
+	c.audit = s
+}
+
+func (c *Client) recordCall(method, path string, start time.Time, status, retries int, err error) {
+	if c.audit == nil {
+		return
+	}
+	rec := AuditRecord{
+		Type:      "api_call",
+		Ts:        start.UTC().Format(time.RFC3339Nano),
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Retries:   retries,
+	}
+	if err != nil {
+		rec.Err = err.Error()
+	}
+	c.audit.RecordCall(rec)
+}
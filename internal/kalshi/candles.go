@@ -0,0 +1,58 @@
+package kalshi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Candlestick is one bar of GET /series/{series}/markets/{ticker}/candlesticks.
+// Prices are in cents.
+type Candlestick struct {
+	EndPeriodTs  int64       `json:"end_period_ts"`
+	YesBid       CandleOHLC  `json:"yes_bid"`
+	YesAsk       CandleOHLC  `json:"yes_ask"`
+	Price        CandlePrice `json:"price"`
+	Volume       int         `json:"volume"`
+	OpenInterest int         `json:"open_interest"`
+}
+
+// CandleOHLC is a quote's open, high, low and close over a bar.
+type CandleOHLC struct {
+	Open  int `json:"open"`
+	High  int `json:"high"`
+	Low   int `json:"low"`
+	Close int `json:"close"`
+}
+
+// CandlePrice is the traded price over a bar. Its fields are null in bars
+// without trades, except Previous, the last trade before the bar.
+type CandlePrice struct {
+	Open     *int `json:"open"`
+	High     *int `json:"high"`
+	Low      *int `json:"low"`
+	Close    *int `json:"close"`
+	Mean     *int `json:"mean"`
+	Previous *int `json:"previous"`
+}
+
+// GetMarketCandlesticks fetches ticker's bars of period (a minute, an hour
+// or a day) ending between start and end. series is the ticker's series.
+func (c *Client) GetMarketCandlesticks(ctx context.Context, series, ticker string, start, end time.Time, period time.Duration) ([]Candlestick, error) {
+	params := url.Values{}
+	params.Set("start_ts", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end_ts", strconv.FormatInt(end.Unix(), 10))
+	params.Set("period_interval", strconv.Itoa(int(period/time.Minute)))
+
+	var result struct {
+		Ticker       string        `json:"ticker"`
+		Candlesticks []Candlestick `json:"candlesticks"`
+	}
+	path := fmt.Sprintf("/series/%s/markets/%s/candlesticks", url.PathEscape(series), url.PathEscape(ticker))
+	if err := c.get(ctx, path, params, &result); err != nil {
+		return nil, err
+	}
+	return result.Candlesticks, nil
+}
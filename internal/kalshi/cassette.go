@@ -0,0 +1,183 @@
+package kalshi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// A cassette is a JSONL file of REST exchanges, one per line, captured by
+// a Recorder and served back by a Replayer. Requests are keyed by method,
+// path and query; auth headers are never written.
+
+// cassetteEntry is one recorded request and its response.
+type cassetteEntry struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"` // path and query, without the host
+	Body    string            `json:"body,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Resp    json.RawMessage   `json:"response,omitempty"`
+	RawResp string            `json:"raw_response,omitempty"` // when the body isn't JSON
+}
+
+// recordedHeaders are the response headers the client reads.
+var recordedHeaders = []string{"Date", "Retry-After", "Content-Type"}
+
+func cassetteKey(method, url string) string { return method + " " + url }
+
+// Recorder is an http.RoundTripper that passes requests to Next and
+// appends each exchange to a cassette file.
+type Recorder struct {
+	Next http.RoundTripper
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewRecorder appends exchanges made through next (nil for the default
+// transport) to the cassette at path.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening cassette: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{Next: next, f: f}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := cassetteEntry{Method: req.Method, URL: req.URL.RequestURI()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		e.Body = string(body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e.Status = resp.StatusCode
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			if e.Headers == nil {
+				e.Headers = make(map[string]string)
+			}
+			e.Headers[h] = v
+		}
+	}
+	if json.Valid(body) {
+		e.Resp = body
+	} else {
+		e.RawResp = string(body)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return resp, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.f.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("writing cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// Close closes the cassette file.
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+// Replayer is an http.RoundTripper that answers from a cassette without
+// touching the network. Repeated requests get the recorded responses in
+// order, then the last one again; unrecorded requests get a 404.
+type Replayer struct {
+	mu      sync.Mutex
+	entries map[string][]cassetteEntry
+	next    map[string]int
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening cassette: %w", err)
+	}
+	defer f.Close()
+
+	r := &Replayer{entries: make(map[string][]cassetteEntry), next: make(map[string]int)}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e cassetteEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("cassette line %d: %w", line, err)
+		}
+		k := cassetteKey(e.Method, e.URL)
+		r.entries[k] = append(r.entries[k], e)
+	}
+	return r, s.Err()
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	k := cassetteKey(req.Method, req.URL.RequestURI())
+
+	r.mu.Lock()
+	entries := r.entries[k]
+	i := r.next[k]
+	if i < len(entries)-1 {
+		r.next[k]++
+	}
+	r.mu.Unlock()
+
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	if len(entries) == 0 {
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = io.NopCloser(bytes.NewReader([]byte(`{"error":{"code":"not_recorded","message":"` + k + ` is not in the cassette"}}`)))
+		return resp, nil
+	}
+	e := entries[i]
+	resp.StatusCode = e.Status
+	for h, v := range e.Headers {
+		resp.Header.Set(h, v)
+	}
+	body := []byte(e.Resp)
+	if e.RawResp != "" {
+		body = []byte(e.RawResp)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
@@ -1,16 +1,18 @@
 package kalshi
 
 import (
+	"bytes"
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gw/btc15m-data/internal/config"
@@ -22,11 +24,33 @@ type Client struct {
 	http           *http.Client
 	baseURL        string
 	basePathPrefix string
+	audit          AuditSink
+	drift          *DriftDetector // nil when drift detection is off
 }
 
+// NewClient returns a client for cfg's environment. With
+// KALSHI_HTTP_RECORD set, every REST exchange is appended to that cassette;
+// with KALSHI_HTTP_REPLAY set, requests are answered from one instead of
+// the network, and the private key is optional.
 func NewClient(cfg *config.Config) (*Client, error) {
+	hc := &http.Client{Timeout: 10 * time.Second}
+	switch {
+	case cfg.KalshiHTTPReplay != "":
+		rp, err := NewReplayer(cfg.KalshiHTTPReplay)
+		if err != nil {
+			return nil, err
+		}
+		hc.Transport = rp
+	case cfg.KalshiHTTPRecord != "":
+		rec, err := NewRecorder(cfg.KalshiHTTPRecord, nil)
+		if err != nil {
+			return nil, err
+		}
+		hc.Transport = rec
+	}
+
 	key, err := LoadPrivateKey(cfg.KalshiPrivKeyPath)
-	if err != nil {
+	if err != nil && cfg.KalshiHTTPReplay == "" {
 		return nil, fmt.Errorf("loading kalshi key: %w", err)
 	}
 
@@ -38,12 +62,28 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	return &Client{
 		cfg:            cfg,
 		privKey:        key,
-		http:           &http.Client{Timeout: 10 * time.Second},
+		http:           hc,
 		baseURL:        cfg.BaseURL(),
 		basePathPrefix: parsed.Path,
 	}, nil
 }
 
+// NewTestClient returns a client without credentials that sends unsigned
+// requests for baseURL (e.g. an httptest server or "http://kalshi" with a
+// Replayer) through rt.
+func NewTestClient(baseURL string, rt http.RoundTripper) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base URL: %w", err)
+	}
+	return &Client{
+		cfg:            &config.Config{},
+		http:           &http.Client{Timeout: 10 * time.Second, Transport: rt},
+		baseURL:        baseURL,
+		basePathPrefix: parsed.Path,
+	}, nil
+}
+
 func (c *Client) PrivateKey() *rsa.PrivateKey { return c.privKey }
 
 func (c *Client) signPath(path string) string {
@@ -64,6 +104,7 @@ type Market struct {
 	LastPrice              int     `json:"last_price"`
 	Volume                 int     `json:"volume"`
 	OpenInterest           int     `json:"open_interest"`
+	StrikeType             string  `json:"strike_type"` // greater, less, between, custom, ...
 	FloorStrike            float64 `json:"floor_strike"`
 	CapStrike              float64 `json:"cap_strike"`
 	CloseTime              string  `json:"close_time"`
@@ -71,35 +112,19 @@ type Market struct {
 	ExpirationTime         string  `json:"expiration_time"`
 	ExpectedExpirationTime string  `json:"expected_expiration_time"`
 	Result                 string  `json:"result"`
+	ExpirationValue        string  `json:"expiration_value"` // the index print settled on, once determined
 	Subtitle               string  `json:"subtitle"`
 	YesSubTitle            string  `json:"yes_sub_title"`
 	NoSubTitle             string  `json:"no_sub_title"`
-	CustomStrike           string  `json:"custom_strike"`
+	CustomStrike           json.RawMessage `json:"custom_strike"` // an object; see StrikeRange
 	RulesPrimary           string  `json:"rules_primary"`
 }
 
+// StrikePrice is the market's headline strike: the cap, else the floor,
+// from ParseStrike. A range market has both bounds; use StrikeRange or
+// ParseStrike for those.
 func (m *Market) StrikePrice() float64 {
-	if m.CapStrike > 0 {
-		return m.CapStrike
-	}
-	if m.FloorStrike > 0 {
-		return m.FloorStrike
-	}
-
-	if m.RulesPrimary != "" {
-		var strike float64
-		if _, err := fmt.Sscanf(m.RulesPrimary, "%*s at least %f", &strike); err == nil && strike > 0 {
-			return strike
-		}
-		re := regexp.MustCompile(`is at least ([\d.]+)`)
-		if matches := re.FindStringSubmatch(m.RulesPrimary); len(matches) > 1 {
-			if strike, err := strconv.ParseFloat(matches[1], 64); err == nil {
-				return strike
-			}
-		}
-	}
-
-	return 0
+	return m.ParseStrike().Value()
 }
 
 func (m *Market) ExpirationParsed() (time.Time, error) {
@@ -109,6 +134,17 @@ func (m *Market) ExpirationParsed() (time.Time, error) {
 	return time.Parse(time.RFC3339, m.ExpirationTime)
 }
 
+// SettlementValue parses expiration_value, the index value Kalshi settled
+// the market on. It is false until the market is determined, or if the
+// value isn't a number.
+func (m *Market) SettlementValue() (float64, bool) {
+	if m.ExpirationValue == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(m.ExpirationValue, ",", ""), 64)
+	return v, err == nil
+}
+
 type Balance struct {
 	Balance int `json:"balance"`
 }
@@ -153,6 +189,33 @@ type Settlement struct {
 	YesCost         int    `json:"yes_cost"`
 	Revenue         int    `json:"revenue"`
 	SettledTime     string `json:"settled_time"`
+	FeeCost         string `json:"fee_cost"` // dollars, e.g. "0.3400"
+}
+
+// MarketPosition is the portfolio's holding in one market. Position is
+// positive for YES contracts and negative for NO contracts.
+type MarketPosition struct {
+	Ticker             string `json:"ticker"`
+	Position           int    `json:"position"`
+	MarketExposure     int    `json:"market_exposure"`
+	RealizedPnL        int    `json:"realized_pnl"`
+	RestingOrdersCount int    `json:"resting_orders_count"`
+	FeesPaid           int    `json:"fees_paid"`
+}
+
+// CreateOrderRequest is the body of a new order. Set YesPrice or NoPrice
+// to match Side for limit orders.
+type CreateOrderRequest struct {
+	Ticker            string `json:"ticker"`
+	ClientOrderID     string `json:"client_order_id"`
+	Action            string `json:"action"` // "buy" or "sell"
+	Side              string `json:"side"`   // "yes" or "no"
+	Type              string `json:"type"`   // "limit" or "market"
+	Count             int    `json:"count"`
+	YesPrice          int    `json:"yes_price,omitempty"`
+	NoPrice           int    `json:"no_price,omitempty"`
+	TimeInForce       string `json:"time_in_force,omitempty"` // e.g. "immediate_or_cancel"
+	SellPositionFloor *int   `json:"sell_position_floor,omitempty"`
 }
 
 // --- API Methods ---
@@ -188,6 +251,21 @@ func (c *Client) GetMarket(ctx context.Context, ticker string) (*Market, error)
 	return &result.Market, nil
 }
 
+// GetEventMarkets returns every market of one event, whatever its status.
+func (c *Client) GetEventMarkets(ctx context.Context, eventTicker string) ([]Market, error) {
+	params := url.Values{}
+	params.Set("event_ticker", eventTicker)
+	params.Set("limit", "200")
+
+	var result struct {
+		Markets []Market `json:"markets"`
+	}
+	if err := c.get(ctx, "/markets", params, &result); err != nil {
+		return nil, err
+	}
+	return result.Markets, nil
+}
+
 func (c *Client) GetBalance(ctx context.Context) (*Balance, error) {
 	var result Balance
 	if err := c.get(ctx, "/portfolio/balance", nil, &result); err != nil {
@@ -226,6 +304,79 @@ func (c *Client) GetOrders(ctx context.Context, p OrderParams) ([]Order, string,
 	return result.Orders, result.Cursor, nil
 }
 
+// GetOpenOrders returns every resting order, optionally for one ticker.
+func (c *Client) GetOpenOrders(ctx context.Context, ticker string) ([]Order, error) {
+	var all []Order
+	var cursor string
+	for {
+		orders, next, err := c.GetOrders(ctx, OrderParams{Ticker: ticker, Status: "resting", Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, orders...)
+		if next == "" || len(orders) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+// CancelOrder cancels a resting order and returns its final state.
+func (c *Client) CancelOrder(ctx context.Context, orderID string) (*Order, error) {
+	var result struct {
+		Order     Order `json:"order"`
+		ReducedBy int   `json:"reduced_by"`
+	}
+	path := fmt.Sprintf("/portfolio/orders/%s", url.PathEscape(orderID))
+	if err := c.delete(ctx, path, &result); err != nil {
+		return nil, err
+	}
+	return &result.Order, nil
+}
+
+// CreateOrder submits a new order and returns it as accepted.
+func (c *Client) CreateOrder(ctx context.Context, o CreateOrderRequest) (*Order, error) {
+	body, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Order Order `json:"order"`
+	}
+	if err := c.post(ctx, "/portfolio/orders", body, &result); err != nil {
+		return nil, err
+	}
+	return &result.Order, nil
+}
+
+// PositionParams specifies filters for GetPositions.
+type PositionParams struct {
+	Ticker string
+	Cursor string
+}
+
+// GetPositions returns market positions with a non-zero holding.
+func (c *Client) GetPositions(ctx context.Context, p PositionParams) ([]MarketPosition, string, error) {
+	params := url.Values{}
+	params.Set("limit", "200")
+	params.Set("count_filter", "position")
+	if p.Ticker != "" {
+		params.Set("ticker", p.Ticker)
+	}
+	if p.Cursor != "" {
+		params.Set("cursor", p.Cursor)
+	}
+
+	var result struct {
+		MarketPositions []MarketPosition `json:"market_positions"`
+		Cursor          string           `json:"cursor"`
+	}
+	if err := c.get(ctx, "/portfolio/positions", params, &result); err != nil {
+		return nil, "", err
+	}
+	return result.MarketPositions, result.Cursor, nil
+}
+
 // FillParams specifies filters for GetFills.
 type FillParams struct {
 	Ticker string
@@ -276,53 +427,113 @@ func (c *Client) GetSettlements(ctx context.Context, p SettlementParams) ([]Sett
 
 // --- HTTP helpers ---
 
+// Rate-limited GETs are retried a couple of times, honoring Retry-After
+// up to maxRetryWait so a single call can't stall a 1s tick for long.
+const (
+	maxRetries   = 2
+	maxRetryWait = 5 * time.Second
+)
+
 func (c *Client) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	return c.send(ctx, "GET", path, params, nil, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, body []byte, out interface{}) error {
+	return c.send(ctx, "POST", path, nil, body, out)
+}
+
+func (c *Client) delete(ctx context.Context, path string, out interface{}) error {
+	return c.send(ctx, "DELETE", path, nil, nil, out)
+}
+
+// send performs one API call. Rate-limited responses are safe to retry for
+// any method since Kalshi rejects them before acting on the request.
+func (c *Client) send(ctx context.Context, method, path string, params url.Values, body []byte, out interface{}) error {
 	reqURL := c.baseURL + path
 	if params != nil && len(params) > 0 {
 		reqURL += "?" + params.Encode()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
-	if err != nil {
-		return err
+	start := time.Now()
+	var status, retries int
+	var err error
+	for {
+		status, err = c.sendOnce(ctx, method, reqURL, path, body, out)
+		if !errors.Is(err, ErrRateLimited) || retries >= maxRetries {
+			break
+		}
+		wait := RetryAfter(err)
+		if wait == 0 {
+			wait = time.Second
+		}
+		if wait > maxRetryWait {
+			wait = maxRetryWait
+		}
+		select {
+		case <-ctx.Done():
+			c.recordCall(method, path, start, status, retries, ctx.Err())
+			return err
+		case <-time.After(wait):
+		}
+		retries++
 	}
 
-	headers, err := AuthHeaders(c.cfg, c.privKey, "GET", c.signPath(path))
+	c.recordCall(method, path, start, status, retries, err)
+	return err
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, reqURL, path string, body []byte, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reader)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	for k, v := range headers {
-		req.Header.Set(k, v)
+
+	if c.privKey != nil {
+		headers, err := AuthHeaders(c.cfg, c.privKey, method, c.signPath(path))
+		if err != nil {
+			return 0, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
 	}
 	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	return c.doRequest(req, out)
 }
 
-func (c *Client) doRequest(req *http.Request, out interface{}) error {
+func (c *Client) doRequest(req *http.Request, out interface{}) (int, error) {
 	slog.Debug("kalshi request", "method", req.Method, "url", req.URL.String())
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return fmt.Errorf("kalshi request failed: %w", err)
+		return 0, fmt.Errorf("kalshi request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("reading response: %w", err)
+		return resp.StatusCode, fmt.Errorf("reading response: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
 		slog.Error("kalshi API error", "status", resp.StatusCode, "body", string(body))
-		return fmt.Errorf("kalshi API error %d: %s", resp.StatusCode, string(body))
+		return resp.StatusCode, newAPIError(resp, body)
 	}
 
 	if out != nil {
 		if err := json.Unmarshal(body, out); err != nil {
-			return fmt.Errorf("decoding response: %w (body: %s)", err, string(body))
+			return resp.StatusCode, fmt.Errorf("decoding response: %w (body: %s)", err, string(body))
 		}
+		c.drift.Check(endpointOf(req.Method, strings.TrimPrefix(req.URL.Path, c.basePathPrefix)), body, out)
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
@@ -0,0 +1,297 @@
+package kalshi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDriftEvery is how often each endpoint or WS message type is
+// checked for schema drift.
+const DefaultDriftEvery = time.Minute
+
+// DriftDetector watches Kalshi REST responses and WS messages for changes
+// to their shape: fields that appear, fields that change JSON type, and
+// fields the client decodes that stop being sent. The last of these is the
+// dangerous one, since the decoded value silently becomes zero.
+//
+// What a payload looked like is kept as a baseline, one set of field paths
+// (e.g. "markets[].yes_bid") with their JSON types per endpoint, persisted
+// to a file so drift that happens between runs is caught too. The first
+// payload seen for an endpoint only sets its baseline. Each change is
+// logged once per process and counted; see Counts.
+type DriftDetector struct {
+	path  string // baseline file; "" keeps it in memory
+	every time.Duration
+
+	mu       sync.Mutex
+	base     map[string]map[string]string // endpoint → field path → JSON type
+	checked  map[string]time.Time         // endpoint → last check
+	reported map[string]bool              // endpoint + path + change, logged already
+	counts   map[string]int64             // endpoint → changes found
+}
+
+// NewDriftDetector returns a detector checking each endpoint at most once
+// per every, keeping its baseline in path (created if missing; "" for
+// none).
+func NewDriftDetector(path string, every time.Duration) (*DriftDetector, error) {
+	d := &DriftDetector{
+		path:     path,
+		every:    every,
+		base:     make(map[string]map[string]string),
+		checked:  make(map[string]time.Time),
+		reported: make(map[string]bool),
+		counts:   make(map[string]int64),
+	}
+	if path == "" {
+		return d, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.base); err != nil {
+		return nil, fmt.Errorf("reading schema baseline %s: %w", path, err)
+	}
+	return d, nil
+}
+
+// SetDriftDetector checks every decoded response with d. Pass nil to
+// disable.
+func (c *Client) SetDriftDetector(d *DriftDetector) {
+	c.drift = d
+}
+
+// Counts returns the number of changes found so far, by endpoint.
+func (d *DriftDetector) Counts() map[string]int64 {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]int64, len(d.counts))
+	for k, v := range d.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Check compares raw, a payload from endpoint, with the endpoint's
+// baseline. into is the type the payload is decoded into (a pointer or
+// value); its fields are the ones reported when they go missing. Checks
+// within the detector's interval of the last for endpoint are skipped.
+func (d *DriftDetector) Check(endpoint string, raw []byte, into any) {
+	if d == nil {
+		return
+	}
+	now := time.Now()
+	d.mu.Lock()
+	if now.Sub(d.checked[endpoint]) < d.every {
+		d.mu.Unlock()
+		return
+	}
+	d.checked[endpoint] = now
+	d.mu.Unlock()
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return
+	}
+	seen := make(map[string]string)
+	flatten("", v, seen)
+	declared := make(map[string]bool)
+	declaredPaths(reflect.TypeOf(into), "", declared, 0)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	base, known := d.base[endpoint]
+	if !known {
+		d.base[endpoint] = seen
+		d.saveLocked()
+		return
+	}
+
+	changed := false
+	for _, path := range sortedKeys(seen) {
+		kind, old := seen[path], base[path]
+		switch {
+		case old == "":
+			d.reportLocked(endpoint, path, "added", kind)
+		case kind == old || kind == "null":
+			continue
+		case old != "null":
+			d.reportLocked(endpoint, path, "type changed", old+" → "+kind)
+		}
+		base[path] = kind
+		changed = true
+	}
+	for _, path := range sortedKeys(declared) {
+		if _, ok := seen[path]; ok || base[path] == "" || base[path] == "null" || strings.HasSuffix(path, "[]") {
+			continue // present, never sent, or the elements of an empty list
+		}
+		if parent := parentPath(path); parent != "" && seen[parent] == "" {
+			continue // nothing to hold it in this payload, e.g. an empty list
+		}
+		d.reportLocked(endpoint, path, "missing", base[path])
+	}
+	if changed {
+		d.saveLocked()
+	}
+}
+
+// reportLocked logs and counts one change, once per process. Caller holds
+// d.mu.
+func (d *DriftDetector) reportLocked(endpoint, path, change, detail string) {
+	key := endpoint + " " + path + " " + change
+	if d.reported[key] {
+		return
+	}
+	d.reported[key] = true
+	d.counts[endpoint]++
+	slog.Warn("kalshi schema drift", "endpoint", endpoint, "field", path, "change", change, "type", detail)
+}
+
+// saveLocked writes the baseline atomically. Caller holds d.mu.
+func (d *DriftDetector) saveLocked() {
+	if d.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(d.base, "", "  ")
+	if err == nil {
+		tmp := d.path + ".tmp"
+		if err = os.MkdirAll(filepath.Dir(d.path), 0o755); err == nil {
+			if err = os.WriteFile(tmp, data, 0o644); err == nil {
+				err = os.Rename(tmp, d.path)
+			}
+		}
+	}
+	if err != nil {
+		slog.Warn("schema baseline save failed", "path", d.path, "err", err)
+	}
+}
+
+// flatten records the JSON type of every field path in v. Array elements
+// share the path of their array with "[]" appended.
+func flatten(path string, v any, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]any:
+		if path != "" {
+			out[path] = "object"
+		}
+		for k, child := range t {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, child, out)
+		}
+	case []any:
+		out[path] = "array"
+		for _, child := range t {
+			flatten(path+"[]", child, out)
+		}
+	case string:
+		setKind(out, path, "string")
+	case float64:
+		setKind(out, path, "number")
+	case bool:
+		setKind(out, path, "bool")
+	case nil:
+		setKind(out, path, "null")
+	}
+}
+
+// setKind records kind for path unless an array element already gave it a
+// non-null type.
+func setKind(out map[string]string, path, kind string) {
+	if old, ok := out[path]; !ok || old == "null" {
+		out[path] = kind
+	}
+}
+
+// declaredPaths collects the field paths t decodes, following its json
+// tags. Raw and interface fields accept anything below them.
+func declaredPaths(t reflect.Type, path string, out map[string]bool, depth int) {
+	if t == nil || depth > 8 {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(json.RawMessage(nil)) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			p := name
+			if path != "" {
+				p = path + "." + name
+			}
+			out[p] = true
+			declaredPaths(f.Type, p, out, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		out[path+"[]"] = true
+		declaredPaths(t.Elem(), path+"[]", out, depth+1)
+	}
+}
+
+// parentPath is the path holding path: "markets[]" for
+// "markets[].yes_bid", "" at the top level.
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "[]") {
+		return strings.TrimSuffix(path, "[]")
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// endpointOf names a REST call for drift tracking, with the path's ticker
+// and id segments replaced, e.g. "GET /markets/{id}".
+func endpointOf(method, path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		if s != strings.ToLower(s) || strings.ContainsAny(s, "0123456789") {
+			segs[i] = "{id}"
+		}
+	}
+	return method + " " + strings.Join(segs, "/")
+}
@@ -0,0 +1,80 @@
+package kalshi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error classes for Kalshi REST failures. Match with errors.Is.
+var (
+	ErrRateLimited  = errors.New("kalshi: rate limited")
+	ErrUnauthorized = errors.New("kalshi: unauthorized")
+	ErrMaintenance  = errors.New("kalshi: exchange unavailable (maintenance)")
+	ErrNotFound     = errors.New("kalshi: not found")
+)
+
+// APIError is returned for any non-2xx REST response.
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // zero if the server did not send Retry-After
+
+	class error // one of the Err* sentinels, or nil
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kalshi API error %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error { return e.class }
+
+// RetryAfter returns the server-requested backoff carried by err, or 0.
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.class = ErrRateLimited
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.class = ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		e.class = ErrNotFound
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		e.class = ErrMaintenance
+	case resp.StatusCode >= 500 && strings.Contains(strings.ToLower(e.Body), "maintenance"):
+		e.class = ErrMaintenance
+	}
+	return e
+}
+
+// parseRetryAfter handles both delta-seconds and HTTP-date forms.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package kalshi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ExchangeStatus is the body of GET /exchange/status.
+type ExchangeStatus struct {
+	ExchangeActive bool `json:"exchange_active"`
+	TradingActive  bool `json:"trading_active"`
+}
+
+// ClockSample compares Kalshi's clock, from the Date header of a
+// response, with the local clock halfway through the request.
+type ClockSample struct {
+	Server time.Time
+	Local  time.Time
+	RTT    time.Duration
+}
+
+// Skew is how far the server clock is ahead of the local one. The Date
+// header has one-second resolution, so the server reading is taken as
+// the middle of its second; the estimate is good to about ±0.5s plus
+// half the round trip.
+func (s ClockSample) Skew() time.Duration {
+	return s.Server.Add(500 * time.Millisecond).Sub(s.Local)
+}
+
+// GetExchangeStatus fetches the exchange status together with a reading
+// of the server clock.
+func (c *Client) GetExchangeStatus(ctx context.Context) (*ExchangeStatus, ClockSample, error) {
+	const path = "/exchange/status"
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, ClockSample{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		err = fmt.Errorf("kalshi request failed: %w", err)
+		c.recordCall("GET", path, start, 0, 0, err)
+		return nil, ClockSample{}, err
+	}
+	defer resp.Body.Close()
+	rtt := time.Since(start)
+	sample := ClockSample{Local: start.Add(rtt / 2), RTT: rtt}
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode >= 400 {
+		err = newAPIError(resp, body)
+	}
+	var status ExchangeStatus
+	if err == nil {
+		if err = json.Unmarshal(body, &status); err != nil {
+			err = fmt.Errorf("decoding exchange status: %w", err)
+		}
+	}
+	if err == nil {
+		if sample.Server, err = http.ParseTime(resp.Header.Get("Date")); err != nil {
+			err = fmt.Errorf("parsing Date header %q: %w", resp.Header.Get("Date"), err)
+		}
+	}
+	c.recordCall("GET", path, start, resp.StatusCode, 0, err)
+	if err != nil {
+		return nil, ClockSample{}, err
+	}
+	return &status, sample, nil
+}
+
+// MaintenanceWindow is an announced period when the exchange is down.
+type MaintenanceWindow struct {
+	Start time.Time `json:"start_datetime"`
+	End   time.Time `json:"end_datetime"`
+}
+
+// ExchangeSchedule is the part of GET /exchange/schedule the collector
+// uses.
+type ExchangeSchedule struct {
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows"`
+}
+
+// GetExchangeSchedule fetches the announced maintenance windows.
+func (c *Client) GetExchangeSchedule(ctx context.Context) (*ExchangeSchedule, error) {
+	var result struct {
+		Schedule ExchangeSchedule `json:"schedule"`
+	}
+	if err := c.get(ctx, "/exchange/schedule", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.Schedule, nil
+}
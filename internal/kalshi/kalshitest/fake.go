@@ -0,0 +1,361 @@
+// Package kalshitest provides an in-memory kalshi.API for tests and
+// offline runs.
+package kalshitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+)
+
+// Fixtures is the state a Fake serves. Its JSON form uses the field names
+// of Kalshi's own responses, so captured API output can be pasted in.
+type Fixtures struct {
+	Markets     []kalshi.Market         `json:"markets"`
+	SeriesList  []kalshi.Series         `json:"series"`
+	Orders      []kalshi.Order          `json:"orders"`
+	Positions   []kalshi.MarketPosition `json:"market_positions"`
+	Fills       []kalshi.Fill           `json:"fills"`
+	Settlements []kalshi.Settlement     `json:"settlements"`
+	Balance     int                     `json:"balance"`
+	Status      kalshi.ExchangeStatus   `json:"exchange_status"`
+	Schedule    kalshi.ExchangeSchedule `json:"schedule"`
+
+	Candlesticks map[string][]kalshi.Candlestick `json:"candlesticks"` // 1-minute bars by market ticker
+}
+
+// Fake implements kalshi.API from Fixtures. Orders it creates rest until
+// canceled. Set Errors to make a method fail, keyed by its name (e.g.
+// "GetMarkets"); Calls records every method called, in order. Lists are
+// served in one page. A Fake is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	fx     Fixtures
+	Errors map[string]error
+	Calls  []string
+	Clock  func() time.Time // server time for GetExchangeStatus; default time.Now
+	nextID int
+}
+
+var _ kalshi.API = (*Fake)(nil)
+
+// NewFake returns a Fake serving fx, with the exchange open.
+func NewFake(fx Fixtures) *Fake {
+	if fx.Status == (kalshi.ExchangeStatus{}) {
+		fx.Status = kalshi.ExchangeStatus{ExchangeActive: true, TradingActive: true}
+	}
+	return &Fake{fx: fx, Errors: make(map[string]error), Clock: time.Now}
+}
+
+// LoadFake returns a Fake serving the fixtures in a JSON file.
+func LoadFake(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fx Fixtures
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("parsing fixtures %s: %w", path, err)
+	}
+	return NewFake(fx), nil
+}
+
+// Series returns a KXBTC15M-style event: strikes markets a step apart
+// centered on spot, open for trading until close. Its tickers follow
+// Kalshi's format, so EventDate and the settlement rules recognize them.
+func Series(series string, close time.Time, spot, step float64, strikes int) []kalshi.Market {
+	et, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		et = time.UTC
+	}
+	event := fmt.Sprintf("%s-%s", series, strings.ToUpper(close.In(et).Format("06Jan021504")))
+	first := spot - step*float64(strikes/2)
+	var out []kalshi.Market
+	for i := 0; i < strikes; i++ {
+		floor := first + step*float64(i)
+		out = append(out, kalshi.Market{
+			Ticker:         fmt.Sprintf("%s-%02d", event, i),
+			EventTicker:    event,
+			Status:         "active",
+			YesBid:         max(1, 50-(i-strikes/2)*10-1),
+			YesAsk:         min(99, 50-(i-strikes/2)*10+1),
+			StrikeType:     "greater",
+			FloorStrike:    floor,
+			OpenTime:       close.Add(-15 * time.Minute).UTC().Format(time.RFC3339),
+			CloseTime:      close.UTC().Format(time.RFC3339),
+			ExpirationTime: close.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
+// call records method and returns its injected error, if any. The caller
+// holds f.mu.
+func (f *Fake) call(method string) error {
+	f.Calls = append(f.Calls, method)
+	return f.Errors[method]
+}
+
+// AddSeries adds series to those listed, as if Kalshi had launched it.
+func (f *Fake) AddSeries(s kalshi.Series) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fx.SeriesList = append(f.fx.SeriesList, s)
+}
+
+// SetMarkets replaces the markets served.
+func (f *Fake) SetMarkets(markets []kalshi.Market) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fx.Markets = markets
+}
+
+// SetCandlesticks replaces ticker's 1-minute bars.
+func (f *Fake) SetCandlesticks(ticker string, bars []kalshi.Candlestick) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fx.Candlesticks == nil {
+		f.fx.Candlesticks = make(map[string][]kalshi.Candlestick)
+	}
+	f.fx.Candlesticks[ticker] = bars
+}
+
+// AddFill appends a fill, as if an order had traded.
+func (f *Fake) AddFill(fill kalshi.Fill) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fx.Fills = append(f.fx.Fills, fill)
+}
+
+func (f *Fake) GetMarkets(ctx context.Context, seriesTicker string, status string) ([]kalshi.Market, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetMarkets"); err != nil {
+		return nil, err
+	}
+	var out []kalshi.Market
+	for _, m := range f.fx.Markets {
+		if seriesTicker != "" && !strings.HasPrefix(m.Ticker, seriesTicker+"-") {
+			continue
+		}
+		if status != "" && !statusMatches(status, m.Status) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// statusMatches applies the market status filter the way Kalshi does:
+// "open" lists active markets, "closed" closed ones and "settled"
+// settled or finalized ones.
+func statusMatches(filter, status string) bool {
+	switch filter {
+	case "open":
+		return status == "active" || status == "open"
+	case "settled":
+		return status == "settled" || status == "finalized" || status == "determined"
+	}
+	return status == filter
+}
+
+func (f *Fake) GetMarket(ctx context.Context, ticker string) (*kalshi.Market, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetMarket"); err != nil {
+		return nil, err
+	}
+	for _, m := range f.fx.Markets {
+		if m.Ticker == ticker {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("market %s: %w", ticker, kalshi.ErrNotFound)
+}
+
+func (f *Fake) GetEventMarkets(ctx context.Context, eventTicker string) ([]kalshi.Market, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetEventMarkets"); err != nil {
+		return nil, err
+	}
+	var out []kalshi.Market
+	for _, m := range f.fx.Markets {
+		if m.EventTicker == eventTicker {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) GetSeriesList(ctx context.Context, category string) ([]kalshi.Series, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetSeriesList"); err != nil {
+		return nil, err
+	}
+	var out []kalshi.Series
+	for _, s := range f.fx.SeriesList {
+		if category == "" || s.Category == category {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// GetMarketCandlesticks serves ticker's bars ending within [start, end].
+// Only 1-minute bars are kept, so other periods get those too.
+func (f *Fake) GetMarketCandlesticks(ctx context.Context, series, ticker string, start, end time.Time, period time.Duration) ([]kalshi.Candlestick, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetMarketCandlesticks"); err != nil {
+		return nil, err
+	}
+	var out []kalshi.Candlestick
+	for _, b := range f.fx.Candlesticks[ticker] {
+		if b.EndPeriodTs >= start.Unix() && b.EndPeriodTs <= end.Unix() {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) GetBalance(ctx context.Context) (*kalshi.Balance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetBalance"); err != nil {
+		return nil, err
+	}
+	return &kalshi.Balance{Balance: f.fx.Balance}, nil
+}
+
+func (f *Fake) GetOrders(ctx context.Context, p kalshi.OrderParams) ([]kalshi.Order, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetOrders"); err != nil {
+		return nil, "", err
+	}
+	var out []kalshi.Order
+	for _, o := range f.fx.Orders {
+		if (p.Ticker == "" || o.Ticker == p.Ticker) && (p.Status == "" || o.Status == p.Status) {
+			out = append(out, o)
+		}
+	}
+	return out, "", nil
+}
+
+func (f *Fake) GetOpenOrders(ctx context.Context, ticker string) ([]kalshi.Order, error) {
+	orders, _, err := f.GetOrders(ctx, kalshi.OrderParams{Ticker: ticker, Status: "resting"})
+	return orders, err
+}
+
+func (f *Fake) CreateOrder(ctx context.Context, req kalshi.CreateOrderRequest) (*kalshi.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("CreateOrder"); err != nil {
+		return nil, err
+	}
+	f.nextID++
+	now := f.Clock().UTC().Format(time.RFC3339)
+	o := kalshi.Order{
+		OrderID:           fmt.Sprintf("fake-%d", f.nextID),
+		Ticker:            req.Ticker,
+		Action:            req.Action,
+		Side:              req.Side,
+		Type:              req.Type,
+		YesPrice:          req.YesPrice,
+		NoPrice:           req.NoPrice,
+		Quantity:          req.Count,
+		RemainingQuantity: req.Count,
+		Status:            "resting",
+		CreatedTime:       now,
+		UpdatedTime:       now,
+	}
+	f.fx.Orders = append(f.fx.Orders, o)
+	return &o, nil
+}
+
+func (f *Fake) CancelOrder(ctx context.Context, orderID string) (*kalshi.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("CancelOrder"); err != nil {
+		return nil, err
+	}
+	for i := range f.fx.Orders {
+		if o := &f.fx.Orders[i]; o.OrderID == orderID {
+			o.Status = "canceled"
+			o.RemainingQuantity = 0
+			o.UpdatedTime = f.Clock().UTC().Format(time.RFC3339)
+			out := *o
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("order %s: %w", orderID, kalshi.ErrNotFound)
+}
+
+func (f *Fake) GetPositions(ctx context.Context, p kalshi.PositionParams) ([]kalshi.MarketPosition, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetPositions"); err != nil {
+		return nil, "", err
+	}
+	var out []kalshi.MarketPosition
+	for _, pos := range f.fx.Positions {
+		if p.Ticker == "" || pos.Ticker == p.Ticker {
+			out = append(out, pos)
+		}
+	}
+	return out, "", nil
+}
+
+func (f *Fake) GetFills(ctx context.Context, p kalshi.FillParams) ([]kalshi.Fill, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetFills"); err != nil {
+		return nil, "", err
+	}
+	var out []kalshi.Fill
+	for _, fill := range f.fx.Fills {
+		if p.Ticker == "" || fill.Ticker == p.Ticker {
+			out = append(out, fill)
+		}
+	}
+	return out, "", nil
+}
+
+func (f *Fake) GetSettlements(ctx context.Context, p kalshi.SettlementParams) ([]kalshi.Settlement, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetSettlements"); err != nil {
+		return nil, "", err
+	}
+	return append([]kalshi.Settlement(nil), f.fx.Settlements...), "", nil
+}
+
+func (f *Fake) GetExchangeStatus(ctx context.Context) (*kalshi.ExchangeStatus, kalshi.ClockSample, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetExchangeStatus"); err != nil {
+		return nil, kalshi.ClockSample{}, err
+	}
+	// Skew reads the server clock as the middle of its second; make it zero.
+	now := f.Clock()
+	status := f.fx.Status
+	return &status, kalshi.ClockSample{Server: now.Add(-500 * time.Millisecond), Local: now}, nil
+}
+
+func (f *Fake) GetExchangeSchedule(ctx context.Context) (*kalshi.ExchangeSchedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("GetExchangeSchedule"); err != nil {
+		return nil, err
+	}
+	sched := f.fx.Schedule
+	return &sched, nil
+}
@@ -0,0 +1,491 @@
+package kalshitest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSServer speaks the Kalshi market data WebSocket protocol as KalshiFeed
+// uses it: subscribe, update_subscription and unsubscribe commands, "ok"
+// replies carrying subscription ids, and ticker, orderbook_snapshot,
+// orderbook_delta, market_lifecycle_v2 and market_position messages with
+// per-subscription sequence numbers.
+//
+// Messages are pushed by the test: Ticker, Book and Delta write to every
+// subscribed connection before returning, so a test controls exactly what
+// the client sees and when. The server keeps each market's book, so a new
+// orderbook subscription, like the resubscribe KalshiFeed sends after a
+// sequence gap, starts with a snapshot of it. Gap, Disconnect and Refuse
+// inject the faults the client has to recover from.
+//
+// Point a client at it with config.Config's KalshiWSURL (KALSHI_WS_URL)
+// and a nil private key; handshakes are not authenticated.
+type WSServer struct {
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	conns    map[*wsConn]bool
+	books    map[string]*wsBook
+	connects int
+	refuse   int  // handshakes left to reject; -1 rejects all
+	noLife   bool // reject market_lifecycle_v2 subscriptions
+	changed  chan struct{}
+}
+
+type wsBook struct {
+	yes, no map[int]int
+}
+
+// wsConn is one client connection and its subscriptions.
+type wsConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	// Guarded by WSServer.mu.
+	subs    map[int]*wsSub
+	nextSID int
+}
+
+type wsSub struct {
+	channel string
+	tickers map[string]bool // nil for channels that cover every market
+	seq     int
+	skip    int // seq numbers to skip before the next message
+}
+
+type wsCmd struct {
+	ID     int64  `json:"id"`
+	Cmd    string `json:"cmd"`
+	Params struct {
+		Channels      []string `json:"channels"`
+		MarketTickers []string `json:"market_tickers"`
+		SIDs          []int    `json:"sids"`
+		Action        string   `json:"action"`
+	} `json:"params"`
+}
+
+type wsOut struct {
+	ID   int64  `json:"id,omitempty"`
+	Type string `json:"type"`
+	SID  int    `json:"sid,omitempty"`
+	Seq  int    `json:"seq,omitempty"`
+	Msg  any    `json:"msg"`
+}
+
+// NewWSServer starts a server on a loopback port. Close it when done.
+func NewWSServer() *WSServer {
+	s := &WSServer{
+		conns:   make(map[*wsConn]bool),
+		books:   make(map[string]*wsBook),
+		changed: make(chan struct{}),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// URL is the ws:// address to dial.
+func (s *WSServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Close drops every connection and stops the server.
+func (s *WSServer) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
+
+// notifyLocked wakes Wait callers. Caller must hold s.mu.
+func (s *WSServer) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *WSServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.refuse != 0 {
+		if s.refuse > 0 {
+			s.refuse--
+		}
+		s.mu.Unlock()
+		http.Error(w, "refused by test", http.StatusServiceUnavailable)
+		return
+	}
+	s.mu.Unlock()
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{ws: ws, subs: make(map[int]*wsSub)}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.connects++
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.notifyLocked()
+		s.mu.Unlock()
+		ws.Close()
+	}()
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var cmd wsCmd
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			c.send(wsOut{Type: "error", Msg: map[string]any{"code": 1, "msg": "bad command"}})
+			continue
+		}
+		s.handle(c, cmd)
+	}
+}
+
+func (s *WSServer) handle(c *wsConn, cmd wsCmd) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notifyLocked()
+
+	switch cmd.Cmd {
+	case "subscribe":
+		type okEntry struct {
+			Channel string `json:"channel"`
+			SID     int    `json:"sid"`
+		}
+		var ok []okEntry
+		var snapshots []string
+		for _, ch := range cmd.Params.Channels {
+			if ch == "market_lifecycle_v2" && s.noLife {
+				c.send(wsOut{ID: cmd.ID, Type: "error", Msg: map[string]any{"code": 14, "msg": "unknown channel"}})
+				continue
+			}
+			c.nextSID++
+			sub := &wsSub{channel: ch}
+			if ch != "market_lifecycle_v2" && ch != "market_positions" {
+				sub.tickers = make(map[string]bool)
+				for _, t := range cmd.Params.MarketTickers {
+					sub.tickers[t] = true
+				}
+			}
+			c.subs[c.nextSID] = sub
+			ok = append(ok, okEntry{Channel: ch, SID: c.nextSID})
+			if ch == "orderbook_delta" {
+				snapshots = cmd.Params.MarketTickers
+			}
+		}
+		if len(ok) > 0 {
+			c.send(wsOut{ID: cmd.ID, Type: "ok", Msg: ok})
+		}
+		for _, t := range snapshots {
+			s.snapshotLocked(c, t)
+		}
+	case "update_subscription":
+		for _, sid := range cmd.Params.SIDs {
+			sub, ok := c.subs[sid]
+			if !ok || sub.tickers == nil {
+				continue
+			}
+			for _, t := range cmd.Params.MarketTickers {
+				switch cmd.Params.Action {
+				case "add_markets":
+					sub.tickers[t] = true
+					if sub.channel == "orderbook_delta" {
+						s.snapshotLocked(c, t)
+					}
+				case "remove_markets", "delete_markets":
+					delete(sub.tickers, t)
+				}
+			}
+		}
+		c.send(wsOut{ID: cmd.ID, Type: "ok", Msg: map[string]any{"sids": cmd.Params.SIDs}})
+	case "unsubscribe":
+		for _, sid := range cmd.Params.SIDs {
+			delete(c.subs, sid)
+			c.send(wsOut{ID: cmd.ID, Type: "unsubscribed", SID: sid})
+		}
+	default:
+		c.send(wsOut{ID: cmd.ID, Type: "error", Msg: map[string]any{"code": 5, "msg": "unknown command"}})
+	}
+}
+
+// send writes one message, dropping it if the connection is gone; the
+// client notices that on its own.
+func (c *wsConn) send(m wsOut) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.ws.WriteJSON(m)
+}
+
+// sendSeq sends m on sub, numbering it. Caller must hold WSServer.mu.
+func (c *wsConn) sendSeq(sid int, sub *wsSub, m wsOut) {
+	sub.seq += 1 + sub.skip
+	sub.skip = 0
+	m.SID, m.Seq = sid, sub.seq
+	c.send(m)
+}
+
+// snapshotLocked sends ticker's book to c's orderbook subscription, if it
+// has one covering ticker and the server has a book for it. Caller must
+// hold s.mu.
+func (s *WSServer) snapshotLocked(c *wsConn, ticker string) {
+	b, ok := s.books[ticker]
+	if !ok {
+		return
+	}
+	for sid, sub := range c.subs {
+		if sub.channel == "orderbook_delta" && sub.tickers[ticker] {
+			c.sendSeq(sid, sub, wsOut{Type: "orderbook_snapshot", Msg: map[string]any{
+				"market_ticker": ticker,
+				"yes":           levels(b.yes),
+				"no":            levels(b.no),
+			}})
+		}
+	}
+}
+
+func levels(side map[int]int) [][2]int {
+	out := make([][2]int, 0, len(side))
+	for p, q := range side {
+		out = append(out, [2]int{p, q})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+// broadcastLocked sends m to every subscription on channel covering
+// ticker. Caller must hold s.mu.
+func (s *WSServer) broadcastLocked(channel, ticker string, m wsOut) {
+	for c := range s.conns {
+		for sid, sub := range c.subs {
+			if sub.channel == channel && (sub.tickers == nil || sub.tickers[ticker]) {
+				c.sendSeq(sid, sub, m)
+			}
+		}
+	}
+}
+
+// TickerUpdate is the payload of a ticker message.
+type TickerUpdate struct {
+	MarketTicker string `json:"market_ticker"`
+	Price        int    `json:"price"`
+	YesBid       int    `json:"yes_bid"`
+	YesAsk       int    `json:"yes_ask"`
+	Volume       int    `json:"volume"`
+	OpenInterest int    `json:"open_interest"`
+}
+
+// Ticker sends t to the connections subscribed to its market.
+func (s *WSServer) Ticker(t TickerUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastLocked("ticker", t.MarketTicker, wsOut{Type: "ticker", Msg: t})
+}
+
+// Book replaces ticker's book with the given [price, quantity] levels and
+// sends it as a snapshot.
+func (s *WSServer) Book(ticker string, yes, no [][2]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &wsBook{yes: make(map[int]int), no: make(map[int]int)}
+	for _, l := range yes {
+		b.yes[l[0]] = l[1]
+	}
+	for _, l := range no {
+		b.no[l[0]] = l[1]
+	}
+	s.books[ticker] = b
+	for c := range s.conns {
+		s.snapshotLocked(c, ticker)
+	}
+}
+
+// Delta changes the quantity at price on side ("yes" or "no") of ticker's
+// book by delta and sends the change.
+func (s *WSServer) Delta(ticker, side string, price, delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.books[ticker]
+	if !ok {
+		b = &wsBook{yes: make(map[int]int), no: make(map[int]int)}
+		s.books[ticker] = b
+	}
+	levels := b.no
+	if side == "yes" {
+		levels = b.yes
+	}
+	if levels[price] += delta; levels[price] <= 0 {
+		delete(levels, price)
+	}
+	s.broadcastLocked("orderbook_delta", ticker, wsOut{Type: "orderbook_delta", Msg: map[string]any{
+		"market_ticker": ticker,
+		"price":         price,
+		"delta":         delta,
+		"side":          side,
+	}})
+}
+
+// Lifecycle sends a market_lifecycle_v2 event (e.g. "determined" with its
+// result, or "close_date_updated" with a new close) to every lifecycle
+// subscription.
+func (s *WSServer) Lifecycle(ticker, event, result string, close time.Time) {
+	msg := map[string]any{"market_ticker": ticker, "event_type": event}
+	if result != "" {
+		msg["result"] = result
+	}
+	if !close.IsZero() {
+		msg["close_ts"] = close.Unix()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastLocked("market_lifecycle_v2", ticker, wsOut{Type: "market_lifecycle_v2", Msg: msg})
+}
+
+// Position sends the account's holding in ticker to every market_positions
+// subscription. Money is in cents; the server converts it to the
+// centi-cents Kalshi sends.
+func (s *WSServer) Position(ticker string, position, cost, realizedPnL, fees int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastLocked("market_positions", ticker, wsOut{Type: "market_position", Msg: map[string]any{
+		"market_ticker": ticker,
+		"position":      position,
+		"position_cost": cost * 100,
+		"realized_pnl":  realizedPnL * 100,
+		"fees_paid":     fees * 100,
+	}})
+}
+
+// Gap makes the next message on each subscription to channel skip n
+// sequence numbers, as if n messages had been lost in transit.
+func (s *WSServer) Gap(channel string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		for _, sub := range c.subs {
+			if sub.channel == channel {
+				sub.skip += n
+			}
+		}
+	}
+}
+
+// Disconnect drops every open connection without a close frame.
+func (s *WSServer) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+}
+
+// Refuse makes the next n handshakes fail with 503; n < 0 refuses all
+// until Refuse(0). With Disconnect it simulates an outage or a reconnect
+// storm.
+func (s *WSServer) Refuse(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = n
+}
+
+// RejectLifecycle makes lifecycle subscriptions fail, as in environments
+// that don't offer the channel.
+func (s *WSServer) RejectLifecycle(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noLife = reject
+}
+
+// Connects returns how many connections have been accepted so far.
+func (s *WSServer) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+// Subscribed returns the markets the open connections hold channel
+// subscriptions for, sorted.
+func (s *WSServer) Subscribed(channel string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribedLocked(channel)
+}
+
+func (s *WSServer) subscribedLocked(channel string) []string {
+	seen := make(map[string]bool)
+	for c := range s.conns {
+		for _, sub := range c.subs {
+			if sub.channel == channel {
+				for t := range sub.tickers {
+					seen[t] = true
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for t := range seen {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Wait blocks until cond, called with the server's state after each
+// connection or command, returns true, or ctx is done.
+func (s *WSServer) Wait(ctx context.Context, cond func(WSState) bool) error {
+	for {
+		s.mu.Lock()
+		st := WSState{Conns: len(s.conns), Connects: s.connects, server: s}
+		ok := cond(st)
+		changed := s.changed
+		s.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// WSState is what a Wait condition sees. Its methods may only be called
+// from within the condition.
+type WSState struct {
+	Conns    int // open connections
+	Connects int // connections accepted so far
+	server   *WSServer
+}
+
+// Subscribed is WSServer.Subscribed for use in a Wait condition.
+func (st WSState) Subscribed(channel string) []string {
+	return st.server.subscribedLocked(channel)
+}
+
+// WaitSubscribed blocks until the open connections are subscribed to every
+// ticker on channel, or ctx is done.
+func (s *WSServer) WaitSubscribed(ctx context.Context, channel string, tickers ...string) error {
+	return s.Wait(ctx, func(st WSState) bool {
+		have := st.Subscribed(channel)
+		for _, t := range tickers {
+			if !slices.Contains(have, t) {
+				return false
+			}
+		}
+		return true
+	})
+}
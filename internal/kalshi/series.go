@@ -0,0 +1,31 @@
+package kalshi
+
+import (
+	"context"
+	"net/url"
+)
+
+// Series is the part of a GET /series entry the collector uses.
+type Series struct {
+	Ticker    string   `json:"ticker"`
+	Title     string   `json:"title"`
+	Category  string   `json:"category"`
+	Frequency string   `json:"frequency"`
+	Tags      []string `json:"tags"`
+}
+
+// GetSeriesList lists the exchange's series, optionally only those in
+// category (e.g. "Crypto").
+func (c *Client) GetSeriesList(ctx context.Context, category string) ([]Series, error) {
+	params := url.Values{}
+	if category != "" {
+		params.Set("category", category)
+	}
+	var result struct {
+		Series []Series `json:"series"`
+	}
+	if err := c.get(ctx, "/series", params, &result); err != nil {
+		return nil, err
+	}
+	return result.Series, nil
+}
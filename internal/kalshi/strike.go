@@ -0,0 +1,251 @@
+package kalshi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Strike is a market's parsed strike: which side of its bounds pays YES
+// (ticks.StrikeAbove, StrikeBelow or StrikeBetween) and the bounds, floor
+// for above, cap for below and both for between. Type is "" when nothing
+// could be parsed.
+type Strike struct {
+	Type   string
+	Floor  float64
+	Cap    float64
+	Source string // "fields", "custom_strike" or "rules"
+}
+
+// Value is the single headline strike: the cap if there is one, else the
+// floor.
+func (s Strike) Value() float64 {
+	if s.Cap > 0 {
+		return s.Cap
+	}
+	return s.Floor
+}
+
+// ParseStrike works out the strike from, in order of preference, the
+// floor_strike/cap_strike fields, the custom_strike object and the
+// rules_primary text. strike_type, when Kalshi sends one, decides the
+// type; otherwise it follows from which bounds were found. A market whose
+// rules text yields nothing is logged once and counted in
+// UnparsedStrikes.
+func (m *Market) ParseStrike() Strike {
+	var s Strike
+	switch {
+	case m.FloorStrike > 0 || m.CapStrike > 0:
+		s = Strike{Floor: m.FloorStrike, Cap: m.CapStrike, Source: "fields"}
+	default:
+		if floor, cap := customStrikeBounds(m.CustomStrike); floor > 0 || cap > 0 {
+			s = Strike{Floor: floor, Cap: cap, Source: "custom_strike"}
+		} else if r, ok := ParseRulesStrike(m.RulesPrimary); ok {
+			s = r
+		}
+	}
+
+	switch m.StrikeType {
+	case "greater", "greater_or_equal":
+		s.Type = ticks.StrikeAbove
+		if s.Floor == 0 {
+			s.Floor = s.Cap
+		}
+		s.Cap = 0
+	case "less", "less_or_equal":
+		s.Type = ticks.StrikeBelow
+		if s.Cap == 0 {
+			s.Cap = s.Floor
+		}
+		s.Floor = 0
+	case "between":
+		s.Type = ticks.StrikeBetween
+	default:
+		if s.Type == "" {
+			s.Type = inferStrikeType(s.Floor, s.Cap)
+		}
+	}
+
+	if s.Source == "" && m.RulesPrimary != "" {
+		reportUnparsedStrike(m)
+	}
+	return s
+}
+
+// StrikeRange is ParseStrike without the source.
+func (m *Market) StrikeRange() (kind string, floor, cap float64) {
+	s := m.ParseStrike()
+	return s.Type, s.Floor, s.Cap
+}
+
+func inferStrikeType(floor, cap float64) string {
+	switch {
+	case floor > 0 && cap > 0:
+		return ticks.StrikeBetween
+	case floor > 0:
+		return ticks.StrikeAbove
+	case cap > 0:
+		return ticks.StrikeBelow
+	}
+	return ""
+}
+
+// strikeNum matches a price as written in rules text: an optional dollar
+// sign, thousands separators and decimals.
+const strikeNum = `\$?(\d[\d,]*(?:\.\d+)?)`
+
+var (
+	betweenRule = regexp.MustCompile(`between ` + strikeNum + ` and ` + strikeNum)
+	aboveRules  = []*regexp.Regexp{
+		regexp.MustCompile(`(?:at least|above|greater than(?: or equal to)?|more than|higher than) ` + strikeNum),
+		regexp.MustCompile(strikeNum + ` or (?:above|higher|more|greater)`),
+	}
+	belowRules = []*regexp.Regexp{
+		regexp.MustCompile(`(?:at most|below|less than(?: or equal to)?|lower than) ` + strikeNum),
+		regexp.MustCompile(strikeNum + ` or (?:below|lower|less)`),
+	}
+)
+
+// ParseRulesStrike reads the strike out of a market's rules text, e.g.
+// "... is at least 97,250.00 at 4 PM EST ...", "... above $97,250 ...",
+// "... below 97,249.99 ..." or "... between 97,000 and 97,249.99 ...".
+// When the text states both a lower and an upper condition the one
+// written first wins, unless it is a between range.
+func ParseRulesStrike(text string) (Strike, bool) {
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	if text == "" {
+		return Strike{}, false
+	}
+
+	if m := betweenRule.FindStringSubmatch(text); m != nil {
+		lo, ok1 := parseStrikeNumber(m[1])
+		hi, ok2 := parseStrikeNumber(m[2])
+		if ok1 && ok2 {
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			return Strike{Type: ticks.StrikeBetween, Floor: lo, Cap: hi, Source: "rules"}, true
+		}
+	}
+
+	best, bestAt := Strike{}, -1
+	try := func(res []*regexp.Regexp, kind string) {
+		for _, re := range res {
+			loc := re.FindStringSubmatchIndex(text)
+			if loc == nil || (bestAt >= 0 && loc[0] >= bestAt) {
+				continue
+			}
+			v, ok := parseStrikeNumber(text[loc[2]:loc[3]])
+			if !ok {
+				continue
+			}
+			best, bestAt = Strike{Type: kind, Source: "rules"}, loc[0]
+			if kind == ticks.StrikeAbove {
+				best.Floor = v
+			} else {
+				best.Cap = v
+			}
+		}
+	}
+	try(aboveRules, ticks.StrikeAbove)
+	try(belowRules, ticks.StrikeBelow)
+	return best, bestAt >= 0
+}
+
+// parseStrikeNumber parses "97,250.00" or "$97250"; zero is rejected.
+func parseStrikeNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(s, "$"), ",", ""), 64)
+	return f, err == nil && f > 0
+}
+
+// customStrikeBounds reads floor and cap values out of a custom_strike
+// object. Kalshi keys it by target, so numeric values under keys naming
+// a floor or cap are taken as such, and a lone numeric value as a floor.
+// Older responses carried the object as a JSON string; that is unwrapped.
+func customStrikeBounds(raw json.RawMessage) (floor, cap float64) {
+	if len(raw) == 0 {
+		return 0, 0
+	}
+	var inner string
+	if json.Unmarshal(raw, &inner) == nil {
+		raw = json.RawMessage(inner)
+	}
+	var obj map[string]any
+	if json.Unmarshal(raw, &obj) != nil {
+		return 0, 0
+	}
+
+	var lone float64
+	numeric := 0
+	for k, v := range obj {
+		f, ok := customStrikeNumber(v)
+		if !ok {
+			continue
+		}
+		numeric++
+		lone = f
+		switch key := strings.ToLower(k); {
+		case strings.Contains(key, "floor"):
+			floor = f
+		case strings.Contains(key, "cap"):
+			cap = f
+		}
+	}
+	if floor == 0 && cap == 0 && numeric == 1 {
+		floor = lone
+	}
+	return floor, cap
+}
+
+// customStrikeNumber accepts a JSON number or a numeric string.
+func customStrikeNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, x > 0
+	case string:
+		return parseStrikeNumber(x)
+	}
+	return 0, false
+}
+
+// maxUnparsedTracked bounds how many tickers are remembered as already
+// reported; past it the oldest are forgotten. Markets expire within the
+// hour, so a forgotten one is rarely seen, and counted, again.
+const maxUnparsedTracked = 1024
+
+var (
+	unparsedStrikes atomic.Int64
+	unparsedMu      sync.Mutex
+	unparsedSeen    = make(map[string]bool) // tickers reported
+	unparsedOrder   []string                // unparsedSeen's keys, oldest first
+)
+
+// UnparsedStrikes counts markets whose strike could not be parsed from
+// any source, so a new rules phrasing shows up in telemetry instead of as
+// silent zero strikes.
+func UnparsedStrikes() int64 { return unparsedStrikes.Load() }
+
+func reportUnparsedStrike(m *Market) {
+	unparsedMu.Lock()
+	if unparsedSeen[m.Ticker] {
+		unparsedMu.Unlock()
+		return
+	}
+	if len(unparsedOrder) >= maxUnparsedTracked {
+		delete(unparsedSeen, unparsedOrder[0])
+		unparsedOrder = unparsedOrder[1:]
+	}
+	unparsedSeen[m.Ticker] = true
+	unparsedOrder = append(unparsedOrder, m.Ticker)
+	unparsedMu.Unlock()
+
+	unparsedStrikes.Add(1)
+	slog.Warn("strike: could not parse", "ticker", m.Ticker,
+		"strike_type", m.StrikeType, "rules", m.RulesPrimary)
+}
@@ -0,0 +1,121 @@
+package kalshi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+func TestParseRulesStrike(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Strike
+		ok   bool
+	}{
+		{"at least", "If the price of Bitcoin is at least 97,250.00 at 4 PM EST, then the market resolves to Yes.",
+			Strike{Type: ticks.StrikeAbove, Floor: 97250}, true},
+		{"above dollar", "If BTC is above $97,250 on Jan 1, then Yes.",
+			Strike{Type: ticks.StrikeAbove, Floor: 97250}, true},
+		{"greater than or equal", "resolves Yes if the index is greater than or equal to 100000.5",
+			Strike{Type: ticks.StrikeAbove, Floor: 100000.5}, true},
+		{"or above", "If the index is 97,250 or above at expiry, Yes.",
+			Strike{Type: ticks.StrikeAbove, Floor: 97250}, true},
+		{"below", "If the price is below 97,249.99 at 4 PM, then Yes.",
+			Strike{Type: ticks.StrikeBelow, Cap: 97249.99}, true},
+		{"less than", "Yes if BRTI is less than $1,234,567.89.",
+			Strike{Type: ticks.StrikeBelow, Cap: 1234567.89}, true},
+		{"or lower", "If the index is 96,000 or lower, Yes.",
+			Strike{Type: ticks.StrikeBelow, Cap: 96000}, true},
+		{"between", "If the price is between 97,000 and 97,249.99 at 4 PM EST, then Yes.",
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99}, true},
+		{"between reversed", "between $97,249.99 and $97,000",
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99}, true},
+		{"case and spacing", "If BTC is   AT\n LEAST 97,250 then Yes.",
+			Strike{Type: ticks.StrikeAbove, Floor: 97250}, true},
+		{"first condition wins", "Yes if above 97,000; No if below 97,000.",
+			Strike{Type: ticks.StrikeAbove, Floor: 97000}, true},
+		{"no number", "If Bitcoin goes up, then Yes.", Strike{}, false},
+		{"zero", "If the price is above 0, then Yes.", Strike{}, false},
+		{"empty", "", Strike{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRulesStrike(tt.text)
+			if tt.ok {
+				tt.want.Source = "rules"
+			}
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseRulesStrike(%q) = %+v, %v; want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseStrike(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Market
+		want Strike
+	}{
+		{"floor field", Market{StrikeType: "greater", FloorStrike: 97250},
+			Strike{Type: ticks.StrikeAbove, Floor: 97250, Source: "fields"}},
+		{"cap field", Market{StrikeType: "less", CapStrike: 97249.99},
+			Strike{Type: ticks.StrikeBelow, Cap: 97249.99, Source: "fields"}},
+		{"between fields", Market{StrikeType: "between", FloorStrike: 97000, CapStrike: 97249.99},
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99, Source: "fields"}},
+		{"type inferred from fields", Market{FloorStrike: 97000, CapStrike: 97249.99},
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99, Source: "fields"}},
+		{"greater moves a lone cap to the floor", Market{StrikeType: "greater_or_equal", CapStrike: 97250},
+			Strike{Type: ticks.StrikeAbove, Floor: 97250, Source: "fields"}},
+		{"less moves a lone floor to the cap", Market{StrikeType: "less_or_equal", FloorStrike: 97250},
+			Strike{Type: ticks.StrikeBelow, Cap: 97250, Source: "fields"}},
+		{"custom_strike object", Market{StrikeType: "custom", CustomStrike: json.RawMessage(`{"floor_strike":97000,"cap_strike":"97,249.99"}`)},
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99, Source: "custom_strike"}},
+		{"custom_strike lone value", Market{StrikeType: "greater", CustomStrike: json.RawMessage(`{"BTC":"97250"}`)},
+			Strike{Type: ticks.StrikeAbove, Floor: 97250, Source: "custom_strike"}},
+		{"custom_strike as a string", Market{CustomStrike: json.RawMessage(`"{\"cap\": 97249.99}"`)},
+			Strike{Type: ticks.StrikeBelow, Cap: 97249.99, Source: "custom_strike"}},
+		{"custom_strike without numbers falls back to rules", Market{CustomStrike: json.RawMessage(`{"asset":"BTC"}`), RulesPrimary: "If BTC is at least 97,250 then Yes."},
+			Strike{Type: ticks.StrikeAbove, Floor: 97250, Source: "rules"}},
+		{"fields win over rules", Market{FloorStrike: 97500, RulesPrimary: "If BTC is at least 97,250 then Yes."},
+			Strike{Type: ticks.StrikeAbove, Floor: 97500, Source: "fields"}},
+		{"rules between", Market{RulesPrimary: "between 97,000 and 97,249.99"},
+			Strike{Type: ticks.StrikeBetween, Floor: 97000, Cap: 97249.99, Source: "rules"}},
+		{"nothing", Market{}, Strike{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.ParseStrike(); got != tt.want {
+				t.Errorf("ParseStrike() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnparsedStrikeReportedOnce(t *testing.T) {
+	before := UnparsedStrikes()
+	m := Market{Ticker: "KXTEST-UNPARSED", RulesPrimary: "If Bitcoin goes up, then Yes."}
+	for i := 0; i < 3; i++ {
+		if s := m.ParseStrike(); s != (Strike{}) {
+			t.Fatalf("ParseStrike() = %+v, want nothing", s)
+		}
+	}
+	if n := UnparsedStrikes() - before; n != 1 {
+		t.Errorf("counted %d unparsed strikes, want 1", n)
+	}
+}
+
+func TestUnparsedStrikeTrackingIsBounded(t *testing.T) {
+	for i := 0; i < maxUnparsedTracked+100; i++ {
+		m := Market{Ticker: fmt.Sprintf("KXTEST-BOUND-%d", i), RulesPrimary: "no strike here"}
+		m.ParseStrike()
+	}
+	unparsedMu.Lock()
+	defer unparsedMu.Unlock()
+	if len(unparsedSeen) > maxUnparsedTracked || len(unparsedOrder) > maxUnparsedTracked {
+		t.Errorf("tracking %d tickers (%d ordered), want at most %d", len(unparsedSeen), len(unparsedOrder), maxUnparsedTracked)
+	}
+}
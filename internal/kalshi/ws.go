@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/framelog"
 )
 
 // KalshiFeed is a WebSocket client for Kalshi real-time market data.
@@ -28,21 +30,72 @@ type KalshiFeed struct {
 	books    map[string]*Orderbook    // ticker → full depth book
 	metadata map[string]*MarketMeta   // ticker → REST metadata
 
+	// positions holds the account's holdings when the market_positions
+	// channel is enabled (see EnablePositions); nil otherwise.
+	positions map[string]*Position
+
 	// desiredTickers is the set of markets we want subscribed (set by UpdateSubscriptions).
 	desiredTickers map[string]bool
 
+	frames *framelog.Ring // raw frame capture; nil when off
+	drift  *DriftDetector // payload shape checks; nil when off
+
+	// cacheTTL is how long after its close a market that discovery no
+	// longer reports stays cached; see Evict.
+	cacheTTL time.Duration
+
 	// Write-side state: conn, subscription tracking, command sequence.
 	// Protected by writeMu. Lock ordering: mu before writeMu.
 	writeMu           sync.Mutex
 	conn              *websocket.Conn
 	tickerSID         int
 	orderbookSID      int
+	lifecycleSID      int
+	positionsSID      int
 	subscribedTickers map[string]bool
 	cmdSeq            int64
 
+	// lastSeq is the last envelope seq seen per subscription id. Only the
+	// read loop touches it; connect resets it.
+	lastSeq map[int]int
+	seqGaps atomic.Int64
+
 	connected atomic.Bool
+
+	// Connection history for Stats and Events.
+	statsMu        sync.Mutex
+	connects       int64
+	lastDisconnect time.Time
+	lastDisconnErr string
+	events         chan ConnEvent
+}
+
+// FeedStats is a point-in-time view of the feed's connection and
+// subscriptions.
+type FeedStats struct {
+	Connected         bool      `json:"connected"`
+	Reconnects        int64     `json:"reconnects"`      // successful connections after the first
+	LastDisconnect    time.Time `json:"last_disconnect"` // zero if never disconnected
+	LastDisconnectErr string    `json:"last_disconnect_err,omitempty"`
+	TickerSID         int       `json:"ticker_sid"`
+	OrderbookSID      int       `json:"orderbook_sid"`
+	LifecycleSID      int       `json:"lifecycle_sid"`
+	SubscribedTickers int       `json:"subscribed_tickers"`
+	CachedMarkets     int       `json:"cached_markets"` // markets with metadata, prices or a book held in memory
+	SeqGaps           int64     `json:"seq_gaps"`
+}
+
+// ConnEvent is a connection state change.
+type ConnEvent struct {
+	Time      time.Time
+	Connected bool
+	Err       string // why the connection dropped; empty on connect
 }
 
+// connEventBuffer bounds Events; changes beyond it are dropped so a slow
+// consumer never stalls the feed.
+const connEventBuffer = 16
+
 // MarketPrice holds real-time ticker data from WS.
 type MarketPrice struct {
 	YesBid       int
@@ -50,14 +103,31 @@ type MarketPrice struct {
 	LastPrice    int
 	Volume       int
 	OpenInterest int
+	Updated      time.Time // receipt of the last ticker message
+}
+
+// Position is the account's holding in one market, from the
+// market_positions channel or a REST seed. Money is in cents.
+type Position struct {
+	Position    int // contracts: positive YES, negative NO
+	Cost        int
+	RealizedPnL int
+	FeesPaid    int
+	Updated     time.Time // receipt of the last WS message; zero for a REST seed
 }
 
 // MarketMeta holds REST-sourced metadata for a market.
 type MarketMeta struct {
-	Status string
-	Result string
-	Strike float64
-	Expiry time.Time
+	EventTicker string
+	Status      string
+	Result      string
+	Strike      float64
+	StrikeType  string  // ticks.StrikeAbove, StrikeBelow or StrikeBetween
+	StrikeFloor float64
+	StrikeCap   float64
+	Settlement  float64   // index value settled on; 0 until determined
+	Close       time.Time // trading stops
+	Expiry      time.Time // settlement, a few minutes after Close
 }
 
 // Orderbook holds the depth for one market's YES and NO sides.
@@ -65,11 +135,37 @@ type Orderbook struct {
 	Yes   map[int]int // price_cents → quantity
 	No    map[int]int
 	Ready bool
+	Stale   bool      // deltas were missed; cleared by the next snapshot
+	Updated time.Time // receipt of the last snapshot or delta
+
+	// Price-sorted views of Yes and No, rebuilt only for sides changed
+	// since the last Snapshot. They are shared with snapshot callers, so
+	// they are replaced, never modified.
+	cacheMu             sync.Mutex
+	yesLevels, noLevels [][2]int
+	yesDirty, noDirty   bool
+}
+
+// levels returns the sorted YES and NO levels. Caller holds f.mu for
+// reading; cacheMu serializes concurrent snapshots.
+func (b *Orderbook) levels() (yes, no [][2]int) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if b.yesDirty {
+		b.yesLevels = sortedLevels(b.Yes)
+		b.yesDirty = false
+	}
+	if b.noDirty {
+		b.noLevels = sortedLevels(b.No)
+		b.noDirty = false
+	}
+	return b.yesLevels, b.noLevels
 }
 
 // MarketSnapshot is the merged WS+REST view of a single market.
 type MarketSnapshot struct {
 	Ticker       string
+	EventTicker  string
 	Status       string
 	Result       string
 	YesBid       int
@@ -79,8 +175,18 @@ type MarketSnapshot struct {
 	OpenInterest int
 	SecsLeft     int
 	Strike       float64
+	StrikeType   string
+	StrikeFloor  float64
+	StrikeCap    float64
+	Settlement   float64
+	Close        time.Time
+	Expiry       time.Time
 	YesBook      [][2]int
 	NoBook       [][2]int
+	BookStale    bool      // book may be missing deltas (sequence gap)
+	TickerUpdate time.Time // last WS ticker message; zero if none
+	BookUpdate   time.Time // last WS orderbook snapshot or delta; zero if none
+	LastUpdate   time.Time // later of the two
 	FromWS       bool
 }
 
@@ -94,7 +200,84 @@ func NewKalshiFeed(cfg *config.Config, privKey *rsa.PrivateKey) *KalshiFeed {
 		books:             make(map[string]*Orderbook),
 		metadata:          make(map[string]*MarketMeta),
 		desiredTickers:    make(map[string]bool),
+		cacheTTL:          DefaultCacheTTL,
 		subscribedTickers: make(map[string]bool),
+		lastSeq:           make(map[int]int),
+		events:            make(chan ConnEvent, connEventBuffer),
+	}
+}
+
+// DefaultCacheTTL is how long a closed market stays cached by default.
+const DefaultCacheTTL = 30 * time.Minute
+
+// SetCacheTTL changes how long closed markets stay cached. Call it before Run.
+func (f *KalshiFeed) SetCacheTTL(d time.Duration) {
+	f.mu.Lock()
+	f.cacheTTL = d
+	f.mu.Unlock()
+}
+
+// SetFrameLog records every raw frame the feed receives in r, dumping it
+// when the connection fails or the server sends an error. Call it before
+// Run.
+func (f *KalshiFeed) SetFrameLog(r *framelog.Ring) {
+	f.frames = r
+}
+
+// SetDriftDetector checks the payloads of the message types the feed
+// decodes with d. Call it before Run.
+func (f *KalshiFeed) SetDriftDetector(d *DriftDetector) {
+	f.drift = d
+}
+
+// EnablePositions subscribes to the account's market_positions channel on
+// every connection, so Positions reports holdings as they change. The
+// channel is private: the feed needs a key. Call it before Run.
+func (f *KalshiFeed) EnablePositions() {
+	f.mu.Lock()
+	if f.positions == nil {
+		f.positions = make(map[string]*Position)
+	}
+	f.mu.Unlock()
+}
+
+// Positions returns the account's non-zero holdings by ticker, or nil when
+// positions are not enabled.
+func (f *KalshiFeed) Positions() map[string]Position {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.positions == nil {
+		return nil
+	}
+	out := make(map[string]Position, len(f.positions))
+	for t, p := range f.positions {
+		if p.Position != 0 {
+			out[t] = *p
+		}
+	}
+	return out
+}
+
+// SeedPositions fills in holdings from a REST GetPositions listing. The
+// channel only reports changes, so positions opened before the feed
+// connected are otherwise unknown. Markets the channel already reported
+// keep its newer values.
+func (f *KalshiFeed) SeedPositions(ps []MarketPosition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.positions == nil {
+		return
+	}
+	for _, p := range ps {
+		if old, ok := f.positions[p.Ticker]; ok && !old.Updated.IsZero() {
+			continue
+		}
+		f.positions[p.Ticker] = &Position{
+			Position:    p.Position,
+			Cost:        p.MarketExposure,
+			RealizedPnL: p.RealizedPnL,
+			FeesPaid:    p.FeesPaid,
+		}
 	}
 }
 
@@ -103,13 +286,74 @@ func (f *KalshiFeed) IsConnected() bool {
 	return f.connected.Load()
 }
 
+// SeqGaps returns how many sequence gaps have been detected since start.
+func (f *KalshiFeed) SeqGaps() int64 {
+	return f.seqGaps.Load()
+}
+
+// Stats returns the current connection and subscription state.
+func (f *KalshiFeed) Stats() FeedStats {
+	f.statsMu.Lock()
+	s := FeedStats{
+		Connected:         f.connected.Load(),
+		LastDisconnect:    f.lastDisconnect,
+		LastDisconnectErr: f.lastDisconnErr,
+		SeqGaps:           f.seqGaps.Load(),
+	}
+	if f.connects > 1 {
+		s.Reconnects = f.connects - 1
+	}
+	f.statsMu.Unlock()
+
+	f.mu.RLock()
+	s.CachedMarkets = f.cachedLocked()
+	f.mu.RUnlock()
+
+	f.writeMu.Lock()
+	s.TickerSID = f.tickerSID
+	s.OrderbookSID = f.orderbookSID
+	s.LifecycleSID = f.lifecycleSID
+	s.SubscribedTickers = len(f.subscribedTickers)
+	f.writeMu.Unlock()
+	return s
+}
+
+// Events returns connection state changes. It is meant for a single
+// consumer; events that don't fit its buffer are dropped.
+func (f *KalshiFeed) Events() <-chan ConnEvent {
+	return f.events
+}
+
+func (f *KalshiFeed) emit(ev ConnEvent) {
+	select {
+	case f.events <- ev:
+	default:
+		slog.Debug("kalshi ws: event dropped", "connected", ev.Connected)
+	}
+}
+
 // Run maintains the WebSocket connection with automatic reconnection.
 func (f *KalshiFeed) Run(ctx context.Context) error {
 	for {
-		if err := f.connect(ctx); err != nil {
+		err := f.connect(ctx)
+		if err != nil {
 			slog.Warn("kalshi ws disconnected", "err", err)
+			if ctx.Err() == nil {
+				f.frames.DumpOnError("kalshi", err)
+			}
+		}
+		if f.connected.Swap(false) {
+			now := time.Now()
+			reason := ""
+			if err != nil {
+				reason = err.Error()
+			}
+			f.statsMu.Lock()
+			f.lastDisconnect = now
+			f.lastDisconnErr = reason
+			f.statsMu.Unlock()
+			f.emit(ConnEvent{Time: now, Err: reason})
 		}
-		f.connected.Store(false)
 
 		select {
 		case <-ctx.Done():
@@ -131,9 +375,12 @@ func (f *KalshiFeed) connect(ctx context.Context) error {
 	f.conn = conn
 	f.tickerSID = 0
 	f.orderbookSID = 0
+	f.lifecycleSID = 0
+	f.positionsSID = 0
 	f.subscribedTickers = make(map[string]bool)
 	f.cmdSeq = 0
 	f.writeMu.Unlock()
+	f.lastSeq = make(map[int]int)
 
 	// Clear orderbooks (fresh snapshots arrive after subscribe)
 	f.mu.Lock()
@@ -158,7 +405,34 @@ func (f *KalshiFeed) connect(ctx context.Context) error {
 		}
 	}
 
+	// Lifecycle covers every market, so it is subscribed once per
+	// connection. Not all environments offer it; the server's error reply
+	// is logged and status falls back to REST discovery.
+	f.writeMu.Lock()
+	err = f.subscribeLifecycleLocked()
+	f.writeMu.Unlock()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("subscribe lifecycle: %w", err)
+	}
+	f.mu.RLock()
+	positions := f.positions != nil
+	f.mu.RUnlock()
+	if positions {
+		f.writeMu.Lock()
+		err = f.subscribePositionsLocked()
+		f.writeMu.Unlock()
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("subscribe positions: %w", err)
+		}
+	}
+
 	f.connected.Store(true)
+	f.statsMu.Lock()
+	f.connects++
+	f.statsMu.Unlock()
+	f.emit(ConnEvent{Time: time.Now(), Connected: true})
 	slog.Info("kalshi ws connected", "subscriptions", len(tickers))
 
 	// Run read loop with ping keepalive
@@ -169,14 +443,17 @@ func (f *KalshiFeed) connect(ctx context.Context) error {
 }
 
 func (f *KalshiFeed) dial(ctx context.Context) (*websocket.Conn, error) {
-	headers, err := AuthHeaders(f.cfg, f.privKey, "GET", "/trade-api/ws/v2")
-	if err != nil {
-		return nil, fmt.Errorf("auth headers: %w", err)
-	}
-
+	// Without a key the handshake goes unsigned, which only a test server
+	// such as kalshitest.WSServer accepts.
 	h := http.Header{}
-	for k, v := range headers {
-		h.Set(k, v)
+	if f.privKey != nil {
+		headers, err := AuthHeaders(f.cfg, f.privKey, "GET", "/trade-api/ws/v2")
+		if err != nil {
+			return nil, fmt.Errorf("auth headers: %w", err)
+		}
+		for k, v := range headers {
+			h.Set(k, v)
+		}
 	}
 
 	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
@@ -230,6 +507,14 @@ type subscribeParams struct {
 	MarketTickers []string `json:"market_tickers"`
 }
 
+type lifecycleParams struct {
+	Channels []string `json:"channels"`
+}
+
+type unsubscribeParams struct {
+	SIDs []int `json:"sids"`
+}
+
 type updateSubParams struct {
 	SIDs          []int    `json:"sids"`
 	MarketTickers []string `json:"market_tickers"`
@@ -258,6 +543,23 @@ type tickerPayload struct {
 	OpenInterest int    `json:"open_interest"`
 }
 
+// lifecyclePayload is a market_lifecycle_v2 message. Times are unix seconds.
+type lifecyclePayload struct {
+	MarketTicker string `json:"market_ticker"`
+	EventType    string `json:"event_type"` // created, activated, deactivated, close_date_updated, determined, settled
+	CloseTs      int64  `json:"close_ts"`
+	Result       string `json:"result"`
+}
+
+// positionPayload is a market_position message. Money is in centi-cents.
+type positionPayload struct {
+	MarketTicker string `json:"market_ticker"`
+	Position     int    `json:"position"`
+	PositionCost int    `json:"position_cost"`
+	RealizedPnL  int    `json:"realized_pnl"`
+	FeesPaid     int    `json:"fees_paid"`
+}
+
 type obSnapshotPayload struct {
 	MarketTicker string   `json:"market_ticker"`
 	Yes          [][2]int `json:"yes"`
@@ -271,6 +573,16 @@ type obDeltaPayload struct {
 	Side         string `json:"side"`
 }
 
+// wsPayloads is what each data message type decodes into, for drift
+// detection.
+var wsPayloads = map[string]any{
+	"ticker":              tickerPayload{},
+	"orderbook_snapshot":  obSnapshotPayload{},
+	"orderbook_delta":     obDeltaPayload{},
+	"market_lifecycle_v2": lifecyclePayload{},
+	"market_position":     positionPayload{},
+}
+
 // --- Read loop ---
 
 func (f *KalshiFeed) readLoop(ctx context.Context, conn *websocket.Conn) error {
@@ -286,12 +598,17 @@ func (f *KalshiFeed) readLoop(ctx context.Context, conn *websocket.Conn) error {
 			return fmt.Errorf("read: %w", err)
 		}
 		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		f.frames.Add("kalshi", msg)
 
 		var env wsEnvelope
 		if err := json.Unmarshal(msg, &env); err != nil {
 			slog.Debug("kalshi ws: unmarshal error", "err", err)
 			continue
 		}
+		f.checkSeq(env)
+		if payload, ok := wsPayloads[env.Type]; ok {
+			f.drift.Check("ws "+env.Type, env.Msg, payload)
+		}
 
 		switch env.Type {
 		case "ticker":
@@ -300,16 +617,101 @@ func (f *KalshiFeed) readLoop(ctx context.Context, conn *websocket.Conn) error {
 			f.handleOrderbookSnapshot(env.Msg)
 		case "orderbook_delta":
 			f.handleOrderbookDelta(env.Msg)
+		case "market_lifecycle_v2":
+			f.handleLifecycle(env.Msg)
+		case "market_position":
+			f.handlePosition(env.Msg)
 		case "ok":
 			f.handleOK(env.Msg)
 		case "error":
 			slog.Warn("kalshi ws error", "id", env.ID, "msg", string(env.Msg))
+			f.frames.DumpOnError("kalshi", fmt.Errorf("server error: %s", env.Msg))
 		default:
 			slog.Debug("kalshi ws: unknown message type", "type", env.Type)
 		}
 	}
 }
 
+// checkSeq tracks env's sequence number on its subscription. A gap on the
+// orderbook channel means deltas were lost and the stored books can no
+// longer be trusted, so they are marked stale and resubscribed for fresh
+// snapshots. Ticker messages carry full state, so a gap there heals itself.
+func (f *KalshiFeed) checkSeq(env wsEnvelope) {
+	if env.SID == 0 || env.Seq == 0 {
+		return
+	}
+	last, seen := f.lastSeq[env.SID]
+	f.lastSeq[env.SID] = env.Seq
+	if !seen || env.Seq == last+1 {
+		return
+	}
+
+	f.seqGaps.Add(1)
+	if env.Type != "orderbook_snapshot" && env.Type != "orderbook_delta" {
+		slog.Warn("kalshi ws: sequence gap", "type", env.Type, "sid", env.SID,
+			"expected", last+1, "got", env.Seq)
+		return
+	}
+
+	f.writeMu.Lock()
+	current := env.SID == f.orderbookSID
+	f.writeMu.Unlock()
+	if !current {
+		return // leftovers from a subscription already being replaced
+	}
+	slog.Warn("kalshi ws: orderbook sequence gap, resubscribing", "sid", env.SID,
+		"expected", last+1, "got", env.Seq)
+
+	f.mu.Lock()
+	for _, book := range f.books {
+		book.Stale = true
+	}
+	f.mu.Unlock()
+
+	delete(f.lastSeq, env.SID)
+	f.writeMu.Lock()
+	err := f.resubscribeOrderbookLocked()
+	f.writeMu.Unlock()
+	if err != nil {
+		slog.Warn("kalshi ws: orderbook resubscribe failed", "err", err)
+	}
+}
+
+// resubscribeOrderbookLocked replaces the orderbook subscription with a
+// fresh one, which makes the server resend a snapshot for every market.
+// The new SID is picked up by handleOK. Caller must hold writeMu.
+func (f *KalshiFeed) resubscribeOrderbookLocked() error {
+	tickers := make([]string, 0, len(f.subscribedTickers))
+	for t := range f.subscribedTickers {
+		tickers = append(tickers, t)
+	}
+
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	defer f.conn.SetWriteDeadline(time.Time{})
+
+	f.cmdSeq++
+	unsub := wsCommand{
+		ID:     f.cmdSeq,
+		Cmd:    "unsubscribe",
+		Params: unsubscribeParams{SIDs: []int{f.orderbookSID}},
+	}
+	if err := f.conn.WriteJSON(unsub); err != nil {
+		return err
+	}
+	f.orderbookSID = 0
+
+	f.cmdSeq++
+	sub := wsCommand{
+		ID:  f.cmdSeq,
+		Cmd: "subscribe",
+		Params: subscribeParams{
+			Channels:      []string{"orderbook_delta"},
+			MarketTickers: tickers,
+		},
+	}
+	return f.conn.WriteJSON(sub)
+}
+
 func (f *KalshiFeed) handleTicker(raw json.RawMessage) {
 	var t tickerPayload
 	if err := json.Unmarshal(raw, &t); err != nil {
@@ -328,6 +730,7 @@ func (f *KalshiFeed) handleTicker(raw json.RawMessage) {
 	p.LastPrice = t.Price
 	p.Volume = t.Volume
 	p.OpenInterest = t.OpenInterest
+	p.Updated = time.Now()
 	f.mu.Unlock()
 
 	slog.Debug("ws ticker", "ticker", t.MarketTicker, "bid", t.YesBid, "ask", t.YesAsk)
@@ -350,7 +753,10 @@ func (f *KalshiFeed) handleOrderbookSnapshot(raw json.RawMessage) {
 	}
 
 	f.mu.Lock()
-	f.books[snap.MarketTicker] = &Orderbook{Yes: yes, No: no, Ready: true}
+	f.books[snap.MarketTicker] = &Orderbook{
+		Yes: yes, No: no, Ready: true, Updated: time.Now(),
+		yesDirty: true, noDirty: true,
+	}
 	f.mu.Unlock()
 
 	slog.Debug("ws ob snapshot", "ticker", snap.MarketTicker,
@@ -374,15 +780,86 @@ func (f *KalshiFeed) handleOrderbookDelta(raw json.RawMessage) {
 	var side map[int]int
 	if d.Side == "yes" {
 		side = book.Yes
+		book.yesDirty = true
 	} else {
 		side = book.No
+		book.noDirty = true
 	}
 
 	side[d.Price] += d.Delta
 	if side[d.Price] <= 0 {
 		delete(side, d.Price)
 	}
+	book.Updated = time.Now()
+	f.mu.Unlock()
+}
+
+// lifecycleStatus maps lifecycle events to the REST status they lead to.
+var lifecycleStatus = map[string]string{
+	"activated":   "active",
+	"deactivated": "inactive",
+	"determined":  "determined",
+	"settled":     "settled",
+}
+
+// statusRank orders market statuses so a lagging REST poll can't move a
+// market back to a state the WS has already seen it leave.
+var statusRank = map[string]int{
+	"initialized": 1,
+	"active":      2,
+	"inactive":    2,
+	"closed":      3,
+	"determined":  4,
+	"settled":     5,
+	"finalized":   5,
+}
+
+func (f *KalshiFeed) handleLifecycle(raw json.RawMessage) {
+	var l lifecyclePayload
+	if err := json.Unmarshal(raw, &l); err != nil {
+		slog.Debug("kalshi ws: lifecycle unmarshal error", "err", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	meta, ok := f.metadata[l.MarketTicker]
+	if !ok {
+		return // not a market we track
+	}
+	if status, ok := lifecycleStatus[l.EventType]; ok {
+		meta.Status = status
+	}
+	if l.Result != "" {
+		meta.Result = l.Result
+	}
+	if l.EventType == "close_date_updated" && l.CloseTs > 0 {
+		meta.Close = time.Unix(l.CloseTs, 0).UTC()
+	}
+	slog.Debug("ws lifecycle", "ticker", l.MarketTicker, "event", l.EventType,
+		"status", meta.Status, "result", meta.Result)
+}
+
+func (f *KalshiFeed) handlePosition(raw json.RawMessage) {
+	var p positionPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		slog.Debug("kalshi ws: position unmarshal error", "err", err)
+		return
+	}
+
+	f.mu.Lock()
+	if f.positions != nil {
+		f.positions[p.MarketTicker] = &Position{
+			Position:    p.Position,
+			Cost:        p.PositionCost / 100,
+			RealizedPnL: p.RealizedPnL / 100,
+			FeesPaid:    p.FeesPaid / 100,
+			Updated:     time.Now(),
+		}
+	}
 	f.mu.Unlock()
+
+	slog.Debug("ws position", "ticker", p.MarketTicker, "position", p.Position)
 }
 
 func (f *KalshiFeed) handleOK(raw json.RawMessage) {
@@ -400,6 +877,10 @@ func (f *KalshiFeed) handleOK(raw json.RawMessage) {
 			f.tickerSID = e.SID
 		case "orderbook_delta":
 			f.orderbookSID = e.SID
+		case "market_lifecycle_v2":
+			f.lifecycleSID = e.SID
+		case "market_positions":
+			f.positionsSID = e.SID
 		}
 		slog.Debug("ws subscribed", "channel", e.Channel, "sid", e.SID)
 	}
@@ -431,6 +912,54 @@ func (f *KalshiFeed) subscribeLocked(tickers []string) error {
 	return nil
 }
 
+// subIDsLocked returns the known subscription ids; the orderbook one is
+// briefly unknown while it is being resubscribed. Caller must hold writeMu.
+func (f *KalshiFeed) subIDsLocked() []int {
+	sids := []int{f.tickerSID}
+	if f.orderbookSID != 0 {
+		sids = append(sids, f.orderbookSID)
+	}
+	return sids
+}
+
+// Resubscribe drops the current connection. Run reconnects and subscribes
+// afresh, so every book is rebuilt from a new snapshot.
+func (f *KalshiFeed) Resubscribe() {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	if f.conn != nil {
+		f.conn.Close()
+	}
+}
+
+// subscribeLifecycleLocked subscribes to status changes for all markets.
+// Caller must hold writeMu.
+func (f *KalshiFeed) subscribeLifecycleLocked() error {
+	f.cmdSeq++
+	cmd := wsCommand{
+		ID:     f.cmdSeq,
+		Cmd:    "subscribe",
+		Params: lifecycleParams{Channels: []string{"market_lifecycle_v2"}},
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	defer f.conn.SetWriteDeadline(time.Time{})
+	return f.conn.WriteJSON(cmd)
+}
+
+// subscribePositionsLocked subscribes to the account's position changes
+// in every market. Caller must hold writeMu.
+func (f *KalshiFeed) subscribePositionsLocked() error {
+	f.cmdSeq++
+	cmd := wsCommand{
+		ID:     f.cmdSeq,
+		Cmd:    "subscribe",
+		Params: lifecycleParams{Channels: []string{"market_positions"}},
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	defer f.conn.SetWriteDeadline(time.Time{})
+	return f.conn.WriteJSON(cmd)
+}
+
 // UpdateSubscriptions adjusts which markets the WS is subscribed to.
 // Called by the collector's discovery loop.
 func (f *KalshiFeed) UpdateSubscriptions(tickers []string) {
@@ -491,7 +1020,7 @@ func (f *KalshiFeed) UpdateSubscriptions(tickers []string) {
 				ID:  f.cmdSeq,
 				Cmd: "update_subscription",
 				Params: updateSubParams{
-					SIDs:          []int{f.tickerSID, f.orderbookSID},
+					SIDs:          f.subIDsLocked(),
 					MarketTickers: toAdd,
 					Action:        "add_markets",
 				},
@@ -514,7 +1043,7 @@ func (f *KalshiFeed) UpdateSubscriptions(tickers []string) {
 			ID:  f.cmdSeq,
 			Cmd: "update_subscription",
 			Params: updateSubParams{
-				SIDs:          []int{f.tickerSID, f.orderbookSID},
+				SIDs:          f.subIDsLocked(),
 				MarketTickers: toRemove,
 				Action:        "remove_markets",
 			},
@@ -544,6 +1073,61 @@ func (f *KalshiFeed) UpdateSubscriptions(tickers []string) {
 	}
 }
 
+// Evict drops the cached state of markets that closed more than the cache
+// TTL before now and that discovery no longer reports, along with prices
+// and books for unknown markets that have been quiet as long. Subscription
+// updates only clean up markets they unsubscribe, which misses markets
+// that vanish while disconnected or that WS messages re-create after
+// removal; without this a long-running feed grows with every window.
+// It returns the number of markets evicted.
+func (f *KalshiFeed) Evict(now time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cutoff := now.Add(-f.cacheTTL)
+	evict := make(map[string]bool)
+	for t, meta := range f.metadata {
+		if c := meta.closeOrExpiry(); !c.IsZero() && c.Before(cutoff) && !f.desiredTickers[t] {
+			evict[t] = true
+		}
+	}
+	for t, p := range f.prices {
+		if _, ok := f.metadata[t]; !ok && !f.desiredTickers[t] && p.Updated.Before(cutoff) {
+			evict[t] = true
+		}
+	}
+	for t, b := range f.books {
+		if _, ok := f.metadata[t]; !ok && !f.desiredTickers[t] && b.Updated.Before(cutoff) {
+			evict[t] = true
+		}
+	}
+	for t := range evict {
+		delete(f.metadata, t)
+		delete(f.prices, t)
+		delete(f.books, t)
+		delete(f.positions, t)
+	}
+	return len(evict)
+}
+
+// cachedLocked counts the distinct markets held in any cache. Caller holds f.mu.
+func (f *KalshiFeed) cachedLocked() int {
+	n := len(f.metadata)
+	for t := range f.prices {
+		if _, ok := f.metadata[t]; !ok {
+			n++
+		}
+	}
+	for t := range f.books {
+		_, inMeta := f.metadata[t]
+		_, inPrices := f.prices[t]
+		if !inMeta && !inPrices {
+			n++
+		}
+	}
+	return n
+}
+
 // UpdateMetadata pushes REST-sourced metadata into the feed cache.
 func (f *KalshiFeed) UpdateMetadata(markets []Market) {
 	f.mu.Lock()
@@ -552,12 +1136,29 @@ func (f *KalshiFeed) UpdateMetadata(markets []Market) {
 	for i := range markets {
 		m := &markets[i]
 		expiry, _ := m.ExpirationParsed()
-		f.metadata[m.Ticker] = &MarketMeta{
-			Status: m.Status,
-			Result: m.Result,
-			Strike: m.StrikePrice(),
-			Expiry: expiry,
+		closeTime, _ := time.Parse(time.RFC3339, m.CloseTime)
+		kind, floor, cap := m.StrikeRange()
+		settlement, _ := m.SettlementValue()
+		meta := &MarketMeta{
+			EventTicker: m.EventTicker,
+			Status:      m.Status,
+			Result:      m.Result,
+			Strike:      m.StrikePrice(),
+			StrikeType:  kind,
+			StrikeFloor: floor,
+			StrikeCap:   cap,
+			Settlement:  settlement,
+			Close:       closeTime,
+			Expiry:      expiry,
+		}
+		// Lifecycle messages may be ahead of REST; keep what they told us.
+		if old, ok := f.metadata[m.Ticker]; ok && statusRank[old.Status] > statusRank[meta.Status] {
+			meta.Status = old.Status
+			if meta.Result == "" {
+				meta.Result = old.Result
+			}
 		}
+		f.metadata[m.Ticker] = meta
 	}
 }
 
@@ -566,40 +1167,136 @@ func (f *KalshiFeed) Snapshot() []MarketSnapshot {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
+	now := time.Now()
 	result := make([]MarketSnapshot, 0, len(f.metadata))
 	for ticker, meta := range f.metadata {
-		snap := MarketSnapshot{
-			Ticker: ticker,
-			Status: meta.Status,
-			Result: meta.Result,
-			Strike: meta.Strike,
-			FromWS: true,
+		result = append(result, f.snapshotLocked(ticker, meta, now))
+	}
+	return result
+}
+
+// SnapshotForEvent returns the markets of one event, sorted by strike.
+func (f *KalshiFeed) SnapshotForEvent(eventTicker string) []MarketSnapshot {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	now := time.Now()
+	var result []MarketSnapshot
+	for ticker, meta := range f.metadata {
+		if meta.EventTicker == eventTicker {
+			result = append(result, f.snapshotLocked(ticker, meta, now))
 		}
+	}
+	sortLadder(result)
+	return result
+}
 
-		secsLeft := int(time.Until(meta.Expiry).Seconds())
-		if secsLeft < 0 {
-			secsLeft = 0
+// ActiveMarkets returns the markets of the window trading at now and of
+// the one after it, ordered by close time and then strike. A window is the
+// set of markets sharing a close time; markets past their close are left
+// out since their prices no longer trade.
+func (f *KalshiFeed) ActiveMarkets(now time.Time) []MarketSnapshot {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var closes []time.Time
+	seen := make(map[time.Time]bool)
+	for _, meta := range f.metadata {
+		c := meta.closeOrExpiry()
+		if c.After(now) && !seen[c] {
+			seen[c] = true
+			closes = append(closes, c)
 		}
-		snap.SecsLeft = secsLeft
+	}
+	sort.Slice(closes, func(i, j int) bool { return closes[i].Before(closes[j]) })
+	if len(closes) > 2 {
+		closes = closes[:2]
+	}
 
-		// Merge WS price data
-		if price, ok := f.prices[ticker]; ok {
-			snap.YesBid = price.YesBid
-			snap.YesAsk = price.YesAsk
-			snap.LastPrice = price.LastPrice
-			snap.Volume = price.Volume
-			snap.OpenInterest = price.OpenInterest
+	var result []MarketSnapshot
+	for ticker, meta := range f.metadata {
+		c := meta.closeOrExpiry()
+		for _, w := range closes {
+			if c.Equal(w) {
+				result = append(result, f.snapshotLocked(ticker, meta, now))
+				break
+			}
 		}
+	}
+	sortLadder(result)
+	return result
+}
+
+// closeOrExpiry is the close time, falling back to expiry for metadata
+// without one.
+func (m *MarketMeta) closeOrExpiry() time.Time {
+	if !m.Close.IsZero() {
+		return m.Close
+	}
+	return m.Expiry
+}
 
-		// Merge orderbook data
-		if book, ok := f.books[ticker]; ok && book.Ready {
-			snap.YesBook = sortedLevels(book.Yes)
-			snap.NoBook = sortedLevels(book.No)
+// sortLadder orders snapshots by window, then strike.
+func sortLadder(snaps []MarketSnapshot) {
+	sort.Slice(snaps, func(i, j int) bool {
+		if !snaps[i].Close.Equal(snaps[j].Close) {
+			return snaps[i].Close.Before(snaps[j].Close)
 		}
+		return snaps[i].Strike < snaps[j].Strike
+	})
+}
 
-		result = append(result, snap)
+// snapshotLocked merges one market's WS and REST state. Caller holds f.mu.
+func (f *KalshiFeed) snapshotLocked(ticker string, meta *MarketMeta, now time.Time) MarketSnapshot {
+	snap := MarketSnapshot{
+		Ticker:      ticker,
+		EventTicker: meta.EventTicker,
+		Status:      meta.Status,
+		Result:      meta.Result,
+		Strike:      meta.Strike,
+		StrikeType:  meta.StrikeType,
+		StrikeFloor: meta.StrikeFloor,
+		StrikeCap:   meta.StrikeCap,
+		Settlement:  meta.Settlement,
+		Close:       meta.Close,
+		Expiry:      meta.Expiry,
+		FromWS:      true,
 	}
-	return result
+
+	// Kalshi sends no lifecycle event when trading stops, so an active
+	// market is reported closed once its close time passes.
+	if snap.Status == "active" && !meta.Close.IsZero() && !now.Before(meta.Close) {
+		snap.Status = "closed"
+	}
+
+	secsLeft := int(meta.Expiry.Sub(now).Seconds())
+	if secsLeft < 0 {
+		secsLeft = 0
+	}
+	snap.SecsLeft = secsLeft
+
+	// Merge WS price data
+	if price, ok := f.prices[ticker]; ok {
+		snap.YesBid = price.YesBid
+		snap.YesAsk = price.YesAsk
+		snap.LastPrice = price.LastPrice
+		snap.Volume = price.Volume
+		snap.OpenInterest = price.OpenInterest
+		snap.TickerUpdate = price.Updated
+		snap.LastUpdate = price.Updated
+	}
+
+	// Merge orderbook data
+	if book, ok := f.books[ticker]; ok && book.Ready {
+		snap.YesBook, snap.NoBook = book.levels()
+		snap.BookStale = book.Stale
+		snap.BookUpdate = book.Updated
+		if book.Updated.After(snap.LastUpdate) {
+			snap.LastUpdate = book.Updated
+		}
+	}
+
+	return snap
 }
 
 // sortedLevels converts a price→qty map to a sorted [][2]int slice.
@@ -611,8 +1308,6 @@ func sortedLevels(m map[int]int) [][2]int {
 	for price, qty := range m {
 		levels = append(levels, [2]int{price, qty})
 	}
-	sort.Slice(levels, func(i, j int) bool {
-		return levels[i][0] < levels[j][0]
-	})
+	slices.SortFunc(levels, func(a, b [2]int) int { return a[0] - b[0] })
 	return levels
 }
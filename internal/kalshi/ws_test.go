@@ -0,0 +1,90 @@
+package kalshi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/config"
+)
+
+// benchFeed returns a feed tracking markets markets, each with a book of
+// levels price levels per side, and the tickers of its markets.
+func benchFeed(b *testing.B, markets, levels int) (*KalshiFeed, []string) {
+	b.Helper()
+	f := NewKalshiFeed(&config.Config{KalshiEnv: "demo"}, nil)
+	expiry := time.Now().Add(15 * time.Minute)
+	tickers := make([]string, markets)
+	for i := range tickers {
+		tickers[i] = fmt.Sprintf("KXBTC15M-25JAN0100-T%d", 95000+250*i)
+		f.metadata[tickers[i]] = &MarketMeta{
+			EventTicker: "KXBTC15M-25JAN0100",
+			Status:      "active",
+			Strike:      float64(95000 + 250*i),
+			Expiry:      expiry,
+			Close:       expiry,
+		}
+		snap := obSnapshotPayload{MarketTicker: tickers[i]}
+		for p := 1; p <= levels; p++ {
+			snap.Yes = append(snap.Yes, [2]int{p, 100 * p})
+			snap.No = append(snap.No, [2]int{p, 50 * p})
+		}
+		raw, err := json.Marshal(snap)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.handleOrderbookSnapshot(raw)
+	}
+	return f, tickers
+}
+
+// BenchmarkSnapshot measures Snapshot over 40 markets with 49-level
+// books. "few-dirty" applies 5 deltas between snapshots, as between two
+// ticks of a live feed, so only those books' sides are re-sorted;
+// "all-dirty" marks every side changed, which is what every snapshot cost
+// before sorted levels were cached.
+func BenchmarkSnapshot(b *testing.B) {
+	const markets, levels, deltas = 40, 49, 5
+
+	b.Run("few-dirty", func(b *testing.B) {
+		f, tickers := benchFeed(b, markets, levels)
+		raws := make([]json.RawMessage, deltas)
+		for i := range raws {
+			side := "yes"
+			if i%2 == 1 {
+				side = "no"
+			}
+			raws[i], _ = json.Marshal(obDeltaPayload{MarketTicker: tickers[i*7%markets], Price: 1 + i*9%levels, Delta: 1, Side: side})
+		}
+		f.Snapshot()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for _, raw := range raws {
+				f.handleOrderbookDelta(raw)
+			}
+			b.StartTimer()
+			if snaps := f.Snapshot(); len(snaps) != markets {
+				b.Fatalf("got %d markets, want %d", len(snaps), markets)
+			}
+		}
+	})
+
+	b.Run("all-dirty", func(b *testing.B) {
+		f, _ := benchFeed(b, markets, levels)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for _, book := range f.books {
+				book.yesDirty, book.noDirty = true, true
+			}
+			b.StartTimer()
+			if snaps := f.Snapshot(); len(snaps) != markets {
+				b.Fatalf("got %d markets, want %d", len(snaps), markets)
+			}
+		}
+	})
+}
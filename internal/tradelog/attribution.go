@@ -0,0 +1,53 @@
+package tradelog
+
+import (
+	"context"
+	"time"
+)
+
+// entryBuckets are the time-to-close ranges used by PnLByEntryBucket.
+// Positions entered more than the last bound before close go in "15m+".
+var entryBuckets = []struct {
+	label string
+	upTo  time.Duration
+}{
+	{"0-1m", 1 * time.Minute},
+	{"1-3m", 3 * time.Minute},
+	{"3-5m", 5 * time.Minute},
+	{"5-15m", 15 * time.Minute},
+}
+
+// PnLByEntryBucket groups settled positions by how long before the market
+// closed for trading their first buy was filled. Close rather than
+// settlement is the reference, since nothing can be entered after it.
+func PnLByEntryBucket(ctx context.Context, store *Store) ([]EntryBucketPnL, error) {
+	entries, err := store.settledEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]EntryBucketPnL, len(entryBuckets)+1)
+	for i, b := range entryBuckets {
+		out[i].Bucket = b.label
+	}
+	out[len(entryBuckets)].Bucket = "15m+"
+
+	for _, e := range entries {
+		left := e.CloseTime.Sub(e.EntryTime)
+		i := len(entryBuckets)
+		for j, b := range entryBuckets {
+			if left <= b.upTo {
+				i = j
+				break
+			}
+		}
+		r := &out[i]
+		r.Positions++
+		r.Cost += e.Cost
+		r.NetPnL += e.NetPnL
+		if e.NetPnL > 0 {
+			r.Wins++
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,201 @@
+package tradelog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Backups are SQLite snapshots named tradelog-<UTC time>.db, optionally
+// encrypted (.db.enc) with the archive key. Names sort by time.
+const (
+	backupPrefix     = "tradelog-"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// DefaultBackupDir returns the backups directory next to a SQLite
+// database: data/tradelog.db → data/backups.
+func DefaultBackupDir(dsn string) string {
+	return filepath.Join(filepath.Dir(sqlitePath(dsn)), "backups")
+}
+
+// sqlitePath strips connection parameters from a SQLite DSN.
+func sqlitePath(dsn string) string {
+	path, _, _ := strings.Cut(dsn, "?")
+	return path
+}
+
+// Backup writes a consistent snapshot of the database to dir with VACUUM
+// INTO, which runs alongside other readers and writers, checks its
+// integrity, and encrypts it when key is set. It then deletes all but the
+// newest keep backups (keep <= 0 keeps them all) and returns the new
+// backup's path. Only SQLite databases can be backed up this way.
+func (s *Store) Backup(ctx context.Context, dir string, keep int, key []byte) (string, error) {
+	if s.db.dialect != sqliteDialect {
+		return "", errors.New("backup: only SQLite databases are supported (use pg_dump for Postgres)")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("backup dir: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeFormat)+".db")
+	tmp := path + ".tmp"
+	os.Remove(tmp) // VACUUM INTO refuses to overwrite
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	if err := integrityCheck(ctx, tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("backup: %w", err)
+	}
+
+	if key != nil {
+		path += ticks.EncExt
+		err := ticks.EncryptFile(tmp, path, key)
+		os.Remove(tmp)
+		if err != nil {
+			return "", fmt.Errorf("backup: encrypt: %w", err)
+		}
+	} else if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("backup: %w", err)
+	}
+
+	if keep > 0 {
+		if err := pruneBackups(dir, keep); err != nil {
+			return path, fmt.Errorf("backup: pruning: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// Backups returns the backups in dir, oldest first.
+func Backups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, backupPrefix) &&
+			(strings.HasSuffix(name, ".db") || strings.HasSuffix(name, ".db"+ticks.EncExt)) {
+			out = append(out, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func pruneBackups(dir string, keep int) error {
+	backups, err := Backups(dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Restore replaces the SQLite database at dsn with a backup, decrypting it
+// with key if it is encrypted. The backup is checked before anything is
+// touched, and the current database, if any, is first saved as
+// <db>.pre-restore. Nothing may have the database open while it runs.
+func Restore(ctx context.Context, dsn, backup string, key []byte) error {
+	if dialectFor(dsn) != sqliteDialect {
+		return errors.New("restore: only SQLite databases are supported")
+	}
+	dbPath := sqlitePath(dsn)
+	tmp := dbPath + ".restore.tmp"
+	defer os.Remove(tmp)
+
+	var err error
+	if strings.HasSuffix(backup, ticks.EncExt) {
+		if key == nil {
+			return fmt.Errorf("restore: %w", ticks.ErrNoKey)
+		}
+		err = ticks.DecryptFile(backup, tmp, key)
+	} else {
+		err = copyFile(backup, tmp)
+	}
+	if err != nil {
+		return fmt.Errorf("restore: reading backup: %w", err)
+	}
+	if err := integrityCheck(ctx, tmp); err != nil {
+		return fmt.Errorf("restore: backup %s: %w", backup, err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		// VACUUM INTO takes in what is still in the WAL, which copying
+		// the main file alone would lose.
+		pre := dbPath + ".pre-restore"
+		os.Remove(pre)
+		db, err := sql.Open(sqliteDialect.driver, dbPath)
+		if err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+		_, err = db.ExecContext(ctx, `VACUUM INTO ?`, pre)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("restore: saving current database: %w", err)
+		}
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("restore: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// integrityCheck runs PRAGMA integrity_check on the SQLite file at path.
+func integrityCheck(ctx context.Context, path string) error {
+	db, err := sql.Open(sqliteDialect.driver, sqliteDSN(path, "mode=ro"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,289 @@
+package tradelog
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportResult counts what ImportCSV did.
+type ImportResult struct {
+	Kind     string `json:"kind"` // "fills" or "settlements"
+	Rows     int    `json:"rows"`
+	Inserted int    `json:"inserted"`
+	Skipped  int    `json:"skipped"` // already present
+}
+
+// csvAliases maps normalized header names from Kalshi statement exports
+// onto the API field names used by the store.
+var csvAliases = map[string]string{
+	"market_ticker":   "ticker",
+	"market":          "ticker",
+	"contracts":       "count",
+	"quantity":        "count",
+	"created":         "created_time",
+	"time":            "created_time",
+	"date":            "created_time",
+	"trade_time":      "created_time",
+	"result":          "market_result",
+	"settled":         "settled_time",
+	"settlement_time": "settled_time",
+	"liquidity":       "is_taker",
+	"yes_contracts":   "yes_total_count",
+	"no_contracts":    "no_total_count",
+	"payout":          "revenue",
+	"fees":            "fee_cost",
+	"fee":             "fee_cost",
+}
+
+// ImportCSV loads a Kalshi fills or settlements CSV export into the store.
+// The kind is detected from the header: a market_result column means
+// settlements, anything else is treated as fills.
+//
+// Prices and amounts are taken as cents when they are bare integers, and
+// as dollars when they carry a "$" or a decimal point.
+func ImportCSV(ctx context.Context, store *Store, path string) (ImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("reading header: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		name := normalizeHeader(h)
+		if alias, ok := csvAliases[name]; ok {
+			name = alias
+		}
+		if _, dup := cols[name]; !dup {
+			cols[name] = i
+		}
+	}
+	if _, ok := cols["ticker"]; !ok {
+		return ImportResult{}, fmt.Errorf("%s: no ticker column in header %v", path, header)
+	}
+
+	res := ImportResult{Kind: "fills"}
+	if _, ok := cols["market_result"]; ok {
+		res.Kind = "settlements"
+	}
+
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := csvRow{cols: cols, rec: rec}
+		res.Rows++
+
+		var inserted bool
+		if res.Kind == "settlements" {
+			st, err := row.settlement()
+			if err != nil {
+				return res, fmt.Errorf("line %d: %w", line, err)
+			}
+			inserted, err = store.ImportSettlement(ctx, &st)
+			if err != nil {
+				return res, err
+			}
+		} else {
+			fill, err := row.fill(store.owner())
+			if err != nil {
+				return res, fmt.Errorf("line %d: %w", line, err)
+			}
+			inserted, err = store.ImportFill(ctx, &fill)
+			if err != nil {
+				return res, err
+			}
+		}
+		if inserted {
+			res.Inserted++
+		} else {
+			res.Skipped++
+		}
+	}
+	return res, nil
+}
+
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	h = strings.NewReplacer(" ", "_", "-", "_", "(", "", ")", "").Replace(h)
+	return h
+}
+
+type csvRow struct {
+	cols map[string]int
+	rec  []string
+}
+
+func (r csvRow) str(name string) string {
+	i, ok := r.cols[name]
+	if !ok || i >= len(r.rec) {
+		return ""
+	}
+	return strings.TrimSpace(r.rec[i])
+}
+
+func (r csvRow) int(name string) (int, error) {
+	v := strings.ReplaceAll(r.str(name), ",", "")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+func (r csvRow) cents(name string) (int, error) {
+	v := strings.ReplaceAll(r.str(name), ",", "")
+	if v == "" {
+		return 0, nil
+	}
+	neg := strings.HasPrefix(v, "-") || (strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")"))
+	v = strings.Trim(v, "-()")
+	dollars := strings.HasPrefix(v, "$") || strings.Contains(v, ".")
+	v = strings.TrimSuffix(strings.TrimPrefix(v, "$"), "¢")
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if dollars {
+		f *= 100
+	}
+	c := int(f + 0.5)
+	if neg {
+		c = -c
+	}
+	return c, nil
+}
+
+func (r csvRow) time(name string) (time.Time, error) {
+	v := r.str(name)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05 MST",
+		"2006-01-02T15:04:05",
+		"01/02/2006 15:04:05",
+		"01/02/2006 3:04 PM",
+		"2006-01-02",
+	} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: unrecognized time %q", name, v)
+}
+
+// fill parses a fills row of account's statement.
+func (r csvRow) fill(account string) (Fill, error) {
+	f := Fill{
+		TradeID: r.str("trade_id"),
+		OrderID: r.str("order_id"),
+		Ticker:  r.str("ticker"),
+		Side:    strings.ToLower(r.str("side")),
+		Action:  strings.ToLower(r.str("action")),
+	}
+	var err error
+	if f.CreatedTime, err = r.time("created_time"); err != nil {
+		return f, err
+	}
+	if f.Count, err = r.int("count"); err != nil {
+		return f, err
+	}
+	if f.YesPrice, err = r.cents("yes_price"); err != nil {
+		return f, err
+	}
+	if f.NoPrice, err = r.cents("no_price"); err != nil {
+		return f, err
+	}
+	if _, ok := r.cols["yes_price"]; !ok {
+		// Single price column in the traded side's terms.
+		p, err := r.cents("price")
+		if err != nil {
+			return f, err
+		}
+		if f.Side == "no" {
+			f.NoPrice, f.YesPrice = p, 100-p
+		} else {
+			f.YesPrice, f.NoPrice = p, 100-p
+		}
+	} else if _, ok := r.cols["no_price"]; !ok {
+		f.NoPrice = 100 - f.YesPrice
+	}
+
+	switch strings.ToLower(r.str("is_taker")) {
+	case "true", "1", "yes", "taker":
+		f.IsTaker = true
+	}
+
+	if f.Side != "yes" && f.Side != "no" {
+		return f, fmt.Errorf("side %q: want yes or no", f.Side)
+	}
+	if f.Action != "buy" && f.Action != "sell" {
+		return f, fmt.Errorf("action %q: want buy or sell", f.Action)
+	}
+
+	// Statements may omit trade IDs; derive a stable one so re-importing
+	// the same file is a no-op. Other accounts' IDs include the account,
+	// as two can make identical trades.
+	if f.TradeID == "" {
+		key := fmt.Sprintf("%s|%s|%s|%s|%d|%d",
+			f.Ticker, f.CreatedTime.Format(time.RFC3339Nano), f.Side, f.Action, f.YesPrice, f.Count)
+		if account != DefaultAccount {
+			key = account + "|" + key
+		}
+		h := sha1.Sum([]byte(key))
+		f.TradeID = "csv-" + hex.EncodeToString(h[:8])
+	}
+	return f, nil
+}
+
+func (r csvRow) settlement() (Settlement, error) {
+	st := Settlement{
+		Ticker:       r.str("ticker"),
+		MarketResult: strings.ToLower(r.str("market_result")),
+	}
+	var err error
+	if st.SettledTime, err = r.time("settled_time"); err != nil {
+		return st, err
+	}
+	if st.YesTotalCount, err = r.int("yes_total_count"); err != nil {
+		return st, err
+	}
+	if st.NoTotalCount, err = r.int("no_total_count"); err != nil {
+		return st, err
+	}
+	if st.YesCost, err = r.cents("yes_cost"); err != nil {
+		return st, err
+	}
+	if st.NoCost, err = r.cents("no_cost"); err != nil {
+		return st, err
+	}
+	if st.Revenue, err = r.cents("revenue"); err != nil {
+		return st, err
+	}
+	if st.FeeCost, err = r.cents("fee_cost"); err != nil {
+		return st, err
+	}
+	return st, nil
+}
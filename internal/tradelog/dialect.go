@@ -0,0 +1,217 @@
+package tradelog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialect captures what differs between the supported backends. Queries
+// are written once with ? placeholders and rebound per dialect.
+type dialect struct {
+	name   string // also the migrations subdirectory
+	driver string
+	// tableExists takes one placeholder, the table name.
+	tableExists string
+	// columns lists table or view name, "table" or "view", column name
+	// and type, tables first, columns in declared order.
+	columns string
+}
+
+var (
+	sqliteDialect = dialect{
+		name:        "sqlite",
+		driver:      "sqlite",
+		tableExists: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		columns: `
+			SELECT m.name, m.type, p.name, p.type
+			FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+			ORDER BY m.type, m.name, p.cid`,
+	}
+	postgresDialect = dialect{
+		name:        "postgres",
+		driver:      "pgx",
+		tableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`,
+		columns: `
+			SELECT c.table_name, CASE t.table_type WHEN 'VIEW' THEN 'view' ELSE 'table' END,
+				c.column_name, c.data_type
+			FROM information_schema.columns c
+			JOIN information_schema.tables t
+				ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+			WHERE c.table_schema = current_schema()
+			ORDER BY 2, 1, c.ordinal_position`,
+	}
+)
+
+// dialectFor picks the backend from a DSN: postgres:// or postgresql://
+// URLs select Postgres, anything else is a SQLite file path.
+func dialectFor(dsn string) dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresDialect
+	}
+	return sqliteDialect
+}
+
+// rebind rewrites ? placeholders to $1, $2, ... for Postgres. None of the
+// queries in this package contain a literal ?.
+func (d dialect) rebind(q string) string {
+	if d.name != "postgres" {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SQLite connection settings. Every connection waits up to busyTimeout
+// for a lock, writes start with BEGIN IMMEDIATE so they queue on that
+// timeout instead of failing on lock upgrade, and a single writer
+// connection means the process never contends with itself. Reads use a
+// separate pool, which WAL lets run alongside the writer.
+const (
+	busyTimeout  = 5 * time.Second
+	busyRetries  = 4
+	busyBackoff  = 100 * time.Millisecond
+	readPoolSize = 4
+)
+
+// boundDB is a *sql.DB that rebinds every context query for its dialect.
+// For SQLite, queries go to a read-only pool and everything else to the
+// single-connection writer.
+type boundDB struct {
+	*sql.DB
+	read    *sql.DB
+	dialect dialect
+}
+
+func openDB(dsn string) (*boundDB, error) {
+	d := dialectFor(dsn)
+	if d != sqliteDialect {
+		conn, err := sql.Open(d.driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &boundDB{DB: conn, read: conn, dialect: d}, nil
+	}
+
+	ms := busyTimeout.Milliseconds()
+	w, err := sql.Open(d.driver, sqliteDSN(dsn,
+		fmt.Sprintf("_pragma=busy_timeout(%d)", ms), "_pragma=journal_mode(WAL)", "_txlock=immediate"))
+	if err != nil {
+		return nil, err
+	}
+	w.SetMaxOpenConns(1)
+
+	r, err := sql.Open(d.driver, sqliteDSN(dsn,
+		fmt.Sprintf("_pragma=busy_timeout(%d)", ms), "_pragma=query_only(1)"))
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	r.SetMaxOpenConns(readPoolSize)
+
+	return &boundDB{DB: w, read: r, dialect: d}, nil
+}
+
+func sqliteDSN(path string, params ...string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + strings.Join(params, "&")
+}
+
+func (c *boundDB) Close() error {
+	err := c.DB.Close()
+	if c.read != c.DB {
+		if rerr := c.read.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+func (c *boundDB) ExecContext(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := retryBusy(ctx, func() error {
+		var err error
+		res, err = c.DB.ExecContext(ctx, c.dialect.rebind(q), args...)
+		return err
+	})
+	return res, err
+}
+
+func (c *boundDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := retryBusy(ctx, func() error {
+		var err error
+		tx, err = c.DB.BeginTx(ctx, opts)
+		return err
+	})
+	return tx, err
+}
+
+func (c *boundDB) QueryContext(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := retryBusy(ctx, func() error {
+		var err error
+		rows, err = c.read.QueryContext(ctx, c.dialect.rebind(q), args...)
+		return err
+	})
+	return rows, err
+}
+
+func (c *boundDB) QueryRowContext(ctx context.Context, q string, args ...any) *sql.Row {
+	return c.read.QueryRowContext(ctx, c.dialect.rebind(q), args...)
+}
+
+// retryBusy reruns fn while SQLite reports the database busy or locked,
+// which busy_timeout alone doesn't cover when another process holds the
+// lock for longer.
+func retryBusy(ctx context.Context, fn func() error) error {
+	wait := busyBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if !isBusy(err) || attempt >= busyRetries {
+			return err
+		}
+		slog.Debug("sqlite busy, retrying", "attempt", attempt+1, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func isBusy(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package tradelog
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Markout horizons measured by ExecQuality.
+const (
+	markoutShort = 10 * time.Second
+	markoutLong  = 60 * time.Second
+)
+
+// ExecQuality computes slippage, markouts and a queue-position proxy for
+// every fill that has a fresh two-sided snapshot in the collector archives,
+// and stores them in fill_metrics. It returns the number of fills measured.
+func ExecQuality(ctx context.Context, store *Store, dataDir, prefix string) (int, error) {
+	fills, err := store.Fills(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("loading fills: %w", err)
+	}
+
+	var n int
+	err = forEachDay(fills, dataDir, prefix, func(day []Fill, obs map[string][]observation) error {
+		for _, f := range day {
+			m, ok := measureFill(f, obs[f.Ticker])
+			if !ok {
+				continue
+			}
+			if err := store.UpsertFillMetrics(ctx, &m); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// measureFill scores one fill. All prices are expressed in YES terms and
+// signed by direction: buying YES or selling NO is long, the rest short.
+func measureFill(f Fill, obs []observation) (FillMetrics, bool) {
+	i := snapshotAt(obs, f.CreatedTime)
+	if i < 0 || f.CreatedTime.Sub(obs[i].ts) > maxSnapshotLag {
+		return FillMetrics{}, false
+	}
+	snap := obs[i].snap
+	mid, ok := midpoint(obs[i])
+	if !ok {
+		return FillMetrics{}, false
+	}
+
+	dir := 1.0
+	long := (f.Side == "yes") == (f.Action == "buy")
+	if !long {
+		dir = -1
+	}
+
+	m := FillMetrics{
+		TradeID:  f.TradeID,
+		IsTaker:  f.IsTaker,
+		Count:    f.Count,
+		Mid:      mid,
+		Slippage: dir * (float64(f.YesPrice) - mid),
+	}
+	m.Markout10s = markout(obs, f.CreatedTime.Add(markoutShort), mid, dir)
+	m.Markout60s = markout(obs, f.CreatedTime.Add(markoutLong), mid, dir)
+
+	// A resting long-YES order sits on the YES bid ladder at the YES price;
+	// a short one sits on the NO ladder at the NO price. The snapshot size
+	// at that level is what was queued there just before the fill.
+	if !f.IsTaker {
+		book, price := snap.YesBook, f.YesPrice
+		if !long {
+			book, price = snap.NoBook, f.NoPrice
+		}
+		q := 0
+		for _, lvl := range book {
+			if lvl[0] == price {
+				q = lvl[1]
+				break
+			}
+		}
+		m.QueueAhead = &q
+	}
+	return m, true
+}
+
+// markout is the directional move of the midpoint from mid to the last
+// snapshot at or before t, or nil if there is no fresh snapshot there.
+func markout(obs []observation, t time.Time, mid, dir float64) *float64 {
+	i := snapshotAt(obs, t)
+	if i < 0 || t.Sub(obs[i].ts) > maxSnapshotLag {
+		return nil
+	}
+	later, ok := midpoint(obs[i])
+	if !ok {
+		return nil
+	}
+	v := dir * (later - mid)
+	return &v
+}
+
+func midpoint(o observation) (float64, bool) {
+	if o.snap.YesBid <= 0 || o.snap.YesAsk <= 0 || o.snap.YesAsk < o.snap.YesBid {
+		return 0, false
+	}
+	return float64(o.snap.YesBid+o.snap.YesAsk) / 2, true
+}
@@ -0,0 +1,210 @@
+package tradelog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+)
+
+// FlattenAction is what the Flattener does with a position inside the
+// pre-expiry window.
+type FlattenAction string
+
+const (
+	FlattenSell   FlattenAction = "sell"   // sell the position at any price
+	FlattenSettle FlattenAction = "settle" // log it and let it settle
+)
+
+// maxFlattenAttempts bounds how many sell orders are sent for one market,
+// so a thin book can't turn into an order storm.
+const maxFlattenAttempts = 3
+
+// FlattenConfig controls a Flattener.
+type FlattenConfig struct {
+	Before   time.Duration // act this long before the market closes for trading
+	Action   FlattenAction
+	Interval time.Duration // how often to poll positions
+	DryRun   bool          // log decisions without sending orders
+}
+
+// Flattener watches open positions and, shortly before each market stops
+// trading, either sells them or leaves them to settle. The window is
+// measured to close_time rather than settlement, since orders are only
+// accepted while the market is open.
+type Flattener struct {
+	client kalshi.API
+	cfg    FlattenConfig
+	placer *OrderPlacer // nil: orders go straight to the client
+
+	closeTimes map[string]time.Time
+	attempts   map[string]int
+	decided    map[string]bool // settle decisions already logged
+}
+
+func NewFlattener(client kalshi.API, cfg FlattenConfig) *Flattener {
+	return &Flattener{
+		client:     client,
+		cfg:        cfg,
+		closeTimes: make(map[string]time.Time),
+		attempts:   make(map[string]int),
+		decided:    make(map[string]bool),
+	}
+}
+
+// SetOrderPlacer sends the Flattener's orders through p, recording the
+// context each was placed in.
+func (f *Flattener) SetOrderPlacer(p *OrderPlacer) {
+	f.placer = p
+}
+
+// Run polls until ctx is cancelled. Poll errors are logged and retried on
+// the next interval.
+func (f *Flattener) Run(ctx context.Context) error {
+	slog.Info("flattener started", "before", f.cfg.Before, "action", f.cfg.Action, "dry_run", f.cfg.DryRun)
+
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Check(ctx); err != nil {
+			slog.Error("flattener check failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check makes one pass over the current positions.
+func (f *Flattener) Check(ctx context.Context) error {
+	positions, err := f.openPositions(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching positions: %w", err)
+	}
+
+	now := time.Now()
+	for _, p := range positions {
+		closeTime, err := f.closeTime(ctx, p.Ticker)
+		if err != nil {
+			slog.Warn("flattener: no close time", "ticker", p.Ticker, "err", err)
+			continue
+		}
+		left := closeTime.Sub(now)
+		if left > f.cfg.Before || left <= 0 {
+			continue
+		}
+		f.decide(ctx, p, left)
+	}
+
+	// Forget markets that closed long ago so a long-running daemon stays small.
+	for t, ct := range f.closeTimes {
+		if now.Sub(ct) > time.Hour {
+			delete(f.closeTimes, t)
+			delete(f.attempts, t)
+			delete(f.decided, t)
+		}
+	}
+	return nil
+}
+
+func (f *Flattener) decide(ctx context.Context, p kalshi.MarketPosition, left time.Duration) {
+	side, count := "yes", p.Position
+	if p.Position < 0 {
+		side, count = "no", -p.Position
+	}
+	log := slog.With("ticker", p.Ticker, "side", side, "count", count, "secs_to_close", int(left.Seconds()))
+
+	if f.cfg.Action == FlattenSettle {
+		if !f.decided[p.Ticker] {
+			log.Info("flattener: leaving position to settle")
+			f.decided[p.Ticker] = true
+		}
+		return
+	}
+
+	if f.attempts[p.Ticker] >= maxFlattenAttempts {
+		if !f.decided[p.Ticker] {
+			log.Warn("flattener: giving up, position still open", "attempts", f.attempts[p.Ticker])
+			f.decided[p.Ticker] = true
+		}
+		return
+	}
+	f.attempts[p.Ticker]++
+
+	if f.cfg.DryRun {
+		log.Info("flattener: would sell (dry run)")
+		return
+	}
+
+	// A 1¢ immediate-or-cancel limit takes whatever the book offers without
+	// leaving a resting order behind; the floor stops it flipping the side.
+	floor := 0
+	req := kalshi.CreateOrderRequest{
+		Ticker:            p.Ticker,
+		ClientOrderID:     fmt.Sprintf("flatten-%s-%d", p.Ticker, time.Now().UnixNano()),
+		Action:            "sell",
+		Side:              side,
+		Type:              "limit",
+		Count:             count,
+		TimeInForce:       "immediate_or_cancel",
+		SellPositionFloor: &floor,
+	}
+	if side == "yes" {
+		req.YesPrice = 1
+	} else {
+		req.NoPrice = 1
+	}
+
+	place := f.client.CreateOrder
+	if f.placer != nil {
+		place = f.placer.Place
+	}
+	o, err := place(ctx, req)
+	if err != nil {
+		log.Error("flattener: sell failed", "attempt", f.attempts[p.Ticker], "err", err)
+		return
+	}
+	log.Info("flattener: sold", "order_id", o.OrderID, "status", o.Status, "filled", o.FilledQuantity)
+}
+
+func (f *Flattener) openPositions(ctx context.Context) ([]kalshi.MarketPosition, error) {
+	var all []kalshi.MarketPosition
+	var cursor string
+	for {
+		positions, next, err := f.client.GetPositions(ctx, kalshi.PositionParams{Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range positions {
+			if p.Position != 0 {
+				all = append(all, p)
+			}
+		}
+		if next == "" || len(positions) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+// closeTime returns a market's trading close, cached since it never moves.
+func (f *Flattener) closeTime(ctx context.Context, ticker string) (time.Time, error) {
+	if t, ok := f.closeTimes[ticker]; ok {
+		return t, nil
+	}
+	m, err := f.client.GetMarket(ctx, ticker)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, m.CloseTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing close_time %q: %w", m.CloseTime, err)
+	}
+	f.closeTimes[ticker] = t
+	return t, nil
+}
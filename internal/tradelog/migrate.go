@@ -0,0 +1,171 @@
+package tradelog
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in migrations/<dialect>/NNNN_name.sql and are applied in
+// version order, each in its own transaction. Every dialect carries the
+// same versions. Never edit a shipped migration; add a new file instead.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+func loadMigrations(d dialect) ([]Migration, error) {
+	paths, err := fs.Glob(migrationFiles, "migrations/"+d.name+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Migration
+	seen := make(map[int]string)
+	for _, p := range paths {
+		base := strings.TrimSuffix(path.Base(p), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.sql", p)
+		}
+		v, err := strconv.Atoi(num)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s: bad version %q", p, num)
+		}
+		if prev, dup := seen[v]; dup {
+			return nil, fmt.Errorf("migration version %d used by %s and %s", v, prev, p)
+		}
+		seen[v] = p
+
+		body, err := migrationFiles.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Migration{Version: v, Name: name, SQL: string(body)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// legacyColumns were added to SQLite tables by in-place ALTERs before
+// versioned migrations existed. The baseline's CREATE IF NOT EXISTS can't add them
+// to an older table, so they are patched in when adopting such a database.
+var legacyColumns = []struct{ table, column, def string }{
+	{"markets", "event_ticker", "TEXT NOT NULL DEFAULT ''"},
+	{"markets", "title", "TEXT NOT NULL DEFAULT ''"},
+	{"markets", "strike", "REAL NOT NULL DEFAULT 0"},
+	{"markets", "open_time", "DATETIME"},
+	{"markets", "result", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate brings db up to the latest migration and returns the migrations
+// that were (or, with dryRun, would be) applied.
+func migrate(db *boundDB, dryRun bool) ([]Migration, error) {
+	ctx := context.Background()
+	all, err := loadMigrations(db.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range all {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(ctx, db, m, current == 0); err != nil {
+			return nil, err
+		}
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	return pending, nil
+}
+
+// schemaVersion returns the highest applied migration, or 0 for a new or
+// pre-versioning database.
+func schemaVersion(ctx context.Context, db *boundDB) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, db.dialect.tableExists, "schema_migrations").Scan(&n); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	var v int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return v, nil
+}
+
+func applyMigration(ctx context.Context, db *boundDB, m Migration, adopting bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if adopting && m.Version == 1 && db.dialect == sqliteDialect {
+		if err := addLegacyColumns(tx); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, db.dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("recording migration %d: %w", m.Version, err)
+	}
+	return tx.Commit()
+}
+
+func addLegacyColumns(tx *sql.Tx) error {
+	for _, c := range legacyColumns {
+		var n int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.def)); err != nil {
+			return fmt.Errorf("adding %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
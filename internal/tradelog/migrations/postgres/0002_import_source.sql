@@ -0,0 +1,5 @@
+-- Rows can now come from CSV account statements as well as the API.
+-- Imported fills often have no matching order, so the order FK goes.
+ALTER TABLE fills ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
+ALTER TABLE settlements ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
+ALTER TABLE fills DROP CONSTRAINT IF EXISTS fills_order_id_fkey;
@@ -0,0 +1,19 @@
+-- What the market looked like when an order was placed: the last recorded
+-- snapshot of its market, BRTI and realized volatility (see OrderPlacer).
+CREATE TABLE IF NOT EXISTS order_context (
+	order_id      TEXT PRIMARY KEY,
+	ticker        TEXT NOT NULL,
+	placed_time   TIMESTAMPTZ NOT NULL,
+	source        TEXT NOT NULL DEFAULT '',
+	snapshot_time TIMESTAMPTZ,
+	yes_bid       INTEGER NOT NULL DEFAULT 0,
+	yes_ask       INTEGER NOT NULL DEFAULT 0,
+	last_price    INTEGER NOT NULL DEFAULT 0,
+	strike        DOUBLE PRECISION NOT NULL DEFAULT 0,
+	secs_left     INTEGER NOT NULL DEFAULT 0,
+	status        TEXT NOT NULL DEFAULT '',
+	yes_book      TEXT NOT NULL DEFAULT '',
+	no_book       TEXT NOT NULL DEFAULT '',
+	brti          DOUBLE PRECISION NOT NULL DEFAULT 0,
+	vol           DOUBLE PRECISION NOT NULL DEFAULT 0
+);
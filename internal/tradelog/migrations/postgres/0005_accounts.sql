@@ -0,0 +1,71 @@
+-- Rows belong to a Kalshi account, so one database can hold several (see
+-- KALSHI_ACCOUNTS). Rows from before accounts belong to 'default'.
+-- Markets are exchange data shared by every account; fill_context and
+-- fill_metrics reach their account through fills.
+ALTER TABLE orders ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE fills ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE order_context ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE settlements ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_orders_account ON orders(account);
+CREATE INDEX IF NOT EXISTS idx_fills_account ON fills(account, ticker);
+
+-- Two accounts can settle the same market, so settlements are keyed by
+-- account and ticker.
+ALTER TABLE settlements DROP CONSTRAINT settlements_pkey;
+ALTER TABLE settlements ADD PRIMARY KEY (account, ticker);
+
+-- The views gain a leading account column, which CREATE OR REPLACE can't
+-- add.
+DROP VIEW IF EXISTS v_positions;
+DROP VIEW IF EXISTS v_daily_pnl;
+
+-- Positions are per account: two accounts in one market are two positions.
+CREATE VIEW v_positions AS
+SELECT
+	f.account,
+	f.ticker,
+	SUM(CASE WHEN f.side = 'yes' AND f.action = 'buy' THEN f.count
+	         WHEN f.side = 'yes' AND f.action = 'sell' THEN -f.count
+	         ELSE 0 END) AS yes_contracts,
+	SUM(CASE WHEN f.side = 'no' AND f.action = 'buy' THEN f.count
+	         WHEN f.side = 'no' AND f.action = 'sell' THEN -f.count
+	         ELSE 0 END) AS no_contracts,
+	SUM(CASE WHEN f.side = 'yes' AND f.action = 'buy' THEN f.yes_price * f.count
+	         WHEN f.side = 'yes' AND f.action = 'sell' THEN -f.yes_price * f.count
+	         ELSE 0 END) AS yes_cost,
+	SUM(CASE WHEN f.side = 'no' AND f.action = 'buy' THEN f.no_price * f.count
+	         WHEN f.side = 'no' AND f.action = 'sell' THEN -f.no_price * f.count
+	         ELSE 0 END) AS no_cost,
+	COALESCE(s.market_result, '') AS market_result,
+	COALESCE(s.revenue, 0) AS revenue
+FROM fills f
+LEFT JOIN settlements s ON s.account = f.account AND s.ticker = f.ticker
+GROUP BY f.account, f.ticker, s.market_result, s.revenue;
+
+-- Daily PnL of all accounts combined, as before.
+CREATE VIEW v_daily_pnl AS
+SELECT
+	TO_CHAR(s.settled_time AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date,
+	SUM(s.revenue) AS revenue,
+	SUM(s.yes_cost + s.no_cost) AS cost,
+	SUM(s.revenue - s.yes_cost - s.no_cost) AS net_pnl,
+	COUNT(*) AS trades
+FROM settlements s
+WHERE s.revenue != 0 OR s.yes_cost != 0 OR s.no_cost != 0
+GROUP BY 1
+ORDER BY date;
+
+-- Daily PnL of each account.
+CREATE VIEW v_account_daily_pnl AS
+SELECT
+	s.account,
+	TO_CHAR(s.settled_time AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date,
+	SUM(s.revenue) AS revenue,
+	SUM(s.yes_cost + s.no_cost) AS cost,
+	SUM(s.revenue - s.yes_cost - s.no_cost) AS net_pnl,
+	COUNT(*) AS trades
+FROM settlements s
+WHERE s.revenue != 0 OR s.yes_cost != 0 OR s.no_cost != 0
+GROUP BY 1, 2
+ORDER BY account, date;
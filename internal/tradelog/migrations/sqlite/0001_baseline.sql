@@ -1,9 +1,21 @@
-package tradelog
+-- Baseline: the schema as it stood when versioned migrations were
+-- introduced. Everything is IF NOT EXISTS so databases created before
+-- versioning adopt it without changes.
+
+CREATE TABLE IF NOT EXISTS markets (
+	ticker          TEXT PRIMARY KEY,
+	event_ticker    TEXT NOT NULL DEFAULT '',
+	title           TEXT NOT NULL DEFAULT '',
+	strike          REAL NOT NULL DEFAULT 0,
+	open_time       DATETIME,
+	close_time      DATETIME NOT NULL,
+	expiration_time DATETIME NOT NULL,
+	result          TEXT NOT NULL DEFAULT ''
+);
 
-const schemaDDL = `
 CREATE TABLE IF NOT EXISTS orders (
 	order_id          TEXT PRIMARY KEY,
-	ticker            TEXT NOT NULL,
+	ticker            TEXT NOT NULL REFERENCES markets(ticker),
 	action            TEXT NOT NULL,
 	side              TEXT NOT NULL,
 	type              TEXT NOT NULL,
@@ -25,7 +37,7 @@ CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
 CREATE TABLE IF NOT EXISTS fills (
 	trade_id     TEXT PRIMARY KEY,
 	order_id     TEXT NOT NULL REFERENCES orders(order_id),
-	ticker       TEXT NOT NULL,
+	ticker       TEXT NOT NULL REFERENCES markets(ticker),
 	side         TEXT NOT NULL,
 	action       TEXT NOT NULL,
 	yes_price    INTEGER NOT NULL DEFAULT 0,
@@ -50,6 +62,30 @@ CREATE TABLE IF NOT EXISTS settlements (
 	settled_time   DATETIME NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS fill_context (
+	trade_id      TEXT PRIMARY KEY REFERENCES fills(trade_id),
+	snapshot_time DATETIME,
+	snapshot_lag  REAL NOT NULL DEFAULT 0,
+	yes_bid       INTEGER NOT NULL DEFAULT 0,
+	yes_ask       INTEGER NOT NULL DEFAULT 0,
+	brti          REAL NOT NULL DEFAULT 0,
+	strike        REAL NOT NULL DEFAULT 0,
+	secs_left     INTEGER NOT NULL DEFAULT 0,
+	status        TEXT NOT NULL DEFAULT '',
+	anomaly       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS fill_metrics (
+	trade_id     TEXT PRIMARY KEY REFERENCES fills(trade_id),
+	is_taker     BOOLEAN NOT NULL DEFAULT 0,
+	count        INTEGER NOT NULL DEFAULT 0,
+	mid          REAL NOT NULL,
+	slippage     REAL NOT NULL,
+	markout_10s  REAL,
+	markout_60s  REAL,
+	queue_ahead  INTEGER
+);
+
 CREATE VIEW IF NOT EXISTS v_positions AS
 SELECT
 	f.ticker,
@@ -82,4 +118,3 @@ FROM settlements s
 WHERE s.revenue != 0 OR s.yes_cost != 0 OR s.no_cost != 0
 GROUP BY DATE(s.settled_time)
 ORDER BY date;
-`
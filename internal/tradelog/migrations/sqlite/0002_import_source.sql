@@ -0,0 +1,3 @@
+-- Rows can now come from CSV account statements as well as the API.
+ALTER TABLE fills ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
+ALTER TABLE settlements ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
@@ -0,0 +1,2 @@
+-- Fees charged on a settled position, in cents, as reported by Kalshi.
+ALTER TABLE settlements ADD COLUMN fee_cost INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,19 @@
+-- What the market looked like when an order was placed: the last recorded
+-- snapshot of its market, BRTI and realized volatility (see OrderPlacer).
+CREATE TABLE IF NOT EXISTS order_context (
+	order_id      TEXT PRIMARY KEY,
+	ticker        TEXT NOT NULL,
+	placed_time   DATETIME NOT NULL,
+	source        TEXT NOT NULL DEFAULT '',
+	snapshot_time DATETIME,
+	yes_bid       INTEGER NOT NULL DEFAULT 0,
+	yes_ask       INTEGER NOT NULL DEFAULT 0,
+	last_price    INTEGER NOT NULL DEFAULT 0,
+	strike        REAL NOT NULL DEFAULT 0,
+	secs_left     INTEGER NOT NULL DEFAULT 0,
+	status        TEXT NOT NULL DEFAULT '',
+	yes_book      TEXT NOT NULL DEFAULT '',
+	no_book       TEXT NOT NULL DEFAULT '',
+	brti          REAL NOT NULL DEFAULT 0,
+	vol           REAL NOT NULL DEFAULT 0
+);
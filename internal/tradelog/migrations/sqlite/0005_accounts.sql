@@ -0,0 +1,91 @@
+-- Rows belong to a Kalshi account, so one database can hold several (see
+-- KALSHI_ACCOUNTS). Rows from before accounts belong to 'default'.
+-- Markets are exchange data shared by every account; fill_context and
+-- fill_metrics reach their account through fills.
+ALTER TABLE orders ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE fills ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE order_context ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_orders_account ON orders(account);
+CREATE INDEX IF NOT EXISTS idx_fills_account ON fills(account, ticker);
+
+-- Two accounts can settle the same market, so settlements are keyed by
+-- account and ticker. SQLite can't change a primary key in place.
+DROP VIEW IF EXISTS v_positions;
+DROP VIEW IF EXISTS v_daily_pnl;
+
+CREATE TABLE settlements_new (
+	account        TEXT NOT NULL DEFAULT 'default',
+	ticker         TEXT NOT NULL,
+	market_result  TEXT NOT NULL DEFAULT '',
+	no_total_count INTEGER NOT NULL DEFAULT 0,
+	no_cost        INTEGER NOT NULL DEFAULT 0,
+	yes_total_count INTEGER NOT NULL DEFAULT 0,
+	yes_cost       INTEGER NOT NULL DEFAULT 0,
+	revenue        INTEGER NOT NULL DEFAULT 0,
+	settled_time   DATETIME NOT NULL,
+	source         TEXT NOT NULL DEFAULT 'api',
+	fee_cost       INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (account, ticker)
+);
+
+INSERT INTO settlements_new (ticker, market_result, no_total_count, no_cost,
+	yes_total_count, yes_cost, revenue, settled_time, source, fee_cost)
+SELECT ticker, market_result, no_total_count, no_cost,
+	yes_total_count, yes_cost, revenue, settled_time, source, fee_cost
+FROM settlements;
+
+DROP TABLE settlements;
+ALTER TABLE settlements_new RENAME TO settlements;
+
+-- Positions are per account: two accounts in one market are two positions.
+CREATE VIEW v_positions AS
+SELECT
+	f.account,
+	f.ticker,
+	SUM(CASE WHEN f.side = 'yes' AND f.action = 'buy' THEN f.count
+	         WHEN f.side = 'yes' AND f.action = 'sell' THEN -f.count
+	         ELSE 0 END) AS yes_contracts,
+	SUM(CASE WHEN f.side = 'no' AND f.action = 'buy' THEN f.count
+	         WHEN f.side = 'no' AND f.action = 'sell' THEN -f.count
+	         ELSE 0 END) AS no_contracts,
+	SUM(CASE WHEN f.side = 'yes' AND f.action = 'buy' THEN f.yes_price * f.count
+	         WHEN f.side = 'yes' AND f.action = 'sell' THEN -f.yes_price * f.count
+	         ELSE 0 END) AS yes_cost,
+	SUM(CASE WHEN f.side = 'no' AND f.action = 'buy' THEN f.no_price * f.count
+	         WHEN f.side = 'no' AND f.action = 'sell' THEN -f.no_price * f.count
+	         ELSE 0 END) AS no_cost,
+	COALESCE(s.market_result, '') AS market_result,
+	COALESCE(s.revenue, 0) AS revenue
+FROM fills f
+LEFT JOIN settlements s ON s.account = f.account AND s.ticker = f.ticker
+GROUP BY f.account, f.ticker;
+
+-- Daily PnL of all accounts combined, as before. Times are stored as
+-- "2006-01-02 15:04:05 +0000 UTC", which DATE() can't parse whole, so
+-- only the date and time are passed to it.
+CREATE VIEW v_daily_pnl AS
+SELECT
+	DATE(SUBSTR(s.settled_time, 1, 19)) AS date,
+	SUM(s.revenue) AS revenue,
+	SUM(s.yes_cost + s.no_cost) AS cost,
+	SUM(s.revenue - s.yes_cost - s.no_cost) AS net_pnl,
+	COUNT(*) AS trades
+FROM settlements s
+WHERE s.revenue != 0 OR s.yes_cost != 0 OR s.no_cost != 0
+GROUP BY DATE(SUBSTR(s.settled_time, 1, 19))
+ORDER BY date;
+
+-- Daily PnL of each account.
+CREATE VIEW v_account_daily_pnl AS
+SELECT
+	s.account,
+	DATE(SUBSTR(s.settled_time, 1, 19)) AS date,
+	SUM(s.revenue) AS revenue,
+	SUM(s.yes_cost + s.no_cost) AS cost,
+	SUM(s.revenue - s.yes_cost - s.no_cost) AS net_pnl,
+	COUNT(*) AS trades
+FROM settlements s
+WHERE s.revenue != 0 OR s.yes_cost != 0 OR s.no_cost != 0
+GROUP BY s.account, DATE(SUBSTR(s.settled_time, 1, 19))
+ORDER BY s.account, date;
@@ -0,0 +1,100 @@
+package tradelog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/alert"
+)
+
+// settlementAlertMaxAge bounds how old a settlement can be and still be
+// announced, so the first sync into an empty database doesn't replay the
+// whole account history as alerts.
+const settlementAlertMaxAge = 24 * time.Hour
+
+// SettlementNotifier sends an alert for each settled position that shows
+// up in the store between calls to Check.
+type SettlementNotifier struct {
+	store    *Store
+	notifier alert.Notifier
+	seen     map[positionKey]bool
+}
+
+// NewSettlementNotifier remembers the settlements already stored, so only
+// later ones are announced.
+func NewSettlementNotifier(ctx context.Context, store *Store, notifier alert.Notifier) (*SettlementNotifier, error) {
+	n := &SettlementNotifier{store: store, notifier: notifier, seen: make(map[positionKey]bool)}
+	settlements, err := store.Settlements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range settlements {
+		n.seen[positionKey{st.Account, st.Ticker}] = true
+	}
+	return n, nil
+}
+
+// Check alerts on settlements stored since the last call, typically right
+// after a Sync, and returns how many were announced. Markets settled
+// without a position are skipped.
+func (n *SettlementNotifier) Check(ctx context.Context) (int, error) {
+	settlements, err := n.store.Settlements(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-settlementAlertMaxAge)
+	sent := 0
+	for _, st := range settlements {
+		k := positionKey{st.Account, st.Ticker}
+		if n.seen[k] {
+			continue
+		}
+		n.seen[k] = true
+		if st.YesTotalCount == 0 && st.NoTotalCount == 0 || st.SettledTime.Before(cutoff) {
+			continue
+		}
+		if err := n.notifier.Notify(ctx, SettlementAlert(st)); err != nil {
+			slog.Warn("settlement alert failed", "ticker", st.Ticker, "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// SettlementAlert describes a settled position: what was held, the result
+// and the realized PnL net of fees.
+func SettlementAlert(st Settlement) alert.Alert {
+	var held []string
+	if st.YesTotalCount > 0 {
+		held = append(held, fmt.Sprintf("%d YES", st.YesTotalCount))
+	}
+	if st.NoTotalCount > 0 {
+		held = append(held, fmt.Sprintf("%d NO", st.NoTotalCount))
+	}
+	cost := st.YesCost + st.NoCost
+	pnl := st.Revenue - cost - st.FeeCost
+	title := fmt.Sprintf("Settled %s: %s, PnL %s", st.Ticker, strings.ToUpper(st.MarketResult), usd(pnl))
+	if st.Account != "" && st.Account != DefaultAccount {
+		title = "[" + st.Account + "] " + title
+	}
+	return alert.Alert{
+		Title: title,
+		Message: fmt.Sprintf("Held %s for %s; paid out %s, fees %s, realized PnL %s.",
+			strings.Join(held, " + "), usd(cost), usd(st.Revenue), usd(st.FeeCost), usd(pnl)),
+		Time:     st.SettledTime,
+		Severity: alert.Info,
+	}
+}
+
+// usd formats cents as -$1.23.
+func usd(c int) string {
+	if c < 0 {
+		return "-$" + dollars(-c)
+	}
+	return "$" + dollars(c)
+}
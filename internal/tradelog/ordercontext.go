@@ -0,0 +1,204 @@
+package tradelog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// VolWindow is how much BRTI history the volatility estimate in an order's
+// context covers: one market's life.
+const VolWindow = 15 * time.Minute
+
+// Order context sources.
+const (
+	ContextArchive = "archive" // the collector's recorded snapshot
+	ContextREST    = "rest"    // Kalshi REST, when nothing was recorded
+)
+
+// OrderContext is what the market looked like when an order was placed:
+// the last snapshot of its market at or before PlacedTime, the BRTI of
+// that tick, and BRTI's realized volatility over the VolWindow before it.
+type OrderContext struct {
+	OrderID      string    `json:"order_id"`
+	Ticker       string    `json:"ticker"`
+	PlacedTime   time.Time `json:"placed_time"`
+	Source       string    `json:"source"`        // ContextArchive or ContextREST; "" if nothing was found
+	SnapshotTime time.Time `json:"snapshot_time"` // zero if no snapshot was found
+	YesBid       int       `json:"yes_bid"`
+	YesAsk       int       `json:"yes_ask"`
+	LastPrice    int       `json:"last_price"`
+	Strike       float64   `json:"strike"`
+	SecsLeft     int       `json:"secs_left"`
+	Status       string    `json:"status"`
+	YesBook      [][2]int  `json:"yes_book"`
+	NoBook       [][2]int  `json:"no_book"`
+	BRTI         float64   `json:"brti"`
+	Vol          float64   `json:"vol"` // annualized; 0 without enough recorded BRTI
+}
+
+// OrderPlacer submits orders and stores the context each was placed in,
+// so "what did I see when I traded" can be answered later. The context
+// comes from the collector's archives under DataDir, falling back to the
+// market's REST quote when they hold no snapshot of it.
+type OrderPlacer struct {
+	client  kalshi.API
+	store   *Store
+	archive *ticks.Archive
+}
+
+func NewOrderPlacer(client kalshi.API, store *Store, dataDir, prefix string) *OrderPlacer {
+	return &OrderPlacer{client: client, store: store, archive: ticks.NewArchive(dataDir, prefix)}
+}
+
+// Place submits req and then records its context. The order is placed
+// first so capturing can't delay it; a failure to capture is logged, not
+// returned.
+func (p *OrderPlacer) Place(ctx context.Context, req kalshi.CreateOrderRequest) (*kalshi.Order, error) {
+	placed := time.Now()
+	o, err := p.client.CreateOrder(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	oc := p.Capture(ctx, req.Ticker, placed)
+	oc.OrderID = o.OrderID
+	if err := p.store.UpsertOrderContext(ctx, &oc); err != nil {
+		slog.Warn("order context: store failed", "order_id", o.OrderID, "err", err)
+	}
+	return o, nil
+}
+
+// Capture returns the context of ticker at t, without an order id.
+func (p *OrderPlacer) Capture(ctx context.Context, ticker string, t time.Time) OrderContext {
+	oc := OrderContext{Ticker: ticker, PlacedTime: t}
+
+	recs, err := p.archive.Query(t.Add(-VolWindow), t.Add(time.Nanosecond))
+	if err != nil {
+		slog.Warn("order context: reading archives failed", "ticker", ticker, "err", err)
+	}
+	var samples []ticks.PriceSample
+	for _, rec := range recs {
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, ticks.PriceSample{Time: ts, Price: rec.BRTI})
+		if t.Sub(ts) > maxSnapshotLag {
+			continue
+		}
+		for _, m := range rec.AllMarkets() {
+			if m.Ticker == ticker {
+				oc.Source, oc.SnapshotTime, oc.BRTI = ContextArchive, ts, rec.BRTI
+				oc.setSnap(m)
+			}
+		}
+	}
+	oc.Vol = ticks.RealizedVol(samples)
+	if oc.Source != "" {
+		return oc
+	}
+
+	m, err := p.client.GetMarket(ctx, ticker)
+	if err != nil {
+		slog.Warn("order context: no snapshot", "ticker", ticker, "err", err)
+		return oc
+	}
+	oc.Source, oc.SnapshotTime = ContextREST, time.Now()
+	oc.YesBid, oc.YesAsk, oc.LastPrice = m.YesBid, m.YesAsk, m.LastPrice
+	oc.Strike, oc.Status = m.StrikePrice(), m.Status
+	if len(samples) > 0 {
+		oc.BRTI = samples[len(samples)-1].Price
+	}
+	if expiry, err := m.ExpirationParsed(); err == nil {
+		oc.SecsLeft = max(int(expiry.Sub(t).Seconds()), 0)
+	}
+	return oc
+}
+
+func (oc *OrderContext) setSnap(m ticks.MarketSnap) {
+	oc.YesBid, oc.YesAsk, oc.LastPrice = m.YesBid, m.YesAsk, m.LastPrice
+	oc.Strike, oc.SecsLeft, oc.Status = m.Strike, m.SecsLeft, m.Status
+	oc.YesBook, oc.NoBook = m.YesBook, m.NoBook
+}
+
+func (s *Store) UpsertOrderContext(ctx context.Context, c *OrderContext) error {
+	var snapTime any
+	if !c.SnapshotTime.IsZero() {
+		snapTime = c.SnapshotTime
+	}
+	yesBook, err := json.Marshal(c.YesBook)
+	if err != nil {
+		return err
+	}
+	noBook, err := json.Marshal(c.NoBook)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO order_context (order_id, ticker, placed_time, source, snapshot_time,
+			yes_bid, yes_ask, last_price, strike, secs_left, status, yes_book, no_book, brti, vol,
+			account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(order_id) DO UPDATE SET
+			ticker = excluded.ticker,
+			placed_time = excluded.placed_time,
+			source = excluded.source,
+			snapshot_time = excluded.snapshot_time,
+			yes_bid = excluded.yes_bid,
+			yes_ask = excluded.yes_ask,
+			last_price = excluded.last_price,
+			strike = excluded.strike,
+			secs_left = excluded.secs_left,
+			status = excluded.status,
+			yes_book = excluded.yes_book,
+			no_book = excluded.no_book,
+			brti = excluded.brti,
+			vol = excluded.vol`,
+		c.OrderID, c.Ticker, c.PlacedTime, c.Source, snapTime,
+		c.YesBid, c.YesAsk, c.LastPrice, c.Strike, c.SecsLeft, c.Status,
+		string(yesBook), string(noBook), c.BRTI, c.Vol,
+		s.owner(),
+	)
+	return err
+}
+
+// ErrNoOrderContext is returned by OrderContext for orders placed without
+// an OrderPlacer.
+var ErrNoOrderContext = errors.New("no context recorded for order")
+
+// OrderContext returns the context stored for orderID.
+func (s *Store) OrderContext(ctx context.Context, orderID string) (*OrderContext, error) {
+	var (
+		c               OrderContext
+		snapTime        sql.NullTime
+		yesBook, noBook string
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT order_id, ticker, placed_time, source, snapshot_time, yes_bid, yes_ask,
+			last_price, strike, secs_left, status, yes_book, no_book, brti, vol
+		FROM order_context WHERE order_id = ?`, orderID).Scan(
+		&c.OrderID, &c.Ticker, &c.PlacedTime, &c.Source, &snapTime, &c.YesBid, &c.YesAsk,
+		&c.LastPrice, &c.Strike, &c.SecsLeft, &c.Status, &yesBook, &noBook, &c.BRTI, &c.Vol)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", orderID, ErrNoOrderContext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.SnapshotTime = snapTime.Time
+	if err := json.Unmarshal([]byte(yesBook), &c.YesBook); err != nil {
+		return nil, fmt.Errorf("order %s yes_book: %w", orderID, err)
+	}
+	if err := json.Unmarshal([]byte(noBook), &c.NoBook); err != nil {
+		return nil, fmt.Errorf("order %s no_book: %w", orderID, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,178 @@
+package tradelog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// QueryResult is the outcome of an ad-hoc Query: column names and rows of
+// values, with text as string, numbers as int64 or float64, times as
+// time.Time and NULL as nil.
+type QueryResult struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// readOnlyStatements are the statements Query accepts, by first keyword.
+// WITH can lead into an UPDATE or DELETE; the read-only transaction
+// refuses those.
+var readOnlyStatements = map[string]bool{
+	"SELECT":  true,
+	"WITH":    true,
+	"VALUES":  true,
+	"EXPLAIN": true,
+}
+
+// Query runs one read-only SQL statement, as written by a user, and
+// returns everything it selects. It runs in a read-only transaction on
+// the read connection, so it can't change the database or wait on a
+// writer. Statements other than SELECT, WITH, VALUES and EXPLAIN are
+// refused up front, as is more than one statement: SQLite's read-only
+// mode can be switched off by a PRAGMA. The store's account doesn't
+// apply.
+func (s *Store) Query(ctx context.Context, q string) (*QueryResult, error) {
+	if err := checkReadOnly(q); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.read.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &QueryResult{Columns: cols}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		res.Rows = append(res.Rows, vals)
+	}
+	return res, rows.Err()
+}
+
+// checkReadOnly refuses q unless it is a single statement starting with
+// one of readOnlyStatements. Comments and quoted text are skipped when
+// looking for the keyword and for semicolons.
+func checkReadOnly(q string) error {
+	var code strings.Builder
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; {
+		case c == '-' && strings.HasPrefix(q[i:], "--"):
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+			code.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("unterminated comment")
+			}
+			i += end + 3
+			code.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(q[i+1:], c)
+			if end < 0 {
+				return fmt.Errorf("unterminated %c quote", c)
+			}
+			i += end + 1 // a doubled quote reads as two quoted runs
+			code.WriteString(" x ")
+		default:
+			code.WriteByte(c)
+		}
+	}
+
+	stmt := strings.TrimSpace(code.String())
+	stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+	if stmt == "" {
+		return fmt.Errorf("empty query")
+	}
+	if strings.Contains(stmt, ";") {
+		return fmt.Errorf("only one statement can be run at a time")
+	}
+	words := strings.FieldsFunc(stmt, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if len(words) == 0 {
+		return fmt.Errorf("not a query: %q", q)
+	}
+	if word := strings.ToUpper(words[0]); !readOnlyStatements[word] {
+		return fmt.Errorf("%s statements are not allowed; queries are read-only (SELECT, WITH, VALUES or EXPLAIN)", word)
+	}
+	return nil
+}
+
+// Column is one column of a table or view, as reported by Schema.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Table is a table or view of the trade database, for query help.
+type Table struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"` // "table" or "view"
+	About   string   `json:"about,omitempty"`
+	Columns []Column `json:"columns"`
+}
+
+// tableAbout describes the tables and views users are likely to query.
+var tableAbout = map[string]string{
+	"orders":              "every order placed, one row per order_id",
+	"fills":               "every trade, one row per trade_id; prices in cents",
+	"settlements":         "settled positions, one row per account and market",
+	"markets":             "schedule and result of each traded market",
+	"fill_context":        "recorded market state at each fill (tradelog reconcile)",
+	"fill_metrics":        "slippage and markouts of each fill (tradelog execquality)",
+	"order_context":       "market state when an order was placed (tradelog flatten)",
+	"schema_migrations":   "applied schema migrations",
+	"v_positions":         "net contracts and cost per account and market, with the result",
+	"v_daily_pnl":         "settled PnL per UTC day, all accounts combined",
+	"v_account_daily_pnl": "settled PnL per account and UTC day",
+}
+
+// Schema lists the tables and views of the database with their columns,
+// tables first.
+func (s *Store) Schema(ctx context.Context) ([]Table, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.dialect.columns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []Table
+	for rows.Next() {
+		var name, kind string
+		var c Column
+		if err := rows.Scan(&name, &kind, &c.Name, &c.Type); err != nil {
+			return nil, err
+		}
+		if n := len(tables); n == 0 || tables[n-1].Name != name {
+			tables = append(tables, Table{Name: name, Kind: kind, About: tableAbout[name]})
+		}
+		t := &tables[len(tables)-1]
+		t.Columns = append(t.Columns, c)
+	}
+	return tables, rows.Err()
+}
@@ -0,0 +1,195 @@
+package tradelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// maxSnapshotLag is how long before a fill a recorded snapshot may be and
+// still describe the market the fill traded against.
+const maxSnapshotLag = 5 * time.Second
+
+// Anomaly labels written to fill_context.anomaly.
+const (
+	AnomalyNoSnapshot    = "no_snapshot"
+	AnomalyStaleSnapshot = "stale_snapshot"
+	AnomalyOutsideSpread = "outside_spread"
+	AnomalyNotActive     = "market_not_active"
+)
+
+// ReconciledFill pairs a fill with the market context found for it.
+type ReconciledFill struct {
+	Fill    Fill        `json:"fill"`
+	Context FillContext `json:"context"`
+}
+
+// archiveName matches one day's collector files: daily, hourly and size
+// parts, plain or compressed — but not retrofit backups or manifests.
+var archiveName = regexp.MustCompile(`^(T\d\d)?(\.\d+)?\.jsonl(\.gz|\.zst)?(\.enc)?$`)
+
+type observation struct {
+	ts   time.Time
+	brti float64
+	snap ticks.MarketSnap
+}
+
+// Reconcile looks up every stored fill in the collector archives under
+// dataDir, stores the market context it finds in fill_context, and returns
+// the results in fill order.
+func Reconcile(ctx context.Context, store *Store, dataDir, prefix string) ([]ReconciledFill, error) {
+	fills, err := store.Fills(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading fills: %w", err)
+	}
+
+	var out []ReconciledFill
+	err = forEachDay(fills, dataDir, prefix, func(day []Fill, obs map[string][]observation) error {
+		for _, f := range day {
+			fc := matchFill(f, obs[f.Ticker])
+			if err := store.UpsertFillContext(ctx, &fc); err != nil {
+				return err
+			}
+			out = append(out, ReconciledFill{Fill: f, Context: fc})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// forEachDay groups fills by UTC date and calls fn with each day's fills
+// and the archive observations for the tickers they traded.
+func forEachDay(fills []Fill, dataDir, prefix string, fn func([]Fill, map[string][]observation) error) error {
+	byDate := make(map[string][]Fill)
+	var dates []string
+	for _, f := range fills {
+		d := f.CreatedTime.UTC().Format("2006-01-02")
+		if _, ok := byDate[d]; !ok {
+			dates = append(dates, d)
+		}
+		byDate[d] = append(byDate[d], f)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		obs, err := loadObservations(dataDir, prefix, date, byDate[date])
+		if err != nil {
+			return err
+		}
+		if err := fn(byDate[date], obs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadObservations streams one day's archives, keeping only the tickers
+// traded that day.
+func loadObservations(dataDir, prefix, date string, fills []Fill) (map[string][]observation, error) {
+	want := make(map[string]bool)
+	for _, f := range fills {
+		want[f.Ticker] = true
+	}
+
+	// Archives sit directly in dataDir or, with the monthly layout, in
+	// dataDir/YYYY/MM.
+	stem := prefix + "-" + date
+	var paths []string
+	for i, dir := range []string{dataDir, filepath.Join(dataDir, date[:4], date[5:7])} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if i > 0 && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if strings.HasPrefix(name, stem) && archiveName.MatchString(strings.TrimPrefix(name, stem)) {
+				paths = append(paths, filepath.Join(dir, name))
+			}
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		slog.Warn("reconcile: no archive for date", "date", date, "dir", dataDir)
+	}
+
+	obs := make(map[string][]observation)
+	err := ticks.ReadFiles(paths, func(rec ticks.TickRecord) error {
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		if err != nil {
+			return nil
+		}
+		for _, m := range rec.AllMarkets() {
+			if want[m.Ticker] {
+				obs[m.Ticker] = append(obs[m.Ticker], observation{ts: ts, brti: rec.BRTI, snap: m})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sorted input files are chronological, but be safe for mixed layouts.
+	for _, o := range obs {
+		sort.Slice(o, func(i, j int) bool { return o[i].ts.Before(o[j].ts) })
+	}
+	return obs, nil
+}
+
+// matchFill finds the last snapshot at or before the fill and checks the
+// fill's price against the recorded spread.
+func matchFill(f Fill, obs []observation) FillContext {
+	fc := FillContext{TradeID: f.TradeID}
+
+	i := snapshotAt(obs, f.CreatedTime)
+	if i < 0 {
+		fc.Anomaly = AnomalyNoSnapshot
+		return fc
+	}
+
+	o := obs[i]
+	fc.SnapshotTime = o.ts
+	fc.SnapshotLag = f.CreatedTime.Sub(o.ts).Seconds()
+	fc.YesBid = o.snap.YesBid
+	fc.YesAsk = o.snap.YesAsk
+	fc.BRTI = o.brti
+	fc.Strike = o.snap.Strike
+	fc.SecsLeft = o.snap.SecsLeft
+	fc.Status = o.snap.Status
+
+	var anomalies []string
+	if f.CreatedTime.Sub(o.ts) > maxSnapshotLag {
+		anomalies = append(anomalies, AnomalyStaleSnapshot)
+	}
+	if o.snap.Status != "" && o.snap.Status != "active" {
+		anomalies = append(anomalies, AnomalyNotActive)
+	}
+	// YesPrice is the YES-equivalent price for both sides.
+	if (o.snap.YesBid > 0 && f.YesPrice < o.snap.YesBid) || (o.snap.YesAsk > 0 && f.YesPrice > o.snap.YesAsk) {
+		anomalies = append(anomalies, AnomalyOutsideSpread)
+	}
+	fc.Anomaly = strings.Join(anomalies, ",")
+	return fc
+}
+
+// snapshotAt returns the index of the last observation at or before t, or
+// -1 if there is none.
+func snapshotAt(obs []observation, t time.Time) int {
+	return sort.Search(len(obs), func(i int) bool { return obs[i].ts.After(t) }) - 1
+}
@@ -0,0 +1,131 @@
+package tradelog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reporting decides which day a timestamp is reported under. Days are
+// days in Location that start at SessionHour local time: with a session
+// hour of 17, Monday 17:00 through Tuesday 16:59 is reported as Monday,
+// keeping an evening session on one row. The zero value reports UTC
+// calendar days.
+type Reporting struct {
+	Location    *time.Location
+	SessionHour int
+}
+
+// NewReporting returns the reporting settings for a time zone name (as
+// accepted by time.LoadLocation; "" means UTC) and a session start hour.
+func NewReporting(tz string, sessionHour int) (Reporting, error) {
+	if sessionHour < 0 || sessionHour > 23 {
+		return Reporting{}, fmt.Errorf("session hour must be 0-23, got %d", sessionHour)
+	}
+	loc := time.UTC
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return Reporting{}, fmt.Errorf("time zone %q: %w", tz, err)
+		}
+	}
+	return Reporting{Location: loc, SessionHour: sessionHour}, nil
+}
+
+// In returns t in the reporting time zone.
+func (r Reporting) In(t time.Time) time.Time {
+	if r.Location == nil {
+		return t.UTC()
+	}
+	return t.In(r.Location)
+}
+
+// Day returns the YYYY-MM-DD session date that t belongs to.
+func (r Reporting) Day(t time.Time) string {
+	lt := r.In(t)
+	if lt.Hour() < r.SessionHour {
+		lt = lt.AddDate(0, 0, -1)
+	}
+	return lt.Format("2006-01-02")
+}
+
+// DayStart returns when the session day named by date (YYYY-MM-DD)
+// begins.
+func (r Reporting) DayStart(date string) (time.Time, error) {
+	loc := r.In(time.Time{}).Location()
+	d, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), r.SessionHour, 0, 0, 0, loc), nil
+}
+
+// String describes the settings for report headers, e.g.
+// "America/New_York, sessions from 17:00".
+func (r Reporting) String() string {
+	s := r.In(time.Time{}).Location().String()
+	if r.SessionHour != 0 {
+		s += fmt.Sprintf(", sessions from %02d:00", r.SessionHour)
+	}
+	return s
+}
+
+// DailyPnLIn is v_daily_pnl with days taken from rep rather than UTC
+// dates. Rows are in date order.
+func DailyPnLIn(ctx context.Context, store *Store, rep Reporting) ([]DailyPnL, error) {
+	settlements, err := store.Settlements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dailyPnL(settlements, rep), nil
+}
+
+// dailyPnL totals settlements, in settlement order, by reporting day.
+func dailyPnL(settlements []Settlement, rep Reporting) []DailyPnL {
+	var results []DailyPnL
+	for _, st := range settlements {
+		if st.Revenue == 0 && st.YesCost == 0 && st.NoCost == 0 {
+			continue
+		}
+		day := rep.Day(st.SettledTime)
+		if n := len(results); n == 0 || results[n-1].Date != day {
+			results = append(results, DailyPnL{Date: day})
+		}
+		d := &results[len(results)-1]
+		cost := st.YesCost + st.NoCost
+		d.Revenue += st.Revenue
+		d.Cost += cost
+		d.NetPnL += st.Revenue - cost
+		d.Trades++
+	}
+	return results
+}
+
+// AccountDailyPnLIn is DailyPnLIn for each account separately: rows have
+// Account set and are in account, then date order.
+func AccountDailyPnLIn(ctx context.Context, store *Store, rep Reporting) ([]DailyPnL, error) {
+	settlements, err := store.Settlements(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byAccount := make(map[string][]Settlement)
+	var accounts []string
+	for _, st := range settlements {
+		if _, ok := byAccount[st.Account]; !ok {
+			accounts = append(accounts, st.Account)
+		}
+		byAccount[st.Account] = append(byAccount[st.Account], st)
+	}
+	sort.Strings(accounts)
+
+	var results []DailyPnL
+	for _, a := range accounts {
+		for _, d := range dailyPnL(byAccount[a], rep) {
+			d.Account = a
+			results = append(results, d)
+		}
+	}
+	return results, nil
+}
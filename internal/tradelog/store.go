@@ -2,30 +2,28 @@ package tradelog
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-
-	_ "modernc.org/sqlite"
+	"time"
 )
 
 type Store struct {
-	db *sql.DB
+	db      *boundDB
+	account string // "" for every account; see ForAccount
 }
 
-func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+// DefaultAccount owns the rows written through a store not limited with
+// ForAccount, and every row from before accounts were recorded.
+const DefaultAccount = "default"
+
+// Open connects to the trade database and applies pending migrations. dsn
+// is a SQLite file path or a postgres:// URL.
+func Open(dsn string) (*Store, error) {
+	db, err := openDB(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening db: %w", err)
 	}
 
-	// WAL mode for concurrent reads
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("setting WAL mode: %w", err)
-	}
-
-	// Run schema migration
-	if _, err := db.Exec(schemaDDL); err != nil {
+	if _, err := migrate(db, false); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("schema migration: %w", err)
 	}
@@ -33,16 +31,82 @@ func Open(path string) (*Store, error) {
 	return &Store{db: db}, nil
 }
 
+// Migrate connects to dsn without the automatic upgrade and applies
+// pending migrations, returning them. With dryRun nothing is changed and
+// the returned migrations are the ones that would run.
+func Migrate(dsn string, dryRun bool) ([]Migration, error) {
+	db, err := openDB(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening db: %w", err)
+	}
+	defer db.Close()
+	return migrate(db, dryRun)
+}
+
 func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// ForAccount returns the store limited to one Kalshi account: its queries
+// see only that account's rows and its writes are tagged with it. Queries
+// through a store from Open cover every account. The two share one
+// connection, which closing either closes.
+func (s *Store) ForAccount(account string) *Store {
+	return &Store{db: s.db, account: account}
+}
+
+// Account returns the account s is limited to, or "" for all.
+func (s *Store) Account() string {
+	return s.account
+}
+
+// Accounts returns the accounts with fills or settlements stored, in
+// name order.
+func (s *Store) Accounts(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT account FROM fills
+		UNION
+		SELECT account FROM settlements
+		ORDER BY account`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// owner is the account rows written through s belong to.
+func (s *Store) owner() string {
+	if s.account == "" {
+		return DefaultAccount
+	}
+	return s.account
+}
+
+// accountCond returns a condition limiting col to s's account, true for
+// every row when s isn't limited, and its arguments.
+func (s *Store) accountCond(col string) (string, []any) {
+	if s.account == "" {
+		return "1 = 1", nil
+	}
+	return col + " = ?", []any{s.account}
+}
+
 func (s *Store) UpsertOrder(ctx context.Context, o *Order) error {
 	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO orders (order_id, ticker, action, side, type, yes_price, no_price,
 			quantity, filled_quantity, remaining_quantity, avg_fill_price, status,
-			created_time, updated_time)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			created_time, updated_time, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(order_id) DO UPDATE SET
 			filled_quantity = excluded.filled_quantity,
 			remaining_quantity = excluded.remaining_quantity,
@@ -52,38 +116,244 @@ func (s *Store) UpsertOrder(ctx context.Context, o *Order) error {
 		o.OrderID, o.Ticker, o.Action, o.Side, o.Type,
 		o.YesPrice, o.NoPrice, o.Quantity, o.FilledQuantity,
 		o.RemainingQuantity, o.AvgFillPrice, o.Status,
-		o.CreatedTime, o.UpdatedTime,
+		o.CreatedTime, o.UpdatedTime, s.owner(),
 	)
 	return err
 }
 
 func (s *Store) InsertFill(ctx context.Context, f *Fill) error {
 	_, err := s.db.ExecContext(ctx, `
-		INSERT OR IGNORE INTO fills (trade_id, order_id, ticker, side, action,
-			yes_price, no_price, count, is_taker, created_time)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO fills (trade_id, order_id, ticker, side, action,
+			yes_price, no_price, count, is_taker, created_time, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(trade_id) DO NOTHING`,
 		f.TradeID, f.OrderID, f.Ticker, f.Side, f.Action,
-		f.YesPrice, f.NoPrice, f.Count, f.IsTaker, f.CreatedTime,
+		f.YesPrice, f.NoPrice, f.Count, f.IsTaker, f.CreatedTime, s.owner(),
 	)
 	return err
 }
 
+// ImportFill stores a fill from a CSV statement unless the same trade is
+// already present, either by trade ID or as an API fill of the account
+// with the same ticker, side, action, price and size within a second. It
+// reports whether the fill was inserted.
+func (s *Store) ImportFill(ctx context.Context, f *Fill) (bool, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT trade_id, created_time FROM fills
+		WHERE trade_id = ?
+			OR (account = ? AND ticker = ? AND side = ? AND action = ? AND yes_price = ? AND count = ?)`,
+		f.TradeID, s.owner(), f.Ticker, f.Side, f.Action, f.YesPrice, f.Count)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			return false, err
+		}
+		if d := t.Sub(f.CreatedTime); id == f.TradeID || (d > -time.Second && d < time.Second) {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	rows.Close()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO fills (trade_id, order_id, ticker, side, action,
+			yes_price, no_price, count, is_taker, created_time, source, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'csv', ?)
+		ON CONFLICT(trade_id) DO NOTHING`,
+		f.TradeID, f.OrderID, f.Ticker, f.Side, f.Action,
+		f.YesPrice, f.NoPrice, f.Count, f.IsTaker, f.CreatedTime, s.owner(),
+	)
+	return err == nil, err
+}
+
+// ImportSettlement stores a settlement from a CSV statement. API rows for
+// the same account and ticker take precedence. It reports whether the row was inserted.
+func (s *Store) ImportSettlement(ctx context.Context, st *Settlement) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO settlements (ticker, market_result, no_total_count, no_cost,
+			yes_total_count, yes_cost, revenue, settled_time, fee_cost, source, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'csv', ?)
+		ON CONFLICT(account, ticker) DO NOTHING`,
+		st.Ticker, st.MarketResult, st.NoTotalCount, st.NoCost,
+		st.YesTotalCount, st.YesCost, st.Revenue, st.SettledTime, st.FeeCost, s.owner(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 func (s *Store) UpsertSettlement(ctx context.Context, st *Settlement) error {
 	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO settlements (ticker, market_result, no_total_count, no_cost,
-			yes_total_count, yes_cost, revenue, settled_time)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(ticker) DO UPDATE SET
+			yes_total_count, yes_cost, revenue, settled_time, fee_cost, account)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account, ticker) DO UPDATE SET
 			market_result = excluded.market_result,
 			revenue = excluded.revenue,
-			settled_time = excluded.settled_time`,
+			settled_time = excluded.settled_time,
+			fee_cost = excluded.fee_cost`,
 		st.Ticker, st.MarketResult, st.NoTotalCount, st.NoCost,
-		st.YesTotalCount, st.YesCost, st.Revenue, st.SettledTime,
+		st.YesTotalCount, st.YesCost, st.Revenue, st.SettledTime, st.FeeCost, s.owner(),
 	)
 	return err
 }
 
+func (s *Store) UpsertMarket(ctx context.Context, m *Market) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO markets (ticker, event_ticker, title, strike, open_time,
+			close_time, expiration_time, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ticker) DO UPDATE SET
+			event_ticker = excluded.event_ticker,
+			title = excluded.title,
+			strike = excluded.strike,
+			open_time = excluded.open_time,
+			close_time = excluded.close_time,
+			expiration_time = excluded.expiration_time,
+			result = excluded.result`,
+		m.Ticker, m.EventTicker, m.Title, m.Strike, m.OpenTime,
+		m.CloseTime, m.ExpirationTime, m.Result,
+	)
+	return err
+}
+
+// TickersNeedingMarket returns tickers from orders and fills that have no
+// markets row yet, or whose stored row expired before a result was known.
+func (s *Store) TickersNeedingMarket(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.ticker FROM (
+			SELECT ticker FROM orders
+			UNION
+			SELECT ticker FROM fills
+		) t
+		LEFT JOIN markets m ON m.ticker = t.ticker
+		WHERE m.ticker IS NULL
+			OR (m.result = '' AND m.expiration_time < ?)
+		ORDER BY t.ticker`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		results = append(results, t)
+	}
+	return results, rows.Err()
+}
+
+// positionEntry is a settled position with the time of its first buy.
+type positionEntry struct {
+	Account   string
+	Ticker    string
+	EntryTime time.Time
+	CloseTime time.Time
+	Cost      int
+	NetPnL    int
+}
+
+func (s *Store) settledEntries(ctx context.Context) ([]positionEntry, error) {
+	cond, args := s.accountCond("f.account")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT f.account, f.ticker, f.created_time, m.close_time,
+			st.yes_cost + st.no_cost,
+			st.revenue - st.yes_cost - st.no_cost
+		FROM fills f
+		JOIN markets m ON m.ticker = f.ticker
+		JOIN settlements st ON st.account = f.account AND st.ticker = f.ticker
+		WHERE f.action = 'buy'
+			AND f.created_time = (
+				SELECT MIN(created_time) FROM fills
+				WHERE account = f.account AND ticker = f.ticker AND action = 'buy')
+			AND (st.revenue != 0 OR st.yes_cost != 0 OR st.no_cost != 0)
+			AND `+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []positionEntry
+	for rows.Next() {
+		var e positionEntry
+		if err := rows.Scan(&e.Account, &e.Ticker, &e.EntryTime, &e.CloseTime, &e.Cost, &e.NetPnL); err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
+
+// Settlements returns every settlement in settlement order.
+func (s *Store) Settlements(ctx context.Context) ([]Settlement, error) {
+	cond, args := s.accountCond("account")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT account, ticker, market_result, no_total_count, no_cost, yes_total_count,
+			yes_cost, revenue, settled_time, fee_cost
+		FROM settlements WHERE `+cond+` ORDER BY settled_time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Settlement
+	for rows.Next() {
+		var st Settlement
+		if err := rows.Scan(&st.Account, &st.Ticker, &st.MarketResult, &st.NoTotalCount, &st.NoCost,
+			&st.YesTotalCount, &st.YesCost, &st.Revenue, &st.SettledTime, &st.FeeCost); err != nil {
+			return nil, err
+		}
+		results = append(results, st)
+	}
+	return results, rows.Err()
+}
+
+// positionKey identifies one account's position in one market.
+type positionKey struct {
+	account, ticker string
+}
+
+// firstFillTimes returns the earliest fill time of each position.
+func (s *Store) firstFillTimes(ctx context.Context) (map[positionKey]time.Time, error) {
+	cond, args := s.accountCond("account")
+	rows, err := s.db.QueryContext(ctx, `SELECT account, ticker, created_time FROM fills WHERE `+cond+` ORDER BY created_time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	first := make(map[positionKey]time.Time)
+	for rows.Next() {
+		var k positionKey
+		var t time.Time
+		if err := rows.Scan(&k.account, &k.ticker, &t); err != nil {
+			return nil, err
+		}
+		if _, ok := first[k]; !ok {
+			first[k] = t
+		}
+	}
+	return first, rows.Err()
+}
+
+// GetDailyPnL returns v_daily_pnl, all accounts combined, or the rows of
+// v_account_daily_pnl for the account s is limited to.
 func (s *Store) GetDailyPnL(ctx context.Context) ([]DailyPnL, error) {
+	if s.account != "" {
+		return s.AccountDailyPnL(ctx)
+	}
 	rows, err := s.db.QueryContext(ctx, `SELECT date, revenue, cost, net_pnl, trades FROM v_daily_pnl`)
 	if err != nil {
 		return nil, err
@@ -101,10 +371,34 @@ func (s *Store) GetDailyPnL(ctx context.Context) ([]DailyPnL, error) {
 	return results, rows.Err()
 }
 
+// AccountDailyPnL returns v_account_daily_pnl, each account's days in
+// turn.
+func (s *Store) AccountDailyPnL(ctx context.Context) ([]DailyPnL, error) {
+	cond, args := s.accountCond("account")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT account, date, revenue, cost, net_pnl, trades
+		FROM v_account_daily_pnl WHERE `+cond+` ORDER BY account, date`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []DailyPnL
+	for rows.Next() {
+		var d DailyPnL
+		if err := rows.Scan(&d.Account, &d.Date, &d.Revenue, &d.Cost, &d.NetPnL, &d.Trades); err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
 func (s *Store) GetPositions(ctx context.Context) ([]Position, error) {
+	cond, args := s.accountCond("account")
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT ticker, yes_contracts, no_contracts, yes_cost, no_cost, market_result, revenue
-		FROM v_positions ORDER BY ticker`)
+		SELECT account, ticker, yes_contracts, no_contracts, yes_cost, no_cost, market_result, revenue
+		FROM v_positions WHERE `+cond+` ORDER BY ticker, account`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,7 +407,7 @@ func (s *Store) GetPositions(ctx context.Context) ([]Position, error) {
 	var results []Position
 	for rows.Next() {
 		var p Position
-		if err := rows.Scan(&p.Ticker, &p.YesContracts, &p.NoContracts,
+		if err := rows.Scan(&p.Account, &p.Ticker, &p.YesContracts, &p.NoContracts,
 			&p.YesCost, &p.NoCost, &p.MarketResult, &p.Revenue); err != nil {
 			return nil, err
 		}
@@ -123,11 +417,12 @@ func (s *Store) GetPositions(ctx context.Context) ([]Position, error) {
 }
 
 func (s *Store) OpenPositions(ctx context.Context) ([]Position, error) {
+	cond, args := s.accountCond("account")
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT ticker, yes_contracts, no_contracts, yes_cost, no_cost, market_result, revenue
+		SELECT account, ticker, yes_contracts, no_contracts, yes_cost, no_cost, market_result, revenue
 		FROM v_positions
-		WHERE market_result = ''
-		ORDER BY ticker`)
+		WHERE market_result = '' AND `+cond+`
+		ORDER BY ticker, account`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +431,7 @@ func (s *Store) OpenPositions(ctx context.Context) ([]Position, error) {
 	var results []Position
 	for rows.Next() {
 		var p Position
-		if err := rows.Scan(&p.Ticker, &p.YesContracts, &p.NoContracts,
+		if err := rows.Scan(&p.Account, &p.Ticker, &p.YesContracts, &p.NoContracts,
 			&p.YesCost, &p.NoCost, &p.MarketResult, &p.Revenue); err != nil {
 			return nil, err
 		}
@@ -145,11 +440,113 @@ func (s *Store) OpenPositions(ctx context.Context) ([]Position, error) {
 	return results, rows.Err()
 }
 
+// Fills returns every fill in chronological order.
+func (s *Store) Fills(ctx context.Context) ([]Fill, error) {
+	cond, args := s.accountCond("account")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT account, trade_id, order_id, ticker, side, action, yes_price, no_price,
+			count, is_taker, created_time
+		FROM fills WHERE `+cond+` ORDER BY created_time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Fill
+	for rows.Next() {
+		var f Fill
+		if err := rows.Scan(&f.Account, &f.TradeID, &f.OrderID, &f.Ticker, &f.Side, &f.Action,
+			&f.YesPrice, &f.NoPrice, &f.Count, &f.IsTaker, &f.CreatedTime); err != nil {
+			return nil, err
+		}
+		results = append(results, f)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) UpsertFillContext(ctx context.Context, c *FillContext) error {
+	var snapTime any
+	if !c.SnapshotTime.IsZero() {
+		snapTime = c.SnapshotTime
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO fill_context (trade_id, snapshot_time, snapshot_lag, yes_bid, yes_ask,
+			brti, strike, secs_left, status, anomaly)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(trade_id) DO UPDATE SET
+			snapshot_time = excluded.snapshot_time,
+			snapshot_lag = excluded.snapshot_lag,
+			yes_bid = excluded.yes_bid,
+			yes_ask = excluded.yes_ask,
+			brti = excluded.brti,
+			strike = excluded.strike,
+			secs_left = excluded.secs_left,
+			status = excluded.status,
+			anomaly = excluded.anomaly`,
+		c.TradeID, snapTime, c.SnapshotLag, c.YesBid, c.YesAsk,
+		c.BRTI, c.Strike, c.SecsLeft, c.Status, c.Anomaly,
+	)
+	return err
+}
+
+func (s *Store) UpsertFillMetrics(ctx context.Context, m *FillMetrics) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO fill_metrics (trade_id, is_taker, count, mid, slippage,
+			markout_10s, markout_60s, queue_ahead)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(trade_id) DO UPDATE SET
+			is_taker = excluded.is_taker,
+			count = excluded.count,
+			mid = excluded.mid,
+			slippage = excluded.slippage,
+			markout_10s = excluded.markout_10s,
+			markout_60s = excluded.markout_60s,
+			queue_ahead = excluded.queue_ahead`,
+		m.TradeID, m.IsTaker, m.Count, m.Mid, m.Slippage,
+		m.Markout10s, m.Markout60s, m.QueueAhead,
+	)
+	return err
+}
+
+// ExecSummary returns fill_metrics aggregated into maker and taker rows.
+func (s *Store) ExecSummary(ctx context.Context) ([]ExecSummary, error) {
+	cond, args := s.accountCond("f.account")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.is_taker, COUNT(*), SUM(m.count),
+			SUM(m.slippage * m.count) / SUM(m.count),
+			COALESCE(SUM(m.markout_10s * m.count) / SUM(CASE WHEN m.markout_10s IS NOT NULL THEN m.count END), 0),
+			COALESCE(SUM(m.markout_60s * m.count) / SUM(CASE WHEN m.markout_60s IS NOT NULL THEN m.count END), 0),
+			COALESCE(CAST(SUM(CASE WHEN m.markout_60s < 0 THEN m.count ELSE 0 END) AS REAL)
+				/ SUM(CASE WHEN m.markout_60s IS NOT NULL THEN m.count END), 0),
+			COALESCE(AVG(m.queue_ahead), 0)
+		FROM fill_metrics m
+		JOIN fills f ON f.trade_id = m.trade_id
+		WHERE m.count > 0 AND `+cond+`
+		GROUP BY m.is_taker
+		ORDER BY m.is_taker`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ExecSummary
+	for rows.Next() {
+		var e ExecSummary
+		if err := rows.Scan(&e.IsTaker, &e.Fills, &e.Contracts, &e.AvgSlippage,
+			&e.AvgMarkout10s, &e.AvgMarkout60s, &e.AdverseShare, &e.AvgQueueAhead); err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
+
 func (s *Store) RecentTrades(ctx context.Context, limit int) ([]Fill, error) {
+	cond, args := s.accountCond("account")
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT trade_id, order_id, ticker, side, action, yes_price, no_price,
+		SELECT account, trade_id, order_id, ticker, side, action, yes_price, no_price,
 			count, is_taker, created_time
-		FROM fills ORDER BY created_time DESC LIMIT ?`, limit)
+		FROM fills WHERE `+cond+` ORDER BY created_time DESC LIMIT ?`, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +555,7 @@ func (s *Store) RecentTrades(ctx context.Context, limit int) ([]Fill, error) {
 	var results []Fill
 	for rows.Next() {
 		var f Fill
-		if err := rows.Scan(&f.TradeID, &f.OrderID, &f.Ticker, &f.Side, &f.Action,
+		if err := rows.Scan(&f.Account, &f.TradeID, &f.OrderID, &f.Ticker, &f.Side, &f.Action,
 			&f.YesPrice, &f.NoPrice, &f.Count, &f.IsTaker, &f.CreatedTime); err != nil {
 			return nil, err
 		}
@@ -166,3 +563,55 @@ func (s *Store) RecentTrades(ctx context.Context, limit int) ([]Fill, error) {
 	}
 	return results, rows.Err()
 }
+
+// TradesBetween returns the latest limit fills created in [since, until),
+// newest first, like RecentTrades. A zero bound is open and limit <= 0
+// means no limit.
+func (s *Store) TradesBetween(ctx context.Context, since, until time.Time, limit int) ([]Fill, error) {
+	fills, err := s.Fills(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Fill
+	for i := len(fills) - 1; i >= 0; i-- {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		if inRange(fills[i].CreatedTime, since, until) {
+			results = append(results, fills[i])
+		}
+	}
+	return results, nil
+}
+
+// PositionsOpenedBetween returns the positions, or only the open ones,
+// whose first fill was in [since, until). A zero bound is open.
+func (s *Store) PositionsOpenedBetween(ctx context.Context, openOnly bool, since, until time.Time) ([]Position, error) {
+	var rows []Position
+	var err error
+	if openOnly {
+		rows, err = s.OpenPositions(ctx)
+	} else {
+		rows, err = s.GetPositions(ctx)
+	}
+	if err != nil || (since.IsZero() && until.IsZero()) {
+		return rows, err
+	}
+
+	first, err := s.firstFillTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var results []Position
+	for _, p := range rows {
+		if t, ok := first[positionKey{p.Account, p.Ticker}]; ok && inRange(t, since, until) {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
+
+func inRange(t, since, until time.Time) bool {
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
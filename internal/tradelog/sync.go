@@ -2,24 +2,32 @@ package tradelog
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gw/btc15m-data/internal/kalshi"
 )
 
-// Sync fetches all orders, fills, and settlements from Kalshi and stores them.
-func Sync(ctx context.Context, client *kalshi.Client, store *Store) error {
+// Sync fetches all orders, fills, and settlements from Kalshi and stores
+// them, along with the schedule of every market traded. Rows belong to
+// the store's account (see Store.ForAccount), which should be client's.
+func Sync(ctx context.Context, client kalshi.API, store *Store) error {
 	if err := syncOrders(ctx, client, store); err != nil {
 		return err
 	}
 	if err := syncFills(ctx, client, store); err != nil {
 		return err
 	}
-	return syncSettlements(ctx, client, store)
+	if err := syncSettlements(ctx, client, store); err != nil {
+		return err
+	}
+	return syncMarkets(ctx, client, store)
 }
 
-func syncOrders(ctx context.Context, client *kalshi.Client, store *Store) error {
+func syncOrders(ctx context.Context, client kalshi.API, store *Store) error {
 	var cursor string
 	total := 0
 	for {
@@ -43,7 +51,7 @@ func syncOrders(ctx context.Context, client *kalshi.Client, store *Store) error
 	return nil
 }
 
-func syncFills(ctx context.Context, client *kalshi.Client, store *Store) error {
+func syncFills(ctx context.Context, client kalshi.API, store *Store) error {
 	var cursor string
 	total := 0
 	for {
@@ -67,7 +75,7 @@ func syncFills(ctx context.Context, client *kalshi.Client, store *Store) error {
 	return nil
 }
 
-func syncSettlements(ctx context.Context, client *kalshi.Client, store *Store) error {
+func syncSettlements(ctx context.Context, client kalshi.API, store *Store) error {
 	var cursor string
 	total := 0
 	for {
@@ -91,6 +99,27 @@ func syncSettlements(ctx context.Context, client *kalshi.Client, store *Store) e
 	return nil
 }
 
+// syncMarkets fetches metadata for every traded market not yet stored, and
+// refetches expired ones until their result is known.
+func syncMarkets(ctx context.Context, client kalshi.API, store *Store) error {
+	tickers, err := store.TickersNeedingMarket(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, t := range tickers {
+		m, err := client.GetMarket(ctx, t)
+		if err != nil {
+			return fmt.Errorf("fetching market %s: %w", t, err)
+		}
+		local := kalshiMarketToLocal(*m)
+		if err := store.UpsertMarket(ctx, &local); err != nil {
+			return err
+		}
+	}
+	slog.Info("synced markets", "count", len(tickers))
+	return nil
+}
+
 func parseTime(s string) time.Time {
 	t, _ := time.Parse(time.RFC3339, s)
 	return t
@@ -115,6 +144,20 @@ func kalshiOrderToLocal(o kalshi.Order) Order {
 	}
 }
 
+func kalshiMarketToLocal(m kalshi.Market) Market {
+	exp, _ := m.ExpirationParsed()
+	return Market{
+		Ticker:         m.Ticker,
+		EventTicker:    m.EventTicker,
+		Title:          m.Title,
+		Strike:         m.StrikePrice(),
+		OpenTime:       parseTime(m.OpenTime),
+		CloseTime:      parseTime(m.CloseTime),
+		ExpirationTime: exp,
+		Result:         m.Result,
+	}
+}
+
 func kalshiFillToLocal(f kalshi.Fill) Fill {
 	return Fill{
 		TradeID:     f.TradeID,
@@ -140,5 +183,16 @@ func kalshiSettlementToLocal(s kalshi.Settlement) Settlement {
 		YesCost:       s.YesCost,
 		Revenue:       s.Revenue,
 		SettledTime:   parseTime(s.SettledTime),
+		FeeCost:       parseDollars(s.FeeCost),
+	}
+}
+
+// parseDollars converts a fixed-point dollar string to cents, rounding to
+// the nearest cent. Empty or malformed values are 0.
+func parseDollars(s string) int {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
 	}
+	return int(math.Round(f * 100))
 }
@@ -0,0 +1,92 @@
+package tradelog
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// TaxReport returns every position settled in the given calendar year
+// (UTC), with proceeds, cost basis and fees as recorded by Kalshi.
+func TaxReport(ctx context.Context, store *Store, year int) ([]TaxLot, error) {
+	settlements, err := store.Settlements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	acquired, err := store.firstFillTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lots []TaxLot
+	for _, st := range settlements {
+		if st.SettledTime.UTC().Year() != year {
+			continue
+		}
+		if st.Revenue == 0 && st.YesCost == 0 && st.NoCost == 0 {
+			continue
+		}
+		cost := st.YesCost + st.NoCost
+		lots = append(lots, TaxLot{
+			Ticker:    st.Ticker,
+			Result:    st.MarketResult,
+			Contracts: st.YesTotalCount + st.NoTotalCount,
+			Acquired:  acquired[positionKey{st.Account, st.Ticker}],
+			Settled:   st.SettledTime,
+			Proceeds:  st.Revenue,
+			CostBasis: cost,
+			Fees:      st.FeeCost,
+			Gain:      st.Revenue - cost - st.FeeCost,
+		})
+	}
+	return lots, nil
+}
+
+// WriteTaxCSV writes lots in a 1099-B style layout, dollars with two
+// decimals, followed by a TOTAL row.
+func WriteTaxCSV(w io.Writer, lots []TaxLot) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"description", "ticker", "result", "contracts",
+		"date_acquired", "date_settled", "proceeds", "cost_basis", "fees", "gain_loss"})
+
+	var total TaxLot
+	for _, l := range lots {
+		acquired := ""
+		if !l.Acquired.IsZero() {
+			acquired = l.Acquired.UTC().Format("2006-01-02")
+		}
+		cw.Write([]string{
+			fmt.Sprintf("%d %s", l.Contracts, l.Ticker),
+			l.Ticker,
+			l.Result,
+			strconv.Itoa(l.Contracts),
+			acquired,
+			l.Settled.UTC().Format("2006-01-02"),
+			dollars(l.Proceeds),
+			dollars(l.CostBasis),
+			dollars(l.Fees),
+			dollars(l.Gain),
+		})
+		total.Contracts += l.Contracts
+		total.Proceeds += l.Proceeds
+		total.CostBasis += l.CostBasis
+		total.Fees += l.Fees
+		total.Gain += l.Gain
+	}
+	cw.Write([]string{"TOTAL", "", "", strconv.Itoa(total.Contracts), "", "",
+		dollars(total.Proceeds), dollars(total.CostBasis), dollars(total.Fees), dollars(total.Gain)})
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func dollars(c int) string {
+	sign := ""
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
@@ -19,46 +19,137 @@ type Order struct {
 	UpdatedTime       time.Time
 }
 
+// Fill is a trade of one account. Account is filled in by queries; writes
+// take the store's account.
 type Fill struct {
-	TradeID     string
-	OrderID     string
-	Ticker      string
-	Side        string
-	Action      string
-	YesPrice    int
-	NoPrice     int
-	Count       int
-	IsTaker     bool
-	CreatedTime time.Time
+	Account     string    `json:"account"`
+	TradeID     string    `json:"trade_id"`
+	OrderID     string    `json:"order_id"`
+	Ticker      string    `json:"ticker"`
+	Side        string    `json:"side"`
+	Action      string    `json:"action"`
+	YesPrice    int       `json:"yes_price"`
+	NoPrice     int       `json:"no_price"`
+	Count       int       `json:"count"`
+	IsTaker     bool      `json:"is_taker"`
+	CreatedTime time.Time `json:"created_time"`
 }
 
+// Settlement is one account's settled position in a market. Account is
+// filled in by queries, as for Fill.
 type Settlement struct {
-	Ticker       string
-	MarketResult string
-	NoTotalCount int
-	NoCost       int
+	Account       string
+	Ticker        string
+	MarketResult  string
+	NoTotalCount  int
+	NoCost        int
 	YesTotalCount int
-	YesCost      int
-	Revenue      int
-	SettledTime  time.Time
+	YesCost       int
+	Revenue       int
+	SettledTime   time.Time
+	FeeCost       int
 }
 
-// DailyPnL is a row from the v_daily_pnl view.
+// Market is the metadata of a traded market. CloseTime is when trading
+// stops; ExpirationTime is settlement, a few minutes later.
+type Market struct {
+	Ticker         string
+	EventTicker    string
+	Title          string
+	Strike         float64
+	OpenTime       time.Time
+	CloseTime      time.Time
+	ExpirationTime time.Time
+	Result         string // "" until settled
+}
+
+// FillContext is the recorded market state at the time of a fill,
+// produced by Reconcile.
+type FillContext struct {
+	TradeID      string    `json:"trade_id"`
+	SnapshotTime time.Time `json:"snapshot_time"` // zero if no snapshot was found
+	SnapshotLag  float64   `json:"snapshot_lag"`  // seconds between snapshot and fill
+	YesBid       int       `json:"yes_bid"`
+	YesAsk       int       `json:"yes_ask"`
+	BRTI         float64   `json:"brti"`
+	Strike       float64   `json:"strike"`
+	SecsLeft     int       `json:"secs_left"`
+	Status       string    `json:"status"`
+	Anomaly      string    `json:"anomaly"` // "" when the fill looks consistent with the data
+}
+
+// FillMetrics measures execution quality of a single fill against the
+// recorded book, produced by ExecQuality. Prices are in cents from the
+// trader's point of view: positive slippage means paying worse than mid,
+// negative markouts mean the market moved against the fill afterwards.
+type FillMetrics struct {
+	TradeID    string
+	IsTaker    bool
+	Count      int
+	Mid        float64  // YES midpoint at the fill
+	Slippage   float64  // fill price vs Mid
+	Markout10s *float64 // nil if no snapshot 10s after the fill
+	Markout60s *float64
+	QueueAhead *int // maker fills only: resting size at the fill price beforehand
+}
+
+// ExecSummary aggregates FillMetrics for maker or taker fills. Averages
+// are weighted by contracts.
+type ExecSummary struct {
+	IsTaker       bool    `json:"is_taker"`
+	Fills         int     `json:"fills"`
+	Contracts     int     `json:"contracts"`
+	AvgSlippage   float64 `json:"avg_slippage"`
+	AvgMarkout10s float64 `json:"avg_markout_10s"`
+	AvgMarkout60s float64 `json:"avg_markout_60s"`
+	AdverseShare  float64 `json:"adverse_share"` // fraction of contracts with a negative 60s markout
+	AvgQueueAhead float64 `json:"avg_queue_ahead"`
+}
+
+// EntryBucketPnL is settled PnL for positions opened within one range of
+// minutes before the market closed.
+type EntryBucketPnL struct {
+	Bucket    string `json:"bucket"`
+	Positions int    `json:"positions"`
+	Wins      int    `json:"wins"`
+	Cost      int    `json:"cost"`
+	NetPnL    int    `json:"net_pnl"`
+}
+
+// TaxLot is one settled position as reported by TaxReport. Amounts are in
+// cents; Gain is Proceeds - CostBasis - Fees.
+type TaxLot struct {
+	Ticker    string    `json:"ticker"`
+	Result    string    `json:"result"`
+	Contracts int       `json:"contracts"`
+	Acquired  time.Time `json:"acquired"` // first fill in the market, zero if unknown
+	Settled   time.Time `json:"settled"`
+	Proceeds  int       `json:"proceeds"`
+	CostBasis int       `json:"cost_basis"`
+	Fees      int       `json:"fees"`
+	Gain      int       `json:"gain"`
+}
+
+// DailyPnL is a row from the v_daily_pnl view (UTC dates) or DailyPnLIn.
+// Account is set for rows of one account, from v_account_daily_pnl or
+// AccountDailyPnLIn.
 type DailyPnL struct {
-	Date    string
-	Revenue int
-	Cost    int
-	NetPnL  int
-	Trades  int
+	Account string `json:"account,omitempty"`
+	Date    string `json:"date"`
+	Revenue int    `json:"revenue"`
+	Cost    int    `json:"cost"`
+	NetPnL  int    `json:"net_pnl"`
+	Trades  int    `json:"trades"`
 }
 
 // Position is a row from the v_positions view.
 type Position struct {
-	Ticker       string
-	YesContracts int
-	NoContracts  int
-	YesCost      int
-	NoCost       int
-	MarketResult string
-	Revenue      int
+	Account      string `json:"account"`
+	Ticker       string `json:"ticker"`
+	YesContracts int    `json:"yes_contracts"`
+	NoContracts  int    `json:"no_contracts"`
+	YesCost      int    `json:"yes_cost"`
+	NoCost       int    `json:"no_cost"`
+	MarketResult string `json:"market_result"`
+	Revenue      int    `json:"revenue"`
 }
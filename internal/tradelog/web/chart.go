@@ -0,0 +1,76 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/gw/btc15m-data/internal/tradelog"
+)
+
+// Chart layout, in SVG user units; the chart scales to the page width.
+const (
+	chartW    = 600
+	chartH    = 200
+	chartPadL = 52
+	chartPadR = 8
+	chartPadT = 10
+	chartPadB = 22
+)
+
+// pnlChart draws days, oldest first, as bars of daily net PnL with a line
+// of the cumulative PnL over them.
+func pnlChart(days []tradelog.DailyPnL) template.HTML {
+	if len(days) == 0 {
+		return ""
+	}
+
+	cum := make([]int, len(days))
+	lo, hi, run := 0, 0, 0
+	for i, d := range days {
+		run += d.NetPnL
+		cum[i] = run
+		lo, hi = min(lo, d.NetPnL, run), max(hi, d.NetPnL, run)
+	}
+	if hi == lo {
+		hi = lo + 100
+	}
+	plotW := float64(chartW - chartPadL - chartPadR)
+	plotH := float64(chartH - chartPadT - chartPadB)
+	y := func(v int) float64 {
+		return chartPadT + plotH*float64(hi-v)/float64(hi-lo)
+	}
+	slot := plotW / float64(len(days))
+	x := func(i int) float64 { return chartPadL + slot*(float64(i)+0.5) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="daily PnL">`, chartW, chartH)
+	for i, v := range []int{hi, 0, lo} {
+		if i > 0 && v == hi || i == 2 && v == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, chartPadL, chartW-chartPadR, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartPadL-4, y(v)+4, usd(v))
+	}
+	bar := min(slot*0.7, 40)
+	for i, d := range days {
+		top, bottom := y(max(d.NetPnL, 0)), y(min(d.NetPnL, 0))
+		class := "gain"
+		if d.NetPnL < 0 {
+			class = "loss"
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" class="%s"><title>%s: %s</title></rect>`,
+			x(i)-bar/2, top, bar, max(bottom-top, 1), class, d.Date, usd(d.NetPnL))
+	}
+	pts := make([]string, len(cum))
+	for i, v := range cum {
+		pts[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(v))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" class="cum"/>`, strings.Join(pts, " "))
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, chartPadL, chartH-6, days[0].Date)
+	if len(days) > 1 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartW-chartPadR, chartH-6, days[len(days)-1].Date)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
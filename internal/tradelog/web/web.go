@@ -0,0 +1,305 @@
+// Package web serves a small dashboard of the trade database: a daily PnL
+// chart, open positions and recent trades, laid out for a phone. Pages
+// are server-rendered from embedded templates; each section reloads
+// itself from a fragment URL, so no JavaScript library is needed.
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/tradelog"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// Defaults for the sections, overridable per request with ?days= and
+// ?trades=.
+const (
+	DefaultDays   = 30
+	DefaultTrades = 25
+)
+
+// Server serves the dashboard of one store.
+//
+//	GET /            the whole page; ?account= limits it to one account
+//	GET /pnl         daily PnL chart and table; ?days= sets how many
+//	GET /positions   open positions
+//	GET /trades      recent fills; ?trades= sets how many
+//
+// Without SetAuth there is no authentication; bind it to a loopback or
+// private address.
+type Server struct {
+	store   *tradelog.Store
+	rep     tradelog.Reporting
+	refresh time.Duration
+	user    string
+	pass    string
+	tmpl    *template.Template
+	srv     *http.Server
+}
+
+// New returns a server for store on addr, with days grouped by rep.
+func New(addr string, store *tradelog.Store, rep tradelog.Reporting) *Server {
+	s := &Server{store: store, rep: rep, refresh: 30 * time.Second}
+	s.tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+		"usd":   usd,
+		"price": price,
+		"cost":  func(p tradelog.Position) int { return p.YesCost + p.NoCost },
+		"clock": func(t time.Time) string { return rep.In(t).Format("01-02 15:04:05") },
+	}).ParseFS(templateFS, "templates/*.html"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/pnl", fragment(s, "pnl", s.pnlView))
+	mux.HandleFunc("/positions", fragment(s, "positions", s.positionsView))
+	mux.HandleFunc("/trades", fragment(s, "trades", s.tradesView))
+	s.srv = &http.Server{Addr: addr, Handler: s.authorize(mux), ReadHeaderTimeout: 5 * time.Second}
+	return s
+}
+
+// SetAuth requires HTTP basic auth with user and pass. Call it before
+// Run.
+func (s *Server) SetAuth(user, pass string) {
+	s.user, s.pass = user, pass
+}
+
+// SetRefresh sets how often an open page reloads its sections; 0 turns
+// reloading off. Call it before Run.
+func (s *Server) SetRefresh(d time.Duration) {
+	s.refresh = d
+}
+
+// Run serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("dashboard listening", "addr", s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("dashboard: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.user != "" || s.pass != "" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(pass), []byte(s.pass)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="tradelog"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// request is what a page or fragment was asked for.
+type request struct {
+	ctx     context.Context
+	store   *tradelog.Store
+	account string
+	days    int
+	trades  int
+}
+
+func (s *Server) parse(r *http.Request) request {
+	q := r.URL.Query()
+	req := request{
+		ctx:     r.Context(),
+		store:   s.store,
+		account: q.Get("account"),
+		days:    intParam(q, "days", DefaultDays),
+		trades:  intParam(q, "trades", DefaultTrades),
+	}
+	if req.account != "" {
+		req.store = s.store.ForAccount(req.account)
+	}
+	return req
+}
+
+// query returns the parameters to pass on to fragment URLs.
+func (req request) query() string {
+	q := url.Values{}
+	if req.account != "" {
+		q.Set("account", req.account)
+	}
+	if req.days != DefaultDays {
+		q.Set("days", strconv.Itoa(req.days))
+	}
+	if req.trades != DefaultTrades {
+		q.Set("trades", strconv.Itoa(req.trades))
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+func intParam(q url.Values, name string, def int) int {
+	if n, err := strconv.Atoi(q.Get(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+type pageView struct {
+	Title     string
+	Account   string
+	Accounts  []string
+	Reporting string
+	Query     string
+	RefreshMs int64
+	Error     string
+	PnL       pnlView
+	Positions positionsView
+	Trades    tradesView
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	req := s.parse(r)
+	page := pageView{
+		Title:     "tradelog",
+		Account:   req.account,
+		Reporting: s.rep.String(),
+		Query:     req.query(),
+		RefreshMs: s.refresh.Milliseconds(),
+		PnL:       s.pnlView(req),
+		Positions: s.positionsView(req),
+		Trades:    s.tradesView(req),
+	}
+	if req.account != "" {
+		page.Title += " · " + req.account
+	}
+	accounts, err := s.store.Accounts(req.ctx)
+	if err != nil {
+		page.Error = err.Error()
+	}
+	if len(accounts) > 1 || req.account != "" {
+		page.Accounts = accounts
+	}
+	s.render(w, "page", page)
+}
+
+// fragment serves one section for the page's reloads.
+func fragment[V any](s *Server, name string, view func(request) V) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, name, view(s.parse(r)))
+	}
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.tmpl.ExecuteTemplate(w, name, data); err != nil {
+		slog.Warn("dashboard render failed", "template", name, "err", err)
+	}
+}
+
+type pnlView struct {
+	Error string
+	Days  []tradelog.DailyPnL // newest first
+	Shown int                 // days charted
+	Total int                 // net PnL of every day, not just those shown
+	Chart template.HTML
+}
+
+func (s *Server) pnlView(req request) pnlView {
+	var v pnlView
+	rows, err := tradelog.DailyPnLIn(req.ctx, req.store, s.rep)
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	for _, d := range rows {
+		v.Total += d.NetPnL
+	}
+	if len(rows) > req.days {
+		rows = rows[len(rows)-req.days:]
+	}
+	v.Shown = len(rows)
+	v.Chart = pnlChart(rows)
+	for i := len(rows) - 1; i >= 0; i-- {
+		v.Days = append(v.Days, rows[i])
+	}
+	return v
+}
+
+type positionsView struct {
+	Error       string
+	Rows        []tradelog.Position
+	ShowAccount bool
+	Cost        int
+}
+
+func (s *Server) positionsView(req request) positionsView {
+	var v positionsView
+	rows, err := req.store.OpenPositions(req.ctx)
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	v.Rows = rows
+	for _, p := range rows {
+		v.Cost += p.YesCost + p.NoCost
+		v.ShowAccount = v.ShowAccount || p.Account != rows[0].Account
+	}
+	return v
+}
+
+type tradesView struct {
+	Error       string
+	Fills       []tradelog.Fill
+	ShowAccount bool
+}
+
+func (s *Server) tradesView(req request) tradesView {
+	var v tradesView
+	fills, err := req.store.RecentTrades(req.ctx, req.trades)
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	v.Fills = fills
+	for _, f := range fills {
+		v.ShowAccount = v.ShowAccount || f.Account != fills[0].Account
+	}
+	return v
+}
+
+// price is what a fill paid per contract, in its side's terms.
+func price(f tradelog.Fill) int {
+	if f.Side == "no" {
+		return f.NoPrice
+	}
+	return f.YesPrice
+}
+
+// usd formats cents as -$1.23.
+func usd(c int) string {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, c/100, c%100)
+}
@@ -0,0 +1,486 @@
+// Package brti computes a proxy for the CF Benchmarks Bitcoin Real-Time
+// Index from exchange price feeds: by default the median of the fresh
+// feeds' mid-prices, sampled into a short history and, during a market's
+// settlement window, into the ticks its settlement is computed from.
+//
+// It depends only on the ExchangeFeed interface, so programs can embed it
+// with feeds of their own; the collector's Coinbase, Kraken and Bitstamp
+// WebSocket feeds implement it.
+package brti
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// ExchangeFeed is one exchange's live BTC-USD price.
+type ExchangeFeed interface {
+	Name() string
+	Run(ctx context.Context) error
+	MidPrice() float64
+	LastUpdate() time.Time
+	IsStale() bool // >5s since last update
+}
+
+// Clock is the time source of a Proxy. internal/clock's clocks satisfy it.
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+// Aggregation selects how fresh feed prices combine into one.
+type Aggregation string
+
+const (
+	Median   Aggregation = "median"   // middle price; mean of the middle two
+	Weighted Aggregation = "weighted" // mean weighted by Options.Weights
+)
+
+// OutlierPolicy selects what happens to a price too far from the others.
+type OutlierPolicy string
+
+const (
+	OutlierDrop  OutlierPolicy = "drop"  // leave it out
+	OutlierClamp OutlierPolicy = "clamp" // pull it in to MaxDeviation
+)
+
+// DefaultHistory is how many samples a Proxy keeps by default: 15 minutes
+// at one per second.
+const DefaultHistory = 900
+
+// Options configures a Proxy. The zero value is the median of every fresh
+// feed, which is what the collector records.
+type Options struct {
+	Aggregation Aggregation        // default Median
+	Weights     map[string]float64 // by feed name, for Weighted; missing = 1, <= 0 leaves the feed out
+
+	// Quorum is how many fresh feeds, after outliers are dropped, a new
+	// price needs. With fewer, Snapshot keeps returning the last price.
+	// Default 1.
+	Quorum int
+
+	// MaxDeviation, when positive, treats a price further than this
+	// fraction from the median of the fresh prices as an outlier, handled
+	// per Outliers (default OutlierDrop). It needs three prices to tell
+	// which one is off, so it does nothing with two.
+	MaxDeviation float64
+	Outliers     OutlierPolicy
+
+	History int   // samples kept; default DefaultHistory
+	Clock   Clock // timestamps samples; default the wall clock
+}
+
+// ParseWeights reads Options.Weights from comma-separated name=weight
+// pairs, e.g. "coinbase=2,kraken=1,bitstamp=1".
+func ParseWeights(s string) (map[string]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	out := make(map[string]float64)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("weights: %q is not name=weight", kv)
+		}
+		w, err := strconv.ParseFloat(v, 64)
+		if err != nil || w < 0 || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights: %s must be a non-negative number, got %q", k, v)
+		}
+		out[k] = w
+	}
+	return out, nil
+}
+
+// TimedPrice is a BRTI sample and when it was taken.
+type TimedPrice struct {
+	Time  time.Time
+	Price float64
+}
+
+// Proxy is the index proxy over a set of feeds. It is safe for
+// concurrent use.
+type Proxy struct {
+	opts Options
+
+	mu              sync.RWMutex
+	clock           Clock
+	feeds           []ExchangeFeed
+	price           float64
+	priceHistory    []TimedPrice // ring buffer, last opts.History samples
+	historyIdx      int
+	historyFull     bool
+	settlementTicks []TimedPrice // samples since StartSettlementWindow
+	sampling        bool
+}
+
+// New returns a Proxy over feeds, which it reads but does not run.
+func New(feeds []ExchangeFeed, opts Options) (*Proxy, error) {
+	switch opts.Aggregation {
+	case "":
+		opts.Aggregation = Median
+	case Median, Weighted:
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q (want median or weighted)", opts.Aggregation)
+	}
+	switch opts.Outliers {
+	case "":
+		opts.Outliers = OutlierDrop
+	case OutlierDrop, OutlierClamp:
+	default:
+		return nil, fmt.Errorf("unknown outlier policy %q (want drop or clamp)", opts.Outliers)
+	}
+	if opts.MaxDeviation < 0 {
+		return nil, fmt.Errorf("negative max deviation %v", opts.MaxDeviation)
+	}
+	opts.Quorum = max(opts.Quorum, 1)
+	if opts.History <= 0 {
+		opts.History = DefaultHistory
+	}
+	clk := opts.Clock
+	if clk == nil {
+		clk = wallClock{}
+	}
+	return &Proxy{
+		opts:         opts,
+		feeds:        feeds,
+		clock:        clk,
+		priceHistory: make([]TimedPrice, opts.History),
+	}, nil
+}
+
+// SetClock makes the proxy timestamp its samples with clk instead of the
+// wall clock. Call it before sampling starts.
+func (b *Proxy) SetClock(clk Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clk
+}
+
+// SetFeeds replaces the feeds the proxy aggregates.
+func (b *Proxy) SetFeeds(feeds []ExchangeFeed) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.feeds = feeds
+}
+
+func (b *Proxy) currentFeeds() []ExchangeFeed {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.feeds
+}
+
+type feedPrice struct {
+	name  string
+	price float64
+}
+
+// Snapshot computes the proxy price from the non-stale mid-prices, or
+// returns the last one if fewer than the quorum are fresh.
+func (b *Proxy) Snapshot() float64 {
+	var prices []feedPrice
+	for _, f := range b.currentFeeds() {
+		if !f.IsStale() {
+			p := f.MidPrice()
+			if p > 0 {
+				prices = append(prices, feedPrice{f.Name(), p})
+			}
+		}
+	}
+	prices = b.trimOutliers(prices)
+
+	p := 0.0
+	if len(prices) >= b.opts.Quorum {
+		p = b.aggregate(prices)
+	}
+	if p <= 0 {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return b.price // return last known price
+	}
+
+	b.mu.Lock()
+	b.price = p
+	b.mu.Unlock()
+
+	return p
+}
+
+func (b *Proxy) trimOutliers(prices []feedPrice) []feedPrice {
+	dev := b.opts.MaxDeviation
+	if dev <= 0 || len(prices) < 3 {
+		return prices
+	}
+	m := median(sortedPrices(prices))
+	lo, hi := m*(1-dev), m*(1+dev)
+	out := prices[:0]
+	for _, fp := range prices {
+		if fp.price >= lo && fp.price <= hi {
+			out = append(out, fp)
+			continue
+		}
+		slog.Debug("brti: outlier", "feed", fp.name, "price", fp.price, "median", m, "policy", b.opts.Outliers)
+		if b.opts.Outliers == OutlierClamp {
+			fp.price = min(max(fp.price, lo), hi)
+			out = append(out, fp)
+		}
+	}
+	return out
+}
+
+func (b *Proxy) aggregate(prices []feedPrice) float64 {
+	if b.opts.Aggregation == Weighted {
+		sum, total := 0.0, 0.0
+		for _, fp := range prices {
+			w, ok := b.opts.Weights[fp.name]
+			if !ok {
+				w = 1
+			}
+			if w > 0 {
+				sum += w * fp.price
+				total += w
+			}
+		}
+		if total == 0 {
+			return 0
+		}
+		return sum / total
+	}
+	return median(sortedPrices(prices))
+}
+
+func sortedPrices(prices []feedPrice) []float64 {
+	out := make([]float64, len(prices))
+	for i, fp := range prices {
+		out[i] = fp.price
+	}
+	sort.Float64s(out)
+	return out
+}
+
+// RecordSample appends the current snapshot to the price history ring buffer.
+func (b *Proxy) RecordSample() {
+	p := b.Snapshot()
+	if p <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.priceHistory[b.historyIdx] = TimedPrice{Time: b.clock.Now(), Price: p}
+	b.historyIdx++
+	if b.historyIdx >= len(b.priceHistory) {
+		b.historyIdx = 0
+		b.historyFull = true
+	}
+}
+
+// PriceHistory returns the most recent N samples from the ring buffer,
+// oldest first.
+func (b *Proxy) PriceHistory(n int) []TimedPrice {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n = min(n, b.historyLenLocked())
+	if n <= 0 {
+		return nil
+	}
+
+	result := make([]TimedPrice, n)
+	for i := range n {
+		result[i] = b.sampleLocked(n - 1 - i)
+	}
+	return result
+}
+
+// PricesSince returns the samples taken at or after t, oldest first. Unlike
+// PriceHistory it does not assume one sample per second, so it stays
+// correct when the tick interval changes or samples are missed.
+func (b *Proxy) PricesSince(t time.Time) []TimedPrice {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	total := b.historyLenLocked()
+	n := 0
+	for n < total && !b.sampleLocked(n).Time.Before(t) {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+
+	result := make([]TimedPrice, n)
+	for i := range n {
+		result[i] = b.sampleLocked(n - 1 - i)
+	}
+	return result
+}
+
+// MinMaxLast returns the lowest and highest sampled prices over the last d.
+// ok is false if there were no samples in that span.
+func (b *Proxy) MinMaxLast(d time.Duration) (lo, hi float64, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	since := b.clock.Now().Add(-d)
+	total := b.historyLenLocked()
+	for i := 0; i < total; i++ {
+		s := b.sampleLocked(i)
+		if s.Time.Before(since) {
+			break
+		}
+		if !ok || s.Price < lo {
+			lo = s.Price
+		}
+		if !ok || s.Price > hi {
+			hi = s.Price
+		}
+		ok = true
+	}
+	return lo, hi, ok
+}
+
+// historyLenLocked is the number of samples in the ring buffer. Caller holds b.mu.
+func (b *Proxy) historyLenLocked() int {
+	if b.historyFull {
+		return len(b.priceHistory)
+	}
+	return b.historyIdx
+}
+
+// sampleLocked returns the sample age positions back from the newest (0 is
+// the newest). Caller holds b.mu.
+func (b *Proxy) sampleLocked(age int) TimedPrice {
+	idx := b.historyIdx - 1 - age
+	if idx < 0 {
+		idx += len(b.priceHistory)
+	}
+	return b.priceHistory[idx]
+}
+
+// StartSettlementWindow begins recording per-second ticks for a market's
+// settlement window (the final minute for KXBTC15M; see ticks.SettlementRule).
+func (b *Proxy) StartSettlementWindow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settlementTicks = make([]TimedPrice, 0, 60)
+	b.sampling = true
+	slog.Info("settlement window started")
+}
+
+// RecordSettlementTick records one per-second BRTI value during the settlement window.
+func (b *Proxy) RecordSettlementTick() {
+	p := b.Snapshot()
+	if p <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sampling {
+		b.settlementTicks = append(b.settlementTicks, TimedPrice{Time: b.clock.Now(), Price: p})
+		slog.Debug("settlement tick", "k", len(b.settlementTicks), "price", p)
+	}
+}
+
+// SettlementTicks returns the observed ticks so far.
+func (b *Proxy) SettlementTicks() []float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]float64, len(b.settlementTicks))
+	for i, t := range b.settlementTicks {
+		out[i] = t.Price
+	}
+	return out
+}
+
+// IsSampling returns whether we're in a settlement window.
+func (b *Proxy) IsSampling() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sampling
+}
+
+// StopSettlementWindow ends settlement sampling.
+func (b *Proxy) StopSettlementWindow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sampling = false
+}
+
+// SettlementAverage returns the average of all settlement ticks collected so far.
+func (b *Proxy) SettlementAverage() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.settlementTicks) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range b.settlementTicks {
+		sum += v.Price
+	}
+	return sum / float64(len(b.settlementTicks))
+}
+
+// SettlementValue applies rule to the settlement ticks collected so far for
+// a market closing at close.
+func (b *Proxy) SettlementValue(rule ticks.SettlementRule, close time.Time) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	samples := make([]ticks.PriceSample, len(b.settlementTicks))
+	for i, t := range b.settlementTicks {
+		samples[i] = ticks.PriceSample(t)
+	}
+	return rule.Settle(samples, close)
+}
+
+// Price returns the last computed proxy price.
+func (b *Proxy) Price() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.price
+}
+
+// FeedStatus returns a summary of each feed's health.
+func (b *Proxy) FeedStatus() []FeedHealth {
+	var out []FeedHealth
+	for _, f := range b.currentFeeds() {
+		out = append(out, FeedHealth{
+			Name:       f.Name(),
+			Price:      f.MidPrice(),
+			LastUpdate: f.LastUpdate(),
+			Stale:      f.IsStale(),
+		})
+	}
+	return out
+}
+
+type FeedHealth struct {
+	Name       string
+	Price      float64
+	LastUpdate time.Time
+	Stale      bool
+}
+
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[n/2]
+}
@@ -0,0 +1,297 @@
+// Package btc15m embeds the collector in another Go program: the Kalshi
+// REST and WebSocket clients, the exchange feeds, the BRTI proxy and the
+// tick writer, assembled as cmd/datacollector assembles them, with ticks
+// delivered in-process as they are written.
+//
+//	c, err := btc15m.New(btc15m.Options{APIKeyID: id, PrivateKeyPath: "kalshi.pem"})
+//	if err != nil { ... }
+//	ticks, cancel := c.Subscribe(64)
+//	defer cancel()
+//	if err := c.Start(ctx); err != nil { ... }
+//	defer c.Stop(context.Background())
+//	for t := range ticks { ... }
+//
+// Ticks are written to OutputDir as the collector writes them, so the
+// archive tools work on an embedded collector's data too.
+package btc15m
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gw/btc15m-data/internal/collector"
+	"github.com/gw/btc15m-data/internal/config"
+	"github.com/gw/btc15m-data/internal/feed"
+	"github.com/gw/btc15m-data/internal/kalshi"
+	"github.com/gw/btc15m-data/pkg/brti"
+	"github.com/gw/btc15m-data/pkg/ticks"
+)
+
+// Options configures a Collector. Only the credentials are required; the
+// rest default to what cmd/datacollector does without flags.
+type Options struct {
+	APIKeyID       string
+	PrivateKeyPath string
+	Env            string // "prod" (default) or "demo"
+
+	Series       string        // default KXBTC15M
+	OutputDir    string        // default ./data
+	Prefix       string        // file name prefix, default kxbtc15m
+	TickInterval time.Duration // default 1s
+
+	Feeds    []string          // default coinbase, kraken, bitstamp
+	FeedURLs map[string]string // replace exchange WebSocket endpoints, by feed name
+	BRTI     brti.Options
+
+	// Exposure records the account's positions and resting orders in each
+	// tracked market with every tick, as --exposure does.
+	Exposure bool
+
+	// KalshiWSURL replaces the environment's WebSocket endpoint, and
+	// HTTPReplay answers REST from a cassette instead of Kalshi (see
+	// "Testing Without Kalshi" in the README); with it the key is optional.
+	KalshiWSURL string
+	HTTPReplay  string
+}
+
+// Collector is an embedded collector. Start it once; Stop ends it.
+type Collector struct {
+	client kalshi.API
+	ws     *kalshi.KalshiFeed
+	feeds  []brti.ExchangeFeed
+	proxy  *brti.Proxy
+	writer *collector.Writer
+	inner  *collector.Collector
+
+	mu      sync.Mutex
+	subs    map[chan ticks.TickRecord]bool
+	started bool
+	cancel  context.CancelFunc
+	dropped int64
+	runErr  error
+	stopped chan struct{} // closed when Run returns
+}
+
+// New assembles a Collector without connecting to anything.
+func New(opts Options) (*Collector, error) {
+	cfg := &config.Config{
+		KalshiAPIKeyID:    opts.APIKeyID,
+		KalshiPrivKeyPath: opts.PrivateKeyPath,
+		KalshiEnv:         opts.Env,
+		KalshiWSURL:       opts.KalshiWSURL,
+		KalshiHTTPReplay:  opts.HTTPReplay,
+		OutputDir:         opts.OutputDir,
+		SeriesTicker:      opts.Series,
+	}
+	if cfg.KalshiEnv == "" {
+		cfg.KalshiEnv = "prod"
+	}
+	client, err := kalshi.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newCollector(opts, cfg, client, kalshi.NewKalshiFeed(cfg, client.PrivateKey()))
+}
+
+func newCollector(opts Options, cfg *config.Config, client kalshi.API, ws *kalshi.KalshiFeed) (*Collector, error) {
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = "./data"
+	}
+	if cfg.SeriesTicker == "" {
+		cfg.SeriesTicker = "KXBTC15M"
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "kxbtc15m"
+	}
+	if len(opts.Feeds) == 0 {
+		opts.Feeds = []string{"coinbase", "kraken", "bitstamp"}
+	}
+
+	var feeds []brti.ExchangeFeed
+	for _, name := range opts.Feeds {
+		f, err := feed.NewByNameURL(name, opts.FeedURLs[name])
+		if err != nil {
+			return nil, err
+		}
+		rf, err := feed.WithRESTFallback(f, feed.DefaultRESTAfter, feed.DefaultRESTEvery)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, rf)
+	}
+	proxy, err := brti.New(feeds, opts.BRTI)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := collector.NewWriterOptions(cfg.OutputDir, opts.Prefix, collector.WriterOptions{
+		BufferSize:    64 << 10,
+		FlushInterval: time.Second,
+		SyncInterval:  5 * time.Second,
+		Journal:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{
+		client:  client,
+		ws:      ws,
+		feeds:   feeds,
+		proxy:   proxy,
+		writer:  writer,
+		inner:   collector.New(client, ws, proxy, feeds, writer, cfg.SeriesTicker),
+		subs:    make(map[chan ticks.TickRecord]bool),
+		stopped: make(chan struct{}),
+	}
+	if opts.TickInterval > 0 {
+		c.inner.SetInterval(opts.TickInterval)
+	}
+	if opts.Exposure {
+		ws.EnablePositions()
+		c.inner.EnableExposure(collector.DefaultExposureEvery)
+	}
+	c.inner.AddTickHook(c.publish)
+	return c, nil
+}
+
+// Start checks the credentials and starts collecting in the background.
+// ctx bounds only the credential check; collection runs until Stop.
+func (c *Collector) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return errors.New("btc15m: collector already started")
+	}
+	if _, err := c.client.GetBalance(ctx); err != nil {
+		return fmt.Errorf("auth check: %w", err)
+	}
+	c.started = true
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.writer.CompressStale()
+
+	go func() {
+		if err := c.ws.Run(runCtx); err != nil && runCtx.Err() == nil {
+			slog.Error("kalshi ws error", "err", err)
+		}
+	}()
+	for _, f := range c.feeds {
+		go func() {
+			if err := f.Run(runCtx); err != nil && runCtx.Err() == nil {
+				slog.Error("feed error", "feed", f.Name(), "err", err)
+			}
+		}()
+	}
+	go func() {
+		err := c.inner.Run(runCtx)
+		if runCtx.Err() == nil {
+			slog.Error("collector stopped on its own", "err", err)
+			c.mu.Lock()
+			c.runErr = err
+			c.mu.Unlock()
+		}
+		close(c.stopped)
+	}()
+	return nil
+}
+
+// Stop ends collection and drains as cmd/datacollector does on SIGTERM:
+// it records the results of markets that expired in the last 15 minutes,
+// closes the files and waits for compressions. ctx bounds the drain. Stop
+// closes every subscription channel and returns the error that ended the
+// collector, if it stopped on its own before Stop.
+func (c *Collector) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.cancel = nil
+	c.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-c.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.closeSubs()
+	c.mu.Lock()
+	runErr := c.runErr
+	c.mu.Unlock()
+
+	if err := c.inner.Drain(ctx, "stopped", collector.DefaultSettleLookback); err != nil {
+		return errors.Join(runErr, fmt.Errorf("drain: %w", err))
+	}
+	return runErr
+}
+
+// Done is closed when collection has ended: after Stop, or earlier if the
+// collector gave up on its own, e.g. because its watchdog found writes
+// stalled. Stop is still needed then, to drain and to learn why.
+func (c *Collector) Done() <-chan struct{} {
+	return c.stopped
+}
+
+// Subscribe returns a channel receiving every tick written from now on,
+// and a function ending the subscription. A subscriber that falls more
+// than buffer ticks behind misses ticks rather than holding up the
+// collector; Dropped counts them. The channel is closed by cancel or Stop.
+func (c *Collector) Subscribe(buffer int) (<-chan ticks.TickRecord, func()) {
+	ch := make(chan ticks.TickRecord, max(buffer, 1))
+	c.mu.Lock()
+	c.subs[ch] = true
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.subs[ch] {
+			delete(c.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (c *Collector) publish(t ticks.TickRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subs {
+		select {
+		case ch <- t:
+		default:
+			c.dropped++
+		}
+	}
+}
+
+func (c *Collector) closeSubs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subs {
+		delete(c.subs, ch)
+		close(ch)
+	}
+}
+
+// Dropped returns how many ticks subscribers have missed by falling behind.
+func (c *Collector) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Price returns the last BRTI proxy price.
+func (c *Collector) Price() float64 {
+	return c.proxy.Price()
+}
+
+// Feeds returns each exchange feed's health.
+func (c *Collector) Feeds() []brti.FeedHealth {
+	return c.proxy.FeedStatus()
+}
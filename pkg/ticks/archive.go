@@ -0,0 +1,61 @@
+package ticks
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventDate parses the date (and time, when present) encoded in a market
+// or event ticker, e.g. 26FEB091900 in KXBTC15M-26FEB091900-00. The
+// result is the wall-clock time Kalshi uses, US Eastern, labelled UTC.
+func EventDate(ticker string) (time.Time, bool) {
+	parts := strings.Split(ticker, "-")
+	if len(parts) < 2 || len(parts[1]) < 7 {
+		return time.Time{}, false
+	}
+	code, layout := parts[1][:7], "06Jan02"
+	if len(parts[1]) >= 11 {
+		code, layout = parts[1][:11], "06Jan021504"
+	}
+	// time.Parse wants "Feb", not "FEB".
+	code = code[:3] + strings.ToLower(code[3:5]) + code[5:]
+	t, err := time.Parse(layout, code)
+	return t, err == nil
+}
+
+// FindArchives returns the archive files under dir, in flat or monthly
+// layout, that can hold ticks for ticker. Eastern time runs behind UTC, so
+// the files for the ticker's date and the day after cover it; tickers
+// without a date fall back to every archive with prefix.
+func FindArchives(dir, prefix, ticker string) []string {
+	var patterns []string
+	if t, ok := EventDate(ticker); ok {
+		for _, d := range []time.Time{t, t.AddDate(0, 0, 1)} {
+			name := prefix + "-" + d.Format("2006-01-02") + "*.jsonl*"
+			patterns = append(patterns,
+				filepath.Join(dir, name),
+				filepath.Join(dir, d.Format("2006"), d.Format("01"), name))
+		}
+	} else {
+		patterns = []string{
+			filepath.Join(dir, prefix+"-*.jsonl*"),
+			filepath.Join(dir, "*", "*", prefix+"-*.jsonl*"),
+		}
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		matches, _ := filepath.Glob(p)
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				paths = append(paths, m)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
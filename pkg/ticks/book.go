@@ -0,0 +1,65 @@
+package ticks
+
+// BookFeatures summarizes a market's orderbook so consumers don't need to
+// walk the raw levels. Kalshi books hold bids only: a NO bid at p is a YES
+// ask at 100-p. Prices are in cents on the YES scale.
+type BookFeatures struct {
+	YesDepth5  int     `json:"yes_depth5"` // YES bid qty within 5¢ of mid
+	NoDepth5   int     `json:"no_depth5"`  // NO bid qty within 5¢ of mid (the YES ask side)
+	Imbalance  float64 `json:"imbalance"`  // (yes-no)/(yes+no) of the depth5 totals, in [-1, 1]
+	Microprice float64 `json:"microprice"` // top-of-book size-weighted mid
+	YesTop3    [3]int  `json:"yes_top3"`   // sizes of the best three YES bid levels
+	NoTop3     [3]int  `json:"no_top3"`    // sizes of the best three NO bid levels
+}
+
+// ComputeBookFeatures derives BookFeatures from price-ascending YES and NO
+// bid levels. It returns nil when both sides are empty. Mid-relative fields
+// are zero unless both sides have at least one level.
+func ComputeBookFeatures(yes, no [][2]int) *BookFeatures {
+	if len(yes) == 0 && len(no) == 0 {
+		return nil
+	}
+
+	f := &BookFeatures{
+		YesTop3: top3(yes),
+		NoTop3:  top3(no),
+	}
+	if len(yes) == 0 || len(no) == 0 {
+		return f
+	}
+
+	bestYes := yes[len(yes)-1]
+	bestNo := no[len(no)-1]
+	bid := float64(bestYes[0])
+	ask := float64(100 - bestNo[0])
+	mid := (bid + ask) / 2
+
+	for _, l := range yes {
+		if float64(l[0]) >= mid-5 {
+			f.YesDepth5 += l[1]
+		}
+	}
+	for _, l := range no {
+		if float64(100-l[0]) <= mid+5 {
+			f.NoDepth5 += l[1]
+		}
+	}
+	if total := f.YesDepth5 + f.NoDepth5; total > 0 {
+		f.Imbalance = float64(f.YesDepth5-f.NoDepth5) / float64(total)
+	}
+
+	bidQty, askQty := float64(bestYes[1]), float64(bestNo[1])
+	if bidQty+askQty > 0 {
+		f.Microprice = (bid*askQty + ask*bidQty) / (bidQty + askQty)
+	}
+	return f
+}
+
+// top3 returns the sizes of the three highest-priced levels, best first.
+func top3(levels [][2]int) [3]int {
+	var out [3]int
+	for i := 0; i < 3 && i < len(levels); i++ {
+		out[i] = levels[len(levels)-1-i][1]
+	}
+	return out
+}
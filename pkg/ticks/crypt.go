@@ -0,0 +1,364 @@
+package ticks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Encrypted archives (.enc appended to the name, e.g. .jsonl.gz.enc) are
+// AES-256-GCM in fixed-size chunks, so a reader can seek to any index
+// block without decrypting what comes before it. The file starts with a
+// 16-byte header: encMagic, a version byte and a random 7-byte nonce
+// prefix. Each chunk of up to encChunkSize plaintext bytes follows, sealed
+// with the nonce prefix, the chunk number and a flag marking the final
+// chunk, so a truncated or reordered file fails to decrypt.
+
+// EncExt is the extension of encrypted archive files.
+const EncExt = ".enc"
+
+const (
+	encMagic      = "BTC15ENC"
+	encVersion    = 1
+	encHeaderSize = 16
+	encChunkSize  = 64 << 10
+	encTagSize    = 16
+	encKeySize    = 32
+)
+
+// ErrNoKey is returned when opening an encrypted archive with no key set.
+var ErrNoKey = errors.New("encrypted archive: no key (set ARCHIVE_KEY or ARCHIVE_KEY_FILE)")
+
+var (
+	keyMu     sync.Mutex
+	keyLoaded bool
+	archKey   []byte
+	keyErr    error
+)
+
+// SetArchiveKey sets the key Open and OpenAt decrypt .enc archives with,
+// instead of the one in the environment.
+func SetArchiveKey(key []byte) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	archKey, keyErr, keyLoaded = key, nil, true
+}
+
+// ArchiveKey returns the archive key: the one given to SetArchiveKey, else
+// ARCHIVE_KEY (64 hex digits or base64), else the contents of the file
+// named by ARCHIVE_KEY_FILE. It returns nil when none is configured.
+func ArchiveKey() ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if !keyLoaded {
+		archKey, keyErr = keyFromEnv()
+		keyLoaded = true
+	}
+	return archKey, keyErr
+}
+
+func keyFromEnv() ([]byte, error) {
+	if v := os.Getenv("ARCHIVE_KEY"); v != "" {
+		k, err := ParseKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("ARCHIVE_KEY: %w", err)
+		}
+		return k, nil
+	}
+	if path := os.Getenv("ARCHIVE_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ARCHIVE_KEY_FILE: %w", err)
+		}
+		k, err := ParseKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("ARCHIVE_KEY_FILE %s: %w", path, err)
+		}
+		return k, nil
+	}
+	return nil, nil
+}
+
+// ParseKey decodes a 32-byte key written as hex or base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if k, err := hex.DecodeString(s); err == nil && len(k) == encKeySize {
+		return k, nil
+	}
+	if k, err := base64.StdEncoding.DecodeString(s); err == nil && len(k) == encKeySize {
+		return k, nil
+	}
+	return nil, fmt.Errorf("want %d bytes as hex or base64", encKeySize)
+}
+
+// NewKey returns a random key, hex-encoded as ParseKey accepts it.
+func NewKey() string {
+	k := make([]byte, encKeySize)
+	rand.Read(k)
+	return hex.EncodeToString(k)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != encKeySize {
+		return nil, fmt.Errorf("key is %d bytes, want %d", len(key), encKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[7:11], n)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// EncryptWriter encrypts everything written to it. Close seals the final
+// chunk; without it the file cannot be decrypted.
+type EncryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+	out    []byte
+	header bool
+}
+
+// NewEncryptWriter returns a writer that encrypts to w with key.
+func NewEncryptWriter(w io.Writer, key []byte) (*EncryptWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, 7)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	return &EncryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (e *EncryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is sealed only once more data arrives, so the one
+		// Close seals is never empty unless the whole stream is.
+		if len(e.buf) == encChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (e *EncryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *EncryptWriter) seal(last bool) error {
+	if !e.header {
+		hdr := make([]byte, 0, encHeaderSize)
+		hdr = append(hdr, encMagic...)
+		hdr = append(hdr, encVersion)
+		hdr = append(hdr, e.prefix...)
+		if _, err := e.w.Write(hdr); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.prefix, e.n, last), e.buf, nil)
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.n++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// DecryptReader decrypts an encrypted archive. It seeks in plaintext
+// offsets, which is what index blocks record.
+type DecryptReader struct {
+	r      io.ReadSeeker
+	aead   cipher.AEAD
+	prefix []byte
+	chunks int64 // chunks in the file
+	chunk  int64 // chunk buf holds, -1 for none
+	pos    int64 // plaintext offset of the next Read
+	buf    []byte
+	sealed []byte
+}
+
+// NewDecryptReader reads the encrypted file r with key.
+func NewDecryptReader(r io.ReadSeeker, key []byte) (*DecryptReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("encrypted archive header: %w", err)
+	}
+	if !bytes.Equal(hdr[:len(encMagic)], []byte(encMagic)) {
+		return nil, errors.New("not an encrypted archive")
+	}
+	if hdr[len(encMagic)] != encVersion {
+		return nil, fmt.Errorf("encrypted archive version %d not supported", hdr[len(encMagic)])
+	}
+	sealedSize := int64(encChunkSize + encTagSize)
+	body := size - encHeaderSize
+	if body < encTagSize {
+		return nil, errors.New("encrypted archive truncated")
+	}
+	return &DecryptReader{
+		r:      r,
+		aead:   aead,
+		prefix: hdr[len(encMagic)+1:],
+		chunks: (body + sealedSize - 1) / sealedSize,
+		chunk:  -1,
+	}, nil
+}
+
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	i := d.pos / encChunkSize
+	if i >= d.chunks {
+		return 0, io.EOF
+	}
+	if i != d.chunk {
+		if err := d.load(i); err != nil {
+			return 0, err
+		}
+	}
+	off := d.pos - i*encChunkSize
+	if off >= int64(len(d.buf)) {
+		if i == d.chunks-1 {
+			return 0, io.EOF
+		}
+		return 0, errors.New("encrypted archive: short chunk before the last")
+	}
+	n := copy(p, d.buf[off:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *DecryptReader) load(i int64) error {
+	sealedSize := int64(encChunkSize + encTagSize)
+	if _, err := d.r.Seek(encHeaderSize+i*sealedSize, io.SeekStart); err != nil {
+		return err
+	}
+	if cap(d.sealed) < int(sealedSize) {
+		d.sealed = make([]byte, sealedSize)
+	}
+	n, err := io.ReadFull(d.r, d.sealed[:sealedSize])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	buf, err := d.aead.Open(d.buf[:0], chunkNonce(d.prefix, uint32(i), i == d.chunks-1), d.sealed[:n], nil)
+	if err != nil {
+		return fmt.Errorf("encrypted archive chunk %d: wrong key or corrupt file", i)
+	}
+	d.buf, d.chunk = buf, i
+	return nil
+}
+
+// Seek sets the plaintext offset of the next Read.
+func (d *DecryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	default:
+		return 0, errors.New("encrypted archive: seek from end not supported")
+	}
+	if offset < 0 {
+		return 0, errors.New("encrypted archive: negative offset")
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+// EncryptFile encrypts src to dst with key, writing dst atomically.
+func EncryptFile(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeAtomic(dst, func(w io.Writer) error {
+		ew, err := NewEncryptWriter(w, key)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(ew, in); err != nil {
+			return err
+		}
+		return ew.Close()
+	})
+}
+
+// DecryptFile decrypts src to dst with key, writing dst atomically.
+func DecryptFile(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dr, err := NewDecryptReader(in, key)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, dr)
+		return err
+	})
+}
+
+func writeAtomic(dst string, fill func(io.Writer) error) error {
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := fill(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package ticks
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeltaRecord carries only what changed since the previous tick. Exchange
+// prices move nearly every second so they are always included; markets
+// list only tickers with at least one changed field.
+type DeltaRecord struct {
+	Type     string        `json:"type"` // "delta"
+	Ts       string        `json:"ts"`
+	BRTI     float64       `json:"brti"`
+	Coinbase float64       `json:"coinbase"`
+	Kraken   float64       `json:"kraken"`
+	Bitstamp float64       `json:"bitstamp"`
+	Markets  []MarketDelta `json:"markets,omitempty"`
+	Removed  []string      `json:"removed,omitempty"` // tickers no longer tracked
+	Latency  *TickLatency  `json:"latency,omitempty"`
+	Window   *WindowStats  `json:"window,omitempty"`
+	Source   string        `json:"source,omitempty"`
+
+	FeedSources map[string]string         `json:"feed_sources,omitempty"`
+	Exposure    map[string]MarketExposure `json:"exposure,omitempty"`
+}
+
+// MarketDelta holds changed fields for one market; nil means unchanged.
+// Book changes are [price, qty] levels where qty 0 removes the level.
+// Book features are not carried; the decoder recomputes them.
+type MarketDelta struct {
+	Ticker    string   `json:"ticker"`
+	YesBid    *int     `json:"yes_bid,omitempty"`
+	YesAsk    *int     `json:"yes_ask,omitempty"`
+	LastPrice *int     `json:"last_price,omitempty"`
+	Volume    *int     `json:"volume,omitempty"`
+	OpenInt   *int     `json:"open_interest,omitempty"`
+	Strike    *float64 `json:"strike,omitempty"`
+	SecsLeft  *int     `json:"secs_left,omitempty"`
+	Status    *string  `json:"status,omitempty"`
+	Result    *string  `json:"result,omitempty"`
+	YesBook   [][2]int `json:"yes_book,omitempty"`
+	NoBook    [][2]int `json:"no_book,omitempty"`
+	BookStale *bool    `json:"book_stale,omitempty"`
+	Anomaly   *string  `json:"anomaly,omitempty"`
+	TickerTs  *string  `json:"ticker_ts,omitempty"`
+	BookTs    *string  `json:"book_ts,omitempty"`
+	Fresh     *bool    `json:"fresh,omitempty"`
+
+	StrikeType  *string  `json:"strike_type,omitempty"`
+	StrikeFloor *float64 `json:"strike_floor,omitempty"`
+	StrikeCap   *float64 `json:"strike_cap,omitempty"`
+
+	SettlementValue *float64 `json:"settlement_value,omitempty"`
+}
+
+// DeltaDecoder reconstitutes full TickRecords from a keyframe+delta stream.
+// Feed it records in file order; it must see a keyframe before any delta.
+type DeltaDecoder struct {
+	markets  map[string]MarketSnap
+	expiries map[string]time.Time // event → expiry, from the last keyframe
+}
+
+func NewDeltaDecoder() *DeltaDecoder {
+	return &DeltaDecoder{}
+}
+
+// Keyframe resets decoder state from a full tick and returns it unchanged.
+func (d *DeltaDecoder) Keyframe(rec TickRecord) TickRecord {
+	markets := rec.AllMarkets()
+	d.markets = make(map[string]MarketSnap, len(markets))
+	for _, m := range markets {
+		d.markets[m.Ticker] = m
+	}
+	d.expiries = rec.eventExpiries()
+	return rec
+}
+
+// Apply merges a delta into the current state and returns the full tick.
+func (d *DeltaDecoder) Apply(rec DeltaRecord) (TickRecord, error) {
+	if d.markets == nil {
+		return TickRecord{}, fmt.Errorf("delta at %s before any keyframe", rec.Ts)
+	}
+
+	for _, t := range rec.Removed {
+		delete(d.markets, t)
+	}
+	for _, md := range rec.Markets {
+		m := d.markets[md.Ticker]
+		m.Ticker = md.Ticker
+		setIf(&m.YesBid, md.YesBid)
+		setIf(&m.YesAsk, md.YesAsk)
+		setIf(&m.LastPrice, md.LastPrice)
+		setIf(&m.Volume, md.Volume)
+		setIf(&m.OpenInt, md.OpenInt)
+		setIf(&m.Strike, md.Strike)
+		setIf(&m.SecsLeft, md.SecsLeft)
+		setIf(&m.Status, md.Status)
+		setIf(&m.Result, md.Result)
+		setIf(&m.BookStale, md.BookStale)
+		setIf(&m.Anomaly, md.Anomaly)
+		setIf(&m.TickerTs, md.TickerTs)
+		setIf(&m.BookTs, md.BookTs)
+		setIf(&m.Fresh, md.Fresh)
+		setIf(&m.StrikeType, md.StrikeType)
+		setIf(&m.StrikeFloor, md.StrikeFloor)
+		setIf(&m.StrikeCap, md.StrikeCap)
+		setIf(&m.SettlementValue, md.SettlementValue)
+		m.YesBook = applyLevels(m.YesBook, md.YesBook)
+		m.NoBook = applyLevels(m.NoBook, md.NoBook)
+		m.Book = ComputeBookFeatures(m.YesBook, m.NoBook)
+		d.markets[md.Ticker] = m
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+	if err != nil {
+		return TickRecord{}, fmt.Errorf("delta ts %q: %w", rec.Ts, err)
+	}
+	markets := make([]MarketSnap, 0, len(d.markets))
+	for _, m := range d.markets {
+		markets = append(markets, m)
+	}
+
+	// Events are not carried in deltas; they follow from the tickers, with
+	// expiries remembered from the keyframe.
+	out := TickRecord{
+		Type:     "tick",
+		Ts:       rec.Ts,
+		BRTI:     rec.BRTI,
+		Coinbase: rec.Coinbase,
+		Kraken:   rec.Kraken,
+		Bitstamp: rec.Bitstamp,
+		Events:   GroupEvents(markets, ts, d.expiries),
+		Latency:  rec.Latency,
+		Window:   rec.Window,
+		Source:   rec.Source,
+
+		FeedSources: rec.FeedSources,
+		Exposure:    rec.Exposure,
+	}
+	for _, e := range out.Events {
+		if _, ok := d.expiries[e.Event]; !ok {
+			if t, err := time.Parse(time.RFC3339, e.Expiry); err == nil {
+				d.expiries[e.Event] = t
+			}
+		}
+	}
+	return out, nil
+}
+
+// applyLevels returns a new price-sorted book with changes merged in.
+func applyLevels(book, changes [][2]int) [][2]int {
+	if len(changes) == 0 {
+		return book
+	}
+	var out [][2]int
+	i, j := 0, 0
+	for i < len(book) || j < len(changes) {
+		switch {
+		case j >= len(changes) || (i < len(book) && book[i][0] < changes[j][0]):
+			out = append(out, book[i])
+			i++
+		case i >= len(book) || changes[j][0] < book[i][0]:
+			if changes[j][1] > 0 {
+				out = append(out, changes[j])
+			}
+			j++
+		default:
+			if changes[j][1] > 0 {
+				out = append(out, changes[j])
+			}
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,412 @@
+package ticks
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// AppendJSON appends r encoded as JSON to b. The output is byte-for-byte
+// what encoding/json produces for r, but it is built without reflection
+// and, given a b with enough capacity, without allocating. Like
+// encoding/json it fails on NaN and infinite floats.
+func (r *TickRecord) AppendJSON(b []byte) ([]byte, error) {
+	e := encoder{b: b}
+	e.raw(`{"type":`)
+	e.str(r.Type)
+	if r.SchemaVersion != 0 {
+		e.raw(`,"schema_version":`)
+		e.int(r.SchemaVersion)
+	}
+	e.raw(`,"ts":`)
+	e.str(r.Ts)
+	e.raw(`,"brti":`)
+	e.float(r.BRTI)
+	e.raw(`,"coinbase":`)
+	e.float(r.Coinbase)
+	e.raw(`,"kraken":`)
+	e.float(r.Kraken)
+	e.raw(`,"bitstamp":`)
+	e.float(r.Bitstamp)
+	if r.Binance != 0 {
+		e.raw(`,"binance":`)
+		e.float(r.Binance)
+	}
+	if len(r.Markets) > 0 {
+		e.raw(`,"markets":`)
+		e.markets(r.Markets)
+	}
+	if len(r.Events) > 0 {
+		e.raw(`,"events":[`)
+		for i := range r.Events {
+			if i > 0 {
+				e.raw(",")
+			}
+			e.event(&r.Events[i])
+		}
+		e.raw("]")
+	}
+	if r.Latency != nil {
+		e.raw(`,"latency":`)
+		e.latency(r.Latency)
+	}
+	if r.Window != nil {
+		e.raw(`,"window":`)
+		e.window(r.Window)
+	}
+	if r.Source != "" {
+		e.raw(`,"source":`)
+		e.str(r.Source)
+	}
+	if len(r.FeedSources) > 0 {
+		e.raw(`,"feed_sources":`)
+		e.strMap(r.FeedSources)
+	}
+	if len(r.Exposure) > 0 {
+		e.raw(`,"exposure":`)
+		e.exposure(r.Exposure)
+	}
+	e.raw("}")
+	return e.b, e.err
+}
+
+type encoder struct {
+	b   []byte
+	err error
+}
+
+func (e *encoder) raw(s string) { e.b = append(e.b, s...) }
+
+func (e *encoder) int(v int) { e.b = strconv.AppendInt(e.b, int64(v), 10) }
+
+func (e *encoder) bool(v bool) { e.b = strconv.AppendBool(e.b, v) }
+
+// float matches encoding/json: shortest representation, exponent form
+// only for very large or small magnitudes, with e-07 shortened to e-7.
+func (e *encoder) float(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if e.err == nil {
+			e.err = fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		e.raw("null")
+		return
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	e.b = strconv.AppendFloat(e.b, f, format, -1, 64)
+	if format == 'e' {
+		n := len(e.b)
+		if n >= 4 && e.b[n-4] == 'e' && e.b[n-3] == '-' && e.b[n-2] == '0' {
+			e.b[n-2] = e.b[n-1]
+			e.b = e.b[:n-1]
+		}
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// str writes a quoted string with encoding/json's default escaping,
+// including HTML-sensitive characters and U+2028/U+2029.
+func (e *encoder) str(s string) {
+	e.b = append(e.b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			e.b = append(e.b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				e.b = append(e.b, '\\', c)
+			case '\b':
+				e.b = append(e.b, '\\', 'b')
+			case '\f':
+				e.b = append(e.b, '\\', 'f')
+			case '\n':
+				e.b = append(e.b, '\\', 'n')
+			case '\r':
+				e.b = append(e.b, '\\', 'r')
+			case '\t':
+				e.b = append(e.b, '\\', 't')
+			default:
+				e.b = append(e.b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			e.b = append(e.b, s[start:i]...)
+			e.raw("\ufffd")
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			e.b = append(e.b, s[start:i]...)
+			e.b = append(e.b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	e.b = append(e.b, s[start:]...)
+	e.b = append(e.b, '"')
+}
+
+func (e *encoder) levels(levels [][2]int) {
+	e.raw("[")
+	for i, l := range levels {
+		if i > 0 {
+			e.raw(",")
+		}
+		e.raw("[")
+		e.int(l[0])
+		e.raw(",")
+		e.int(l[1])
+		e.raw("]")
+	}
+	e.raw("]")
+}
+
+func (e *encoder) ints3(v [3]int) {
+	e.raw("[")
+	for i, n := range v {
+		if i > 0 {
+			e.raw(",")
+		}
+		e.int(n)
+	}
+	e.raw("]")
+}
+
+func (e *encoder) markets(ms []MarketSnap) {
+	if ms == nil {
+		e.raw("null")
+		return
+	}
+	e.raw("[")
+	for i := range ms {
+		if i > 0 {
+			e.raw(",")
+		}
+		e.market(&ms[i])
+	}
+	e.raw("]")
+}
+
+func (e *encoder) market(m *MarketSnap) {
+	e.raw(`{"ticker":`)
+	e.str(m.Ticker)
+	e.raw(`,"yes_bid":`)
+	e.int(m.YesBid)
+	e.raw(`,"yes_ask":`)
+	e.int(m.YesAsk)
+	e.raw(`,"last_price":`)
+	e.int(m.LastPrice)
+	e.raw(`,"volume":`)
+	e.int(m.Volume)
+	e.raw(`,"open_interest":`)
+	e.int(m.OpenInt)
+	if m.Strike != 0 {
+		e.raw(`,"strike":`)
+		e.float(m.Strike)
+	}
+	e.raw(`,"secs_left":`)
+	e.int(m.SecsLeft)
+	if m.Status != "" {
+		e.raw(`,"status":`)
+		e.str(m.Status)
+	}
+	if m.Result != "" {
+		e.raw(`,"result":`)
+		e.str(m.Result)
+	}
+	if len(m.YesBook) > 0 {
+		e.raw(`,"yes_book":`)
+		e.levels(m.YesBook)
+	}
+	if len(m.NoBook) > 0 {
+		e.raw(`,"no_book":`)
+		e.levels(m.NoBook)
+	}
+	if m.BookStale {
+		e.raw(`,"book_stale":`)
+		e.bool(m.BookStale)
+	}
+	if m.Anomaly != "" {
+		e.raw(`,"anomaly":`)
+		e.str(m.Anomaly)
+	}
+	if m.TickerTs != "" {
+		e.raw(`,"ticker_ts":`)
+		e.str(m.TickerTs)
+	}
+	if m.BookTs != "" {
+		e.raw(`,"book_ts":`)
+		e.str(m.BookTs)
+	}
+	if m.Fresh {
+		e.raw(`,"fresh":`)
+		e.bool(m.Fresh)
+	}
+	if m.Unchanged {
+		e.raw(`,"unchanged":`)
+		e.bool(m.Unchanged)
+	}
+	if m.StrikeType != "" {
+		e.raw(`,"strike_type":`)
+		e.str(m.StrikeType)
+	}
+	if m.StrikeFloor != 0 {
+		e.raw(`,"strike_floor":`)
+		e.float(m.StrikeFloor)
+	}
+	if m.StrikeCap != 0 {
+		e.raw(`,"strike_cap":`)
+		e.float(m.StrikeCap)
+	}
+	if m.SettlementValue != 0 {
+		e.raw(`,"settlement_value":`)
+		e.float(m.SettlementValue)
+	}
+	if m.Book != nil {
+		e.raw(`,"book":`)
+		e.book(m.Book)
+	}
+	e.raw("}")
+}
+
+func (e *encoder) book(f *BookFeatures) {
+	e.raw(`{"yes_depth5":`)
+	e.int(f.YesDepth5)
+	e.raw(`,"no_depth5":`)
+	e.int(f.NoDepth5)
+	e.raw(`,"imbalance":`)
+	e.float(f.Imbalance)
+	e.raw(`,"microprice":`)
+	e.float(f.Microprice)
+	e.raw(`,"yes_top3":`)
+	e.ints3(f.YesTop3)
+	e.raw(`,"no_top3":`)
+	e.ints3(f.NoTop3)
+	e.raw("}")
+}
+
+func (e *encoder) event(ev *EventSnap) {
+	e.raw(`{"event":`)
+	e.str(ev.Event)
+	if ev.Expiry != "" {
+		e.raw(`,"expiry":`)
+		e.str(ev.Expiry)
+	}
+	e.raw(`,"markets":`)
+	e.markets(ev.Markets)
+	e.raw("}")
+}
+
+func (e *encoder) window(w *WindowStats) {
+	e.raw(`{"start":`)
+	e.str(w.Start)
+	e.raw(`,"open":`)
+	e.float(w.Open)
+	e.raw(`,"high":`)
+	e.float(w.High)
+	e.raw(`,"low":`)
+	e.float(w.Low)
+	e.raw(`,"twap":`)
+	e.float(w.TWAP)
+	e.raw("}")
+}
+
+// strMap encodes a small map with its keys sorted, as encoding/json does.
+func (e *encoder) strMap(m map[string]string) {
+	var buf [8]string
+	keys := buf[:0]
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	e.raw("{")
+	for i, k := range keys {
+		if i > 0 {
+			e.raw(",")
+		}
+		e.str(k)
+		e.raw(":")
+		e.str(m[k])
+	}
+	e.raw("}")
+}
+
+// exposure encodes the exposure map with its tickers sorted.
+func (e *encoder) exposure(m map[string]MarketExposure) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	e.raw("{")
+	for i, k := range keys {
+		if i > 0 {
+			e.raw(",")
+		}
+		x := m[k]
+		e.str(k)
+		e.raw(`:{"position":`)
+		e.int(x.Position)
+		e.optInt(`,"cost":`, x.Cost)
+		e.optInt(`,"realized_pnl":`, x.RealizedPnL)
+		e.optInt(`,"fees_paid":`, x.FeesPaid)
+		e.optInt(`,"resting_orders":`, x.RestingOrders)
+		e.optInt(`,"resting_yes":`, x.RestingYes)
+		e.optInt(`,"resting_no":`, x.RestingNo)
+		e.raw("}")
+	}
+	e.raw("}")
+}
+
+// optInt writes key and v unless v is zero, as omitempty does.
+func (e *encoder) optInt(key string, v int) {
+	if v != 0 {
+		e.raw(key)
+		e.int(v)
+	}
+}
+
+func (e *encoder) latency(l *TickLatency) {
+	e.raw(`{"snapshot_ms":`)
+	e.float(l.SnapshotMs)
+	if l.PrevWriteMs != 0 {
+		e.raw(`,"prev_write_ms":`)
+		e.float(l.PrevWriteMs)
+	}
+	if len(l.FeedAgeMs) > 0 {
+		// A handful of feeds; a fixed array keeps the key sort off the heap.
+		var buf [8]string
+		keys := buf[:0]
+		for k := range l.FeedAgeMs {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		e.raw(`,"feed_age_ms":{`)
+		for i, k := range keys {
+			if i > 0 {
+				e.raw(",")
+			}
+			e.str(k)
+			e.raw(":")
+			e.float(l.FeedAgeMs[k])
+		}
+		e.raw("}")
+	}
+	e.raw("}")
+}
@@ -0,0 +1,156 @@
+package ticks
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Strings and floats chosen to hit the encoder's escaping and number
+// formatting edge cases.
+var (
+	testStrings = []string{
+		"", "tick", "KXBTC15M-25JAN01-T100000", "2025-01-01T00:00:00.123Z",
+		`quote " backslash \ slash /`, "<script>&amp;</script>", "tab\tnewline\ncr\r",
+		"\x00\x01\x1f\x7f", "café ☃ 𝄞", "  ", "bad \xff utf-8 \xc3", "\xed\xa0\x80",
+	}
+	testFloats = []float64{
+		0, -0.0, 1, -1, 0.5, 97123.45, 1e20, 1e21, 1e-6, 1e-7, 123456789012345678,
+		math.MaxFloat64, math.SmallestNonzeroFloat64, -3.4e-9, 0.1 + 0.2,
+	}
+)
+
+// fill sets v, and everything it points to, to random values: nil, empty
+// and filled slices, maps and pointers alike.
+func fill(rng *rand.Rand, v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(testStrings[rng.Intn(len(testStrings))])
+	case reflect.Float64:
+		if rng.Intn(3) == 0 {
+			v.SetFloat((rng.Float64() - 0.5) * math.Pow(10, float64(rng.Intn(40)-20)))
+		} else {
+			v.SetFloat(testFloats[rng.Intn(len(testFloats))])
+		}
+	case reflect.Int, reflect.Int64:
+		switch rng.Intn(4) {
+		case 0:
+			v.SetInt(0)
+		case 1:
+			v.SetInt(-rng.Int63n(1000))
+		default:
+			v.SetInt(rng.Int63n(1 << 40))
+		}
+	case reflect.Bool:
+		v.SetBool(rng.Intn(2) == 0)
+	case reflect.Pointer:
+		if depth > 4 || rng.Intn(3) == 0 {
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(rng, v.Elem(), depth+1)
+	case reflect.Slice:
+		if depth > 4 || rng.Intn(4) == 0 {
+			return
+		}
+		n := rng.Intn(4)
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			fill(rng, v.Index(i), depth+1)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(rng, v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		if depth > 4 || rng.Intn(4) == 0 {
+			return
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := rng.Intn(4); i > 0; i-- {
+			k, e := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			fill(rng, k, depth+1)
+			fill(rng, e, depth+1)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && rng.Intn(5) > 0 {
+				fill(rng, v.Field(i), depth+1)
+			}
+		}
+	default:
+		panic("fill: unhandled kind " + v.Kind().String())
+	}
+}
+
+func checkAppendJSON(t *testing.T, r *TickRecord) {
+	t.Helper()
+	want, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	got, err := r.AppendJSON(nil)
+	if err != nil {
+		t.Fatalf("AppendJSON: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("AppendJSON differs from encoding/json\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestAppendJSONMatchesEncodingJSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		var r TickRecord
+		fill(rng, reflect.ValueOf(&r).Elem(), 0)
+		checkAppendJSON(t, &r)
+	}
+}
+
+func TestAppendJSONAppends(t *testing.T) {
+	r := TickRecord{Type: "tick", Ts: "2025-01-01T00:00:00Z", BRTI: 97000.5}
+	want, _ := json.Marshal(&r)
+	got, err := r.AppendJSON([]byte("prefix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "prefix"+string(want) {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestAppendJSONRejectsNonFinite(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		r := TickRecord{Type: "tick", Markets: []MarketSnap{{Ticker: "X", Strike: f}}}
+		if _, err := r.AppendJSON(nil); err == nil {
+			t.Errorf("AppendJSON accepted %v", f)
+		}
+	}
+}
+
+func FuzzAppendJSON(f *testing.F) {
+	for i, s := range testStrings {
+		f.Add(s, testFloats[i%len(testFloats)], i)
+	}
+	f.Fuzz(func(t *testing.T, s string, x float64, n int) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return
+		}
+		r := TickRecord{
+			Type: s, Ts: s, BRTI: x, Source: s,
+			FeedSources: map[string]string{s: s, "coinbase": s},
+			Markets: []MarketSnap{{
+				Ticker: s, YesBid: n, Strike: x, Status: s,
+				YesBook: [][2]int{{n, -n}},
+				Book:    &BookFeatures{},
+			}},
+			Exposure: map[string]MarketExposure{s: {Position: n, Cost: -n}},
+			Latency:  &TickLatency{SnapshotMs: x, FeedAgeMs: map[string]float64{s: x}},
+		}
+		checkAppendJSON(t, &r)
+	})
+}
@@ -0,0 +1,177 @@
+package ticks
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Forecast methods.
+const (
+	ForecastMonteCarlo = "mc"          // simulated BRTI paths
+	ForecastClosedForm = "closed_form" // lognormal approximation
+)
+
+// forecastStep is the spacing of simulated BRTI samples inside the
+// settlement window, matching the collector's tick.
+const forecastStep = time.Second
+
+// ForecastModel prices the markets of an event by modelling BRTI as
+// geometric Brownian motion up to the event's close and reducing the path
+// to a settlement value under the series' SettlementRule.
+//
+// With Paths > 0 it simulates that many paths, sampling each once a second
+// through the settlement window. Otherwise it uses a closed form that
+// treats the average over the window as lognormal, which is quicker and
+// close enough away from the window but cruder inside it.
+type ForecastModel struct {
+	Paths int
+	Drift float64    // annualized drift of BRTI; 0 prices without a view on direction
+	Rand  *rand.Rand // source for simulated paths; nil uses the global one
+}
+
+// Method returns ForecastMonteCarlo or ForecastClosedForm.
+func (m ForecastModel) Method() string {
+	if m.Paths > 0 {
+		return ForecastMonteCarlo
+	}
+	return ForecastClosedForm
+}
+
+// Forecast returns the probability that each of markets, all of one event
+// closing at close, settles YES, seen at now with BRTI at spot and an
+// annualized volatility of vol. seen holds BRTI samples up to now in time
+// order; those inside the settlement window already count towards the
+// settlement value, and the rest are ignored.
+func (m ForecastModel) Forecast(now, close time.Time, spot, vol float64, seen []PriceSample, markets []MarketSnap) []MarketForecast {
+	if len(markets) == 0 {
+		return nil
+	}
+	rule := SettlementRuleFor(markets[0].Ticker)
+
+	var probYes func(*MarketSnap) float64
+	switch {
+	case !now.Before(close):
+		v := rule.Settle(seen, close)
+		probYes = func(mk *MarketSnap) float64 { return boolProb(v > 0 && mk.PaysYes(v)) }
+	case m.Paths > 0:
+		values := m.simulate(rule, now, close, spot, vol, seen)
+		probYes = func(mk *MarketSnap) float64 {
+			n := 0
+			for _, v := range values {
+				if mk.PaysYes(v) {
+					n++
+				}
+			}
+			return float64(n) / float64(len(values))
+		}
+	default:
+		atLeast := m.closedForm(rule, now, close, spot, vol, seen)
+		probYes = func(mk *MarketSnap) float64 {
+			floor, cap := mk.bounds()
+			return max(atLeast(floor)-atLeast(cap), 0)
+		}
+	}
+
+	out := make([]MarketForecast, len(markets))
+	for i := range markets {
+		mk := &markets[i]
+		out[i] = MarketForecast{Ticker: mk.Ticker, Strike: mk.Strike, ProbYes: probYes(mk)}
+		if mk.YesBid > 0 && mk.YesAsk > 0 {
+			out[i].Mid = float64(mk.YesBid+mk.YesAsk) / 2
+		}
+	}
+	return out
+}
+
+// simulate returns the settlement value of each of m.Paths simulated paths.
+// Only the samples inside the settlement window matter, so each path jumps
+// straight to the window's start.
+func (m ForecastModel) simulate(rule SettlementRule, now, close time.Time, spot, vol float64, seen []PriceSample) []float64 {
+	norm := rand.NormFloat64
+	if m.Rand != nil {
+		norm = m.Rand.NormFloat64
+	}
+	step := func(s float64, dt time.Duration) float64 {
+		years := dt.Seconds() / secondsPerYear
+		return s * math.Exp((m.Drift-vol*vol/2)*years+vol*math.Sqrt(years)*norm())
+	}
+
+	from := close.Add(-rule.Window)
+	prefix := make([]PriceSample, 0, len(seen))
+	for _, s := range seen {
+		if s.Time.After(from) && !s.Time.After(now) {
+			prefix = append(prefix, s)
+		}
+	}
+	samples := make([]PriceSample, 0, len(prefix)+int(rule.Window/forecastStep)+1)
+
+	values := make([]float64, m.Paths)
+	for p := range values {
+		samples = append(samples[:0], prefix...)
+		t, s := now, spot
+		if from.After(t) {
+			s, t = step(s, from.Sub(t)), from
+		}
+		for t.Before(close) {
+			dt := min(forecastStep, close.Sub(t))
+			s, t = step(s, dt), t.Add(dt)
+			samples = append(samples, PriceSample{Time: t, Price: s})
+		}
+		if v := rule.Settle(samples, close); v > 0 {
+			values[p] = v
+		} else {
+			values[p] = s
+		}
+	}
+	return values
+}
+
+// closedForm returns P(settlement value >= x) as a function of x. Log BRTI
+// is Brownian, so the average of its samples over a window of length W
+// ending T from now is, taking the average of logs for the log of the
+// average, normal with variance σ²(T-W+W/3). Inside the window the samples
+// already taken are fixed and only the rest are random.
+func (m ForecastModel) closedForm(rule SettlementRule, now, close time.Time, spot, vol float64, seen []PriceSample) func(float64) float64 {
+	left := close.Sub(now).Seconds() / secondsPerYear
+	window := rule.Window.Seconds() / secondsPerYear
+	mu := m.Drift - vol*vol/2
+
+	var mean, variance, fixed, weight float64 // fixed contributes weight of the value
+	switch {
+	case rule.Method == SettleLast || window == 0:
+		mean, variance = mu*left, vol*vol*left
+	case left >= window:
+		mean, variance = mu*(left-window/2), vol*vol*(left-window+window/3)
+	default:
+		mean, variance = mu*left/2, vol*vol*left/3
+		if v := rule.Settle(seen, close); v > 0 {
+			weight = (window - left) / window
+			fixed = v * weight
+		}
+	}
+	mean += math.Log(spot)
+	sd := math.Sqrt(variance)
+
+	return func(x float64) float64 {
+		if math.IsInf(x, 1) {
+			return 0
+		}
+		// The random part must average at least this for the total to reach x.
+		need := (x - fixed) / (1 - weight)
+		if need <= 0 {
+			return 1
+		}
+		if sd == 0 {
+			return boolProb(mean >= math.Log(need))
+		}
+		return 0.5 * math.Erfc((math.Log(need)-mean)/(sd*math.Sqrt2))
+	}
+}
+
+func boolProb(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
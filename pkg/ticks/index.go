@@ -0,0 +1,403 @@
+package ticks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Archives are compressed as a series of independent blocks (gzip members
+// or zstd frames, which standard tools read as one stream), each starting
+// at a full tick on or after a minute boundary. An index sidecar records
+// where each block starts so a reader can seek to the minutes, or the
+// markets, it needs instead of decompressing the whole day.
+
+// Index locates blocks within one compressed archive.
+type Index struct {
+	File    string            `json:"file"` // base name, resolved relative to the index
+	Blocks  []IndexBlock      `json:"blocks"`
+	Tickers map[string][2]int `json:"tickers"` // ticker → first and last block it appears in
+}
+
+// IndexBlock is one independently decompressible block.
+type IndexBlock struct {
+	Offset  int64  `json:"offset"`   // compressed byte offset of the block
+	FirstTs string `json:"first_ts"` // ts of its first record
+	Records int    `json:"records"`  // non-empty lines in the block
+}
+
+// IndexPath returns where the index for an archive file lives:
+// prefix-2006-01-02.jsonl.gz → prefix-2006-01-02.index.json.
+func IndexPath(archivePath string) string {
+	base := archivePath
+	if i := strings.Index(filepath.Base(base), ".jsonl"); i >= 0 {
+		base = filepath.Join(filepath.Dir(base), filepath.Base(base)[:i])
+	}
+	return base + ".index.json"
+}
+
+// LoadIndex reads the index sidecar of an archive.
+func LoadIndex(archivePath string) (*Index, error) {
+	data, err := os.ReadFile(IndexPath(archivePath))
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing index: %w", err)
+	}
+	return &idx, nil
+}
+
+// WriteIndex atomically writes idx next to archivePath.
+func WriteIndex(archivePath string, idx *Index) error {
+	idx.File = filepath.Base(archivePath)
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	dst := IndexPath(archivePath)
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// blockWriter compresses one block at a time into dst.
+type blockWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+type zstdBlockWriter struct{ *zstd.Encoder }
+
+func (z zstdBlockWriter) Reset(w io.Writer) { z.Encoder.Reset(w) }
+
+// countingWriter tracks the compressed offset.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// indexProbe is the part of a record the index needs.
+type indexProbe struct {
+	Type    string `json:"type"`
+	Ts      string `json:"ts"`
+	Markets []struct {
+		Ticker string `json:"ticker"`
+	} `json:"markets"`
+	Events []struct {
+		Markets []struct {
+			Ticker    string `json:"ticker"`
+			Unchanged bool   `json:"unchanged"`
+		} `json:"markets"`
+	} `json:"events"`
+}
+
+// selfContained reports whether the record can start a block: a full tick
+// with no books left out for the previous tick to supply.
+func (p *indexProbe) selfContained() bool {
+	if p.Type != "tick" {
+		return false
+	}
+	for _, e := range p.Events {
+		for _, m := range e.Markets {
+			if m.Unchanged {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// CompressIndexed compresses the JSONL in src to dst with codec ("gzip" or
+// "zstd") as seekable blocks and returns their index.
+func CompressIndexed(src io.Reader, dst io.Writer, codec string) (*Index, error) {
+	cw := &countingWriter{w: dst}
+	var zw blockWriter
+	switch codec {
+	case "gzip":
+		gz, err := gzip.NewWriterLevel(cw, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		zw = gz
+	case "zstd":
+		enc, err := zstd.NewWriter(cw, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		zw = zstdBlockWriter{enc}
+	default:
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+
+	idx := &Index{Tickers: make(map[string][2]int)}
+	var blockMinute time.Time
+	open := false
+
+	br := bufio.NewReaderSize(src, 1<<20)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				var p indexProbe
+				json.Unmarshal(trimmed, &p)
+				ts, tsErr := time.Parse(time.RFC3339Nano, p.Ts)
+
+				// A block may only start on a self-contained tick, so
+				// deltas and deduplicated books never need state from an
+				// earlier block.
+				if p.selfContained() && tsErr == nil && (!open || ts.Truncate(time.Minute).After(blockMinute)) {
+					if open {
+						if err := zw.Close(); err != nil {
+							return nil, err
+						}
+						zw.Reset(cw)
+					}
+					idx.Blocks = append(idx.Blocks, IndexBlock{Offset: cw.n, FirstTs: p.Ts})
+					blockMinute = ts.Truncate(time.Minute)
+					open = true
+				}
+				if !open {
+					// Leading records before the first tick go in block 0.
+					idx.Blocks = append(idx.Blocks, IndexBlock{Offset: 0, FirstTs: p.Ts})
+					open = true
+				}
+				b := len(idx.Blocks) - 1
+				idx.Blocks[b].Records++
+				note := func(t string) {
+					if r, ok := idx.Tickers[t]; ok {
+						idx.Tickers[t] = [2]int{r[0], b}
+					} else {
+						idx.Tickers[t] = [2]int{b, b}
+					}
+				}
+				for _, m := range p.Markets {
+					note(m.Ticker)
+				}
+				for _, e := range p.Events {
+					for _, m := range e.Markets {
+						note(m.Ticker)
+					}
+				}
+			}
+			if _, err := zw.Write(line); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Query selects ticks from an archive. Zero fields don't constrain.
+type Query struct {
+	Ticker  string    // only blocks where this market appears
+	Tickers []string  // only blocks where any of these markets appear
+	From    time.Time // ticks at or after From
+	To      time.Time // ticks before To
+}
+
+// OpenAt opens a compressed archive at the start of a block.
+func OpenAt(path string, offset int64) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFileReader(path, f, offset)
+}
+
+// ReadRange calls fn for the ticks in path that match q's time bounds,
+// seeking straight to the relevant blocks when the archive has an index
+// and scanning the whole file otherwise. With q.Ticker or q.Tickers set,
+// only blocks where those markets appear are read, but fn still receives
+// whole ticks.
+func ReadRange(path string, q Query, fn func(TickRecord) error) error {
+	inRange := func(rec TickRecord) bool {
+		if q.From.IsZero() && q.To.IsZero() {
+			return true
+		}
+		ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+		return err == nil && !ts.Before(q.From) && (q.To.IsZero() || ts.Before(q.To))
+	}
+
+	idx, err := LoadIndex(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return ReadFile(path, func(rec TickRecord) error {
+			if !inRange(rec) {
+				return nil
+			}
+			return fn(rec)
+		})
+	}
+
+	first, last, ok := idx.blockRange(q)
+	if !ok {
+		return nil
+	}
+	r, err := OpenAt(path, idx.Blocks[first].Offset)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	want := 0
+	for _, b := range idx.Blocks[first : last+1] {
+		want += b.Records
+	}
+	for r.Records() < want {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if r.Records() > want {
+			break // the last record read belongs to the next block
+		}
+		if !inRange(rec) {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockRange returns the blocks that can hold ticks matching q.
+func (idx *Index) blockRange(q Query) (first, last int, ok bool) {
+	if len(idx.Blocks) == 0 {
+		return 0, 0, false
+	}
+	first, last = 0, len(idx.Blocks)-1
+	if tickers := q.allTickers(); len(tickers) > 0 {
+		first, last = len(idx.Blocks), -1
+		for _, t := range tickers {
+			if r, found := idx.Tickers[t]; found {
+				first, last = min(first, r[0]), max(last, r[1])
+			}
+		}
+		if last < 0 {
+			return 0, 0, false
+		}
+	}
+	startOf := func(i int) time.Time {
+		t, _ := time.Parse(time.RFC3339Nano, idx.Blocks[i].FirstTs)
+		return t
+	}
+	if !q.From.IsZero() {
+		// The block holding From is the last one starting at or before it.
+		i := sort.Search(len(idx.Blocks), func(i int) bool { return startOf(i).After(q.From) }) - 1
+		first = max(first, i, 0)
+	}
+	if !q.To.IsZero() {
+		i := sort.Search(len(idx.Blocks), func(i int) bool { return !startOf(i).Before(q.To) }) - 1
+		last = min(last, i)
+	}
+	return first, last, first <= last
+}
+
+func (q Query) allTickers() []string {
+	if q.Ticker == "" {
+		return q.Tickers
+	}
+	return append([]string{q.Ticker}, q.Tickers...)
+}
+
+// ReindexFile recompresses an existing .jsonl.gz or .jsonl.zst archive
+// into seekable blocks in place and writes its index. The records are
+// unchanged but the file's bytes (and so its manifest checksum) are not.
+func ReindexFile(path string) (*Index, error) {
+	codec := ""
+	switch {
+	case strings.HasSuffix(path, ".jsonl.gz"):
+		codec = "gzip"
+	case strings.HasSuffix(path, ".jsonl.zst"):
+		codec = "zstd"
+	case strings.HasSuffix(path, EncExt):
+		return nil, fmt.Errorf("%s: encrypted archives are indexed when written; decrypt to reindex", path)
+	default:
+		return nil, fmt.Errorf("%s: not a compressed archive", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var src io.Reader
+	if codec == "gzip" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	} else {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	tmpPath := path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := CompressIndexed(src, tmp, codec)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := WriteIndex(path, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
@@ -0,0 +1,148 @@
+package ticks
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// closeToExpiry is how long before expiry trading closes on KXBTC15M
+// (secs_left counts to expiry). It marks the close of markets recorded
+// without a status.
+const closeToExpiry = 294 * time.Second
+
+// MarketLabels are the outcomes of one market, known only once it is over.
+type MarketLabels struct {
+	Ticker          string
+	Result          string    // "yes" or "no"; empty if no result was recorded
+	SettlementValue float64   // Kalshi's settlement value, when recorded
+	SettleBRTI      float64   // BRTI reduced under the series' SettlementRule up to Close
+	ExpiryBRTI      float64   // last BRTI at or before expiry
+	Close           time.Time // last tick the market was open for trading
+}
+
+// Excursion is how far a market's yes mid went each way, in cents, after a
+// tick and before trading closed: Favorable for a YES holder (the highest
+// mid less the mid at the tick) and Adverse against one (the mid at the
+// tick less the lowest). Neither is negative.
+type Excursion struct {
+	Favorable float64
+	Adverse   float64
+}
+
+// Labeler collects what a supervised-learning label needs from a market's
+// whole life. Feed it every tick of the archives with Observe, then look up
+// labels; labels of markets still open at the last tick are incomplete.
+type Labeler struct {
+	markets map[string]*labelState
+}
+
+type labelState struct {
+	MarketLabels
+	rule   SettlementRule
+	window []PriceSample // trailing BRTI while open, enough for rule
+	mids   []PriceSample // yes mid while open
+
+	// Suffix extremes of mids, built on the first Excursion lookup.
+	sufMax, sufMin []float64
+}
+
+// NewLabeler returns an empty Labeler.
+func NewLabeler() *Labeler {
+	return &Labeler{markets: make(map[string]*labelState)}
+}
+
+// Observe adds a tick taken at ts. Ticks must come in time order.
+func (l *Labeler) Observe(ts time.Time, rec *TickRecord) {
+	for _, ev := range rec.Events {
+		expiry, _ := time.Parse(time.RFC3339, ev.Expiry)
+		for i := range ev.Markets {
+			l.observe(ts, rec.BRTI, expiry, &ev.Markets[i])
+		}
+	}
+	for i := range rec.Markets {
+		m := &rec.Markets[i]
+		l.observe(ts, rec.BRTI, ts.Add(time.Duration(m.SecsLeft)*time.Second), m)
+	}
+}
+
+func (l *Labeler) observe(ts time.Time, brti float64, expiry time.Time, m *MarketSnap) {
+	s, ok := l.markets[m.Ticker]
+	if !ok {
+		s = &labelState{MarketLabels: MarketLabels{Ticker: m.Ticker}, rule: SettlementRuleFor(m.Ticker)}
+		l.markets[m.Ticker] = s
+	}
+	if m.Result != "" {
+		s.Result = m.Result
+	}
+	if m.SettlementValue != 0 {
+		s.SettlementValue = m.SettlementValue
+	}
+
+	open := m.Status == "active" || m.Status == "" && time.Duration(m.SecsLeft)*time.Second > closeToExpiry
+	// Without a known expiry, the last BRTI while open is the best there is.
+	if brti > 0 && (expiry.IsZero() && open || !expiry.IsZero() && !ts.After(expiry)) {
+		s.ExpiryBRTI = brti
+	}
+	if !open {
+		return
+	}
+	s.Close = ts
+	if brti > 0 {
+		s.window = append(s.window, PriceSample{Time: ts, Price: brti})
+		cutoff := ts.Add(-s.rule.Window)
+		n := 0
+		for n < len(s.window) && !s.window[n].Time.After(cutoff) {
+			n++
+		}
+		s.window = s.window[n:]
+		s.SettleBRTI = s.rule.Settle(s.window, ts)
+	}
+	if m.YesBid > 0 && m.YesAsk > 0 {
+		s.mids = append(s.mids, PriceSample{Time: ts, Price: float64(m.YesBid+m.YesAsk) / 2})
+		s.sufMax, s.sufMin = nil, nil
+	}
+}
+
+// Market returns the labels of ticker, or false if it was never seen.
+func (l *Labeler) Market(ticker string) (MarketLabels, bool) {
+	s, ok := l.markets[ticker]
+	if !ok {
+		return MarketLabels{}, false
+	}
+	return s.MarketLabels, true
+}
+
+// Excursion returns ticker's excursion after ts, measured from the last
+// mid at or before ts. It returns false when there is no such mid.
+func (l *Labeler) Excursion(ticker string, ts time.Time) (Excursion, bool) {
+	s, ok := l.markets[ticker]
+	if !ok {
+		return Excursion{}, false
+	}
+	i := sort.Search(len(s.mids), func(i int) bool { return s.mids[i].Time.After(ts) })
+	if i == 0 {
+		return Excursion{}, false
+	}
+	ref := s.mids[i-1].Price
+	if i == len(s.mids) {
+		return Excursion{}, true
+	}
+	if s.sufMax == nil {
+		s.buildSuffix()
+	}
+	return Excursion{
+		Favorable: max(s.sufMax[i]-ref, 0),
+		Adverse:   max(ref-s.sufMin[i], 0),
+	}, true
+}
+
+func (s *labelState) buildSuffix() {
+	n := len(s.mids)
+	s.sufMax, s.sufMin = make([]float64, n+1), make([]float64, n+1)
+	s.sufMax[n], s.sufMin[n] = math.Inf(-1), math.Inf(1)
+	for i := n - 1; i >= 0; i-- {
+		s.sufMax[i] = max(s.sufMax[i+1], s.mids[i].Price)
+		s.sufMin[i] = min(s.sufMin[i+1], s.mids[i].Price)
+	}
+}
@@ -0,0 +1,112 @@
+package ticks
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventSnap is one Kalshi event — a 15-minute window — with its markets
+// ordered by strike.
+type EventSnap struct {
+	Event   string       `json:"event"`
+	Expiry  string       `json:"expiry,omitempty"` // RFC3339 settlement time
+	Markets []MarketSnap `json:"markets"`
+}
+
+// EventOf returns the event ticker of a market ticker, e.g.
+// KXBTC15M-26FEB101215-15 → KXBTC15M-26FEB101215.
+func EventOf(ticker string) string {
+	if i := strings.LastIndexByte(ticker, '-'); i > 0 {
+		return ticker[:i]
+	}
+	return ticker
+}
+
+// GroupEvents builds the strike ladders for one tick at ts. expiries maps
+// event ticker to settlement time; events missing from it get ts plus the
+// largest secs_left among their markets. Events are ordered by expiry.
+func GroupEvents(markets []MarketSnap, ts time.Time, expiries map[string]time.Time) []EventSnap {
+	if len(markets) == 0 {
+		return nil
+	}
+
+	byEvent := make(map[string]*EventSnap)
+	exp := make(map[string]time.Time)
+	var order []string
+	for _, m := range markets {
+		ev := EventOf(m.Ticker)
+		e, ok := byEvent[ev]
+		if !ok {
+			e = &EventSnap{Event: ev}
+			byEvent[ev] = e
+			order = append(order, ev)
+		}
+		e.Markets = append(e.Markets, m)
+
+		if t, ok := expiries[ev]; ok {
+			exp[ev] = t
+		} else if t := ts.Add(time.Duration(m.SecsLeft) * time.Second); t.After(exp[ev]) {
+			exp[ev] = t
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		a, b := exp[order[i]], exp[order[j]]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return order[i] < order[j]
+	})
+
+	out := make([]EventSnap, 0, len(order))
+	for _, ev := range order {
+		e := byEvent[ev]
+		sort.Slice(e.Markets, func(i, j int) bool {
+			if e.Markets[i].Strike != e.Markets[j].Strike {
+				return e.Markets[i].Strike < e.Markets[j].Strike
+			}
+			return e.Markets[i].Ticker < e.Markets[j].Ticker
+		})
+		e.Expiry = exp[ev].UTC().Format(time.RFC3339)
+		out = append(out, *e)
+	}
+	return out
+}
+
+// AllMarkets returns every market in the record: the flat list of pre-v6
+// records, or the events' ladders in order.
+func (r *TickRecord) AllMarkets() []MarketSnap {
+	if len(r.Events) == 0 {
+		return r.Markets
+	}
+	var out []MarketSnap
+	for _, e := range r.Events {
+		out = append(out, e.Markets...)
+	}
+	return out
+}
+
+// EachMarket calls fn with a pointer to every market in the record, so
+// tools that patch records in place work for either layout.
+func (r *TickRecord) EachMarket(fn func(*MarketSnap)) {
+	for i := range r.Markets {
+		fn(&r.Markets[i])
+	}
+	for i := range r.Events {
+		for j := range r.Events[i].Markets {
+			fn(&r.Events[i].Markets[j])
+		}
+	}
+}
+
+// eventExpiries maps each event in the record to its parsed expiry.
+func (r *TickRecord) eventExpiries() map[string]time.Time {
+	out := make(map[string]time.Time, len(r.Events))
+	for _, e := range r.Events {
+		if t, err := time.Parse(time.RFC3339, e.Expiry); err == nil {
+			out[e.Event] = t
+		}
+	}
+	return out
+}
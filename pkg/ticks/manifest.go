@@ -0,0 +1,157 @@
+package ticks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest describes one archive file for long-term integrity checks.
+type Manifest struct {
+	File      string         `json:"file"` // base name, resolved relative to the manifest
+	Bytes     int64          `json:"bytes"`
+	SHA256    string         `json:"sha256"`
+	Records   int            `json:"records"` // non-empty lines of any type
+	Ticks     int            `json:"ticks"`
+	First     string         `json:"first_ts,omitempty"`
+	Last      string         `json:"last_ts,omitempty"`
+	Markets   map[string]int `json:"markets"` // ticker → ticks it appears in
+	CreatedAt string         `json:"created_at"`
+}
+
+// ManifestPath returns where the manifest for an archive file lives:
+// prefix-2006-01-02.jsonl.gz → prefix-2006-01-02.manifest.json.
+func ManifestPath(archivePath string) string {
+	base := archivePath
+	if i := strings.Index(filepath.Base(base), ".jsonl"); i >= 0 {
+		base = filepath.Join(filepath.Dir(base), filepath.Base(base)[:i])
+	}
+	return base + ".manifest.json"
+}
+
+// FileManifest returns a manifest of path with only its size and checksum
+// filled in, for files the Reader can't summarize, such as exports; the
+// caller fills in the counts it knows.
+func FileManifest(path string) (*Manifest, error) {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		File:      filepath.Base(path),
+		Bytes:     size,
+		SHA256:    sum,
+		Markets:   make(map[string]int),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// BuildManifest reads an archive and summarizes it.
+func BuildManifest(path string) (*Manifest, error) {
+	m, err := FileManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		m.Ticks++
+		if m.First == "" {
+			m.First = rec.Ts
+		}
+		m.Last = rec.Ts
+		for _, mk := range rec.AllMarkets() {
+			m.Markets[mk.Ticker]++
+		}
+	}
+	m.Records = r.Records()
+	return m, nil
+}
+
+// WriteManifest builds and atomically writes the manifest for an archive.
+func WriteManifest(archivePath string) (*Manifest, error) {
+	m, err := BuildManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Save(archivePath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save atomically writes m to ManifestPath(archivePath).
+func (m *Manifest) Save(archivePath string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dst := ManifestPath(archivePath)
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// VerifyManifest checks the archive named by a manifest file against it.
+func VerifyManifest(manifestPath string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	path := filepath.Join(filepath.Dir(manifestPath), m.File)
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if size != m.Bytes {
+		return fmt.Errorf("%s: size %d, manifest says %d", m.File, size, m.Bytes)
+	}
+	if sum != m.SHA256 {
+		return fmt.Errorf("%s: sha256 %s, manifest says %s", m.File, sum, m.SHA256)
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
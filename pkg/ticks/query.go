@@ -0,0 +1,143 @@
+package ticks
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoTick is returned by Archive.At when no tick precedes the time asked
+// for closely enough.
+var ErrNoTick = errors.New("no tick at that time")
+
+// DefaultAtLookback is how far before the requested time Archive.At looks
+// for a tick by default.
+const DefaultAtLookback = 5 * time.Minute
+
+// Archive is a directory of collected files, in flat or monthly layout,
+// queried by time. Files are found by the UTC date in their names and read
+// through their index sidecars where they have one, so a query decompresses
+// only the minutes and markets it needs.
+type Archive struct {
+	Dir      string
+	Prefix   string        // file name prefix, e.g. kxbtc15m
+	Lookback time.Duration // how far At looks back; DefaultAtLookback if zero
+}
+
+// NewArchive returns an Archive over the files named prefix-* in dir.
+func NewArchive(dir, prefix string) *Archive {
+	return &Archive{Dir: dir, Prefix: prefix}
+}
+
+// Query returns the ticks in [from, to), oldest first. With tickers given,
+// each tick is narrowed to the events and markets named (market or event
+// tickers), and ticks holding none of them are left out.
+func (a *Archive) Query(from, to time.Time, tickers ...string) ([]TickRecord, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+	q := Query{From: from, To: to}
+	// Only market tickers are in the index; an event ticker can't narrow
+	// the blocks read.
+	for _, t := range tickers {
+		if strings.Count(t, "-") < 2 {
+			q.Tickers = nil
+			break
+		}
+		q.Tickers = append(q.Tickers, t)
+	}
+
+	var out []TickRecord
+	for _, p := range a.files(from, to) {
+		err := ReadRange(p, q, func(rec TickRecord) error {
+			if len(tickers) > 0 {
+				events := rec.narrow(tickers)
+				if len(events) == 0 {
+					return nil
+				}
+				rec.Markets, rec.Events = nil, events
+			}
+			out = append(out, rec)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// File names don't sort size-rotated parts in time order.
+	times := make(map[string]time.Time, len(out))
+	for _, rec := range out {
+		times[rec.Ts], _ = time.Parse(time.RFC3339Nano, rec.Ts)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return times[out[i].Ts].Before(times[out[j].Ts]) })
+	return out, nil
+}
+
+// At returns the last tick at or before ts, narrowed to tickers as in
+// Query, or ErrNoTick if there is none within the archive's lookback.
+func (a *Archive) At(ts time.Time, tickers ...string) (TickRecord, error) {
+	lookback := a.Lookback
+	if lookback <= 0 {
+		lookback = DefaultAtLookback
+	}
+	recs, err := a.Query(ts.Add(-lookback), ts.Add(time.Nanosecond), tickers...)
+	if err != nil {
+		return TickRecord{}, err
+	}
+	if len(recs) == 0 {
+		return TickRecord{}, fmt.Errorf("%s: %w", ts.UTC().Format(time.RFC3339), ErrNoTick)
+	}
+	return recs[len(recs)-1], nil
+}
+
+// files returns the archives whose UTC date falls within [from, to].
+func (a *Archive) files(from, to time.Time) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	last := to.UTC().Truncate(24 * time.Hour)
+	for d := from.UTC().Truncate(24 * time.Hour); !d.After(last); d = d.AddDate(0, 0, 1) {
+		name := a.Prefix + "-" + d.Format("2006-01-02") + "*.jsonl*"
+		for _, pattern := range []string{
+			filepath.Join(a.Dir, name),
+			filepath.Join(a.Dir, d.Format("2006"), d.Format("01"), name),
+		} {
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				if !seen[m] && !strings.HasSuffix(m, ".tmp") {
+					seen[m] = true
+					paths = append(paths, m)
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// narrow returns rec's events cut down to the markets in want, which holds
+// market or event tickers. An event named in want keeps its whole ladder.
+func (rec *TickRecord) narrow(want []string) []EventSnap {
+	var out []EventSnap
+	for _, ev := range rec.Events {
+		if slices.Contains(want, ev.Event) {
+			out = append(out, ev)
+			continue
+		}
+		var markets []MarketSnap
+		for _, m := range ev.Markets {
+			if slices.Contains(want, m.Ticker) {
+				markets = append(markets, m)
+			}
+		}
+		if len(markets) > 0 {
+			ev.Markets = markets
+			out = append(out, ev)
+		}
+	}
+	return out
+}
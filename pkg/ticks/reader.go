@@ -0,0 +1,251 @@
+package ticks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Reader iterates over tick records in a JSONL stream. Older records are
+// upgraded to CurrentSchemaVersion and delta-encoded records are expanded
+// to full ticks; records of other types are skipped unless a handler is
+// registered for them with Handle or HandleOther.
+type Reader struct {
+	scanner  *bufio.Scanner
+	closers  []io.Closer
+	decoder  *DeltaDecoder
+	books    map[string]MarketSnap // previous tick's markets, to restore unchanged books
+	handlers map[string]func(json.RawMessage) error
+	other    func(recordType string, raw json.RawMessage) error
+	line     int
+	records  int
+}
+
+// NewReader reads uncompressed JSONL from r.
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024) // ticks with books can be large
+	return &Reader{
+		scanner:  s,
+		decoder:  NewDeltaDecoder(),
+		handlers: make(map[string]func(json.RawMessage) error),
+	}
+}
+
+// Open opens a .jsonl, .jsonl.gz or .jsonl.zst file, or one of those
+// encrypted (.enc appended) with the ArchiveKey.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFileReader(path, f, 0)
+}
+
+// newFileReader wraps f in the decryptor and decompressor for path's
+// extensions, starting at offset, which must be where a compressed stream
+// starts (in plaintext bytes for encrypted files).
+func newFileReader(path string, f *os.File, offset int64) (*Reader, error) {
+	var rs io.ReadSeeker = f
+	if strings.HasSuffix(path, EncExt) {
+		key, err := ArchiveKey()
+		if err == nil && key == nil {
+			err = ErrNoKey
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		dr, err := NewDecryptReader(f, key)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		rs, path = dr, strings.TrimSuffix(path, EncExt)
+	}
+	if offset > 0 {
+		if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	var src io.Reader = rs
+	closers := []io.Closer{f}
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		src = gz
+		closers = append([]io.Closer{gz}, closers...)
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(src)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		src = zr
+		closers = append([]io.Closer{zr.IOReadCloser()}, closers...)
+	}
+
+	r := NewReader(src)
+	r.closers = closers
+	return r, nil
+}
+
+// Handle registers fn for records of the given type (e.g. "divergence").
+func (r *Reader) Handle(recordType string, fn func(raw json.RawMessage) error) {
+	r.handlers[recordType] = fn
+}
+
+// HandleOther registers fn for records of every type other than ticks,
+// deltas and those given to Handle, including types added after this
+// package was built.
+func (r *Reader) HandleOther(fn func(recordType string, raw json.RawMessage) error) {
+	r.other = fn
+}
+
+// Next returns the next tick, or io.EOF at the end of the stream.
+func (r *Reader) Next() (TickRecord, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		r.records++
+
+		var env struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &env); err != nil {
+			return TickRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+
+		switch env.Type {
+		case "tick":
+			var rec TickRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return TickRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+			}
+			if rec.SchemaVersion < CurrentSchemaVersion {
+				from := rec.SchemaVersion
+				if from == 0 {
+					from = InferVersion(line)
+				}
+				Upgrade(&rec, from)
+			}
+			r.restoreBooks(&rec)
+			return r.decoder.Keyframe(rec), nil
+		case "delta":
+			var d DeltaRecord
+			if err := json.Unmarshal(line, &d); err != nil {
+				return TickRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+			}
+			rec, err := r.decoder.Apply(d)
+			if err != nil {
+				return TickRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+			}
+			rec.SchemaVersion = CurrentSchemaVersion
+			return rec, nil
+		default:
+			fn, ok := r.handlers[env.Type]
+			if !ok && r.other == nil {
+				continue
+			}
+			raw := make(json.RawMessage, len(line))
+			copy(raw, line)
+			var err error
+			if ok {
+				err = fn(raw)
+			} else {
+				err = r.other(env.Type, raw)
+			}
+			if err != nil {
+				return TickRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+			}
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return TickRecord{}, err
+	}
+	return TickRecord{}, io.EOF
+}
+
+// restoreBooks fills in the books of markets marked unchanged from the
+// previous tick and remembers this tick's markets for the next one.
+func (r *Reader) restoreBooks(rec *TickRecord) {
+	cur := make(map[string]MarketSnap, len(r.books))
+	for i := range rec.Events {
+		for j := range rec.Events[i].Markets {
+			m := &rec.Events[i].Markets[j]
+			if m.Unchanged {
+				if prev, ok := r.books[m.Ticker]; ok {
+					m.YesBook, m.NoBook, m.Book = prev.YesBook, prev.NoBook, prev.Book
+				}
+				m.Unchanged = false
+			}
+			cur[m.Ticker] = *m
+		}
+	}
+	r.books = cur
+}
+
+// Records returns how many non-empty lines (of any type) have been read.
+func (r *Reader) Records() int {
+	return r.records
+}
+
+// Close releases the underlying file, if the Reader was created by Open.
+func (r *Reader) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// ReadFile calls fn for every tick in path. Returning an error from fn
+// stops iteration and is returned as-is.
+func ReadFile(path string, fn func(TickRecord) error) error {
+	r, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFiles calls ReadFile for each path in order.
+func ReadFiles(paths []string, fn func(TickRecord) error) error {
+	for _, p := range paths {
+		if err := ReadFile(p, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package ticks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schema version history. Records written before v4 carry no
+// schema_version field; the Reader infers their version from the fields
+// present (see InferVersion) and upgrades them in place.
+//
+//	1  Initial format: brti plus coinbase/kraken/bitstamp/binance prices;
+//	   markets with bid/ask/last/volume/open_interest/strike/secs_left.
+//	2  Binance feed dropped (BRTI is the median of three feeds); markets
+//	   gain status and result.
+//	3  Markets gain yes_book/no_book depth from the Kalshi WS orderbook.
+//	   REST-fallback ticks in v3+ files still have no books.
+//	4  Records carry an explicit schema_version; delta encoding available.
+//	5  Markets with books gain derived book features (depth, imbalance,
+//	   microprice, top-3 sizes); the upgrade computes them for older records.
+//	6  The flat markets list is replaced by events, one per 15-minute
+//	   window, each with its expiry and a strike-ordered ladder. The
+//	   upgrade groups older records by ticker prefix, taking the expiry
+//	   from ts + secs_left.
+//	7  Markets gain book_stale, set while the collector recovers from a
+//	   gap in the Kalshi WS orderbook sequence.
+//	8  Markets gain anomaly ("crossed" or "stale") when the collector's
+//	   book check distrusts the snapshot.
+//	9  Markets gain ticker_ts/book_ts (receipt of the last WS ticker and
+//	   orderbook messages) and fresh, false when the values were carried
+//	   forward from an earlier tick. REST-fallback markets are always fresh.
+//	10 Ticks gain latency: time from the sampling timer to the assembled
+//	   record, the previous tick's write latency, and each feed's price age.
+//	11 Ticks gain window: open, high, low and running TWAP of BRTI for the
+//	   15-minute window containing the tick.
+//	12 Markets gain unchanged: with --dedupe-books the collector omits the
+//	   book of a market whose book and prices match the previous tick. The
+//	   Reader fills it back in; raw readers must carry it forward.
+//	13 Markets gain strike_type (above, below or between) with
+//	   strike_floor/strike_cap, so range markets keep both bounds rather
+//	   than strike alone. Older records leave them empty.
+//	14 Ticks gain source, "rest" when the markets came from the REST
+//	   fallback. The fallback now fetches on its own cadence, so its
+//	   markets are fresh only on the tick that fetched them.
+//	15 Markets gain settlement_value, the index value Kalshi settled them
+//	   on (expiration_value), once determined.
+//	16 Ticks gain feed_sources, naming the exchange feeds whose price came
+//	   from their REST ticker because their WebSocket was down.
+//	17 Ticks gain exposure (with --exposure): the account's position and
+//	   resting orders in each tracked market it has a stake in.
+const CurrentSchemaVersion = 17
+
+// upgrades[v] converts a record from version v to v+1. Versions 1-3 only
+// added fields, so they decode into the current struct as-is and need no
+// step; register one here whenever a field changes meaning or can be derived.
+var upgrades = map[int]func(*TickRecord){
+	4: upgradeV4,
+	5: upgradeV5,
+}
+
+// upgradeV4 derives book features from the raw levels.
+func upgradeV4(rec *TickRecord) {
+	for i := range rec.Markets {
+		m := &rec.Markets[i]
+		if m.Book == nil {
+			m.Book = ComputeBookFeatures(m.YesBook, m.NoBook)
+		}
+	}
+}
+
+// upgradeV5 groups the flat markets list into events.
+func upgradeV5(rec *TickRecord) {
+	ts, err := time.Parse(time.RFC3339Nano, rec.Ts)
+	if err != nil {
+		return
+	}
+	rec.Events = GroupEvents(rec.Markets, ts, nil)
+	rec.Markets = nil
+}
+
+// Upgrade converts rec (of version from) to CurrentSchemaVersion.
+func Upgrade(rec *TickRecord, from int) {
+	for v := from; v < CurrentSchemaVersion; v++ {
+		if fn, ok := upgrades[v]; ok {
+			fn(rec)
+		}
+	}
+	rec.SchemaVersion = CurrentSchemaVersion
+}
+
+// InferVersion guesses the schema version of an unversioned raw tick line.
+func InferVersion(raw []byte) int {
+	var probe struct {
+		SchemaVersion int              `json:"schema_version"`
+		Binance       *float64         `json:"binance"`
+		Markets       []map[string]any `json:"markets"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return CurrentSchemaVersion
+	}
+	if probe.SchemaVersion > 0 {
+		return probe.SchemaVersion
+	}
+	if probe.Binance != nil {
+		return 1
+	}
+	version := 1
+	for _, m := range probe.Markets {
+		if _, ok := m["yes_book"]; ok {
+			return 3
+		}
+		if _, ok := m["no_book"]; ok {
+			return 3
+		}
+		if _, ok := m["status"]; ok {
+			version = 2
+		}
+	}
+	if len(probe.Markets) == 0 {
+		version = 2 // no markets to tell by; assume post-binance
+	}
+	return version
+}
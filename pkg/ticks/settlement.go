@@ -0,0 +1,152 @@
+package ticks
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Settlement methods: how the index samples in a settlement window are
+// reduced to the settlement value.
+const (
+	SettleMean = "mean" // simple average of the samples
+	SettleTWAP = "twap" // each sample weighted by how long it stood
+	SettleLast = "last" // the last sample at or before close
+)
+
+// SettlementRule is how a series' markets settle from BRTI: a window
+// ending at the market's close and a method for reducing the samples in it.
+type SettlementRule struct {
+	Window time.Duration
+	Method string
+}
+
+// DefaultSettlementRule is the KXBTC15M rule: the simple average of BRTI
+// over the final minute of trading.
+var DefaultSettlementRule = SettlementRule{Window: time.Minute, Method: SettleMean}
+
+// settlementRules holds the rules of the known BTC series, keyed by series
+// ticker. Kalshi's hourly (KXBTCD) and range (KXBTC) contracts also
+// average the final 60 seconds, but they close on different schedules, so
+// which ticks count depends on each market's own close.
+var settlementRules = map[string]SettlementRule{
+	"KXBTC15M": DefaultSettlementRule,
+	"KXBTCD":   {Window: time.Minute, Method: SettleMean},
+	"KXBTC":    {Window: time.Minute, Method: SettleMean},
+}
+
+// SeriesOf returns the series ticker of a market or event ticker:
+// everything before the first '-'.
+func SeriesOf(ticker string) string {
+	if i := strings.IndexByte(ticker, '-'); i > 0 {
+		return ticker[:i]
+	}
+	return ticker
+}
+
+// SettlementRuleFor returns the rule for ticker's series, or
+// DefaultSettlementRule for series without one.
+func SettlementRuleFor(ticker string) SettlementRule {
+	if r, ok := settlementRules[SeriesOf(ticker)]; ok {
+		return r
+	}
+	return DefaultSettlementRule
+}
+
+// SetSettlementRule registers or replaces the rule for a series.
+func SetSettlementRule(series string, r SettlementRule) error {
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("series %s: %w", series, err)
+	}
+	settlementRules[series] = r
+	return nil
+}
+
+func (r SettlementRule) validate() error {
+	switch r.Method {
+	case SettleMean, SettleTWAP, SettleLast:
+	default:
+		return fmt.Errorf("unknown settlement method %q (want mean, twap or last)", r.Method)
+	}
+	if r.Window < 0 {
+		return fmt.Errorf("negative settlement window %s", r.Window)
+	}
+	return nil
+}
+
+// bounds returns the settlement values m pays YES between: floor (0 for
+// none) and cap (+Inf for none). Markets recorded before v13 have no
+// strike type and pay at or above Strike.
+func (m *MarketSnap) bounds() (floor, cap float64) {
+	switch m.StrikeType {
+	case StrikeBelow:
+		cap = m.StrikeCap
+		if cap == 0 {
+			cap = m.Strike
+		}
+		return 0, cap
+	case StrikeBetween:
+		return m.StrikeFloor, m.StrikeCap
+	}
+	floor = m.StrikeFloor
+	if floor == 0 {
+		floor = m.Strike
+	}
+	return floor, math.Inf(1)
+}
+
+// PaysYes reports whether m settles YES on the settlement value v.
+func (m *MarketSnap) PaysYes(v float64) bool {
+	floor, cap := m.bounds()
+	return v >= floor && v <= cap
+}
+
+// PriceSample is one BRTI observation.
+type PriceSample struct {
+	Time  time.Time
+	Price float64
+}
+
+// Settle reduces BRTI samples to the settlement value of a market closing
+// at close. Samples must be in time order; those outside (close-Window,
+// close] are ignored. It returns 0 when no sample falls in the window.
+func (r SettlementRule) Settle(samples []PriceSample, close time.Time) float64 {
+	from := close.Add(-r.Window)
+	var (
+		sum, weight float64
+		n           int
+		last        PriceSample
+	)
+	for _, s := range samples {
+		if !s.Time.After(from) || s.Time.After(close) || s.Price <= 0 {
+			continue
+		}
+		switch {
+		case r.Method == SettleMean:
+			sum += s.Price
+			weight++
+		case r.Method == SettleTWAP && n > 0:
+			dt := s.Time.Sub(last.Time).Seconds()
+			sum += last.Price * dt
+			weight += dt
+		}
+		last = s
+		n++
+	}
+	switch {
+	case n == 0:
+		return 0
+	case r.Method == SettleLast:
+		return last.Price
+	case r.Method == SettleTWAP:
+		// The last sample stands until close.
+		dt := close.Sub(last.Time).Seconds()
+		sum += last.Price * dt
+		weight += dt
+		if weight == 0 {
+			return last.Price
+		}
+	}
+	return sum / weight
+}
@@ -0,0 +1,275 @@
+// Package ticks defines the on-disk record schema written by the data
+// collector and a Reader for iterating over collected archives.
+package ticks
+
+// TickRecord is one per-second snapshot of all prices.
+type TickRecord struct {
+	Type          string       `json:"type"`
+	SchemaVersion int          `json:"schema_version,omitempty"` // see schema.go
+	Ts            string       `json:"ts"`
+	BRTI          float64      `json:"brti"`
+	Coinbase      float64      `json:"coinbase"`
+	Kraken        float64      `json:"kraken"`
+	Bitstamp      float64      `json:"bitstamp"`
+	Binance       float64      `json:"binance,omitempty"` // v1 only
+	Markets       []MarketSnap `json:"markets,omitempty"` // v1-v5; see Events
+	Events        []EventSnap  `json:"events,omitempty"`  // v6+
+	Latency       *TickLatency `json:"latency,omitempty"` // v10+
+	Window        *WindowStats `json:"window,omitempty"`  // v11+
+	Source        string       `json:"source,omitempty"`  // v14+: "rest" when markets came from the REST fallback, "gapfill" when rebuilt from minute bars by cmd/gapfill
+
+	FeedSources map[string]string `json:"feed_sources,omitempty"` // v16+: feeds priced from their REST fallback ("rest") or, in gapfill ticks, minute bars ("ohlc")
+
+	Exposure map[string]MarketExposure `json:"exposure,omitempty"` // v17+: with --exposure, the account's stake in tracked markets, by ticker
+}
+
+// MarketExposure is the account's stake in one market: the position from
+// the Kalshi market_positions WS channel and its resting orders, polled
+// over REST. Only markets with a position or a resting order appear.
+type MarketExposure struct {
+	Position      int `json:"position"`                 // contracts held: positive YES, negative NO
+	Cost          int `json:"cost,omitempty"`           // cents paid for the position
+	RealizedPnL   int `json:"realized_pnl,omitempty"`   // cents
+	FeesPaid      int `json:"fees_paid,omitempty"`      // cents
+	RestingOrders int `json:"resting_orders,omitempty"` // resting orders in the market
+	RestingYes    int `json:"resting_yes,omitempty"`    // contracts left on resting YES-side orders
+	RestingNo     int `json:"resting_no,omitempty"`     // contracts left on resting NO-side orders
+}
+
+// WindowStats summarizes BRTI over the 15-minute window containing the
+// tick, up to and including it. The window starts at Start (inclusive);
+// TWAP weights each sample by how long it stood until the next one.
+type WindowStats struct {
+	Start string  `json:"start"`
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	TWAP  float64 `json:"twap"`
+}
+
+// TickLatency measures how stale a tick's data is. The write of a record
+// can't be timed inside that record, so each tick carries the previous
+// one's write latency.
+type TickLatency struct {
+	SnapshotMs  float64            `json:"snapshot_ms"`             // ticker fire → record assembled
+	PrevWriteMs float64            `json:"prev_write_ms,omitempty"` // ticker fire → write done, previous tick
+	FeedAgeMs   map[string]float64 `json:"feed_age_ms,omitempty"`   // age of each exchange price when read
+}
+
+// Strike types: which side of its strike bounds a market pays YES on.
+const (
+	StrikeAbove   = "above"   // YES at or above StrikeFloor
+	StrikeBelow   = "below"   // YES at or below StrikeCap
+	StrikeBetween = "between" // YES from StrikeFloor to StrikeCap
+)
+
+// MarketSnap is a point-in-time snapshot of a Kalshi market.
+type MarketSnap struct {
+	Ticker    string   `json:"ticker"`
+	YesBid    int      `json:"yes_bid"`
+	YesAsk    int      `json:"yes_ask"`
+	LastPrice int      `json:"last_price"`
+	Volume    int      `json:"volume"`
+	OpenInt   int      `json:"open_interest"`
+	Strike    float64  `json:"strike,omitempty"`
+	SecsLeft  int      `json:"secs_left"`
+	Status    string   `json:"status,omitempty"`
+	Result    string   `json:"result,omitempty"`
+	YesBook   [][2]int `json:"yes_book,omitempty"`
+	NoBook    [][2]int `json:"no_book,omitempty"`
+	BookStale bool     `json:"book_stale,omitempty"` // WS deltas were missed; book awaits a fresh snapshot (v7+)
+	Anomaly   string   `json:"anomaly,omitempty"`    // "crossed" or "stale" when the collector distrusts the book (v8+)
+	TickerTs  string   `json:"ticker_ts,omitempty"`  // receipt of the last WS ticker message (v9+)
+	BookTs    string   `json:"book_ts,omitempty"`    // receipt of the last WS orderbook message (v9+)
+	Fresh     bool     `json:"fresh,omitempty"`      // updated since the previous tick, rather than carried forward (v9+)
+	Unchanged bool     `json:"unchanged,omitempty"`  // book and prices as in the previous tick; books omitted (v12+)
+
+	// Strike bounds (v13+). Strike is kept as the single headline number;
+	// these say which side of it pays, and give both ends of a range.
+	StrikeType  string  `json:"strike_type,omitempty"`  // StrikeAbove, StrikeBelow or StrikeBetween
+	StrikeFloor float64 `json:"strike_floor,omitempty"` // lower bound, for above and between
+	StrikeCap   float64 `json:"strike_cap,omitempty"`   // upper bound, for below and between
+
+	// SettlementValue is the index value Kalshi settled the market on
+	// (its expiration_value), set once the market is determined (v15+).
+	SettlementValue float64 `json:"settlement_value,omitempty"`
+
+	Book *BookFeatures `json:"book,omitempty"` // derived from YesBook/NoBook (v5+)
+}
+
+// DivergenceRecord marks the start or end of a sustained price gap between
+// two exchange feeds.
+type DivergenceRecord struct {
+	Type         string  `json:"type"` // "divergence"
+	Ts           string  `json:"ts"`
+	State        string  `json:"state"` // "start" or "end"
+	FeedA        string  `json:"feed_a"`
+	FeedB        string  `json:"feed_b"`
+	PriceA       float64 `json:"price_a"`
+	PriceB       float64 `json:"price_b"`
+	Diff         float64 `json:"diff"`     // PriceA - PriceB at this record
+	MaxDiff      float64 `json:"max_diff"` // largest |diff| seen during the episode
+	Since        string  `json:"since"`    // when the gap first exceeded the threshold
+	DurationSecs float64 `json:"duration_secs"`
+}
+
+// StatusRecord is a periodic reading of the collector's view of Kalshi.
+// ClockSkewMs is the Kalshi server clock minus the local clock; secs_left
+// is computed from the local clock, so it is off by about that much.
+type StatusRecord struct {
+	Type        string  `json:"type"` // "status"
+	Ts          string  `json:"ts"`
+	ClockSkewMs float64 `json:"clock_skew_ms"`
+	ClockRTTMs  float64 `json:"clock_rtt_ms"` // round trip of the request the skew was read from
+}
+
+// ExchangeStatusRecord is written when Kalshi's exchange or trading state
+// changes: a trading halt, or maintenance, announced or not.
+type ExchangeStatusRecord struct {
+	Type           string `json:"type"` // "exchange_status"
+	Ts             string `json:"ts"`
+	ExchangeActive bool   `json:"exchange_active"`
+	TradingActive  bool   `json:"trading_active"`
+	Maintenance    bool   `json:"maintenance"`               // exchange down or inside an announced window
+	MaintenanceEnd string `json:"maintenance_end,omitempty"` // end of the announced window, if any
+}
+
+// LadderRecord is every market discovery found, from Kalshi REST, without
+// books. The collector writes one periodically when a strike band keeps
+// far strikes out of the ticks, so the full ladder is still on record.
+type LadderRecord struct {
+	Type   string      `json:"type"` // "ladder"
+	Ts     string      `json:"ts"`
+	BRTI   float64     `json:"brti"`
+	Events []EventSnap `json:"events"`
+}
+
+// SeriesRecord marks the point a series found by series discovery starts
+// being collected; its markets appear in ticks from then on.
+type SeriesRecord struct {
+	Type     string `json:"type"` // "series"
+	Ts       string `json:"ts"`
+	Series   string `json:"series"`
+	Title    string `json:"title,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// ShutdownRecord is the last record of a collector that was stopped
+// cleanly. Events holds the markets that expired shortly before, as Kalshi
+// reported them at shutdown, so results determined after the last tick
+// are still on record.
+type ShutdownRecord struct {
+	Type   string      `json:"type"` // "shutdown"
+	Ts     string      `json:"ts"`
+	Reason string      `json:"reason"`
+	Ticks  int64       `json:"ticks"` // written since start
+	Events []EventSnap `json:"events,omitempty"`
+}
+
+// GapRecord marks a stretch with no data. A collector writes one when it
+// starts and finds records from an earlier run in the file it appends to:
+// From is the last of them, Seconds the time between it and Ts.
+type GapRecord struct {
+	Type    string  `json:"type"` // "gap"
+	Ts      string  `json:"ts"`
+	From    string  `json:"from"`
+	Seconds float64 `json:"seconds"`
+	Reason  string  `json:"reason"` // "restart"
+	Clean   bool    `json:"clean"`  // the earlier run ended with a shutdown record
+}
+
+// FeedLatencyRecord reports, per exchange feed, how long its updates took
+// to arrive over the preceding period: receipt time minus the exchange's
+// own timestamp on the update. Feeds whose messages carry no timestamp
+// are left out.
+type FeedLatencyRecord struct {
+	Type  string                  `json:"type"` // "feed_latency"
+	Ts    string                  `json:"ts"`
+	Feeds map[string]LatencyStats `json:"feeds"`
+}
+
+// LatencyStats is a latency distribution in milliseconds. It includes the
+// skew between the exchange's clock and the local one, so values can be
+// negative.
+type LatencyStats struct {
+	Count  int     `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// CandleRecord is one OHLCV bar aggregated from ticks. Symbol is "BRTI" or a
+// market ticker; market bars are built from last_price (cents) and their
+// Volume is contracts traded during the bar.
+type CandleRecord struct {
+	Type     string  `json:"type"` // "candle"
+	Interval string  `json:"interval"`
+	Start    string  `json:"start"` // bar covers [start, start+interval)
+	Symbol   string  `json:"symbol"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   int     `json:"volume"`
+	Ticks    int     `json:"ticks"` // samples that went into the bar
+}
+
+// MarketCandlesRecord is Kalshi's own candlestick history of one market,
+// fetched from REST shortly after the market closes. Unlike the ticks it
+// has no holes where the collector was down, so it is the reference for a
+// market's quotes and trading minute by minute. Prices are in cents.
+type MarketCandlesRecord struct {
+	Type     string         `json:"type"` // "candles"
+	Ts       string         `json:"ts"`
+	Ticker   string         `json:"ticker"`
+	Interval string         `json:"interval"` // "1m"
+	Open     string         `json:"open"`     // market open time
+	Close    string         `json:"close"`    // market close time
+	Candles  []MarketCandle `json:"candles"`
+}
+
+// MarketCandle is one bar of a MarketCandlesRecord, covering the interval
+// up to End.
+type MarketCandle struct {
+	End     string     `json:"end"`
+	YesBid  PriceOHLC  `json:"yes_bid"`
+	YesAsk  PriceOHLC  `json:"yes_ask"`
+	Price   *PriceOHLC `json:"price,omitempty"` // traded price; absent in bars without trades
+	Volume  int        `json:"volume"`
+	OpenInt int        `json:"open_interest"`
+}
+
+// PriceOHLC is a price's open, high, low and close over a bar, in cents.
+type PriceOHLC struct {
+	Open  int `json:"open"`
+	High  int `json:"high"`
+	Low   int `json:"low"`
+	Close int `json:"close"`
+}
+
+// ForecastRecord is a model's probability that each market of one event
+// settles YES, from BRTI, a volatility estimate and the time left to
+// close. See ForecastModel.
+type ForecastRecord struct {
+	Type     string           `json:"type"` // "forecast"
+	Ts       string           `json:"ts"`
+	Event    string           `json:"event"`
+	Close    string           `json:"close"`     // end of trading and of the settlement window
+	SecsLeft float64          `json:"secs_left"` // to Close, not to expiry
+	BRTI     float64          `json:"brti"`
+	Vol      float64          `json:"vol"`             // annualized
+	Drift    float64          `json:"drift,omitempty"` // annualized
+	Method   string           `json:"method"`          // ForecastMonteCarlo or ForecastClosedForm
+	Paths    int              `json:"paths,omitempty"` // Monte Carlo only
+	Markets  []MarketForecast `json:"markets"`
+}
+
+// MarketForecast is one market's modelled probability of settling YES.
+type MarketForecast struct {
+	Ticker  string  `json:"ticker"`
+	Strike  float64 `json:"strike"`
+	ProbYes float64 `json:"prob_yes"`
+	Mid     float64 `json:"mid,omitempty"` // market's (yes_bid+yes_ask)/2 in cents, for comparison
+}
@@ -0,0 +1,33 @@
+package ticks
+
+import (
+	"math"
+	"time"
+)
+
+// secondsPerYear annualizes volatility; BTC trades around the clock.
+const secondsPerYear = 365.25 * 24 * 60 * 60
+
+// RealizedVol is the annualized volatility of the log returns between
+// consecutive samples, taking the mean return as zero. Each return is
+// weighed by the time it spans, so gaps in the data don't inflate it.
+// Samples must be in time order; it returns 0 with fewer than two usable
+// samples.
+func RealizedVol(samples []PriceSample) float64 {
+	var sumSq float64
+	var span time.Duration
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		dt := cur.Time.Sub(prev.Time)
+		if prev.Price <= 0 || cur.Price <= 0 || dt <= 0 {
+			continue
+		}
+		r := math.Log(cur.Price / prev.Price)
+		sumSq += r * r
+		span += dt
+	}
+	if span <= 0 {
+		return 0
+	}
+	return math.Sqrt(sumSq / span.Seconds() * secondsPerYear)
+}
@@ -20,6 +20,10 @@ import tempfile
 from glob import glob
 from pathlib import Path
 
+sys.path.insert(0, str(Path(__file__).resolve().parent.parent))
+
+from btcdata.ticks import iter_markets
+
 DATA_DIR = Path(__file__).resolve().parent.parent / "data"
 PREFIX = "kxbtc15m"
 
@@ -33,7 +37,7 @@ def find_missing(path, compressed):
             d = json.loads(line.strip())
             if d.get("type") != "tick":
                 continue
-            for m in d.get("markets", []):
+            for m in iter_markets(d):
                 t = m["ticker"]
                 r = m.get("result", "")
                 if t not in seen:
@@ -137,7 +141,7 @@ def patch_file(path, compressed, patches):
         for line in f:
             d = json.loads(line.strip())
             if d.get("type") == "tick":
-                for m in d.get("markets", []):
+                for m in iter_markets(d):
                     if m["ticker"] in patches and not m.get("result"):
                         m["result"] = patches[m["ticker"]]
                         if m.get("status") in ("active", ""):